@@ -5,18 +5,20 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"github.com/lestrrat-go/jwx/jwk"
 
+	"grepandit.com/api/internal/auth"
 	"grepandit.com/api/internal/database"
 	"grepandit.com/api/internal/handlers"
 
 	customMiddleware "grepandit.com/api/internal/middleware"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
 	"grepandit.com/api/internal/services"
 )
 
@@ -44,39 +46,71 @@ func main() {
 	defer db.Close()
 	database.Migrate(db)
 
-	autoRefresh := jwk.NewAutoRefresh(context.Background())
-	// Configure the AutoRefresh  to refresh every 15 minutes
 	if os.Getenv("APP_ENV") == "dev" {
 		err = godotenv.Load(".env")
 		if err != nil {
 			log.Fatal("Error loading .env file")
 		}
 	}
-	jwksURL := os.Getenv("AWS_COGNITO_URL")
-	autoRefresh.Configure(jwksURL, jwk.WithMinRefreshInterval(15*time.Minute))
-	set, err := autoRefresh.Fetch(context.Background(), jwksURL)
+	// Identity provider of the tokens, the Cognito user pool unless AUTH_PROVIDER is local
+	authProvider, err := auth.ProviderFromEnv(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to fetch JWK set: %v", err)
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	verifier := authProvider.Verifier()
+	if authProvider.Name() == auth.ProviderCognito && len(verifier.Config.ClientIDs) == 0 {
+		log.Println("AWS_COGNITO_CLIENT_IDS is not set, tokens of any app client of the user pool are accepted")
+	}
+
+	// Load the lemmatizer shared by the services
+	lemmatizer, err := nlp.NewLemmatizer(nlp.DefaultCacheSize)
+	if err != nil {
+		log.Fatalf("Failed to load lemmatizer: %v", err)
 	}
 
 	// Create services
-	verbalQuestionService := services.NewVerbalQuestionService(db)
-	wordService := services.NewWordService(db)
+	verbalQuestionService := services.NewVerbalQuestionService(db, lemmatizer)
+	wordService := services.NewWordService(db, lemmatizer)
+	verbalQuestionService.Words = wordService
 	userService := services.NewUserService(db)
 	userVerbalStatsService := services.NewUserVerbalStatsService(db)
+	calibrationService := services.NewCalibrationService(db)
+	practiceSessionService := services.NewPracticeSessionService(db)
+	mockExamService := services.NewMockExamService(db)
+	quantQuestionService := services.NewQuantQuestionService(db)
+	userQuantStatsService := services.NewUserQuantStatsService(db)
+	writingService := services.NewWritingService(db, lemmatizer)
+	passageService := services.NewPassageService(db, lemmatizer)
+	flashcardService := services.NewFlashcardService(db)
+	vocabQuizService := services.NewVocabQuizService(db, lemmatizer)
+	wordRelationService := services.NewWordRelationService(db, lemmatizer)
+	searchService := services.NewSearchService(db)
+	roleService := services.NewRoleService(db)
 
 	// Create handlers
 	verbalQuestionHandler := handlers.NewVerbalQuestionHandler(verbalQuestionService)
 	wordHandler := handlers.NewWordHandler(wordService)
 	userHandler := handlers.NewUserHandler(userService)
 	userVerbalStatsHandler := handlers.NewUserVerbalStatHandler(userVerbalStatsService)
+	calibrationHandler := handlers.NewCalibrationHandler(calibrationService)
+	practiceSessionHandler := handlers.NewPracticeSessionHandler(practiceSessionService)
+	mockExamHandler := handlers.NewMockExamHandler(mockExamService)
+	quantQuestionHandler := handlers.NewQuantQuestionHandler(quantQuestionService)
+	userQuantStatsHandler := handlers.NewUserQuantStatHandler(userQuantStatsService)
+	writingHandler := handlers.NewWritingHandler(writingService)
+	passageHandler := handlers.NewPassageHandler(passageService)
+	flashcardHandler := handlers.NewFlashcardHandler(flashcardService)
+	vocabQuizHandler := handlers.NewVocabQuizHandler(vocabQuizService)
+	wordRelationHandler := handlers.NewWordRelationHandler(wordRelationService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	roleHandler := handlers.NewRoleHandler(roleService)
 
 	// Start the Echo server
 	e := echo.New()
 	// CORS middleware
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodOptions},
+		AllowMethods:     []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete, http.MethodOptions},
 		AllowHeaders:     []string{"*"},
 		AllowCredentials: true,
 	}))
@@ -95,12 +129,29 @@ func main() {
 		return c.String(http.StatusOK, "Healthy!")
 	})
 
+	// Local identity provider, outside the authGroup so that tokens can be minted
+	if localIssuer, ok := authProvider.(*auth.LocalIssuer); ok {
+		issuerURL, err := url.Parse(localIssuer.Issuer)
+		if err != nil {
+			log.Fatalf("Invalid local issuer: %v", err)
+		}
+		localAuthHandler := handlers.NewLocalAuthHandler(localIssuer)
+		laGroup := e.Group(issuerURL.Path)
+		laGroup.GET(auth.JWKSPath, localAuthHandler.JWKS)
+		laGroup.POST("/token", localAuthHandler.Token)
+		log.Printf("Local identity provider enabled, tokens are minted at %s/token", localIssuer.Issuer)
+	}
+
 	// Now create a group where the JWT middleware will be applied
 	authGroup := e.Group("")
-	authGroup.Use(customMiddleware.JWTAuthMiddleware(set))
+	authGroup.Use(customMiddleware.JWTAuthMiddleware(verifier))
+	// Permissions are checked per route from the roles of the user
+	authorizer := customMiddleware.NewAuthorizer(roleService)
 
 	// Register routes
-	registerRoutes(e, authGroup, verbalQuestionHandler, wordHandler, userHandler, userVerbalStatsHandler)
+	registerRoutes(e, authGroup, authorizer, verbalQuestionHandler, wordHandler, userHandler, userVerbalStatsHandler, calibrationHandler, practiceSessionHandler, mockExamHandler,
+		quantQuestionHandler, userQuantStatsHandler, writingHandler, passageHandler, flashcardHandler, vocabQuizHandler,
+		wordRelationHandler, searchHandler, roleHandler)
 
 	// Start the server
 	port := "5000"
@@ -110,25 +161,52 @@ func main() {
 
 func registerRoutes(e *echo.Echo,
 	authGroup *echo.Group,
+	authorizer *customMiddleware.Authorizer,
 	verbalQuestionHandler *handlers.VerbalQuestionHandler,
 	wordHandler *handlers.WordHandler,
 	userHandler *handlers.UserHandler,
-	userVerbalStatHandler *handlers.UserVerbalStatHandler) {
+	userVerbalStatHandler *handlers.UserVerbalStatHandler,
+	calibrationHandler *handlers.CalibrationHandler,
+	practiceSessionHandler *handlers.PracticeSessionHandler,
+	mockExamHandler *handlers.MockExamHandler,
+	quantQuestionHandler *handlers.QuantQuestionHandler,
+	userQuantStatHandler *handlers.UserQuantStatHandler,
+	writingHandler *handlers.WritingHandler,
+	passageHandler *handlers.PassageHandler,
+	flashcardHandler *handlers.FlashcardHandler,
+	vocabQuizHandler *handlers.VocabQuizHandler,
+	wordRelationHandler *handlers.WordRelationHandler,
+	searchHandler *handlers.SearchHandler,
+	roleHandler *handlers.RoleHandler) {
+
+	review := authorizer.Require(models.PermissionReviewContent)
+	edit := authorizer.Require(models.PermissionEditContent)
 
 	// VerbalQuestion routes
 	vqGroup := authGroup.Group("/vbquestions")
-	vqGroup.POST("", verbalQuestionHandler.Create)
+	vqGroup.POST("", verbalQuestionHandler.Create, edit)
+	vqGroup.POST("/import", verbalQuestionHandler.Import, edit)
+	vqGroup.GET("/export", verbalQuestionHandler.Export, review)
 	vqGroup.GET("/:id", verbalQuestionHandler.Get)
+	vqGroup.PUT("/:id", verbalQuestionHandler.Update, edit)
+	vqGroup.PATCH("/:id", verbalQuestionHandler.Patch, edit)
+	vqGroup.DELETE("/:id", verbalQuestionHandler.Delete, edit)
+	vqGroup.GET("/:id/revisions", verbalQuestionHandler.GetRevisions, review)
+	vqGroup.GET("/:id/revisions/:revision", verbalQuestionHandler.GetRevision, review)
 	vqGroup.GET("/adaptive", verbalQuestionHandler.GetAdaptiveQuestions)
 	vqGroup.GET("/vocab", verbalQuestionHandler.GetQuestionsOnVocab)
 	vqGroup.POST("/random", verbalQuestionHandler.GetRandomQuestions)
 	vqGroup.GET("", verbalQuestionHandler.GetAll)
 
 	// Word routes
-	wGroup := e.Group("/words")
-	wGroup.POST("", wordHandler.Create)
+	wGroup := authGroup.Group("/words")
+	wGroup.POST("", wordHandler.Create, edit)
 	wGroup.PATCH("/marked", wordHandler.MarkWords)
 	wGroup.GET("/marked", wordHandler.GetMarkedWords)
+	wGroup.GET("/featured", wordHandler.GetFeaturedWords)
+	wGroup.GET("/autocomplete", wordHandler.Autocomplete)
+	wGroup.POST("/featured", wordHandler.AddFeaturedWords, authorizer.Require(models.PermissionFeatureWords))
+	wGroup.DELETE("/featured", wordHandler.RemoveFeaturedWords, authorizer.Require(models.PermissionFeatureWords))
 	wGroup.GET("/:id", wordHandler.GetByID)
 	wGroup.GET("/word/:word", wordHandler.GetByWord)
 
@@ -143,10 +221,119 @@ func registerRoutes(e *echo.Echo,
 	uGroup.GET("/marked-words", userHandler.GetMarkedWordsByUserToken)
 	uGroup.GET("/marked-questions", userHandler.GetMarkedVerbalQuestionsByUserToken)
 	uGroup.GET("/problematic-words", userHandler.GetProblematicWordsByUserToken)
+	uGroup.GET("/abilities", userHandler.GetAbilitiesByUserToken)
 
 	// UserVerbalStat routes
 	uvsGroup := authGroup.Group("/verbal-stats")
 	uvsGroup.POST("", userVerbalStatHandler.Create)
 	uvsGroup.GET("", userVerbalStatHandler.GetVerbalStatsByUserToken)
 
+	// Ability replay routes
+	replayAbilities := authorizer.Require(models.PermissionReplayAbilities)
+	arGroup := authGroup.Group("/ability-replays")
+	arGroup.POST("", userVerbalStatHandler.ReplayAllAbilities, replayAbilities)
+	arGroup.POST("/users/:token", userVerbalStatHandler.ReplayAbilities, replayAbilities)
+
+	// Calibration routes
+	calGroup := authGroup.Group("/calibrations")
+	calGroup.POST("", calibrationHandler.Calibrate, edit)
+	calGroup.GET("", calibrationHandler.GetHistory, review)
+	calGroup.POST("/:id/apply", calibrationHandler.Apply, edit)
+
+	// PracticeSession routes
+	psGroup := authGroup.Group("/sessions")
+	psGroup.POST("", practiceSessionHandler.Start)
+	psGroup.GET("", practiceSessionHandler.GetByUserToken)
+	psGroup.GET("/:id", practiceSessionHandler.Get)
+	psGroup.GET("/:id/next", practiceSessionHandler.Next)
+	psGroup.POST("/:id/answers", practiceSessionHandler.Answer)
+	psGroup.POST("/:id/pause", practiceSessionHandler.Pause)
+	psGroup.POST("/:id/resume", practiceSessionHandler.Resume)
+	psGroup.POST("/:id/finish", practiceSessionHandler.Finish)
+	psGroup.GET("/:id/summary", practiceSessionHandler.Summary)
+
+	// MockExam routes
+	meGroup := authGroup.Group("/mock-exams")
+	meGroup.POST("", mockExamHandler.Start)
+	meGroup.GET("", mockExamHandler.GetByUserToken)
+	meGroup.GET("/:id", mockExamHandler.Get)
+	meGroup.POST("/:id/next-section", mockExamHandler.NextSection)
+	meGroup.POST("/:id/finish", mockExamHandler.Finish)
+
+	// QuantQuestion routes
+	qqGroup := authGroup.Group("/qtquestions")
+	qqGroup.POST("", quantQuestionHandler.Create, edit)
+	qqGroup.GET("/:id", quantQuestionHandler.Get)
+	qqGroup.PUT("/:id", quantQuestionHandler.Update, edit)
+	qqGroup.PATCH("/:id", quantQuestionHandler.Patch, edit)
+	qqGroup.DELETE("/:id", quantQuestionHandler.Delete, edit)
+	qqGroup.GET("/adaptive", quantQuestionHandler.GetAdaptiveQuestions)
+	qqGroup.POST("/random", quantQuestionHandler.GetRandomQuestions)
+	qqGroup.GET("", quantQuestionHandler.GetAll)
+
+	// QuantDataSet routes
+	qdsGroup := authGroup.Group("/quant-datasets")
+	qdsGroup.POST("", quantQuestionHandler.CreateDataSet, edit)
+	qdsGroup.GET("/:id", quantQuestionHandler.GetDataSet)
+	qdsGroup.PUT("/:id", quantQuestionHandler.UpdateDataSet, edit)
+	qdsGroup.PATCH("/:id", quantQuestionHandler.PatchDataSet, edit)
+	qdsGroup.DELETE("/:id", quantQuestionHandler.DeleteDataSet, edit)
+
+	// UserQuantStat routes
+	uqsGroup := authGroup.Group("/quant-stats")
+	uqsGroup.POST("", userQuantStatHandler.Create)
+	uqsGroup.GET("", userQuantStatHandler.GetQuantStatsByUserToken)
+
+	// Analytical writing routes
+	wrGroup := authGroup.Group("/writing")
+	wrGroup.POST("/prompts", writingHandler.CreatePrompt, edit)
+	wrGroup.GET("/prompts", writingHandler.GetPrompts)
+	wrGroup.GET("/prompts/random", writingHandler.RandomPrompt)
+	wrGroup.GET("/prompts/:id", writingHandler.GetPrompt)
+	wrGroup.POST("/essays", writingHandler.StartEssay)
+	wrGroup.GET("/essays", writingHandler.GetEssaysByUserToken)
+	wrGroup.GET("/essays/:id", writingHandler.GetEssay)
+	wrGroup.POST("/essays/:id/submit", writingHandler.SubmitEssay)
+
+	// Passage routes
+	pgGroup := authGroup.Group("/passages")
+	pgGroup.POST("", passageHandler.Create, edit)
+	pgGroup.GET("/random", passageHandler.GetRandom)
+	pgGroup.GET("/:id", passageHandler.Get)
+
+	// Flashcard routes
+	fcGroup := authGroup.Group("/flashcards")
+	fcGroup.GET("/queue", flashcardHandler.GetQueue)
+	fcGroup.GET("/stats", flashcardHandler.GetStats)
+	fcGroup.GET("/settings", flashcardHandler.GetSettings)
+	fcGroup.PUT("/settings", flashcardHandler.UpdateSettings)
+	fcGroup.POST("/:word_id/grade", flashcardHandler.Grade)
+
+	// Vocabulary quiz routes
+	vzGroup := authGroup.Group("/vocab-quizzes")
+	vzGroup.POST("", vocabQuizHandler.Generate)
+	vzGroup.GET("", vocabQuizHandler.GetByUserToken)
+	vzGroup.GET("/:id", vocabQuizHandler.Get)
+	vzGroup.POST("/:id/answers", vocabQuizHandler.Answer)
+
+	// Word relation routes
+	wrelGroup := authGroup.Group("/word-relations")
+	wrelGroup.POST("", wordRelationHandler.Create, edit)
+	wrelGroup.GET("", wordRelationHandler.GetByWord)
+	wrelGroup.POST("/import", wordRelationHandler.Import, edit)
+	wrelGroup.GET("/neighborhood", wordRelationHandler.GetNeighborhood)
+	wrelGroup.GET("/drills", wordRelationHandler.GetDrills)
+	wrelGroup.DELETE("/:id", wordRelationHandler.Delete, edit)
+
+	// Search routes
+	authGroup.GET("/search", searchHandler.Search)
+
+	// Role routes
+	manageRoles := authorizer.Require(models.PermissionManageRoles)
+	rlGroup := authGroup.Group("/roles")
+	rlGroup.GET("/me", roleHandler.GetMine)
+	rlGroup.GET("/users/:token", roleHandler.GetByUserToken, manageRoles)
+	rlGroup.POST("/users/:token", roleHandler.Grant, manageRoles)
+	rlGroup.DELETE("/users/:token/:role", roleHandler.Revoke, manageRoles)
+
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/commands"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+//go:embed seed_words.json
+var seedWords []byte
+
+//go:embed seed_questions.jsonl
+var seedQuestions []byte
+
+const usage = "Usage: admin migrate|seed|import|export|relink|abilities|purge [flags]"
+
+/**
+* Runs the maintenance tasks of the API with the same services and
+* environment variables as the server. Reports are printed as JSON.
+* To apply the pending migrations and list them (see cmd/migrate for every command):
+* APP_ENV=dev go run ./cmd/admin migrate
+* APP_ENV=dev go run ./cmd/admin migrate down -steps 2
+* To add sample words and questions to an empty database:
+* APP_ENV=dev go run ./cmd/admin seed
+* To import or export verbal questions (see cmd/questions for every flag):
+* APP_ENV=dev go run ./cmd/admin import -file questions.jsonl [-create-words] [-dry-run]
+* APP_ENV=dev go run ./cmd/admin export -format csv > questions.csv
+* To recompute the vocabulary links and wordmap of every question, or one:
+* APP_ENV=dev go run ./cmd/admin relink [-id 42]
+* To compare the verbal abilities of every user, or one, with a replay of their
+* verbal stats, and to save the replayed abilities:
+* APP_ENV=dev go run ./cmd/admin abilities [-user <token>] [-model irt-eap-batch]
+* APP_ENV=dev go run ./cmd/admin abilities [-user <token>] -apply
+* To delete every record of a user:
+* APP_ENV=dev go run ./cmd/admin purge -user <token> -yes
+**/
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+	tasks := map[string]func(ctx context.Context, db *pgxpool.Pool, args []string){
+		"migrate":   runMigrate,
+		"seed":      runSeed,
+		"import":    commands.Import,
+		"export":    commands.Export,
+		"relink":    runRelink,
+		"abilities": runAbilities,
+		"purge":     runPurge,
+	}
+	run, ok := tasks[os.Args[1]]
+	if !ok {
+		log.Fatalf("Unknown command %q. %s", os.Args[1], usage)
+	}
+	db, err := database.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	// Every other command needs the schema to be up to date
+	if os.Args[1] != "migrate" {
+		database.Migrate(db)
+	}
+	run(ctx, db, os.Args[2:])
+}
+
+// Applies the pending migrations when no migrate command is given
+func runMigrate(ctx context.Context, db *pgxpool.Pool, args []string) {
+	if len(args) == 0 {
+		args = []string{"up"}
+	}
+	commands.Migrate(ctx, db, args)
+}
+
+/**
+* Creates the sample words that do not exist yet, then imports the sample
+* questions unless the database already has questions, so that seeding
+* twice does not duplicate them.
+**/
+func runSeed(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	editor := flags.String("editor", "seed", "editor recorded in the revisions of the sample questions")
+	flags.Parse(args)
+
+	lemmatizer := commands.LoadLemmatizer()
+	var words []models.Word
+	if err := json.Unmarshal(seedWords, &words); err != nil {
+		log.Fatalf("Failed to read the sample words: %v", err)
+	}
+	wordService := services.NewWordService(db, lemmatizer)
+	created := make([]string, 0)
+	for i := range words {
+		_, err := wordService.GetByWord(ctx, words[i].Word)
+		if err == nil {
+			continue
+		}
+		if err != echo.ErrNotFound {
+			log.Fatalf("Failed to look up %q: %v", words[i].Word, err)
+		}
+		if err := wordService.Create(ctx, &words[i]); err != nil {
+			log.Fatalf("Failed to create %q: %v", words[i].Word, err)
+		}
+		created = append(created, words[i].Word)
+	}
+
+	verbalQuestionService := services.NewVerbalQuestionService(db, lemmatizer)
+	var report *models.ImportReport
+	_, err := verbalQuestionService.GetRandom(ctx, 0, 0, 0, nil)
+	switch {
+	case err == echo.ErrNotFound:
+		report, err = verbalQuestionService.Import(ctx, strings.NewReader(string(seedQuestions)),
+			models.ImportOptions{Format: models.FormatJSONL}, models.User{Token: *editor})
+		if err != nil {
+			log.Fatalf("Failed to import the sample questions: %v", err)
+		}
+	case err != nil:
+		log.Fatalf("Failed to look up questions: %v", err)
+	default:
+		fmt.Fprintln(os.Stderr, "The database already has questions, the sample questions are skipped")
+	}
+	commands.WriteJSON(os.Stdout, struct {
+		CreatedWords []string             `json:"created_words"`
+		Questions    *models.ImportReport `json:"questions"`
+	}{created, report})
+	if report != nil && report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runRelink(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("relink", flag.ExitOnError)
+	id := flags.Int("id", 0, "question to relink (default: every question)")
+	flags.Parse(args)
+
+	verbalQuestionService := services.NewVerbalQuestionService(db, commands.LoadLemmatizer())
+	if *id != 0 {
+		changed, err := verbalQuestionService.Relink(ctx, *id)
+		if err != nil {
+			log.Fatalf("Failed to relink question %d: %v", *id, err)
+		}
+		report := &models.MaintenanceReport{Processed: 1, Errors: make([]models.MaintenanceError, 0)}
+		if changed {
+			report.Changed = 1
+		}
+		commands.WriteJSON(os.Stdout, report)
+		return
+	}
+	report, err := verbalQuestionService.RelinkAll(ctx)
+	if err != nil {
+		log.Fatalf("Failed to relink questions: %v", err)
+	}
+	commands.WriteJSON(os.Stdout, report)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runAbilities(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("abilities", flag.ExitOnError)
+	user := flags.String("user", "", "token of the user (default: every user)")
+	model := flags.String("model", string(models.ScoringIRTEAP), "scoring model the verbal stats are replayed under, irt-eap or irt-eap-batch")
+	apply := flags.Bool("apply", false, "save the replayed abilities (default: report the differences only)")
+	flags.Parse(args)
+
+	userVerbalStatsService := services.NewUserVerbalStatsService(db)
+	req := models.AbilityReplayRequest{Model: models.ScoringModel(*model), Apply: *apply}
+	if *user != "" {
+		replay, err := userVerbalStatsService.ReplayAbilities(ctx, *user, req)
+		if err != nil {
+			log.Fatalf("Failed to replay the abilities of %s: %v", *user, err)
+		}
+		commands.WriteJSON(os.Stdout, replay)
+		return
+	}
+	report, err := userVerbalStatsService.ReplayAllAbilities(ctx, req)
+	if err != nil {
+		log.Fatalf("Failed to replay abilities: %v", err)
+	}
+	commands.WriteJSON(os.Stdout, report)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runPurge(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	user := flags.String("user", "", "token of the user whose data is deleted")
+	yes := flags.Bool("yes", false, "confirm the deletion, which cannot be undone")
+	flags.Parse(args)
+	if *user == "" {
+		log.Fatalf("The -user flag is required")
+	}
+	if !*yes {
+		log.Fatalf("Purging deletes every record of %s and cannot be undone. Pass -yes to confirm", strconv.Quote(*user))
+	}
+	userService := services.NewUserService(db)
+	report, err := userService.Purge(ctx, *user)
+	if errors.Is(err, echo.ErrNotFound) {
+		log.Fatalf("No data found for %s", *user)
+	}
+	if err != nil {
+		log.Fatalf("Failed to purge %s: %v", *user, err)
+	}
+	commands.WriteJSON(os.Stdout, report)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+/**
+* Calibrates the verbal questions from the recorded verbal stats and prints
+* the proposals as JSON. Uses the same environment variables as the server.
+* To review proposals without changing questions:
+* APP_ENV=dev go run ./cmd/calibrate -min-responses 50
+* To apply the proposals:
+* APP_ENV=dev go run ./cmd/calibrate -apply
+**/
+func main() {
+	minResponses := flag.Int("min-responses", 30, "minimum number of responses required to calibrate a question")
+	apply := flag.Bool("apply", false, "apply the proposed difficulty and parameters to the questions")
+	editor := flag.String("editor", "calibrate", "editor recorded in the revisions of the calibrated questions")
+	flag.Parse()
+
+	db, err := database.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	database.Migrate(db)
+
+	calibrationService := services.NewCalibrationService(db)
+	calibrations, err := calibrationService.Calibrate(context.Background(), models.CalibrationRequest{
+		MinResponses: *minResponses,
+		Apply:        *apply,
+	}, models.User{Token: *editor})
+	if err != nil {
+		log.Fatalf("Failed to calibrate questions: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(calibrations); err != nil {
+		log.Fatalf("Failed to write calibrations: %v", err)
+	}
+}
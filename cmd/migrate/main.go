@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"grepandit.com/api/internal/commands"
+	"grepandit.com/api/internal/database"
+)
+
+/**
+* Applies and reverts the schema migrations. Uses the same environment
+* variables as the server, which applies the pending migrations when it
+* starts.
+* To apply every pending migration:
+* APP_ENV=dev go run ./cmd/migrate up
+* To revert the last two migrations:
+* APP_ENV=dev go run ./cmd/migrate down -steps 2
+* To migrate up or down to version 20:
+* APP_ENV=dev go run ./cmd/migrate to -version 20
+* To list the migrations and when they were applied:
+* APP_ENV=dev go run ./cmd/migrate status
+**/
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: migrate %s", commands.MigrateUsage)
+	}
+	db, err := database.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	commands.Migrate(context.Background(), db, os.Args[1:])
+}
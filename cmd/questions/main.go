@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"grepandit.com/api/internal/commands"
+	"grepandit.com/api/internal/database"
+)
+
+/**
+* Imports and exports verbal questions in JSON Lines, CSV or as QTI 2.1
+* content packages. Uses the same environment variables as the server. The
+* report of an import is printed as JSON.
+* To check a file without importing it:
+* APP_ENV=dev go run ./cmd/questions import -file questions.csv -dry-run
+* To import in batches of 100, creating missing vocabulary words:
+* APP_ENV=dev go run ./cmd/questions import -file questions.jsonl -batch-size 100 -create-words
+* To resume an interrupted import from the row it reported:
+* APP_ENV=dev go run ./cmd/questions import -file questions.jsonl -batch-size 100 -start-row 201
+* To export the questions:
+* APP_ENV=dev go run ./cmd/questions export -format csv > questions.csv
+* APP_ENV=dev go run ./cmd/questions export -format qti > questions.zip
+**/
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatalf("Usage: questions import|export [flags]")
+	}
+	var run func(ctx context.Context, db *pgxpool.Pool, args []string)
+	switch os.Args[1] {
+	case "import":
+		run = commands.Import
+	case "export":
+		run = commands.Export
+	default:
+		log.Fatalf("Unknown command %q. Use import or export", os.Args[1])
+	}
+	db, err := database.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	database.Migrate(db)
+	run(context.Background(), db, os.Args[2:])
+}
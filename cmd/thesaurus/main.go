@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/nlp"
+	"grepandit.com/api/internal/services"
+)
+
+/**
+* Imports the relations between words of a local thesaurus file, one
+* "word,related_word,type" line per relation. Uses the same environment
+* variables as the server and prints the report of the import as JSON.
+* To check a file without importing it:
+* APP_ENV=dev go run ./cmd/thesaurus -file thesaurus.csv -dry-run
+* To import it:
+* APP_ENV=dev go run ./cmd/thesaurus -file thesaurus.csv
+**/
+func main() {
+	file := flag.String("file", "", "CSV thesaurus file to import")
+	dryRun := flag.Bool("dry-run", false, "validate the file without importing it")
+	flag.Parse()
+	if *file == "" {
+		log.Fatalf("The -file flag is required")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	db, err := database.ConnectDB()
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	database.Migrate(db)
+
+	lemmatizer, err := nlp.NewLemmatizer(nlp.DefaultCacheSize)
+	if err != nil {
+		log.Fatalf("Failed to load lemmatizer: %v", err)
+	}
+	wordRelationService := services.NewWordRelationService(db, lemmatizer)
+	report, err := wordRelationService.Import(context.Background(), f, *dryRun)
+	if err != nil {
+		log.Fatalf("Failed to import relations: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
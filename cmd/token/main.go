@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"grepandit.com/api/internal/auth"
+)
+
+/**
+* Mints a token for a test user with the local identity provider and prints
+* it as JSON. Uses the same AUTH_LOCAL_ISSUER and AUTH_LOCAL_KEY_FILE as the
+* server, which accepts the token when AUTH_PROVIDER is local.
+* To mint an id token for an editor:
+* APP_ENV=dev go run ./cmd/token -sub user-1 -email ada@example.com -groups editor
+* To mint an access token valid for 15 minutes:
+* APP_ENV=dev go run ./cmd/token -sub user-1 -use access -expires-in 15m
+**/
+func main() {
+	sub := flag.String("sub", "", "user token (sub claim) of the user")
+	email := flag.String("email", "", "email of the user, only set on id tokens")
+	username := flag.String("username", "", "username of the user, the sub by default")
+	groups := flag.String("groups", "", "comma separated Cognito groups of the user, such as editor,admin")
+	use := flag.String("use", auth.TokenUseID, "token use, id or access")
+	expiresIn := flag.String("expires-in", "", "lifetime of the token, such as 15m (1h by default)")
+	flag.Parse()
+
+	if os.Getenv("APP_ENV") == "dev" {
+		// The variables may also be set in the environment
+		godotenv.Load(".env")
+	}
+	issuer, err := auth.LocalIssuerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create the local issuer: %v", err)
+	}
+	req := auth.TokenRequest{
+		Subject:   *sub,
+		Email:     *email,
+		Username:  *username,
+		TokenUse:  *use,
+		ExpiresIn: *expiresIn,
+	}
+	for _, group := range strings.Split(*groups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			req.Groups = append(req.Groups, group)
+		}
+	}
+	token, err := issuer.Mint(req)
+	if err != nil {
+		log.Fatalf("Failed to mint token: %v", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(token); err != nil {
+		log.Fatalf("Failed to write token: %v", err)
+	}
+}
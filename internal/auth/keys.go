@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// Provides the public keys that sign the tokens
+type KeySource interface {
+	Keys(ctx context.Context) (jwk.Set, error)
+}
+
+// Key set that never changes, used for tests and local issuers
+type StaticKeys struct {
+	Set jwk.Set
+}
+
+func (k StaticKeys) Keys(ctx context.Context) (jwk.Set, error) {
+	return k.Set, nil
+}
+
+/**
+* Key set served by a JWKS endpoint, such as the one of a Cognito user pool.
+* The set is refreshed in the background so that rotated keys are picked up
+* without a restart.
+**/
+type RemoteKeys struct {
+	URL         string
+	AutoRefresh *jwk.AutoRefresh
+}
+
+// Fetches the key set once so that an unreachable endpoint fails at startup
+func NewRemoteKeys(ctx context.Context, url string, interval time.Duration) (*RemoteKeys, error) {
+	autoRefresh := jwk.NewAutoRefresh(ctx)
+	autoRefresh.Configure(url, jwk.WithMinRefreshInterval(interval))
+	if _, err := autoRefresh.Fetch(ctx, url); err != nil {
+		return nil, err
+	}
+	return &RemoteKeys{URL: url, AutoRefresh: autoRefresh}, nil
+}
+
+func (k *RemoteKeys) Keys(ctx context.Context) (jwk.Set, error) {
+	return k.AutoRefresh.Fetch(ctx, k.URL)
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var ErrInvalidTokenRequest = errors.New("invalid token request")
+
+const (
+	LocalClientID   = "local"
+	LocalKeyID      = "local-1"
+	DefaultLocalTTL = time.Hour
+	// Path of the JWKS relative to the issuer, as served by Cognito
+	JWKSPath = "/.well-known/jwks.json"
+)
+
+/**
+* Identity provider for development and tests. It signs Cognito shaped
+* tokens with its own RSA key and publishes the public key as a JWKS, so
+* the API runs without access to a user pool. The key is kept in a file so
+* that tokens minted from the command line are accepted by the server.
+**/
+type LocalIssuer struct {
+	Issuer   string
+	ClientID string
+	TTL      time.Duration
+	// Current time, replaced by tests
+	Now func() time.Time
+
+	key      *rsa.PrivateKey
+	keys     jwk.Set
+	verifier *Verifier
+}
+
+func NewLocalIssuer(issuer string, key *rsa.PrivateKey) (*LocalIssuer, error) {
+	public, err := jwk.New(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := public.Set(jwk.KeyIDKey, LocalKeyID); err != nil {
+		return nil, err
+	}
+	if err := public.Set(jwk.AlgorithmKey, jwa.RS256); err != nil {
+		return nil, err
+	}
+	if err := public.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, err
+	}
+	keys := jwk.NewSet()
+	keys.Add(public)
+	l := &LocalIssuer{
+		Issuer:   issuer,
+		ClientID: LocalClientID,
+		TTL:      DefaultLocalTTL,
+		Now:      time.Now,
+		key:      key,
+		keys:     keys,
+	}
+	l.verifier, err = NewVerifier(Config{
+		Issuer:    issuer,
+		ClientIDs: []string{l.ClientID},
+		Leeway:    DefaultLeeway,
+	}, StaticKeys{Set: keys})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LocalIssuer) Name() string {
+	return ProviderLocal
+}
+
+func (l *LocalIssuer) Verifier() *Verifier {
+	return l.verifier
+}
+
+// Public keys of the issuer, served as its JWKS
+func (l *LocalIssuer) Keys() jwk.Set {
+	return l.keys
+}
+
+// User a token is minted for
+type TokenRequest struct {
+	Subject  string   `json:"sub"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+	// id (the default) or access
+	TokenUse string `json:"token_use"`
+	// Lifetime of the token, such as 15m. Defaults to the TTL of the issuer.
+	ExpiresIn string `json:"expires_in"`
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	TokenUse  string    `json:"token_use"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+/**
+* Signs a token for a test user with the claims Cognito puts in its tokens:
+* id tokens name the client in aud and carry the email, access tokens name
+* it in client_id.
+**/
+func (l *LocalIssuer) Mint(req TokenRequest) (*TokenResponse, error) {
+	if req.Subject == "" {
+		return nil, fmt.Errorf("%w: sub is required", ErrInvalidTokenRequest)
+	}
+	if req.TokenUse == "" {
+		req.TokenUse = TokenUseID
+	}
+	if req.TokenUse != TokenUseID && req.TokenUse != TokenUseAccess {
+		return nil, fmt.Errorf("%w: token_use %q is not access or id", ErrInvalidTokenRequest, req.TokenUse)
+	}
+	ttl := l.TTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: expires_in %q is not a duration", ErrInvalidTokenRequest, req.ExpiresIn)
+		}
+		ttl = d
+	}
+	if req.Username == "" {
+		req.Username = req.Subject
+	}
+	now := l.Now().Truncate(time.Second)
+	expiresAt := now.Add(ttl)
+	claims := jwt.MapClaims{
+		"sub":       req.Subject,
+		"iss":       l.Issuer,
+		"token_use": req.TokenUse,
+		"auth_time": now.Unix(),
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+	}
+	if req.TokenUse == TokenUseID {
+		claims["aud"] = l.ClientID
+		claims["cognito:username"] = req.Username
+		if req.Email != "" {
+			claims["email"] = req.Email
+			claims["email_verified"] = true
+		}
+	} else {
+		claims["client_id"] = l.ClientID
+		claims["username"] = req.Username
+	}
+	if len(req.Groups) > 0 {
+		claims["cognito:groups"] = req.Groups
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = LocalKeyID
+	signed, err := token.SignedString(l.key)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{Token: signed, TokenUse: req.TokenUse, ExpiresAt: expiresAt.UTC()}, nil
+}
+
+/**
+* Reads the RSA key of the local issuer from a PEM file, generating and
+* saving a new key when the file does not exist yet.
+**/
+func LoadOrCreateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parseKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func parseKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block in the key file", ErrConfig)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: the key file does not hold an RSA key", ErrConfig)
+	}
+	return key, nil
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func localIssuer(t *testing.T) *LocalIssuer {
+	t.Helper()
+	key, _ := testKeys(t)
+	l, err := NewLocalIssuer(DefaultLocalIssuer, key)
+	if err != nil {
+		t.Fatalf("NewLocalIssuer() error = %v", err)
+	}
+	l.Now = func() time.Time { return now }
+	l.Verifier().Now = func() time.Time { return now }
+	return l
+}
+
+func TestMint(t *testing.T) {
+	l := localIssuer(t)
+	tests := []struct {
+		name string
+		req  TokenRequest
+		want Claims
+	}{
+		{"id token", TokenRequest{Subject: "user-1", Email: "ada@example.com", Groups: []string{"editor"}}, Claims{
+			Subject:   "user-1",
+			Email:     "ada@example.com",
+			Username:  "user-1",
+			TokenUse:  TokenUseID,
+			Audience:  []string{LocalClientID},
+			Issuer:    DefaultLocalIssuer,
+			Groups:    []string{"editor"},
+			IssuedAt:  now,
+			ExpiresAt: now.Add(DefaultLocalTTL),
+		}},
+		{"access token", TokenRequest{Subject: "user-1", Email: "ada@example.com", Username: "ada", TokenUse: TokenUseAccess, ExpiresIn: "15m"}, Claims{
+			Subject:   "user-1",
+			Username:  "ada",
+			TokenUse:  TokenUseAccess,
+			ClientID:  LocalClientID,
+			Issuer:    DefaultLocalIssuer,
+			IssuedAt:  now,
+			ExpiresAt: now.Add(15 * time.Minute),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := l.Mint(tt.req)
+			if err != nil {
+				t.Fatalf("Mint() error = %v", err)
+			}
+			if !token.ExpiresAt.Equal(tt.want.ExpiresAt) {
+				t.Errorf("expires at = %v, want %v", token.ExpiresAt, tt.want.ExpiresAt)
+			}
+			claims, err := l.Verifier().Verify(context.Background(), token.Token)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			got, _ := json.Marshal(claims)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("claims = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestMintInvalidRequest(t *testing.T) {
+	l := localIssuer(t)
+	tests := []struct {
+		name string
+		req  TokenRequest
+	}{
+		{"no subject", TokenRequest{}},
+		{"unknown token use", TokenRequest{Subject: "user-1", TokenUse: "refresh"}},
+		{"invalid lifetime", TokenRequest{Subject: "user-1", ExpiresIn: "forever"}},
+		{"negative lifetime", TokenRequest{Subject: "user-1", ExpiresIn: "-1h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := l.Mint(tt.req); !errors.Is(err, ErrInvalidTokenRequest) {
+				t.Errorf("Mint() error = %v, want %v", err, ErrInvalidTokenRequest)
+			}
+		})
+	}
+}
+
+// The API verifies local tokens through the published JWKS like Cognito tokens
+func TestLocalJWKS(t *testing.T) {
+	l := localIssuer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(l.Keys())
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys, err := NewRemoteKeys(ctx, server.URL+JWKSPath, KeyRefreshInterval)
+	if err != nil {
+		t.Fatalf("NewRemoteKeys() error = %v", err)
+	}
+	v, err := NewVerifier(Config{Issuer: l.Issuer, ClientIDs: []string{LocalClientID}}, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Now = func() time.Time { return now }
+	token, err := l.Mint(TokenRequest{Subject: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, token.Token); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "local.pem")
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() error = %v", err)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() error = %v", err)
+	}
+	if !created.Equal(loaded) {
+		t.Error("the key read from the file differs from the created key")
+	}
+}
+
+func TestLocalIssuerFromEnv(t *testing.T) {
+	t.Setenv("AUTH_LOCAL_KEY_FILE", filepath.Join(t.TempDir(), "local.pem"))
+	t.Setenv("AUTH_LOCAL_ISSUER", "")
+
+	t.Setenv("APP_ENV", "prod")
+	if _, err := LocalIssuerFromEnv(); !errors.Is(err, ErrConfig) {
+		t.Errorf("LocalIssuerFromEnv() error = %v in prod, want %v", err, ErrConfig)
+	}
+	t.Setenv("APP_ENV", "test")
+	l, err := LocalIssuerFromEnv()
+	if err != nil {
+		t.Fatalf("LocalIssuerFromEnv() error = %v", err)
+	}
+	if l.Issuer != DefaultLocalIssuer || l.Name() != ProviderLocal {
+		t.Errorf("issuer = %q %q", l.Issuer, l.Name())
+	}
+}
+
+func TestProviderFromEnv(t *testing.T) {
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("AUTH_LOCAL_KEY_FILE", filepath.Join(t.TempDir(), "local.pem"))
+	t.Setenv("AUTH_PROVIDER", ProviderLocal)
+	p, err := ProviderFromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("ProviderFromEnv() error = %v", err)
+	}
+	if _, ok := p.(*LocalIssuer); !ok {
+		t.Errorf("provider = %T, want *LocalIssuer", p)
+	}
+
+	t.Setenv("AUTH_PROVIDER", "okta")
+	if _, err := ProviderFromEnv(context.Background()); !errors.Is(err, ErrConfig) {
+		t.Errorf("ProviderFromEnv() error = %v, want %v", err, ErrConfig)
+	}
+	t.Setenv("AUTH_PROVIDER", ProviderCognito)
+	t.Setenv("AWS_COGNITO_URL", "")
+	if _, err := ProviderFromEnv(context.Background()); !errors.Is(err, ErrConfig) {
+		t.Errorf("ProviderFromEnv() error = %v without AWS_COGNITO_URL, want %v", err, ErrConfig)
+	}
+}
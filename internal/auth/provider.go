@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Names of the providers, selected with AUTH_PROVIDER
+const (
+	ProviderCognito = "cognito"
+	ProviderLocal   = "local"
+)
+
+const (
+	DefaultLocalIssuer  = "http://localhost:5000/auth/local"
+	DefaultLocalKeyFile = "local-auth-key.pem"
+	// Interval of the refresh of the keys of the user pool
+	KeyRefreshInterval = 15 * time.Minute
+)
+
+// Identity provider whose tokens the API accepts
+type Provider interface {
+	Name() string
+	Verifier() *Verifier
+}
+
+// The Cognito user pool of AWS_COGNITO_URL
+type CognitoProvider struct {
+	verifier *Verifier
+}
+
+// Fetches the keys of the user pool and configures the checks from the environment
+func NewCognitoProvider(ctx context.Context) (*CognitoProvider, error) {
+	url := os.Getenv("AWS_COGNITO_URL")
+	if url == "" {
+		return nil, fmt.Errorf("%w: AWS_COGNITO_URL is not set", ErrConfig)
+	}
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := NewRemoteKeys(ctx, url, KeyRefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWK set: %w", err)
+	}
+	verifier, err := NewVerifier(config, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &CognitoProvider{verifier: verifier}, nil
+}
+
+func (p *CognitoProvider) Name() string {
+	return ProviderCognito
+}
+
+func (p *CognitoProvider) Verifier() *Verifier {
+	return p.verifier
+}
+
+/**
+* Creates the provider named by AUTH_PROVIDER, Cognito by default. The local
+* issuer mints tokens for anyone who asks, so it is refused unless APP_ENV
+* is dev or test.
+**/
+func ProviderFromEnv(ctx context.Context) (Provider, error) {
+	switch name := os.Getenv("AUTH_PROVIDER"); name {
+	case "", ProviderCognito:
+		return NewCognitoProvider(ctx)
+	case ProviderLocal:
+		return LocalIssuerFromEnv()
+	default:
+		return nil, fmt.Errorf("%w: unknown AUTH_PROVIDER %q", ErrConfig, name)
+	}
+}
+
+/**
+* Creates the local issuer from AUTH_LOCAL_ISSUER and AUTH_LOCAL_KEY_FILE.
+* The server and the token command share the key file, so tokens minted by
+* one are accepted by the other.
+**/
+func LocalIssuerFromEnv() (*LocalIssuer, error) {
+	if env := os.Getenv("APP_ENV"); env != "dev" && env != "test" {
+		return nil, fmt.Errorf("%w: the local provider is only available when APP_ENV is dev or test", ErrConfig)
+	}
+	issuer := os.Getenv("AUTH_LOCAL_ISSUER")
+	if issuer == "" {
+		issuer = DefaultLocalIssuer
+	}
+	keyFile := os.Getenv("AUTH_LOCAL_KEY_FILE")
+	if keyFile == "" {
+		keyFile = DefaultLocalKeyFile
+	}
+	key, err := LoadOrCreateKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalIssuer(issuer, key)
+}
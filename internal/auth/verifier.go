@@ -0,0 +1,330 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrMalformedToken = errors.New("malformed token")
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrAlgorithm      = errors.New("signing algorithm not allowed")
+	ErrSignature      = errors.New("invalid signature")
+	ErrExpired        = errors.New("token is expired")
+	ErrNotYetValid    = errors.New("token is not valid yet")
+	ErrIssuer         = errors.New("unexpected issuer")
+	ErrAudience       = errors.New("unexpected audience")
+	ErrTokenUse       = errors.New("unexpected token use")
+	ErrMissingClaim   = errors.New("missing claim")
+	ErrConfig         = errors.New("invalid verifier configuration")
+)
+
+// Token uses of Cognito
+const (
+	TokenUseAccess = "access"
+	TokenUseID     = "id"
+)
+
+const (
+	DefaultAlgorithm = "RS256"
+	DefaultLeeway    = 30 * time.Second
+)
+
+/**
+* What a token has to satisfy to be accepted. Issuer is the URL of the user
+* pool. ClientIDs are the app clients whose tokens are accepted, matched
+* against aud for id tokens and client_id for access tokens; when empty
+* the client is not checked. TokenUses defaults to both access and id
+* tokens and Algorithms to RS256. Leeway is the clock skew tolerated on the
+* times of the token.
+**/
+type Config struct {
+	Issuer     string
+	ClientIDs  []string
+	TokenUses  []string
+	Algorithms []string
+	Leeway     time.Duration
+}
+
+/**
+* Reads the configuration from the environment. AWS_COGNITO_ISSUER defaults
+* to AWS_COGNITO_URL without the JWKS path, which is where Cognito serves
+* the keys of a pool. AWS_COGNITO_CLIENT_IDS, AUTH_TOKEN_USE and
+* AUTH_ALGORITHMS are comma separated lists and AUTH_LEEWAY a duration such
+* as 30s.
+**/
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Issuer:     os.Getenv("AWS_COGNITO_ISSUER"),
+		ClientIDs:  splitList(os.Getenv("AWS_COGNITO_CLIENT_IDS")),
+		TokenUses:  splitList(os.Getenv("AUTH_TOKEN_USE")),
+		Algorithms: splitList(os.Getenv("AUTH_ALGORITHMS")),
+		Leeway:     DefaultLeeway,
+	}
+	if config.Issuer == "" {
+		config.Issuer = strings.TrimSuffix(os.Getenv("AWS_COGNITO_URL"), "/.well-known/jwks.json")
+	}
+	if leeway := os.Getenv("AUTH_LEEWAY"); leeway != "" {
+		d, err := time.ParseDuration(leeway)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("%w: AUTH_LEEWAY %q is not a duration", ErrConfig, leeway)
+		}
+		config.Leeway = d
+	}
+	return config, nil
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Claims of a verified token
+type Claims struct {
+	Subject   string
+	Email     string
+	Username  string
+	TokenUse  string
+	ClientID  string
+	Audience  []string
+	Issuer    string
+	Groups    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	NotBefore time.Time
+}
+
+// Verifies the signature and the claims of the tokens issued by a user pool
+type Verifier struct {
+	Config Config
+	Keys   KeySource
+	// Current time, replaced by tests
+	Now func() time.Time
+}
+
+func NewVerifier(config Config, keys KeySource) (*Verifier, error) {
+	if config.Issuer == "" {
+		return nil, fmt.Errorf("%w: no issuer", ErrConfig)
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("%w: no key source", ErrConfig)
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = []string{DefaultAlgorithm}
+	}
+	for _, alg := range config.Algorithms {
+		// Symmetric algorithms would accept tokens signed with the public key
+		if strings.HasPrefix(alg, "HS") || jwt.GetSigningMethod(alg) == nil {
+			return nil, fmt.Errorf("%w: algorithm %q is not supported", ErrConfig, alg)
+		}
+	}
+	if len(config.TokenUses) == 0 {
+		config.TokenUses = []string{TokenUseAccess, TokenUseID}
+	}
+	for _, use := range config.TokenUses {
+		if use != TokenUseAccess && use != TokenUseID {
+			return nil, fmt.Errorf("%w: token use %q is not access or id", ErrConfig, use)
+		}
+	}
+	return &Verifier{Config: config, Keys: keys, Now: time.Now}, nil
+}
+
+/**
+* Verifies a token and returns its claims. The errors wrap one of the
+* sentinel errors of the package, so callers can tell an expired token
+* from an invalid one.
+**/
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	var raw tokenClaims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(tokenString, &raw, func(token *jwt.Token) (interface{}, error) {
+		return v.key(ctx, token)
+	})
+	if err != nil {
+		return nil, parseError(err)
+	}
+	claims := raw.claims()
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Public key of the token after checking its algorithm
+func (v *Verifier) key(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+	if !contains(v.Config.Algorithms, alg) {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+	}
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("%w: no key ID", ErrMalformedToken)
+	}
+	set, err := v.Keys.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
+	}
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	// A key published for one algorithm cannot be used with another
+	if keyAlg := key.Algorithm(); keyAlg != "" && keyAlg != alg {
+		return nil, fmt.Errorf("%w: key %s is for %s", ErrAlgorithm, kid, keyAlg)
+	}
+	var pubkey interface{}
+	if err := key.Raw(&pubkey); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
+	}
+	return pubkey, nil
+}
+
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.Now()
+	leeway := v.Config.Leeway
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: sub", ErrMissingClaim)
+	}
+	if claims.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: exp", ErrMissingClaim)
+	}
+	if now.After(claims.ExpiresAt.Add(leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrExpired, claims.ExpiresAt.Format(time.RFC3339))
+	}
+	if !claims.NotBefore.IsZero() && now.Add(leeway).Before(claims.NotBefore) {
+		return fmt.Errorf("%w: not before %s", ErrNotYetValid, claims.NotBefore.Format(time.RFC3339))
+	}
+	if !claims.IssuedAt.IsZero() && now.Add(leeway).Before(claims.IssuedAt) {
+		return fmt.Errorf("%w: issued at %s", ErrNotYetValid, claims.IssuedAt.Format(time.RFC3339))
+	}
+	if claims.Issuer != v.Config.Issuer {
+		return fmt.Errorf("%w: %q", ErrIssuer, claims.Issuer)
+	}
+	if !contains(v.Config.TokenUses, claims.TokenUse) {
+		return fmt.Errorf("%w: %q", ErrTokenUse, claims.TokenUse)
+	}
+	if len(v.Config.ClientIDs) == 0 {
+		return nil
+	}
+	// Id tokens name the client in aud and access tokens in client_id
+	clients := claims.Audience
+	if claims.TokenUse == TokenUseAccess {
+		clients = []string{claims.ClientID}
+	}
+	for _, client := range clients {
+		if contains(v.Config.ClientIDs, client) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrAudience, strings.Join(clients, ","))
+}
+
+// Maps the errors of the parser to the sentinel errors of the package
+func parseError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	for _, sentinel := range []error{ErrMalformedToken, ErrUnknownKey, ErrAlgorithm} {
+		if errors.Is(ve.Inner, sentinel) {
+			return ve.Inner
+		}
+	}
+	if ve.Errors&jwt.ValidationErrorSignatureInvalid != 0 {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+}
+
+/**
+* Claims as they are encoded in the token. Decoding fails when a claim has
+* the wrong type, such as a numeric sub, instead of panicking later.
+**/
+type tokenClaims struct {
+	Subject     string   `json:"sub"`
+	Email       string   `json:"email"`
+	Username    string   `json:"username"`
+	CognitoUser string   `json:"cognito:username"`
+	TokenUse    string   `json:"token_use"`
+	ClientID    string   `json:"client_id"`
+	Audience    audience `json:"aud"`
+	Issuer      string   `json:"iss"`
+	Groups      []string `json:"cognito:groups"`
+	IssuedAt    *float64 `json:"iat"`
+	ExpiresAt   *float64 `json:"exp"`
+	NotBefore   *float64 `json:"nbf"`
+}
+
+// The times are validated by the verifier with the configured leeway
+func (c *tokenClaims) Valid() error {
+	return nil
+}
+
+func (c *tokenClaims) claims() *Claims {
+	claims := &Claims{
+		Subject:   c.Subject,
+		Email:     c.Email,
+		Username:  c.Username,
+		TokenUse:  c.TokenUse,
+		ClientID:  c.ClientID,
+		Audience:  c.Audience,
+		Issuer:    c.Issuer,
+		Groups:    c.Groups,
+		IssuedAt:  unixTime(c.IssuedAt),
+		ExpiresAt: unixTime(c.ExpiresAt),
+		NotBefore: unixTime(c.NotBefore),
+	}
+	// Id tokens name the user in cognito:username and access tokens in username
+	if claims.Username == "" {
+		claims.Username = c.CognitoUser
+	}
+	return claims
+}
+
+// Time of a numeric date claim, zero when the claim is absent
+func unixTime(seconds *float64) time.Time {
+	if seconds == nil {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(*seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// The aud claim is either a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
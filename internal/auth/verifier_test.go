@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	testIssuer = "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_test"
+	testClient = "client-1"
+	testKeyID  = "key-1"
+)
+
+var now = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+var (
+	keysOnce   sync.Once
+	signingKey *rsa.PrivateKey
+	otherKey   *rsa.PrivateKey
+)
+
+// Generates the signing key served by the JWKS server and a key it does not know
+func testKeys(t *testing.T) (*rsa.PrivateKey, *rsa.PrivateKey) {
+	t.Helper()
+	keysOnce.Do(func() {
+		var err error
+		if signingKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+		if otherKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return signingKey, otherKey
+}
+
+// Serves the public signing key the way Cognito serves the keys of a pool
+func jwksServer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	public, err := jwk.New(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public.Set(jwk.KeyIDKey, testKeyID)
+	public.Set(jwk.AlgorithmKey, jwa.RS256)
+	set := jwk.NewSet()
+	set.Add(public)
+	body, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testVerifier(t *testing.T, config Config) *Verifier {
+	t.Helper()
+	key, _ := testKeys(t)
+	server := jwksServer(t, key)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	keys, err := NewRemoteKeys(ctx, server.URL+"/.well-known/jwks.json", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("NewRemoteKeys() error = %v", err)
+	}
+	v, err := NewVerifier(config, keys)
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	v.Now = func() time.Time { return now }
+	return v
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func accessClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":       "user-1",
+		"iss":       testIssuer,
+		"token_use": TokenUseAccess,
+		"client_id": testClient,
+		"username":  "ada",
+		"iat":       now.Add(-time.Minute).Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+	}
+}
+
+func idClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":              "user-1",
+		"iss":              testIssuer,
+		"token_use":        TokenUseID,
+		"aud":              testClient,
+		"email":            "ada@example.com",
+		"cognito:username": "ada",
+		"cognito:groups":   []string{"editor", "beta"},
+		"iat":              now.Add(-time.Minute).Unix(),
+		"exp":              now.Add(time.Hour).Unix(),
+	}
+}
+
+func with(claims jwt.MapClaims, name string, value interface{}) jwt.MapClaims {
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	key, other := testKeys(t)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: mustMarshalPublic(t, &key.PublicKey)})
+	rs256 := func(claims jwt.MapClaims) string { return sign(t, jwt.SigningMethodRS256, testKeyID, key, claims) }
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenUses []string
+		want      error
+	}{
+		{"access token", rs256(accessClaims()), nil, nil},
+		{"id token", rs256(idClaims()), nil, nil},
+		{"id token with audience list", rs256(with(idClaims(), "aud", []string{"other", testClient})), nil, nil},
+		{"expired", rs256(with(accessClaims(), "exp", now.Add(-time.Minute).Unix())), nil, ErrExpired},
+		{"expired within leeway", rs256(with(accessClaims(), "exp", now.Add(-10*time.Second).Unix())), nil, nil},
+		{"not valid yet", rs256(with(accessClaims(), "nbf", now.Add(time.Minute).Unix())), nil, ErrNotYetValid},
+		{"issued in the future", rs256(with(accessClaims(), "iat", now.Add(time.Minute).Unix())), nil, ErrNotYetValid},
+		{"issued within leeway", rs256(with(accessClaims(), "iat", now.Add(10*time.Second).Unix())), nil, nil},
+		{"no expiry", rs256(with(accessClaims(), "exp", nil)), nil, ErrMissingClaim},
+		{"other issuer", rs256(with(accessClaims(), "iss", "https://evil.example.com")), nil, ErrIssuer},
+		{"other client", rs256(with(accessClaims(), "client_id", "client-2")), nil, ErrAudience},
+		{"access token with audience only", rs256(with(with(accessClaims(), "client_id", nil), "aud", testClient)), nil, ErrAudience},
+		{"other audience", rs256(with(idClaims(), "aud", "client-2")), nil, ErrAudience},
+		{"no token use", rs256(with(accessClaims(), "token_use", nil)), nil, ErrTokenUse},
+		{"access token where id tokens are required", rs256(accessClaims()), []string{TokenUseID}, ErrTokenUse},
+		{"id token where access tokens are required", rs256(idClaims()), []string{TokenUseAccess}, ErrTokenUse},
+		{"no subject", rs256(with(accessClaims(), "sub", nil)), nil, ErrMissingClaim},
+		{"numeric subject", rs256(with(accessClaims(), "sub", 42)), nil, ErrMalformedToken},
+		{"groups of the wrong type", rs256(with(idClaims(), "cognito:groups", "editor")), nil, ErrMalformedToken},
+		{"unsigned", sign(t, jwt.SigningMethodNone, testKeyID, jwt.UnsafeAllowNoneSignatureType, accessClaims()), nil, ErrAlgorithm},
+		{"signed with the public key as HMAC secret", sign(t, jwt.SigningMethodHS256, testKeyID, publicPEM, accessClaims()), nil, ErrAlgorithm},
+		{"algorithm not allowed", sign(t, jwt.SigningMethodRS512, testKeyID, key, accessClaims()), nil, ErrAlgorithm},
+		{"unknown key", sign(t, jwt.SigningMethodRS256, "key-2", key, accessClaims()), nil, ErrUnknownKey},
+		{"no key ID", sign(t, jwt.SigningMethodRS256, "", key, accessClaims()), nil, ErrMalformedToken},
+		{"signed with another key", sign(t, jwt.SigningMethodRS256, testKeyID, other, accessClaims()), nil, ErrSignature},
+		{"not a token", "not-a-token", nil, ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testVerifier(t, Config{
+				Issuer:    testIssuer,
+				ClientIDs: []string{testClient},
+				TokenUses: tt.tokenUses,
+				Leeway:    30 * time.Second,
+			})
+			_, err := v.Verify(context.Background(), tt.token)
+			if tt.want == nil && err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	key, _ := testKeys(t)
+	v := testVerifier(t, Config{Issuer: testIssuer, ClientIDs: []string{testClient}})
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   Claims
+	}{
+		{"access token", accessClaims(), Claims{
+			Subject:   "user-1",
+			Username:  "ada",
+			TokenUse:  TokenUseAccess,
+			ClientID:  testClient,
+			Issuer:    testIssuer,
+			IssuedAt:  now.Add(-time.Minute),
+			ExpiresAt: now.Add(time.Hour),
+		}},
+		{"id token", idClaims(), Claims{
+			Subject:   "user-1",
+			Email:     "ada@example.com",
+			Username:  "ada",
+			TokenUse:  TokenUseID,
+			Audience:  []string{testClient},
+			Issuer:    testIssuer,
+			Groups:    []string{"editor", "beta"},
+			IssuedAt:  now.Add(-time.Minute),
+			ExpiresAt: now.Add(time.Hour),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), sign(t, jwt.SigningMethodRS256, testKeyID, key, tt.claims))
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			got, _ := json.Marshal(claims)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("claims = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutClientIDs(t *testing.T) {
+	key, _ := testKeys(t)
+	v := testVerifier(t, Config{Issuer: testIssuer})
+	token := sign(t, jwt.SigningMethodRS256, testKeyID, key, with(accessClaims(), "client_id", "client-2"))
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify() error = %v, want no client check", err)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	keys := StaticKeys{Set: jwk.NewSet()}
+	tests := []struct {
+		name   string
+		config Config
+		keys   KeySource
+		ok     bool
+	}{
+		{"defaults", Config{Issuer: testIssuer}, keys, true},
+		{"no issuer", Config{}, keys, false},
+		{"no keys", Config{Issuer: testIssuer}, nil, false},
+		{"symmetric algorithm", Config{Issuer: testIssuer, Algorithms: []string{"HS256"}}, keys, false},
+		{"unknown algorithm", Config{Issuer: testIssuer, Algorithms: []string{"XX256"}}, keys, false},
+		{"unknown token use", Config{Issuer: testIssuer, TokenUses: []string{"refresh"}}, keys, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(tt.config, tt.keys)
+			if tt.ok != (err == nil) {
+				t.Fatalf("NewVerifier() error = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok && !errors.Is(err, ErrConfig) {
+				t.Errorf("NewVerifier() error = %v, want %v", err, ErrConfig)
+			}
+			if tt.ok && (len(v.Config.Algorithms) != 1 || len(v.Config.TokenUses) != 2) {
+				t.Errorf("defaults = %v %v, want RS256 and both token uses", v.Config.Algorithms, v.Config.TokenUses)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("AWS_COGNITO_URL", testIssuer+"/.well-known/jwks.json")
+	t.Setenv("AWS_COGNITO_ISSUER", "")
+	t.Setenv("AWS_COGNITO_CLIENT_IDS", "client-1, client-2")
+	t.Setenv("AUTH_TOKEN_USE", "id")
+	t.Setenv("AUTH_ALGORITHMS", "")
+	t.Setenv("AUTH_LEEWAY", "1m")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Issuer != testIssuer {
+		t.Errorf("issuer = %q, want %q", config.Issuer, testIssuer)
+	}
+	if len(config.ClientIDs) != 2 || config.ClientIDs[1] != "client-2" {
+		t.Errorf("client IDs = %q", config.ClientIDs)
+	}
+	if len(config.TokenUses) != 1 || config.TokenUses[0] != TokenUseID {
+		t.Errorf("token uses = %q", config.TokenUses)
+	}
+	if config.Leeway != time.Minute {
+		t.Errorf("leeway = %v, want 1m", config.Leeway)
+	}
+
+	t.Setenv("AUTH_LEEWAY", "soon")
+	if _, err := ConfigFromEnv(); !errors.Is(err, ErrConfig) {
+		t.Errorf("ConfigFromEnv() error = %v, want %v", err, ErrConfig)
+	}
+}
+
+func mustMarshalPublic(t *testing.T, key *rsa.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,191 @@
+/**
+* Package autocomplete is an in-memory prefix index of the vocabulary.
+* Words are indexed by their base form and by the inflected variants found
+* in the question bank, and suggestions are ranked by how often the word is
+* used in questions.
+**/
+package autocomplete
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Word to index with the inflected variants that lead to it
+type Entry struct {
+	WordID    int
+	Word      string
+	Frequency int
+	Variants  []string
+}
+
+// Indexed form of a word, either its base form or a variant
+type key struct {
+	text   string
+	wordID int
+}
+
+type Index struct {
+	mu    sync.RWMutex
+	keys  []key
+	words map[int]*Entry
+}
+
+func New() *Index {
+	return &Index{words: make(map[int]*Entry)}
+}
+
+// Replaces the content of the index with the given entries
+func (i *Index) Replace(entries []Entry) {
+	keys := make([]key, 0, len(entries))
+	words := make(map[int]*Entry, len(entries))
+	for n := range entries {
+		entry := entries[n]
+		words[entry.WordID] = &entry
+		for _, text := range entryKeys(&entry) {
+			keys = append(keys, key{text: text, wordID: entry.WordID})
+		}
+	}
+	sort.Slice(keys, func(a, b int) bool { return less(keys[a], keys[b]) })
+	keys = compact(keys)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = keys
+	i.words = words
+}
+
+/**
+* Adds a word to the index, or updates the frequency of a word that is
+* already indexed and adds its new variants.
+**/
+func (i *Index) Add(entry Entry) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if existing, ok := i.words[entry.WordID]; ok {
+		existing.Frequency = entry.Frequency
+		existing.Variants = append(existing.Variants, entry.Variants...)
+	} else {
+		i.words[entry.WordID] = &entry
+	}
+	for _, text := range entryKeys(&entry) {
+		k := key{text: text, wordID: entry.WordID}
+		n := sort.Search(len(i.keys), func(n int) bool { return !less(i.keys[n], k) })
+		if n < len(i.keys) && i.keys[n] == k {
+			continue
+		}
+		i.keys = append(i.keys, key{})
+		copy(i.keys[n+1:], i.keys[n:])
+		i.keys[n] = k
+	}
+}
+
+// Number of indexed words
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.words)
+}
+
+/**
+* Suggests at most limit words that start with prefix or whose variants
+* start with it. Lemmas of the prefix, such as "run" for "ran", match the
+* words that are exactly equal to them. Exact matches come first, then
+* the words used the most in questions, then the shortest.
+**/
+func (i *Index) Complete(prefix string, limit int, lemmas ...string) []models.WordSuggestion {
+	prefix = normalize(prefix)
+	suggestions := make([]models.WordSuggestion, 0)
+	if prefix == "" || limit <= 0 {
+		return suggestions
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	matches := make(map[int]string)
+	exact := make(map[int]bool)
+	match := func(k key) {
+		word := i.words[k.wordID]
+		current, ok := matches[k.wordID]
+		// Prefer matching the base form, then the shortest variant
+		if !ok || (current != word.Word && (k.text == word.Word || len(k.text) < len(current))) {
+			matches[k.wordID] = k.text
+		}
+		if k.text == prefix {
+			exact[k.wordID] = true
+		}
+	}
+	for n := sort.Search(len(i.keys), func(n int) bool { return i.keys[n].text >= prefix }); n < len(i.keys) &&
+		strings.HasPrefix(i.keys[n].text, prefix); n++ {
+		match(i.keys[n])
+	}
+	for _, lemma := range lemmas {
+		lemma = normalize(lemma)
+		for n := sort.Search(len(i.keys), func(n int) bool { return i.keys[n].text >= lemma }); n < len(i.keys) &&
+			i.keys[n].text == lemma; n++ {
+			match(i.keys[n])
+			exact[i.keys[n].wordID] = true
+		}
+	}
+	for wordID, text := range matches {
+		word := i.words[wordID]
+		suggestions = append(suggestions, models.WordSuggestion{
+			WordID:    wordID,
+			Word:      word.Word,
+			Match:     text,
+			Frequency: word.Frequency,
+		})
+	}
+	sort.Slice(suggestions, func(a, b int) bool {
+		sa, sb := suggestions[a], suggestions[b]
+		if exact[sa.WordID] != exact[sb.WordID] {
+			return exact[sa.WordID]
+		}
+		if sa.Frequency != sb.Frequency {
+			return sa.Frequency > sb.Frequency
+		}
+		if len(sa.Word) != len(sb.Word) {
+			return len(sa.Word) < len(sb.Word)
+		}
+		return sa.Word < sb.Word
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// Normalized base form and variants of an entry
+func entryKeys(entry *Entry) []string {
+	entry.Word = normalize(entry.Word)
+	texts := []string{entry.Word}
+	for _, variant := range entry.Variants {
+		if variant = normalize(variant); variant != "" && variant != entry.Word {
+			texts = append(texts, variant)
+		}
+	}
+	return texts
+}
+
+func normalize(text string) string {
+	return strings.ToLower(strings.TrimSpace(text))
+}
+
+func less(a key, b key) bool {
+	if a.text != b.text {
+		return a.text < b.text
+	}
+	return a.wordID < b.wordID
+}
+
+// Removes the duplicates of sorted keys
+func compact(keys []key) []key {
+	unique := keys[:0]
+	for n, k := range keys {
+		if n == 0 || k != keys[n-1] {
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
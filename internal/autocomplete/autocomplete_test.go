@@ -0,0 +1,85 @@
+package autocomplete
+
+import (
+	"reflect"
+	"testing"
+)
+
+func sampleIndex() *Index {
+	i := New()
+	i.Replace([]Entry{
+		{WordID: 1, Word: "abate", Frequency: 3, Variants: []string{"abated", "Abates"}},
+		{WordID: 2, Word: "abacus", Frequency: 1},
+		{WordID: 3, Word: "aberrant", Frequency: 7, Variants: []string{"aberrantly"}},
+		{WordID: 4, Word: "run", Frequency: 2, Variants: []string{"ran", "running"}},
+		{WordID: 5, Word: "abase", Frequency: 3},
+	})
+	return i
+}
+
+func TestComplete(t *testing.T) {
+	i := sampleIndex()
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		lemmas []string
+		want   []string
+	}{
+		{"ranked by frequency then length", "ab", 10, nil, []string{"aberrant", "abase", "abate", "abacus"}},
+		{"limit", "ab", 2, nil, []string{"aberrant", "abase"}},
+		{"variant", "abated", 10, nil, []string{"abate"}},
+		{"case insensitive", " ABAT", 10, nil, []string{"abate"}},
+		{"lemma", "ran", 10, []string{"run"}, []string{"run"}},
+		{"exact match first", "abase", 10, nil, []string{"abase"}},
+		{"no match", "zz", 10, nil, []string{}},
+		{"empty prefix", "", 10, nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, s := range i.Complete(tt.prefix, tt.limit, tt.lemmas...) {
+				got = append(got, s.Word)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletePrefersBaseForm(t *testing.T) {
+	i := sampleIndex()
+	got := i.Complete("aberr", 1)
+	if len(got) != 1 || got[0].Match != "aberrant" || got[0].Frequency != 7 {
+		t.Errorf("Complete() = %+v, want aberrant matched by its base form", got)
+	}
+	got = i.Complete("runn", 1)
+	if len(got) != 1 || got[0].Match != "running" {
+		t.Errorf("Complete() = %+v, want run matched by running", got)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	i := sampleIndex()
+	i.Add(Entry{WordID: 6, Word: "Abjure", Frequency: 9, Variants: []string{"abjured"}})
+	i.Add(Entry{WordID: 2, Word: "abacus", Frequency: 10, Variants: []string{"abaci"}})
+	if i.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", i.Len())
+	}
+	got := make([]string, 0)
+	for _, s := range i.Complete("ab", 3) {
+		got = append(got, s.Word)
+	}
+	if want := []string{"abacus", "abjure", "aberrant"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Complete() = %v, want %v", got, want)
+	}
+	if got := i.Complete("abaci", 5); len(got) != 1 || got[0].WordID != 2 {
+		t.Errorf("Complete(abaci) = %+v, want abacus", got)
+	}
+	// Adding the same word twice does not duplicate its keys
+	i.Add(Entry{WordID: 6, Word: "abjure", Frequency: 9})
+	if got := i.Complete("abj", 5); len(got) != 1 {
+		t.Errorf("Complete(abj) = %+v, want one suggestion", got)
+	}
+}
@@ -0,0 +1,173 @@
+/**
+* Package commands implements the command line tasks that are shared by the
+* admin command and the standalone commands, so that both accept the same
+* flags and print the same reports. Each task parses its own flags and
+* stops the program when it fails, as the commands do.
+**/
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+	"grepandit.com/api/internal/services"
+)
+
+const MigrateUsage = "up|down|to|status [flags]"
+
+/**
+* Applies or reverts the schema migrations with the command of args, then
+* prints the status of every migration. down reverts -steps migrations and
+* to migrates up or down to -version.
+**/
+func Migrate(ctx context.Context, db *pgxpool.Pool, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: migrate %s", MigrateUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	steps := 1
+	version := -1
+	switch command {
+	case "up", "status":
+	case "down":
+		flags.IntVar(&steps, "steps", 1, "number of migrations to revert")
+	case "to":
+		flags.IntVar(&version, "version", -1, "version to migrate to, 0 to revert every migration")
+	default:
+		log.Fatalf("Unknown command %q. Use up, down, to or status", command)
+	}
+	flags.Parse(args[1:])
+	if command == "to" && version < 0 {
+		log.Fatalf("The -version flag is required")
+	}
+
+	migrator := database.NewMigrator(db)
+	var err error
+	switch command {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, steps)
+	case "to":
+		err = migrator.To(ctx, version)
+	}
+	if err != nil {
+		log.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := printStatus(ctx, migrator); err != nil {
+		log.Fatalf("Failed to get the migration status: %v", err)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+/**
+* Imports the verbal questions of a JSON Lines or CSV file or of a QTI
+* content package and prints the report. Exits with status 1 when a row
+* failed.
+**/
+func Import(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "JSON Lines or CSV file or QTI content package to import")
+	format := flags.String("format", "", "format of the file, jsonl, csv or qti (default: from the file extension)")
+	createWords := flags.Bool("create-words", false, "create the vocabulary words that are not in the words table")
+	batchSize := flags.Int("batch-size", 0, "rows committed per transaction (default: all rows in a single transaction)")
+	startRow := flags.Int("start-row", 0, "row to resume the import from")
+	dryRun := flags.Bool("dry-run", false, "validate the file without importing it")
+	editor := flags.String("editor", "importer", "editor recorded in the revisions of the new questions")
+	flags.Parse(args)
+	if *file == "" {
+		log.Fatalf("The -file flag is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*file), ".")
+		// QTI content packages are zip archives
+		if *format == "zip" {
+			*format = string(models.FormatQTI)
+		}
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	verbalQuestionService := services.NewVerbalQuestionService(db, LoadLemmatizer())
+	report, err := verbalQuestionService.Import(ctx, f, models.ImportOptions{
+		Format:      models.TransferFormat(strings.ToLower(*format)),
+		CreateWords: *createWords,
+		BatchSize:   *batchSize,
+		StartRow:    *startRow,
+		DryRun:      *dryRun,
+	}, models.User{Token: *editor})
+	if report != nil {
+		WriteJSON(os.Stdout, report)
+	}
+	if err != nil {
+		if report != nil && report.NextRow > 0 {
+			log.Fatalf("Import interrupted, resume with -start-row %d: %v", report.NextRow, err)
+		}
+		log.Fatalf("Failed to import questions: %v", err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// Writes every verbal question to the standard output in the format of the -format flag
+func Export(ctx context.Context, db *pgxpool.Pool, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "jsonl", "format of the export, jsonl, csv or qti")
+	flags.Parse(args)
+
+	verbalQuestionService := services.NewVerbalQuestionService(db, nil)
+	err := verbalQuestionService.Export(ctx, os.Stdout, models.TransferFormat(strings.ToLower(*format)))
+	if err != nil {
+		log.Fatalf("Failed to export questions: %v", err)
+	}
+}
+
+func LoadLemmatizer() *nlp.Lemmatizer {
+	lemmatizer, err := nlp.NewLemmatizer(nlp.DefaultCacheSize)
+	if err != nil {
+		log.Fatalf("Failed to load lemmatizer: %v", err)
+	}
+	return lemmatizer
+}
+
+// Writes v as indented JSON, the format of the reports of the commands
+func WriteJSON(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -19,13 +19,21 @@ type DBSecrets struct {
 	Password string `json:"password"`
 }
 
+/**
+* Reads the credentials of the database from DB_USER and DB_PASSWORD in dev,
+* where they come from the .env file, and in test, where they are set by the
+* test environment. Other environments read them from AWS Secrets Manager.
+**/
 func getDBCredentials() (DBSecrets, error) {
-	if os.Getenv("APP_ENV") == "dev" {
+	switch os.Getenv("APP_ENV") {
+	case "dev":
 		err := godotenv.Load(".env")
 		if err != nil {
 			log.Fatal("Error loading .env file")
 		}
 		return DBSecrets{os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD")}, nil
+	case "test":
+		return DBSecrets{os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD")}, nil
 	}
 	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")))
 	if err != nil {
@@ -51,7 +59,8 @@ func getDBCredentials() (DBSecrets, error) {
 
 /**
 * Connects to the POSTGreSQL Db instance and sets up a connection pool to be
-* used. Requires secrets to be accessible from AWS Secrets Manager.
+* used. Outside dev and test, requires secrets to be accessible from AWS
+* Secrets Manager.
 **/
 func ConnectDB() (*pgxpool.Pool, error) {
 	print(os.Getenv("APP_ENV"))
@@ -9,15 +9,41 @@ const (
 	VerbalStatsTable               = "verbal_stats"
 	UserMarkedWordsTable           = "user_marked_words"
 	UserMarkedVerbalQuestionsTable = "user_marked_verbal_questions"
+	UserAbilitiesTable             = "user_abilities"
+	QuestionCalibrationsTable      = "question_calibrations"
+	PracticeSessionsTable          = "practice_sessions"
+	MockExamsTable                 = "mock_exams"
+	QuantQuestionsTable            = "quant_questions"
+	QuantDataSetsTable             = "quant_data_sets"
+	QuantStatsTable                = "quant_stats"
+	WritingPromptsTable            = "writing_prompts"
+	EssaysTable                    = "essays"
+	VerbalQuestionRevisionsTable   = "verbal_question_revisions"
+	PassagesTable                  = "passages"
+	PassageWordsJoinTable          = "passage_words"
+	FlashcardsTable                = "flashcards"
+	FlashcardReviewsTable          = "flashcard_reviews"
+	FlashcardSettingsTable         = "flashcard_settings"
+	VocabQuizzesTable              = "vocab_quizzes"
+	VocabQuizResultsTable          = "vocab_quiz_results"
+	WordRelationsTable             = "word_relations"
+	SchemaMigrationsTable          = "schema_migrations"
+	UserRolesTable                 = "user_roles"
 )
 
+// Text search configuration of the full-text search columns
+const SearchConfig = "english"
+
 // Words field names
 const (
 	WordsIDField       = "id"
 	WordsWordField     = "word"
 	WordsMeaningsField = "meanings"
 	WordsExamplesField = "examples"
-	WordsMarkedField   = "marked"
+	WordsFeaturedField = "featured"
+	WordsSearchField   = "search"
+	// Global flag that was renamed to featured when marking became per user
+	WordsLegacyMarkedField = "marked"
 )
 
 // VerbalQuestions field names
@@ -32,6 +58,13 @@ const (
 	VerbalQuestionsWordField       = "word"
 	VerbalQuestionsDifficultyField = "difficulty"
 	VerbalQuestionsWordmapField    = "wordmap"
+	VerbalQuestionsIRTAField       = "irt_a"
+	VerbalQuestionsIRTBField       = "irt_b"
+	VerbalQuestionsIRTCField       = "irt_c"
+	VerbalQuestionsRevisionField   = "revision"
+	VerbalQuestionsDeletedAtField  = "deleted_at"
+	VerbalQuestionsPassageField    = "passage_id"
+	VerbalQuestionsSearchField     = "search"
 )
 
 // Join table for users and verbal questions
@@ -47,6 +80,7 @@ const (
 	UserEmailField              = "email"
 	UserVerbalAbilityField      = "verbal_ability"
 	UserVerbalAbilityCountField = "verbal_ability_count"
+	UserQuantAbilityField       = "quant_ability"
 )
 
 // VerbalStats field names
@@ -58,6 +92,8 @@ const (
 	VerbalStatsAnswersField  = "answers"
 	VerbalStatsDurationField = "duration"
 	VerbalStatsDateField     = "date"
+	VerbalStatsSessionField  = "session_id"
+	VerbalStatsRevisionField = "question_revision"
 )
 
 // User Marked Words table field names
@@ -73,3 +109,227 @@ const (
 	UserMarkedVerbalQuestionsUserField     = "user_token"
 	UserMarkedVerbalQuestionsQuestionField = "verbal_question"
 )
+
+// User abilities field names
+const (
+	UserAbilitiesIDField        = "id"
+	UserAbilitiesUserField      = "user_token"
+	UserAbilitiesDimensionField = "dimension"
+	UserAbilitiesCategoryField  = "category"
+	UserAbilitiesThetaField     = "theta"
+	UserAbilitiesSEField        = "standard_error"
+	UserAbilitiesResponsesField = "responses"
+	UserAbilitiesUpdatedAtField = "updated_at"
+)
+
+// Question calibrations field names
+const (
+	QuestionCalibrationsIDField                 = "id"
+	QuestionCalibrationsQuestionField           = "question_id"
+	QuestionCalibrationsResponsesField          = "responses"
+	QuestionCalibrationsPValueField             = "p_value"
+	QuestionCalibrationsMedianDurationField     = "median_duration"
+	QuestionCalibrationsDiscriminationField     = "discrimination"
+	QuestionCalibrationsOldDifficultyField      = "old_difficulty"
+	QuestionCalibrationsProposedDifficultyField = "proposed_difficulty"
+	QuestionCalibrationsOldIRTField             = "old_irt"
+	QuestionCalibrationsProposedIRTField        = "proposed_irt"
+	QuestionCalibrationsAppliedField            = "applied"
+	QuestionCalibrationsAppliedAtField          = "applied_at"
+	QuestionCalibrationsCreatedAtField          = "created_at"
+)
+
+// Practice sessions field names
+const (
+	PracticeSessionsIDField            = "id"
+	PracticeSessionsUserField          = "user_token"
+	PracticeSessionsModeField          = "mode"
+	PracticeSessionsCriteriaField      = "criteria"
+	PracticeSessionsStatusField        = "status"
+	PracticeSessionsQuestionsField     = "question_ids"
+	PracticeSessionsAnsweredField      = "answered"
+	PracticeSessionsCorrectField       = "correct"
+	PracticeSessionsElapsedField       = "elapsed"
+	PracticeSessionsStartedAtField     = "started_at"
+	PracticeSessionsLastResumedAtField = "last_resumed_at"
+	PracticeSessionsFinishedAtField    = "finished_at"
+)
+
+// Mock exams field names
+const (
+	MockExamsIDField         = "id"
+	MockExamsUserField       = "user_token"
+	MockExamsStatusField     = "status"
+	MockExamsSection1Field   = "section1_session_id"
+	MockExamsSection2Field   = "section2_session_id"
+	MockExamsRouteField      = "route"
+	MockExamsScoreField      = "score"
+	MockExamsStartedAtField  = "started_at"
+	MockExamsFinishedAtField = "finished_at"
+)
+
+// Quant questions field names
+const (
+	QuantQuestionsIDField         = "id"
+	QuantQuestionsTypeField       = "type"
+	QuantQuestionsTopicField      = "topic"
+	QuantQuestionsQuestionField   = "question"
+	QuantQuestionsQuantityAField  = "quantity_a"
+	QuantQuestionsQuantityBField  = "quantity_b"
+	QuantQuestionsOptionsField    = "options"
+	QuantQuestionsAnswerField     = "answer"
+	QuantQuestionsDifficultyField = "difficulty"
+	QuantQuestionsDataSetField    = "data_set_id"
+	QuantQuestionsIRTAField       = "irt_a"
+	QuantQuestionsIRTBField       = "irt_b"
+	QuantQuestionsIRTCField       = "irt_c"
+	QuantQuestionsDeletedAtField  = "deleted_at"
+)
+
+// Quant data sets field names
+const (
+	QuantDataSetsIDField          = "id"
+	QuantDataSetsTitleField       = "title"
+	QuantDataSetsDescriptionField = "description"
+	QuantDataSetsChartField       = "chart"
+	QuantDataSetsDeletedAtField   = "deleted_at"
+)
+
+// QuantStats field names
+const (
+	QuantStatsIDField       = "id"
+	QuantStatsUserField     = "user_token"
+	QuantStatsQuestionField = "question_id"
+	QuantStatsCorrectField  = "correct"
+	QuantStatsAnswersField  = "answers"
+	QuantStatsDurationField = "duration"
+	QuantStatsDateField     = "date"
+)
+
+// Writing prompts field names
+const (
+	WritingPromptsIDField           = "id"
+	WritingPromptsTypeField         = "type"
+	WritingPromptsPromptField       = "prompt"
+	WritingPromptsInstructionsField = "instructions"
+	WritingPromptsTimeLimitField    = "time_limit"
+)
+
+// Essays field names
+const (
+	EssaysIDField          = "id"
+	EssaysUserField        = "user_token"
+	EssaysPromptField      = "prompt_id"
+	EssaysTextField        = "text"
+	EssaysStatusField      = "status"
+	EssaysStartedAtField   = "started_at"
+	EssaysSubmittedAtField = "submitted_at"
+	EssaysDurationField    = "duration"
+	EssaysOvertimeField    = "overtime"
+	EssaysScoreField       = "score"
+)
+
+// Verbal question revisions field names
+const (
+	VerbalQuestionRevisionsIDField          = "id"
+	VerbalQuestionRevisionsQuestionField    = "question_id"
+	VerbalQuestionRevisionsRevisionField    = "revision"
+	VerbalQuestionRevisionsActionField      = "action"
+	VerbalQuestionRevisionsEditorField      = "editor"
+	VerbalQuestionRevisionsEditorEmailField = "editor_email"
+	VerbalQuestionRevisionsSnapshotField    = "snapshot"
+	VerbalQuestionRevisionsDiffField        = "diff"
+	VerbalQuestionRevisionsCreatedAtField   = "created_at"
+)
+
+// Passages field names
+const (
+	PassagesIDField      = "id"
+	PassagesTitleField   = "title"
+	PassagesTextField    = "text"
+	PassagesWordmapField = "wordmap"
+	PassagesSearchField  = "search"
+)
+
+// Join table for passages and words
+const (
+	PassageWordJoinPassageField = "passage_id"
+	PassageWordJoinWordField    = "word_id"
+)
+
+// Flashcards field names
+const (
+	FlashcardsIDField             = "id"
+	FlashcardsUserField           = "user_token"
+	FlashcardsWordField           = "word_id"
+	FlashcardsRepetitionsField    = "repetitions"
+	FlashcardsIntervalField       = "interval_days"
+	FlashcardsEaseField           = "ease"
+	FlashcardsLapsesField         = "lapses"
+	FlashcardsDueAtField          = "due_at"
+	FlashcardsCreatedAtField      = "created_at"
+	FlashcardsLastReviewedAtField = "last_reviewed_at"
+)
+
+// Flashcard reviews field names
+const (
+	FlashcardReviewsIDField               = "id"
+	FlashcardReviewsFlashcardField        = "flashcard_id"
+	FlashcardReviewsUserField             = "user_token"
+	FlashcardReviewsGradeField            = "grade"
+	FlashcardReviewsNewField              = "new"
+	FlashcardReviewsPreviousIntervalField = "previous_interval_days"
+	FlashcardReviewsIntervalField         = "interval_days"
+	FlashcardReviewsEaseField             = "ease"
+	FlashcardReviewsReviewedAtField       = "reviewed_at"
+)
+
+// Flashcard settings field names
+const (
+	FlashcardSettingsUserField      = "user_token"
+	FlashcardSettingsNewPerDayField = "new_cards_per_day"
+)
+
+// Vocabulary quizzes field names
+const (
+	VocabQuizzesIDField        = "id"
+	VocabQuizzesUserField      = "user_token"
+	VocabQuizzesItemsField     = "items"
+	VocabQuizzesCreatedAtField = "created_at"
+)
+
+// Vocabulary quiz results field names
+const (
+	VocabQuizResultsIDField         = "id"
+	VocabQuizResultsUserField       = "user_token"
+	VocabQuizResultsQuizField       = "quiz_id"
+	VocabQuizResultsItemField       = "item"
+	VocabQuizResultsWordField       = "word_id"
+	VocabQuizResultsKindField       = "kind"
+	VocabQuizResultsCorrectField    = "correct"
+	VocabQuizResultsAnsweredAtField = "answered_at"
+)
+
+// Word relations field names
+const (
+	WordRelationsIDField          = "id"
+	WordRelationsWordField        = "word_id"
+	WordRelationsRelatedWordField = "related_word_id"
+	WordRelationsTypeField        = "type"
+)
+
+// Schema migrations field names
+const (
+	SchemaMigrationsVersionField   = "version"
+	SchemaMigrationsNameField      = "name"
+	SchemaMigrationsAppliedAtField = "applied_at"
+)
+
+// User roles field names
+const (
+	UserRolesIDField        = "id"
+	UserRolesUserField      = "user_token"
+	UserRolesRoleField      = "role"
+	UserRolesGrantedByField = "granted_by"
+	UserRolesGrantedAtField = "granted_at"
+)
@@ -2,134 +2,322 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+/**
+* Key of the advisory lock held while migrating, so that instances started
+* at the same time do not apply the same migration twice.
+**/
+const migrationLockKey = 4857201946
+
+var (
+	ErrUnknownMigration = errors.New("unknown migration")
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// Change of the schema that can be applied and reverted
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migration along with when it was applied. AppliedAt is nil when it is pending.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+/**
+* Applies and reverts migrations, recording the applied versions in the
+* schema migrations table. Each migration runs in its own transaction.
+**/
+type Migrator struct {
+	DB         *pgxpool.Pool
+	Migrations []Migration
+}
+
+func NewMigrator(db *pgxpool.Pool) *Migrator {
+	return &Migrator{DB: db, Migrations: migrations}
+}
+
+// Applies every pending migration. Stops the program when one fails.
 func Migrate(db *pgxpool.Pool) {
-	ctx := context.Background()
-
-	// Create table for words
-	_, err := db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+WordsTable+` (
-			`+WordsIDField+` SERIAL PRIMARY KEY,
-			`+WordsWordField+` VARCHAR(255) UNIQUE,
-			`+WordsMeaningsField+` JSONB,
-			`+WordsExamplesField+` TEXT[],
-			`+WordsMarkedField+` BOOLEAN DEFAULT FALSE NOT NULL
-		);
-	`)
+	if err := NewMigrator(db).Up(context.Background()); err != nil {
+		log.Fatalf("Could not migrate the database: %v", err)
+	}
+}
 
-	if err != nil {
-		log.Fatalf("Could not create "+WordsTable+" table: %v", err)
-	}
-
-	// Create table for verbal questions
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+VerbalQuestionsTable+` (
-			`+VerbalQuestionsIDField+` SERIAL PRIMARY KEY,
-			`+VerbalQuestionsCompetenceField+` INT,
-			`+VerbalQuestionsFramedAsField+` INT,
-			`+VerbalQuestionsTypeField+` INT,
-			`+VerbalQuestionsParagraphField+` TEXT,
-			`+VerbalQuestionsQuestionField+` TEXT,
-			`+VerbalQuestionsOptionsField+` JSONB,
-			`+VerbalQuestionsWordField+` TEXT[],
-			`+VerbalQuestionsDifficultyField+` INT,
-			`+VerbalQuestionsWordmapField+` JSONB
-		);
-	`)
+/**
+* Applies every pending migration. Migrations applied by a newer build are
+* left in place, so that an older instance can still start during a deploy.
+**/
+func (m *Migrator) Up(ctx context.Context) error {
+	if err := validateMigrations(m.Migrations); err != nil {
+		return err
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		target := latestVersion(m.Migrations)
+		if versions := sortedVersions(applied); len(versions) > 0 && versions[len(versions)-1] > target {
+			target = versions[len(versions)-1]
+		}
+		return m.migrate(ctx, conn, applied, target)
+	})
+}
 
-	if err != nil {
-		log.Fatalf("Could not create "+VerbalQuestionsTable+" table: %v", err)
+// Reverts the last steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("%w: steps must be positive, got %d", ErrInvalidMigration, steps)
 	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := sortedVersions(applied)
+		target := 0
+		if steps < len(versions) {
+			target = versions[len(versions)-steps-1]
+		}
+		return m.migrate(ctx, conn, applied, target)
+	})
+}
 
-	// Create user table
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+UsersTable+` (
-			`+UserIDField+` SERIAL PRIMARY KEY,
-			`+UserTokenField+` TEXT NOT NULL UNIQUE,
-			`+UserEmailField+` TEXT NOT NULL,
-			`+UserVerbalAbilityField+` JSONB
-		);
-	`)
+/**
+* Migrates to a version, applying the pending migrations up to it and
+* reverting the applied migrations after it. Version 0 reverts every
+* migration.
+**/
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if err := validateMigrations(m.Migrations); err != nil {
+		return err
+	}
+	if version != 0 && findMigration(m.Migrations, version) == nil {
+		return fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.migrate(ctx, conn, applied, version)
+	})
+}
+
+/**
+* Lists every migration with when it was applied, followed by the applied
+* versions that are unknown to this build.
+**/
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	statuses := make([]MigrationStatus, 0, len(m.Migrations))
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if a, ok := applied[migration.Version]; ok {
+				status.AppliedAt = a.AppliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		for _, version := range sortedVersions(applied) {
+			if findMigration(m.Migrations, version) == nil {
+				statuses = append(statuses, applied[version])
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
 
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, applied map[int]MigrationStatus, target int) error {
+	up, down, err := planMigrations(m.Migrations, applied, target)
 	if err != nil {
-		log.Fatalf("Could not create "+UsersTable+" table: %v", err)
+		return err
+	}
+	for _, migration := range down {
+		if err := runMigration(ctx, conn, migration, false); err != nil {
+			return err
+		}
 	}
+	for _, migration := range up {
+		if err := runMigration(ctx, conn, migration, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Create join table for verbal questions and words
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+VerbalQuestionWordsJoinTable+` (
-				`+VerbalQuestionWordJoinVerbalField+` INT REFERENCES `+VerbalQuestionsTable+`(`+VerbalQuestionsIDField+`) ON DELETE CASCADE,
-				`+VerbalQuestionWordJoinWordField+` INT REFERENCES `+WordsTable+`(`+WordsIDField+`) ON DELETE CASCADE,
-				PRIMARY KEY (`+VerbalQuestionWordJoinVerbalField+`, `+VerbalQuestionWordJoinWordField+`)
+/**
+* Runs fn on a connection holding the migration lock, after creating the
+* schema migrations table. Advisory locks belong to a session, so the same
+* connection is used until the lock is released.
+**/
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("could not acquire the migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS `+SchemaMigrationsTable+` (
+				`+SchemaMigrationsVersionField+` INT PRIMARY KEY,
+				`+SchemaMigrationsNameField+` TEXT NOT NULL,
+				`+SchemaMigrationsAppliedAtField+` TIMESTAMP NOT NULL
 		);
 	`)
+	if err != nil {
+		return fmt.Errorf("could not create "+SchemaMigrationsTable+" table: %w", err)
+	}
+	return fn(conn)
+}
 
+// Applied migrations by version
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int]MigrationStatus, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT `+SchemaMigrationsVersionField+`, `+SchemaMigrationsNameField+`, `+SchemaMigrationsAppliedAtField+`
+		FROM `+SchemaMigrationsTable)
 	if err != nil {
-		log.Fatalf("Could not create "+VerbalQuestionWordsJoinTable+" table: %v", err)
-	}
-
-	// Create verbal stats table
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+VerbalStatsTable+` (
-				`+VerbalStatsIDField+` SERIAL PRIMARY KEY,
-				`+VerbalStatsUserField+` TEXT NOT NULL,
-				`+VerbalStatsQuestionField+` INT NOT NULL REFERENCES `+VerbalQuestionsTable+`(`+VerbalQuestionsIDField+`) ON DELETE CASCADE,
-				`+VerbalStatsCorrectField+` BOOLEAN,
-				`+VerbalStatsAnswersField+` TEXT[],
-				`+VerbalStatsDurationField+` INT,
-				`+VerbalStatsDateField+` TIMESTAMP
-		);
-	`)
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]MigrationStatus)
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		status.AppliedAt = &appliedAt
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
 
+// Applies or reverts a migration and records it in a single transaction
+func runMigration(ctx context.Context, conn *pgxpool.Conn, migration Migration, up bool) error {
+	tx, err := conn.Begin(ctx)
 	if err != nil {
-		log.Fatalf("Could not create "+VerbalStatsTable+" table: %v", err)
+		return err
 	}
+	defer tx.Rollback(ctx)
+	if up {
+		err = execMigration(ctx, tx, migration.Up,
+			`INSERT INTO `+SchemaMigrationsTable+` (`+SchemaMigrationsVersionField+`, `+
+				SchemaMigrationsNameField+`, `+SchemaMigrationsAppliedAtField+`) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, time.Now())
+	} else {
+		err = execMigration(ctx, tx, migration.Down,
+			`DELETE FROM `+SchemaMigrationsTable+` WHERE `+SchemaMigrationsVersionField+` = $1`,
+			migration.Version)
+	}
+	if err != nil {
+		direction := "apply"
+		if !up {
+			direction = "revert"
+		}
+		return fmt.Errorf("could not %s migration %d %s: %w", direction, migration.Version, migration.Name, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	if up {
+		log.Printf("Applied migration %d %s", migration.Version, migration.Name)
+	} else {
+		log.Printf("Reverted migration %d %s", migration.Version, migration.Name)
+	}
+	return nil
+}
 
-	// Create user marked verbal questions table
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+UserMarkedVerbalQuestionsTable+` (
-				`+UserMarkedVerbalQuestionsIDField+` SERIAL PRIMARY KEY,
-				`+UserMarkedVerbalQuestionsUserField+` TEXT,
-				`+UserMarkedVerbalQuestionsQuestionField+` INT REFERENCES `+VerbalQuestionsTable+`(`+VerbalQuestionsIDField+`) ON DELETE CASCADE,
-				UNIQUE (`+UserMarkedVerbalQuestionsUserField+`, `+UserMarkedVerbalQuestionsQuestionField+`)
-		);
-	`)
+func execMigration(ctx context.Context, tx pgx.Tx, sql string, record string, args ...interface{}) error {
+	// Without arguments the statements of a migration run together
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, record, args...)
+	return err
+}
 
-	if err != nil {
-		log.Fatalf("Could not create "+UserMarkedVerbalQuestionsTable+" table: %v", err)
+/**
+* Returns the migrations to apply, in ascending order, and the migrations
+* to revert, in descending order, to move from the applied versions to the
+* target version. Applied versions after the target that are unknown to
+* this build cannot be reverted.
+**/
+func planMigrations(all []Migration, applied map[int]MigrationStatus, target int) ([]Migration, []Migration, error) {
+	up := make([]Migration, 0)
+	down := make([]Migration, 0)
+	versions := sortedVersions(applied)
+	for i := len(versions) - 1; i >= 0 && versions[i] > target; i-- {
+		migration := findMigration(all, versions[i])
+		if migration == nil {
+			return nil, nil, fmt.Errorf("%w: applied version %d cannot be reverted", ErrUnknownMigration, versions[i])
+		}
+		down = append(down, *migration)
 	}
+	for _, migration := range all {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= target {
+			up = append(up, migration)
+		}
+	}
+	return up, down, nil
+}
 
-	// Create user marked words table
-	_, err = db.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS `+UserMarkedWordsTable+` (
-				`+UserMarkedWordsIDField+` SERIAL PRIMARY KEY,
-				`+UserMarkedWordsUserField+` TEXT NOT NULL REFERENCES `+UsersTable+`(`+UserTokenField+`),
-				`+UserMarkedWordsWordField+` INT NOT NULL REFERENCES `+WordsTable+`(`+WordsIDField+`) ON DELETE CASCADE,
-				UNIQUE (`+UserMarkedWordsUserField+`, `+UserMarkedWordsWordField+`)
-		);
-	`)
+// Versions must be positive, unique and ascending
+func validateMigrations(all []Migration) error {
+	for i, migration := range all {
+		if migration.Version <= 0 || (i > 0 && migration.Version <= all[i-1].Version) {
+			return fmt.Errorf("%w: version %d of %s is out of order", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		if migration.Name == "" || migration.Up == "" || migration.Down == "" {
+			return fmt.Errorf("%w: version %d is missing its name, up or down", ErrInvalidMigration, migration.Version)
+		}
+	}
+	return nil
+}
 
-	if err != nil {
-		log.Fatalf("Could not create "+UserMarkedWordsTable+" table: %v", err)
-	}
-
-	// Create needed indexes for querying and improving performance
-	_, err = db.Exec(ctx, `
-		CREATE INDEX IF NOT EXISTS idx_word ON `+WordsTable+`(`+WordsWordField+`);
-		CREATE INDEX IF NOT EXISTS idx_word ON `+WordsTable+`(`+WordsMarkedField+`) WHERE `+WordsMarkedField+`=TRUE;
-		CREATE INDEX IF NOT EXISTS idx_competence ON `+VerbalQuestionsTable+`(`+VerbalQuestionsCompetenceField+`);
-		CREATE INDEX IF NOT EXISTS idx_framed_as ON `+VerbalQuestionsTable+`(`+VerbalQuestionsFramedAsField+`);
-		CREATE INDEX IF NOT EXISTS idx_type ON `+VerbalQuestionsTable+`(`+VerbalQuestionsTypeField+`);
-		CREATE INDEX IF NOT EXISTS idx_user_token_users ON `+UsersTable+`(`+UserTokenField+`);
-		CREATE INDEX IF NOT EXISTS idx_user_token_user_marked_words ON `+UserMarkedWordsTable+`(`+UserMarkedWordsUserField+`);
-		CREATE INDEX IF NOT EXISTS idx_user_token_user_marked_verbal_questions ON `+UserMarkedVerbalQuestionsTable+`(`+UserMarkedVerbalQuestionsUserField+`);
-	`)
+func findMigration(all []Migration, version int) *Migration {
+	for i := range all {
+		if all[i].Version == version {
+			return &all[i]
+		}
+	}
+	return nil
+}
 
-	if err != nil {
-		log.Fatalf("Could not create indices: %v", err)
+func latestVersion(all []Migration) int {
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+func sortedVersions(applied map[int]MigrationStatus) []int {
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
 	}
+	sort.Ints(versions)
+	return versions
 }
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreValid(t *testing.T) {
+	if err := validateMigrations(migrations); err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, migration := range migrations {
+		if names[migration.Name] {
+			t.Errorf("migration name %s is used twice", migration.Name)
+		}
+		names[migration.Name] = true
+	}
+}
+
+func TestIndexNamesAreUnique(t *testing.T) {
+	indexes := make(map[string]int)
+	for _, migration := range migrations {
+		for _, line := range strings.Split(migration.Up, "\n") {
+			if fields := strings.Fields(line); len(fields) > 5 && fields[0] == "CREATE" && fields[1] == "INDEX" {
+				name := fields[5]
+				if version, ok := indexes[name]; ok {
+					t.Errorf("index %s of migration %d is already created by migration %d", name, migration.Version, version)
+				}
+				indexes[name] = migration.Version
+			}
+		}
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []Migration
+	}{
+		{"out of order", []Migration{{2, "b", "up", "down"}, {1, "a", "up", "down"}}},
+		{"duplicate", []Migration{{1, "a", "up", "down"}, {1, "b", "up", "down"}}},
+		{"zero", []Migration{{0, "a", "up", "down"}}},
+		{"missing down", []Migration{{1, "a", "up", ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMigrations(tt.migrations); !errors.Is(err, ErrInvalidMigration) {
+				t.Errorf("validateMigrations() error = %v, want invalid migration", err)
+			}
+		})
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	all := []Migration{{1, "a", "up", "down"}, {2, "b", "up", "down"}, {3, "c", "up", "down"}, {4, "d", "up", "down"}}
+	applied := func(versions ...int) map[int]MigrationStatus {
+		m := make(map[int]MigrationStatus)
+		for _, v := range versions {
+			m[v] = MigrationStatus{Version: v}
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		applied  map[int]MigrationStatus
+		target   int
+		wantUp   []int
+		wantDown []int
+	}{
+		{"fresh database", applied(), 4, []int{1, 2, 3, 4}, []int{}},
+		{"up to date", applied(1, 2, 3, 4), 4, []int{}, []int{}},
+		{"pending", applied(1, 2), 4, []int{3, 4}, []int{}},
+		{"gap", applied(1, 3), 4, []int{2, 4}, []int{}},
+		{"down", applied(1, 2, 3, 4), 2, []int{}, []int{4, 3}},
+		{"down to zero", applied(1, 2), 0, []int{}, []int{2, 1}},
+		{"up and down", applied(1, 3), 2, []int{2}, []int{3}},
+	}
+	versions := func(migrations []Migration) []int {
+		v := make([]int, 0)
+		for _, m := range migrations {
+			v = append(v, m.Version)
+		}
+		return v
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down, err := planMigrations(all, tt.applied, tt.target)
+			if err != nil {
+				t.Fatalf("planMigrations() error = %v", err)
+			}
+			if got := versions(up); !reflect.DeepEqual(got, tt.wantUp) {
+				t.Errorf("up = %v, want %v", got, tt.wantUp)
+			}
+			if got := versions(down); !reflect.DeepEqual(got, tt.wantDown) {
+				t.Errorf("down = %v, want %v", got, tt.wantDown)
+			}
+		})
+	}
+	// An unknown version applied by a newer build cannot be reverted
+	if _, _, err := planMigrations(all, applied(1, 2, 3, 4, 5), 4); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("planMigrations() error = %v, want unknown migration", err)
+	}
+	if _, _, err := planMigrations(all, applied(1, 2, 3, 4, 5), 5); err != nil {
+		t.Errorf("planMigrations() error = %v, want no error when nothing is reverted", err)
+	}
+}
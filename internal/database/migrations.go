@@ -0,0 +1,626 @@
+package database
+
+/**
+* Schema migrations in the order they are applied. Released migrations are
+* never edited: the schema is changed by appending a migration with the
+* next version. The first migrations only create what does not exist yet,
+* so that databases created before migrations were versioned adopt them
+* without changes.
+**/
+var migrations = []Migration{
+	// Create table for words
+	{
+		Version: 1,
+		Name:    "create_words",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + WordsTable + ` (
+			` + WordsIDField + ` SERIAL PRIMARY KEY,
+			` + WordsWordField + ` VARCHAR(255) UNIQUE,
+			` + WordsMeaningsField + ` JSONB,
+			` + WordsExamplesField + ` TEXT[],
+			` + WordsLegacyMarkedField + ` BOOLEAN DEFAULT FALSE NOT NULL
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + WordsTable + `;`,
+	},
+	// Create table for verbal questions
+	{
+		Version: 2,
+		Name:    "create_verbal_questions",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + VerbalQuestionsTable + ` (
+			` + VerbalQuestionsIDField + ` SERIAL PRIMARY KEY,
+			` + VerbalQuestionsCompetenceField + ` INT,
+			` + VerbalQuestionsFramedAsField + ` INT,
+			` + VerbalQuestionsTypeField + ` INT,
+			` + VerbalQuestionsParagraphField + ` TEXT,
+			` + VerbalQuestionsQuestionField + ` TEXT,
+			` + VerbalQuestionsOptionsField + ` JSONB,
+			` + VerbalQuestionsWordField + ` TEXT[],
+			` + VerbalQuestionsDifficultyField + ` INT,
+			` + VerbalQuestionsWordmapField + ` JSONB
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + VerbalQuestionsTable + `;`,
+	},
+	// Create user table
+	{
+		Version: 3,
+		Name:    "create_users",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + UsersTable + ` (
+			` + UserIDField + ` SERIAL PRIMARY KEY,
+			` + UserTokenField + ` TEXT NOT NULL UNIQUE,
+			` + UserEmailField + ` TEXT NOT NULL,
+			` + UserVerbalAbilityField + ` JSONB
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + UsersTable + `;`,
+	},
+	// Create join table for verbal questions and words
+	{
+		Version: 4,
+		Name:    "create_verbal_question_words",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + VerbalQuestionWordsJoinTable + ` (
+				` + VerbalQuestionWordJoinVerbalField + ` INT REFERENCES ` + VerbalQuestionsTable + `(` + VerbalQuestionsIDField + `) ON DELETE CASCADE,
+				` + VerbalQuestionWordJoinWordField + ` INT REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				PRIMARY KEY (` + VerbalQuestionWordJoinVerbalField + `, ` + VerbalQuestionWordJoinWordField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + VerbalQuestionWordsJoinTable + `;`,
+	},
+	// Create verbal stats table
+	{
+		Version: 5,
+		Name:    "create_verbal_stats",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + VerbalStatsTable + ` (
+				` + VerbalStatsIDField + ` SERIAL PRIMARY KEY,
+				` + VerbalStatsUserField + ` TEXT NOT NULL,
+				` + VerbalStatsQuestionField + ` INT NOT NULL REFERENCES ` + VerbalQuestionsTable + `(` + VerbalQuestionsIDField + `) ON DELETE CASCADE,
+				` + VerbalStatsCorrectField + ` BOOLEAN,
+				` + VerbalStatsAnswersField + ` TEXT[],
+				` + VerbalStatsDurationField + ` INT,
+				` + VerbalStatsDateField + ` TIMESTAMP
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + VerbalStatsTable + `;`,
+	},
+	// Create user marked verbal questions table
+	{
+		Version: 6,
+		Name:    "create_user_marked_verbal_questions",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + UserMarkedVerbalQuestionsTable + ` (
+				` + UserMarkedVerbalQuestionsIDField + ` SERIAL PRIMARY KEY,
+				` + UserMarkedVerbalQuestionsUserField + ` TEXT,
+				` + UserMarkedVerbalQuestionsQuestionField + ` INT REFERENCES ` + VerbalQuestionsTable + `(` + VerbalQuestionsIDField + `) ON DELETE CASCADE,
+				UNIQUE (` + UserMarkedVerbalQuestionsUserField + `, ` + UserMarkedVerbalQuestionsQuestionField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + UserMarkedVerbalQuestionsTable + `;`,
+	},
+	// Create user marked words table
+	{
+		Version: 7,
+		Name:    "create_user_marked_words",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + UserMarkedWordsTable + ` (
+				` + UserMarkedWordsIDField + ` SERIAL PRIMARY KEY,
+				` + UserMarkedWordsUserField + ` TEXT NOT NULL REFERENCES ` + UsersTable + `(` + UserTokenField + `),
+				` + UserMarkedWordsWordField + ` INT NOT NULL REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				UNIQUE (` + UserMarkedWordsUserField + `, ` + UserMarkedWordsWordField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + UserMarkedWordsTable + `;`,
+	},
+	// Add item response theory parameters to verbal questions and seed the
+	// parameters of existing questions from their difficulty
+	{
+		Version: 8,
+		Name:    "add_verbal_questions_irt",
+		Up: `
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsIRTAField + ` DOUBLE PRECISION,
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsIRTBField + ` DOUBLE PRECISION,
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsIRTCField + ` DOUBLE PRECISION;
+		UPDATE ` + VerbalQuestionsTable + ` SET
+			` + VerbalQuestionsIRTAField + ` = 1,
+			` + VerbalQuestionsIRTBField + ` = CASE ` + VerbalQuestionsDifficultyField + ` WHEN 1 THEN -1 WHEN 3 THEN 1 ELSE 0 END,
+			` + VerbalQuestionsIRTCField + ` = CASE ` + VerbalQuestionsFramedAsField + ` WHEN 1 THEN 0.2 ELSE 0 END
+		WHERE ` + VerbalQuestionsIRTAField + ` IS NULL;
+	`,
+		Down: `
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			DROP COLUMN IF EXISTS ` + VerbalQuestionsIRTAField + `,
+			DROP COLUMN IF EXISTS ` + VerbalQuestionsIRTBField + `,
+			DROP COLUMN IF EXISTS ` + VerbalQuestionsIRTCField + `;
+	`,
+	},
+	// Create user abilities table
+	{
+		Version: 9,
+		Name:    "create_user_abilities",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + UserAbilitiesTable + ` (
+				` + UserAbilitiesIDField + ` SERIAL PRIMARY KEY,
+				` + UserAbilitiesUserField + ` TEXT NOT NULL REFERENCES ` + UsersTable + `(` + UserTokenField + `) ON DELETE CASCADE,
+				` + UserAbilitiesDimensionField + ` TEXT NOT NULL,
+				` + UserAbilitiesCategoryField + ` TEXT NOT NULL,
+				` + UserAbilitiesThetaField + ` DOUBLE PRECISION NOT NULL,
+				` + UserAbilitiesSEField + ` DOUBLE PRECISION NOT NULL,
+				` + UserAbilitiesResponsesField + ` INT NOT NULL DEFAULT 0,
+				` + UserAbilitiesUpdatedAtField + ` TIMESTAMP NOT NULL,
+				UNIQUE (` + UserAbilitiesUserField + `, ` + UserAbilitiesDimensionField + `, ` + UserAbilitiesCategoryField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + UserAbilitiesTable + `;`,
+	},
+	// Create question calibrations table
+	{
+		Version: 10,
+		Name:    "create_question_calibrations",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + QuestionCalibrationsTable + ` (
+				` + QuestionCalibrationsIDField + ` SERIAL PRIMARY KEY,
+				` + QuestionCalibrationsQuestionField + ` INT NOT NULL REFERENCES ` + VerbalQuestionsTable + `(` + VerbalQuestionsIDField + `) ON DELETE CASCADE,
+				` + QuestionCalibrationsResponsesField + ` INT NOT NULL,
+				` + QuestionCalibrationsPValueField + ` DOUBLE PRECISION NOT NULL,
+				` + QuestionCalibrationsMedianDurationField + ` DOUBLE PRECISION NOT NULL,
+				` + QuestionCalibrationsDiscriminationField + ` DOUBLE PRECISION NOT NULL,
+				` + QuestionCalibrationsOldDifficultyField + ` INT NOT NULL,
+				` + QuestionCalibrationsProposedDifficultyField + ` INT NOT NULL,
+				` + QuestionCalibrationsOldIRTField + ` JSONB NOT NULL,
+				` + QuestionCalibrationsProposedIRTField + ` JSONB NOT NULL,
+				` + QuestionCalibrationsAppliedField + ` BOOLEAN DEFAULT FALSE NOT NULL,
+				` + QuestionCalibrationsAppliedAtField + ` TIMESTAMP,
+				` + QuestionCalibrationsCreatedAtField + ` TIMESTAMP NOT NULL
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + QuestionCalibrationsTable + `;`,
+	},
+	// Create practice sessions table and link verbal stats to their session
+	{
+		Version: 11,
+		Name:    "create_practice_sessions",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + PracticeSessionsTable + ` (
+				` + PracticeSessionsIDField + ` SERIAL PRIMARY KEY,
+				` + PracticeSessionsUserField + ` TEXT NOT NULL REFERENCES ` + UsersTable + `(` + UserTokenField + `) ON DELETE CASCADE,
+				` + PracticeSessionsModeField + ` TEXT NOT NULL,
+				` + PracticeSessionsCriteriaField + ` JSONB NOT NULL,
+				` + PracticeSessionsStatusField + ` TEXT NOT NULL,
+				` + PracticeSessionsQuestionsField + ` INT[] NOT NULL DEFAULT '{}',
+				` + PracticeSessionsAnsweredField + ` INT NOT NULL DEFAULT 0,
+				` + PracticeSessionsCorrectField + ` INT NOT NULL DEFAULT 0,
+				` + PracticeSessionsElapsedField + ` INT NOT NULL DEFAULT 0,
+				` + PracticeSessionsStartedAtField + ` TIMESTAMP NOT NULL,
+				` + PracticeSessionsLastResumedAtField + ` TIMESTAMP NOT NULL,
+				` + PracticeSessionsFinishedAtField + ` TIMESTAMP
+		);
+		ALTER TABLE ` + VerbalStatsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalStatsSessionField + ` INT REFERENCES ` + PracticeSessionsTable + `(` + PracticeSessionsIDField + `) ON DELETE SET NULL;
+	`,
+		Down: `
+		ALTER TABLE ` + VerbalStatsTable + ` DROP COLUMN IF EXISTS ` + VerbalStatsSessionField + `;
+		DROP TABLE IF EXISTS ` + PracticeSessionsTable + `;
+	`,
+	},
+	// Create mock exams table
+	{
+		Version: 12,
+		Name:    "create_mock_exams",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + MockExamsTable + ` (
+				` + MockExamsIDField + ` SERIAL PRIMARY KEY,
+				` + MockExamsUserField + ` TEXT NOT NULL REFERENCES ` + UsersTable + `(` + UserTokenField + `) ON DELETE CASCADE,
+				` + MockExamsStatusField + ` TEXT NOT NULL,
+				` + MockExamsSection1Field + ` INT NOT NULL REFERENCES ` + PracticeSessionsTable + `(` + PracticeSessionsIDField + `) ON DELETE CASCADE,
+				` + MockExamsSection2Field + ` INT REFERENCES ` + PracticeSessionsTable + `(` + PracticeSessionsIDField + `) ON DELETE CASCADE,
+				` + MockExamsRouteField + ` TEXT,
+				` + MockExamsScoreField + ` JSONB,
+				` + MockExamsStartedAtField + ` TIMESTAMP NOT NULL,
+				` + MockExamsFinishedAtField + ` TIMESTAMP
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + MockExamsTable + `;`,
+	},
+	// Create quant tables and the quant ability of users
+	{
+		Version: 13,
+		Name:    "create_quant",
+		Up: `
+		ALTER TABLE ` + UsersTable + `
+			ADD COLUMN IF NOT EXISTS ` + UserQuantAbilityField + ` JSONB;
+		CREATE TABLE IF NOT EXISTS ` + QuantDataSetsTable + ` (
+				` + QuantDataSetsIDField + ` SERIAL PRIMARY KEY,
+				` + QuantDataSetsTitleField + ` TEXT NOT NULL,
+				` + QuantDataSetsDescriptionField + ` TEXT,
+				` + QuantDataSetsChartField + ` JSONB NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS ` + QuantQuestionsTable + ` (
+				` + QuantQuestionsIDField + ` SERIAL PRIMARY KEY,
+				` + QuantQuestionsTypeField + ` INT NOT NULL,
+				` + QuantQuestionsTopicField + ` INT NOT NULL,
+				` + QuantQuestionsQuestionField + ` TEXT NOT NULL,
+				` + QuantQuestionsQuantityAField + ` TEXT NOT NULL DEFAULT '',
+				` + QuantQuestionsQuantityBField + ` TEXT NOT NULL DEFAULT '',
+				` + QuantQuestionsOptionsField + ` JSONB,
+				` + QuantQuestionsAnswerField + ` JSONB,
+				` + QuantQuestionsDifficultyField + ` INT NOT NULL,
+				` + QuantQuestionsDataSetField + ` INT REFERENCES ` + QuantDataSetsTable + `(` + QuantDataSetsIDField + `) ON DELETE CASCADE,
+				` + QuantQuestionsIRTAField + ` DOUBLE PRECISION NOT NULL,
+				` + QuantQuestionsIRTBField + ` DOUBLE PRECISION NOT NULL,
+				` + QuantQuestionsIRTCField + ` DOUBLE PRECISION NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS ` + QuantStatsTable + ` (
+				` + QuantStatsIDField + ` SERIAL PRIMARY KEY,
+				` + QuantStatsUserField + ` TEXT NOT NULL,
+				` + QuantStatsQuestionField + ` INT NOT NULL REFERENCES ` + QuantQuestionsTable + `(` + QuantQuestionsIDField + `) ON DELETE CASCADE,
+				` + QuantStatsCorrectField + ` BOOLEAN,
+				` + QuantStatsAnswersField + ` TEXT[],
+				` + QuantStatsDurationField + ` INT,
+				` + QuantStatsDateField + ` TIMESTAMP
+		);
+	`,
+		Down: `
+		DROP TABLE IF EXISTS ` + QuantStatsTable + `;
+		DROP TABLE IF EXISTS ` + QuantQuestionsTable + `;
+		DROP TABLE IF EXISTS ` + QuantDataSetsTable + `;
+		ALTER TABLE ` + UsersTable + ` DROP COLUMN IF EXISTS ` + UserQuantAbilityField + `;
+	`,
+	},
+	// Create analytical writing tables
+	{
+		Version: 14,
+		Name:    "create_writing",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + WritingPromptsTable + ` (
+				` + WritingPromptsIDField + ` SERIAL PRIMARY KEY,
+				` + WritingPromptsTypeField + ` TEXT NOT NULL,
+				` + WritingPromptsPromptField + ` TEXT NOT NULL,
+				` + WritingPromptsInstructionsField + ` TEXT NOT NULL DEFAULT '',
+				` + WritingPromptsTimeLimitField + ` INT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS ` + EssaysTable + ` (
+				` + EssaysIDField + ` SERIAL PRIMARY KEY,
+				` + EssaysUserField + ` TEXT NOT NULL REFERENCES ` + UsersTable + `(` + UserTokenField + `) ON DELETE CASCADE,
+				` + EssaysPromptField + ` INT NOT NULL REFERENCES ` + WritingPromptsTable + `(` + WritingPromptsIDField + `) ON DELETE CASCADE,
+				` + EssaysTextField + ` TEXT NOT NULL DEFAULT '',
+				` + EssaysStatusField + ` TEXT NOT NULL,
+				` + EssaysStartedAtField + ` TIMESTAMP NOT NULL,
+				` + EssaysSubmittedAtField + ` TIMESTAMP,
+				` + EssaysDurationField + ` INT NOT NULL DEFAULT 0,
+				` + EssaysOvertimeField + ` BOOLEAN NOT NULL DEFAULT FALSE,
+				` + EssaysScoreField + ` JSONB
+		);
+	`,
+		Down: `
+		DROP TABLE IF EXISTS ` + EssaysTable + `;
+		DROP TABLE IF EXISTS ` + WritingPromptsTable + `;
+	`,
+	},
+	// Track revisions of verbal questions and the revision each stat was
+	// recorded against. Existing questions and stats start at revision 1.
+	{
+		Version: 15,
+		Name:    "add_verbal_question_revisions",
+		Up: `
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsRevisionField + ` INT NOT NULL DEFAULT 1,
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsDeletedAtField + ` TIMESTAMP;
+		ALTER TABLE ` + VerbalStatsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalStatsRevisionField + ` INT NOT NULL DEFAULT 1;
+		CREATE TABLE IF NOT EXISTS ` + VerbalQuestionRevisionsTable + ` (
+				` + VerbalQuestionRevisionsIDField + ` SERIAL PRIMARY KEY,
+				` + VerbalQuestionRevisionsQuestionField + ` INT NOT NULL REFERENCES ` + VerbalQuestionsTable + `(` + VerbalQuestionsIDField + `) ON DELETE CASCADE,
+				` + VerbalQuestionRevisionsRevisionField + ` INT NOT NULL,
+				` + VerbalQuestionRevisionsActionField + ` TEXT NOT NULL,
+				` + VerbalQuestionRevisionsEditorField + ` TEXT NOT NULL,
+				` + VerbalQuestionRevisionsEditorEmailField + ` TEXT NOT NULL,
+				` + VerbalQuestionRevisionsSnapshotField + ` JSONB NOT NULL,
+				` + VerbalQuestionRevisionsDiffField + ` JSONB,
+				` + VerbalQuestionRevisionsCreatedAtField + ` TIMESTAMP NOT NULL,
+				UNIQUE (` + VerbalQuestionRevisionsQuestionField + `, ` + VerbalQuestionRevisionsRevisionField + `)
+		);
+	`,
+		Down: `
+		DROP TABLE IF EXISTS ` + VerbalQuestionRevisionsTable + `;
+		ALTER TABLE ` + VerbalStatsTable + ` DROP COLUMN IF EXISTS ` + VerbalStatsRevisionField + `;
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			DROP COLUMN IF EXISTS ` + VerbalQuestionsRevisionField + `,
+			DROP COLUMN IF EXISTS ` + VerbalQuestionsDeletedAtField + `;
+	`,
+	},
+	// Create reading passages shared by sets of reading comprehension
+	// questions, along with their vocabulary
+	{
+		Version: 16,
+		Name:    "create_passages",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + PassagesTable + ` (
+				` + PassagesIDField + ` SERIAL PRIMARY KEY,
+				` + PassagesTitleField + ` TEXT NOT NULL,
+				` + PassagesTextField + ` TEXT NOT NULL,
+				` + PassagesWordmapField + ` JSONB
+		);
+		CREATE TABLE IF NOT EXISTS ` + PassageWordsJoinTable + ` (
+				` + PassageWordJoinPassageField + ` INT REFERENCES ` + PassagesTable + `(` + PassagesIDField + `) ON DELETE CASCADE,
+				` + PassageWordJoinWordField + ` INT REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				PRIMARY KEY (` + PassageWordJoinPassageField + `, ` + PassageWordJoinWordField + `)
+		);
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsPassageField + ` INT REFERENCES ` + PassagesTable + `(` + PassagesIDField + `);
+	`,
+		Down: `
+		ALTER TABLE ` + VerbalQuestionsTable + ` DROP COLUMN IF EXISTS ` + VerbalQuestionsPassageField + `;
+		DROP TABLE IF EXISTS ` + PassageWordsJoinTable + `;
+		DROP TABLE IF EXISTS ` + PassagesTable + `;
+	`,
+	},
+	// Create the spaced repetition flashcards of the users, the log of
+	// their reviews and their daily limit of new cards
+	{
+		Version: 17,
+		Name:    "create_flashcards",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + FlashcardsTable + ` (
+				` + FlashcardsIDField + ` SERIAL PRIMARY KEY,
+				` + FlashcardsUserField + ` TEXT NOT NULL,
+				` + FlashcardsWordField + ` INT NOT NULL REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				` + FlashcardsRepetitionsField + ` INT NOT NULL DEFAULT 0,
+				` + FlashcardsIntervalField + ` INT NOT NULL DEFAULT 0,
+				` + FlashcardsEaseField + ` DOUBLE PRECISION NOT NULL,
+				` + FlashcardsLapsesField + ` INT NOT NULL DEFAULT 0,
+				` + FlashcardsDueAtField + ` TIMESTAMP NOT NULL,
+				` + FlashcardsCreatedAtField + ` TIMESTAMP NOT NULL,
+				` + FlashcardsLastReviewedAtField + ` TIMESTAMP,
+				UNIQUE (` + FlashcardsUserField + `, ` + FlashcardsWordField + `)
+		);
+		CREATE TABLE IF NOT EXISTS ` + FlashcardReviewsTable + ` (
+				` + FlashcardReviewsIDField + ` SERIAL PRIMARY KEY,
+				` + FlashcardReviewsFlashcardField + ` INT NOT NULL REFERENCES ` + FlashcardsTable + `(` + FlashcardsIDField + `) ON DELETE CASCADE,
+				` + FlashcardReviewsUserField + ` TEXT NOT NULL,
+				` + FlashcardReviewsGradeField + ` INT NOT NULL,
+				` + FlashcardReviewsNewField + ` BOOLEAN NOT NULL,
+				` + FlashcardReviewsPreviousIntervalField + ` INT NOT NULL,
+				` + FlashcardReviewsIntervalField + ` INT NOT NULL,
+				` + FlashcardReviewsEaseField + ` DOUBLE PRECISION NOT NULL,
+				` + FlashcardReviewsReviewedAtField + ` TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS ` + FlashcardSettingsTable + ` (
+				` + FlashcardSettingsUserField + ` TEXT PRIMARY KEY,
+				` + FlashcardSettingsNewPerDayField + ` INT NOT NULL
+		);
+	`,
+		Down: `
+		DROP TABLE IF EXISTS ` + FlashcardSettingsTable + `;
+		DROP TABLE IF EXISTS ` + FlashcardReviewsTable + `;
+		DROP TABLE IF EXISTS ` + FlashcardsTable + `;
+	`,
+	},
+	// Create vocabulary quizzes generated from the words table and the
+	// results of their items
+	{
+		Version: 18,
+		Name:    "create_vocab_quizzes",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + VocabQuizzesTable + ` (
+				` + VocabQuizzesIDField + ` SERIAL PRIMARY KEY,
+				` + VocabQuizzesUserField + ` TEXT NOT NULL,
+				` + VocabQuizzesItemsField + ` JSONB NOT NULL,
+				` + VocabQuizzesCreatedAtField + ` TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS ` + VocabQuizResultsTable + ` (
+				` + VocabQuizResultsIDField + ` SERIAL PRIMARY KEY,
+				` + VocabQuizResultsUserField + ` TEXT NOT NULL,
+				` + VocabQuizResultsQuizField + ` INT NOT NULL REFERENCES ` + VocabQuizzesTable + `(` + VocabQuizzesIDField + `) ON DELETE CASCADE,
+				` + VocabQuizResultsItemField + ` INT NOT NULL,
+				` + VocabQuizResultsWordField + ` INT NOT NULL REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				` + VocabQuizResultsKindField + ` TEXT NOT NULL,
+				` + VocabQuizResultsCorrectField + ` BOOLEAN NOT NULL,
+				` + VocabQuizResultsAnsweredAtField + ` TIMESTAMP NOT NULL,
+				UNIQUE (` + VocabQuizResultsQuizField + `, ` + VocabQuizResultsItemField + `)
+		);
+	`,
+		Down: `
+		DROP TABLE IF EXISTS ` + VocabQuizResultsTable + `;
+		DROP TABLE IF EXISTS ` + VocabQuizzesTable + `;
+	`,
+	},
+	// Create the relations between words. Relations go both ways and are
+	// stored once, from the word with the lowest id.
+	{
+		Version: 19,
+		Name:    "create_word_relations",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + WordRelationsTable + ` (
+				` + WordRelationsIDField + ` SERIAL PRIMARY KEY,
+				` + WordRelationsWordField + ` INT NOT NULL REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				` + WordRelationsRelatedWordField + ` INT NOT NULL REFERENCES ` + WordsTable + `(` + WordsIDField + `) ON DELETE CASCADE,
+				` + WordRelationsTypeField + ` TEXT NOT NULL,
+				CHECK (` + WordRelationsWordField + ` < ` + WordRelationsRelatedWordField + `),
+				UNIQUE (` + WordRelationsWordField + `, ` + WordRelationsRelatedWordField + `, ` + WordRelationsTypeField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + WordRelationsTable + `;`,
+	},
+	// Words are marked per user in the user marked words table, so the
+	// global marked flag of the words table becomes the featured list
+	{
+		Version: 20,
+		Name:    "rename_words_marked_to_featured",
+		Up: `
+		ALTER TABLE ` + WordsTable + ` RENAME COLUMN ` + WordsLegacyMarkedField + ` TO ` + WordsFeaturedField + `;
+	`,
+		Down: `
+		ALTER TABLE ` + WordsTable + ` RENAME COLUMN ` + WordsFeaturedField + ` TO ` + WordsLegacyMarkedField + `;
+	`,
+	},
+	// Add the full-text search documents of words, questions and passages
+	// and the trigram extension used to match misspelled words. Examples
+	// are joined with an immutable wrapper of array_to_string, which is only
+	// stable and cannot be used by generated columns.
+	{
+		Version: 21,
+		Name:    "add_search",
+		Up: `
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+		CREATE OR REPLACE FUNCTION immutable_array_to_string(TEXT[], TEXT) RETURNS TEXT
+			LANGUAGE sql IMMUTABLE AS $$ SELECT array_to_string($1, $2) $$;
+		ALTER TABLE ` + WordsTable + `
+			ADD COLUMN IF NOT EXISTS ` + WordsSearchField + ` TSVECTOR GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + SearchConfig + `', COALESCE(` + WordsWordField + `, '')), 'A') ||
+				setweight(jsonb_to_tsvector('` + SearchConfig + `',
+					jsonb_path_query_array(COALESCE(` + WordsMeaningsField + `, '[]'), '$[*].meaning'), '["string"]'), 'B') ||
+				setweight(to_tsvector('` + SearchConfig + `',
+					COALESCE(immutable_array_to_string(` + WordsExamplesField + `, ' '), '')), 'C')
+			) STORED;
+		ALTER TABLE ` + VerbalQuestionsTable + `
+			ADD COLUMN IF NOT EXISTS ` + VerbalQuestionsSearchField + ` TSVECTOR GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + SearchConfig + `', COALESCE(` + VerbalQuestionsQuestionField + `, '')), 'A') ||
+				setweight(to_tsvector('` + SearchConfig + `', COALESCE(` + VerbalQuestionsParagraphField + `, '')), 'B') ||
+				setweight(jsonb_to_tsvector('` + SearchConfig + `',
+					jsonb_path_query_array(COALESCE(` + VerbalQuestionsOptionsField + `, '[]'), '$[*].value'), '["string"]'), 'C')
+			) STORED;
+		ALTER TABLE ` + PassagesTable + `
+			ADD COLUMN IF NOT EXISTS ` + PassagesSearchField + ` TSVECTOR GENERATED ALWAYS AS (
+				setweight(to_tsvector('` + SearchConfig + `', ` + PassagesTextField + `), 'B')
+			) STORED;
+	`,
+		Down: `
+		ALTER TABLE ` + PassagesTable + ` DROP COLUMN IF EXISTS ` + PassagesSearchField + `;
+		ALTER TABLE ` + VerbalQuestionsTable + ` DROP COLUMN IF EXISTS ` + VerbalQuestionsSearchField + `;
+		ALTER TABLE ` + WordsTable + ` DROP COLUMN IF EXISTS ` + WordsSearchField + `;
+		DROP FUNCTION IF EXISTS immutable_array_to_string(TEXT[], TEXT);
+	`,
+	},
+	// Create needed indexes for querying and improving performance
+	{
+		Version: 22,
+		Name:    "create_indexes",
+		Up: `
+		CREATE INDEX IF NOT EXISTS idx_word ON ` + WordsTable + `(` + WordsWordField + `);
+		CREATE INDEX IF NOT EXISTS idx_competence ON ` + VerbalQuestionsTable + `(` + VerbalQuestionsCompetenceField + `);
+		CREATE INDEX IF NOT EXISTS idx_framed_as ON ` + VerbalQuestionsTable + `(` + VerbalQuestionsFramedAsField + `);
+		CREATE INDEX IF NOT EXISTS idx_type ON ` + VerbalQuestionsTable + `(` + VerbalQuestionsTypeField + `);
+		CREATE INDEX IF NOT EXISTS idx_user_token_users ON ` + UsersTable + `(` + UserTokenField + `);
+		CREATE INDEX IF NOT EXISTS idx_user_token_user_marked_words ON ` + UserMarkedWordsTable + `(` + UserMarkedWordsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_user_token_user_marked_verbal_questions ON ` + UserMarkedVerbalQuestionsTable + `(` + UserMarkedVerbalQuestionsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_question_calibrations_question ON ` + QuestionCalibrationsTable + `(` + QuestionCalibrationsQuestionField + `);
+		CREATE INDEX IF NOT EXISTS idx_verbal_stats_question ON ` + VerbalStatsTable + `(` + VerbalStatsQuestionField + `);
+		CREATE INDEX IF NOT EXISTS idx_practice_sessions_user ON ` + PracticeSessionsTable + `(` + PracticeSessionsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_verbal_stats_session ON ` + VerbalStatsTable + `(` + VerbalStatsSessionField + `);
+		CREATE INDEX IF NOT EXISTS idx_mock_exams_user ON ` + MockExamsTable + `(` + MockExamsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_type_irt_b ON ` + VerbalQuestionsTable + `(` + VerbalQuestionsTypeField + `, ` + VerbalQuestionsIRTBField + `);
+		CREATE INDEX IF NOT EXISTS idx_quant_type_irt_b ON ` + QuantQuestionsTable + `(` + QuantQuestionsTypeField + `, ` + QuantQuestionsIRTBField + `);
+		CREATE INDEX IF NOT EXISTS idx_quant_questions_data_set ON ` + QuantQuestionsTable + `(` + QuantQuestionsDataSetField + `);
+		CREATE INDEX IF NOT EXISTS idx_quant_stats_user ON ` + QuantStatsTable + `(` + QuantStatsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_essays_user ON ` + EssaysTable + `(` + EssaysUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_verbal_questions_passage ON ` + VerbalQuestionsTable + `(` + VerbalQuestionsPassageField + `);
+		CREATE INDEX IF NOT EXISTS idx_flashcards_user_due ON ` + FlashcardsTable + `(` + FlashcardsUserField + `, ` + FlashcardsDueAtField + `);
+		CREATE INDEX IF NOT EXISTS idx_flashcard_reviews_user ON ` + FlashcardReviewsTable + `(` + FlashcardReviewsUserField + `, ` + FlashcardReviewsReviewedAtField + `);
+		CREATE INDEX IF NOT EXISTS idx_vocab_quizzes_user ON ` + VocabQuizzesTable + `(` + VocabQuizzesUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_vocab_quiz_results_user ON ` + VocabQuizResultsTable + `(` + VocabQuizResultsUserField + `);
+		CREATE INDEX IF NOT EXISTS idx_word_relations_related ON ` + WordRelationsTable + `(` + WordRelationsRelatedWordField + `);
+		CREATE INDEX IF NOT EXISTS idx_word_relations_type ON ` + WordRelationsTable + `(` + WordRelationsTypeField + `);
+		CREATE INDEX IF NOT EXISTS idx_words_search ON ` + WordsTable + ` USING GIN (` + WordsSearchField + `);
+		CREATE INDEX IF NOT EXISTS idx_words_word_trgm ON ` + WordsTable + ` USING GIN (` + WordsWordField + ` gin_trgm_ops);
+		CREATE INDEX IF NOT EXISTS idx_verbal_questions_search ON ` + VerbalQuestionsTable + ` USING GIN (` + VerbalQuestionsSearchField + `);
+		CREATE INDEX IF NOT EXISTS idx_passages_search ON ` + PassagesTable + ` USING GIN (` + PassagesSearchField + `);
+	`,
+		Down: `
+		DROP INDEX IF EXISTS idx_word;
+		DROP INDEX IF EXISTS idx_competence;
+		DROP INDEX IF EXISTS idx_framed_as;
+		DROP INDEX IF EXISTS idx_type;
+		DROP INDEX IF EXISTS idx_user_token_users;
+		DROP INDEX IF EXISTS idx_user_token_user_marked_words;
+		DROP INDEX IF EXISTS idx_user_token_user_marked_verbal_questions;
+		DROP INDEX IF EXISTS idx_question_calibrations_question;
+		DROP INDEX IF EXISTS idx_verbal_stats_question;
+		DROP INDEX IF EXISTS idx_practice_sessions_user;
+		DROP INDEX IF EXISTS idx_verbal_stats_session;
+		DROP INDEX IF EXISTS idx_mock_exams_user;
+		DROP INDEX IF EXISTS idx_type_irt_b;
+		DROP INDEX IF EXISTS idx_quant_type_irt_b;
+		DROP INDEX IF EXISTS idx_quant_questions_data_set;
+		DROP INDEX IF EXISTS idx_quant_stats_user;
+		DROP INDEX IF EXISTS idx_essays_user;
+		DROP INDEX IF EXISTS idx_verbal_questions_passage;
+		DROP INDEX IF EXISTS idx_flashcards_user_due;
+		DROP INDEX IF EXISTS idx_flashcard_reviews_user;
+		DROP INDEX IF EXISTS idx_vocab_quizzes_user;
+		DROP INDEX IF EXISTS idx_vocab_quiz_results_user;
+		DROP INDEX IF EXISTS idx_word_relations_related;
+		DROP INDEX IF EXISTS idx_word_relations_type;
+		DROP INDEX IF EXISTS idx_words_search;
+		DROP INDEX IF EXISTS idx_words_word_trgm;
+		DROP INDEX IF EXISTS idx_verbal_questions_search;
+		DROP INDEX IF EXISTS idx_passages_search;
+	`,
+	},
+	// The featured words index was created under the name of the words
+	// index, so it never existed. The words index gets its own name.
+	{
+		Version: 23,
+		Name:    "fix_words_indexes",
+		Up: `
+		ALTER INDEX IF EXISTS idx_word RENAME TO idx_words_word;
+		CREATE INDEX IF NOT EXISTS idx_words_featured ON ` + WordsTable + `(` + WordsFeaturedField + `) WHERE ` + WordsFeaturedField + `=TRUE;
+	`,
+		Down: `
+		DROP INDEX IF EXISTS idx_words_featured;
+		ALTER INDEX IF EXISTS idx_words_word RENAME TO idx_word;
+	`,
+	},
+	// Create the roles granted to users in addition to their Cognito groups
+	{
+		Version: 24,
+		Name:    "create_user_roles",
+		Up: `
+		CREATE TABLE IF NOT EXISTS ` + UserRolesTable + ` (
+				` + UserRolesIDField + ` SERIAL PRIMARY KEY,
+				` + UserRolesUserField + ` TEXT NOT NULL,
+				` + UserRolesRoleField + ` TEXT NOT NULL,
+				` + UserRolesGrantedByField + ` TEXT NOT NULL,
+				` + UserRolesGrantedAtField + ` TIMESTAMP NOT NULL,
+				UNIQUE (` + UserRolesUserField + `, ` + UserRolesRoleField + `)
+		);
+	`,
+		Down: `DROP TABLE IF EXISTS ` + UserRolesTable + `;`,
+	},
+	// Migration 8 gave every single answer question a guessing parameter of
+	// 0.2, while new questions get one over their number of options. The
+	// seeded parameter is replaced, calibrations keep the guessing parameter
+	// so it is still the seeded one. The seeded parameter was wrong, so it is
+	// not restored.
+	{
+		Version: 25,
+		Name:    "fix_verbal_questions_irt_c",
+		Up: `
+		UPDATE ` + VerbalQuestionsTable + ` SET
+			` + VerbalQuestionsIRTCField + ` = 1.0 / jsonb_array_length(` + VerbalQuestionsOptionsField + `)
+		WHERE ` + VerbalQuestionsFramedAsField + ` = 1
+			AND ` + VerbalQuestionsIRTCField + ` = 0.2
+			AND jsonb_typeof(` + VerbalQuestionsOptionsField + `) = 'array'
+			AND jsonb_array_length(` + VerbalQuestionsOptionsField + `) > 0;
+	`,
+		Down: `SELECT 1;`,
+	},
+	// Quant questions and data sets are soft deleted like verbal questions,
+	// so that the quant stats recorded for them are kept
+	{
+		Version: 26,
+		Name:    "add_quant_deleted_at",
+		Up: `
+		ALTER TABLE ` + QuantQuestionsTable + ` ADD COLUMN IF NOT EXISTS ` + QuantQuestionsDeletedAtField + ` TIMESTAMP;
+		ALTER TABLE ` + QuantDataSetsTable + ` ADD COLUMN IF NOT EXISTS ` + QuantDataSetsDeletedAtField + ` TIMESTAMP;
+	`,
+		Down: `
+		ALTER TABLE ` + QuantDataSetsTable + ` DROP COLUMN IF EXISTS ` + QuantDataSetsDeletedAtField + `;
+		ALTER TABLE ` + QuantQuestionsTable + ` DROP COLUMN IF EXISTS ` + QuantQuestionsDeletedAtField + `;
+	`,
+	},
+}
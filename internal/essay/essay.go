@@ -0,0 +1,215 @@
+/**
+* Package essay implements a deterministic rubric scorer for analytical
+* writing essays. The score is an estimate on the 0 to 6 scale of the GRE
+* Analytical Writing section built from length, paragraphing, vocabulary
+* sophistication and the use of transitions. Scoring runs entirely offline.
+**/
+package essay
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"grepandit.com/api/internal/nlp"
+)
+
+// Weights of each component in the final score
+const (
+	lengthWeight       = 0.35
+	paragraphingWeight = 0.2
+	vocabularyWeight   = 0.25
+	transitionsWeight  = 0.2
+)
+
+// Essays at or above these targets get full marks for the component
+const (
+	targetWords              = 500
+	minimumWords             = 100
+	targetParagraphs         = 5
+	targetSophisticatedRatio = 0.05
+	targetTransitions        = 8
+)
+
+// Essays shorter than this can not be scored above 1
+const scorableWords = 50
+
+// Transition words and phrases that signal the structure of an argument
+var transitions = []string{
+	"accordingly", "additionally", "admittedly", "also", "although",
+	"as a result", "because", "besides", "but", "certainly",
+	"consequently", "conversely", "despite", "even so", "finally",
+	"first", "firstly", "for example", "for instance", "furthermore",
+	"hence", "however", "in addition", "in conclusion", "in contrast",
+	"in fact", "in other words", "in particular", "in short", "indeed",
+	"instead", "likewise", "meanwhile", "moreover", "nevertheless",
+	"nonetheless", "notably", "on the contrary", "on the other hand",
+	"otherwise", "second", "secondly", "similarly", "specifically",
+	"still", "subsequently", "therefore", "third", "thus", "ultimately",
+	"whereas", "while", "yet",
+}
+
+/**
+* Decides whether a word counts towards vocabulary sophistication. The
+* scorer only passes lowercase words and each distinct word once.
+**/
+type Lexicon func(word string) bool
+
+/**
+* Result of scoring an essay. Each component is normalized between 0 and
+* 1 and Estimate is the weighted score on the 0 to 6 scale in half point
+* steps.
+**/
+type Score struct {
+	Estimate           float64  `json:"estimate"`
+	Length             float64  `json:"length"`
+	Paragraphing       float64  `json:"paragraphing"`
+	Vocabulary         float64  `json:"vocabulary"`
+	Transitions        float64  `json:"transitions"`
+	WordCount          int      `json:"word_count"`
+	ParagraphCount     int      `json:"paragraph_count"`
+	SentenceCount      int      `json:"sentence_count"`
+	LexicalDiversity   float64  `json:"lexical_diversity"`
+	SophisticatedWords []string `json:"sophisticated_words"`
+	TransitionsUsed    []string `json:"transitions_used"`
+}
+
+/**
+* Scores an essay. Sophisticated words are the distinct words of the essay
+* accepted by the lexicon, which may be nil.
+**/
+func Evaluate(text string, lexicon Lexicon) Score {
+	words := Words(text)
+	paragraphs := Paragraphs(text)
+	score := Score{
+		WordCount:          len(words),
+		ParagraphCount:     len(paragraphs),
+		SentenceCount:      countSentences(text),
+		SophisticatedWords: make([]string, 0),
+		TransitionsUsed:    make([]string, 0),
+	}
+	if len(words) == 0 {
+		return score
+	}
+	// Vocabulary is measured on distinct words
+	distinct := make(map[string]struct{})
+	for _, w := range words {
+		distinct[w] = struct{}{}
+	}
+	if lexicon != nil {
+		for w := range distinct {
+			if lexicon(w) {
+				score.SophisticatedWords = append(score.SophisticatedWords, w)
+			}
+		}
+		sort.Strings(score.SophisticatedWords)
+	}
+	score.LexicalDiversity = lexicalDiversity(words)
+	score.TransitionsUsed = findTransitions(words)
+
+	score.Length = clamp(float64(len(words)-minimumWords) / (targetWords - minimumWords))
+	score.Paragraphing = clamp(float64(len(paragraphs)-1) / (targetParagraphs - 1))
+	sophistication := clamp(float64(len(score.SophisticatedWords)) / float64(len(distinct)) / targetSophisticatedRatio)
+	diversity := clamp((score.LexicalDiversity - 0.3) / 0.3)
+	score.Vocabulary = (sophistication + diversity) / 2
+	score.Transitions = clamp(float64(len(score.TransitionsUsed)) / targetTransitions)
+
+	weighted := lengthWeight*score.Length +
+		paragraphingWeight*score.Paragraphing +
+		vocabularyWeight*score.Vocabulary +
+		transitionsWeight*score.Transitions
+	estimate := math.Round(weighted*6*2) / 2
+	if len(words) < scorableWords {
+		estimate = math.Min(estimate, 1)
+	}
+	score.Estimate = estimate
+	return score
+}
+
+/**
+* Splits text into lowercase words. Apostrophes and hyphens within a word
+* are kept so that contractions and compound words count once.
+**/
+func Words(text string) []string {
+	words := nlp.Tokenize(text)
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	return words
+}
+
+// Splits text into non empty paragraphs separated by line breaks
+func Paragraphs(text string) []string {
+	paragraphs := make([]string, 0)
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(p) != "" {
+			paragraphs = append(paragraphs, strings.TrimSpace(p))
+		}
+	}
+	return paragraphs
+}
+
+// Counts the sentences of a text by their terminal punctuation
+func countSentences(text string) int {
+	count := 0
+	inSentence := false
+	for _, r := range text {
+		switch {
+		case r == '.' || r == '!' || r == '?':
+			if inSentence {
+				count++
+			}
+			inSentence = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+	}
+	if inSentence {
+		count++
+	}
+	return count
+}
+
+/**
+* Ratio of distinct words to words measured over windows of 100 words so
+* that the diversity of long essays is not penalized for their length.
+**/
+func lexicalDiversity(words []string) float64 {
+	const window = 100
+	if len(words) <= window {
+		return distinctRatio(words)
+	}
+	total := 0.0
+	windows := 0
+	for start := 0; start+window <= len(words); start += window {
+		total += distinctRatio(words[start : start+window])
+		windows++
+	}
+	return total / float64(windows)
+}
+
+func distinctRatio(words []string) float64 {
+	distinct := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		distinct[w] = struct{}{}
+	}
+	return float64(len(distinct)) / float64(len(words))
+}
+
+// Returns the distinct transitions used in the essay in alphabetical order
+func findTransitions(words []string) []string {
+	joined := " " + strings.Join(words, " ") + " "
+	used := make([]string, 0)
+	for _, t := range transitions {
+		if strings.Contains(joined, " "+t+" ") {
+			used = append(used, t)
+		}
+	}
+	sort.Strings(used)
+	return used
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
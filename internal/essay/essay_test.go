@@ -0,0 +1,92 @@
+package essay
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The author's claim", []string{"the", "author's", "claim"}},
+		{"A well-known \"fact\".", []string{"a", "well-known", "fact"}},
+		{"Ends with - dash and 'quotes'", []string{"ends", "with", "dash", "and", "quotes"}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Words(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Words(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	got := Paragraphs("First paragraph.\r\n\r\n  \nSecond paragraph.\nThird.")
+	if len(got) != 3 {
+		t.Fatalf("Paragraphs returned %d paragraphs, want 3: %v", len(got), got)
+	}
+}
+
+func TestEvaluateEmpty(t *testing.T) {
+	score := Evaluate("   ", nil)
+	if score.Estimate != 0 || score.WordCount != 0 {
+		t.Errorf("Evaluate of an empty essay = %+v, want a zero score", score)
+	}
+}
+
+func TestEvaluateIsDeterministic(t *testing.T) {
+	text := sampleEssay(5, 20)
+	lexicon := func(w string) bool { return w == "ubiquitous" || w == "pragmatic" }
+	first := Evaluate(text, lexicon)
+	for i := 0; i < 5; i++ {
+		if got := Evaluate(text, lexicon); !reflect.DeepEqual(got, first) {
+			t.Fatalf("Evaluate is not deterministic: %+v != %+v", got, first)
+		}
+	}
+	if !reflect.DeepEqual(first.SophisticatedWords, []string{"pragmatic", "ubiquitous"}) {
+		t.Errorf("SophisticatedWords = %v", first.SophisticatedWords)
+	}
+}
+
+func TestEvaluateRewardsDevelopedEssays(t *testing.T) {
+	lexicon := func(w string) bool { return w == "ubiquitous" || w == "pragmatic" }
+	short := Evaluate(sampleEssay(1, 2), lexicon)
+	developed := Evaluate(sampleEssay(5, 12), lexicon)
+	if short.Estimate > 1 {
+		t.Errorf("short essay scored %v, want at most 1", short.Estimate)
+	}
+	if developed.Estimate <= short.Estimate {
+		t.Errorf("developed essay scored %v, not above short essay %v", developed.Estimate, short.Estimate)
+	}
+	if developed.Estimate < 0 || developed.Estimate > 6 {
+		t.Errorf("estimate %v outside of the 0 to 6 scale", developed.Estimate)
+	}
+	if developed.ParagraphCount != 5 || developed.Paragraphing != 1 {
+		t.Errorf("paragraphing = %v with %d paragraphs", developed.Paragraphing, developed.ParagraphCount)
+	}
+	if len(developed.TransitionsUsed) == 0 {
+		t.Error("no transitions found in developed essay")
+	}
+}
+
+// Builds an essay with the given number of paragraphs of varied sentences
+func sampleEssay(paragraphs int, sentences int) string {
+	openers := []string{"However", "Moreover", "For example", "Therefore", "In contrast", "Furthermore", "Consequently", "In conclusion"}
+	subjects := []string{"the city", "a pragmatic council", "every resident", "local business", "this argument", "the survey", "ubiquitous traffic", "public transit"}
+	verbs := []string{"requires", "ignores", "supports", "undermines", "reflects", "challenges", "assumes", "overlooks"}
+	objects := []string{"careful evidence", "several alternatives", "the budget", "long term costs", "reliable data", "community needs", "its premise", "future growth"}
+	var b strings.Builder
+	n := 0
+	for p := 0; p < paragraphs; p++ {
+		for s := 0; s < sentences; s++ {
+			b.WriteString(openers[n%len(openers)] + ", " + subjects[(n/2)%len(subjects)] + " " +
+				verbs[(n/3)%len(verbs)] + " " + objects[(n*5)%len(objects)] + ". ")
+			n++
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type CalibrationHandler struct {
+	Service *services.CalibrationService
+}
+
+func NewCalibrationHandler(s *services.CalibrationService) *CalibrationHandler {
+	return &CalibrationHandler{Service: s}
+}
+
+/**
+* Runs a calibration of the verbal questions from the recorded verbal stats.
+* Proposals are only applied to the questions when apply is set, otherwise
+* they are recorded for review.
+**/
+func (h *CalibrationHandler) Calibrate(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.CalibrationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	calibrations, err := h.Service.Calibrate(ctx, req, u)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to calibrate questions")
+	}
+	return c.JSON(http.StatusOK, calibrations)
+}
+
+/**
+* Retrieves the calibration history. The history can be restricted to a
+* single question with the question_id query parameter.
+**/
+func (h *CalibrationHandler) GetHistory(c echo.Context) error {
+	ctx := c.Request().Context()
+	questionID := 0
+	if param := c.QueryParam("question_id"); param != "" {
+		id, err := strconv.Atoi(param)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid question ID")
+		}
+		questionID = id
+	}
+	limit := 100
+	if param := c.QueryParam("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = l
+	}
+	calibrations, err := h.Service.GetHistory(ctx, questionID, limit)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get calibrations")
+	}
+	return c.JSON(http.StatusOK, calibrations)
+}
+
+// Applies a recorded calibration proposal to its question
+func (h *CalibrationHandler) Apply(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	calibration, err := h.Service.ApplyByID(ctx, id, u)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Calibration not found with id "+c.Param("id"))
+		}
+		if errors.Is(err, services.ErrCalibrationApplied) || errors.Is(err, services.ErrCalibrationStale) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to apply calibration")
+	}
+	return c.JSON(http.StatusOK, calibration)
+}
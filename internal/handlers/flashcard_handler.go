@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+	"grepandit.com/api/internal/srs"
+)
+
+type FlashcardHandler struct {
+	Service *services.FlashcardService
+}
+
+func NewFlashcardHandler(s *services.FlashcardService) *FlashcardHandler {
+	return &FlashcardHandler{Service: s}
+}
+
+// Retrieves the cards to review now, at most limit of them (50 by default)
+func (h *FlashcardHandler) GetQueue(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	limit := 50
+	if param := c.QueryParam("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = l
+	}
+	queue, err := h.Service.GetQueue(c.Request().Context(), u.Token, limit)
+	if err != nil {
+		return flashcardError(err, "Failed to get review queue")
+	}
+	return c.JSON(http.StatusOK, queue)
+}
+
+// Grades the review of the card of a word with again, hard, good or easy
+func (h *FlashcardHandler) Grade(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	wordID, err := strconv.Atoi(c.Param("word_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid word ID")
+	}
+	var req models.FlashcardGradeRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	grade, err := srs.ParseGrade(req.Grade)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid grade. Must be again, hard, good or easy")
+	}
+	card, err := h.Service.Grade(c.Request().Context(), u.Token, wordID, grade)
+	if err != nil {
+		return flashcardError(err, "Failed to grade card")
+	}
+	return c.JSON(http.StatusOK, card)
+}
+
+// Retrieves the review statistics over the last days (30 by default)
+func (h *FlashcardHandler) GetStats(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	days := 30
+	if param := c.QueryParam("days"); param != "" {
+		d, err := strconv.Atoi(param)
+		if err != nil || d <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid days")
+		}
+		days = d
+	}
+	stats, err := h.Service.GetStats(c.Request().Context(), u.Token, days)
+	if err != nil {
+		return flashcardError(err, "Failed to get flashcard stats")
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+func (h *FlashcardHandler) GetSettings(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	settings, err := h.Service.GetSettings(c.Request().Context(), u.Token)
+	if err != nil {
+		return flashcardError(err, "Failed to get flashcard settings")
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// Updates the daily limit of new cards of the user
+func (h *FlashcardHandler) UpdateSettings(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var settings models.FlashcardSettings
+	if err := c.Bind(&settings); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	err = h.Service.UpdateSettings(c.Request().Context(), u.Token, &settings)
+	if err != nil {
+		return flashcardError(err, "Failed to update flashcard settings")
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+// Maps the errors of the flashcard service to HTTP errors
+func flashcardError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrInvalidFlashcardSettings), errors.Is(err, srs.ErrInvalidGrade):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrNewCardLimit):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
@@ -2,36 +2,45 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	customMiddleware "grepandit.com/api/internal/middleware"
 	"grepandit.com/api/internal/models"
 )
 
 /**
-* Extract user info as string values from the passed access
-* token
+* Extract user info from the verified token. Access tokens carry no email,
+* so the email is empty for them
 **/
 func getUserClaims(c echo.Context) (models.User, error) {
-	claims := c.Get("user")
-	if claims == nil {
-		return models.User{}, echo.NewHTTPError(http.StatusUnauthorized, "No user context available")
-	}
-	claimsMap, ok := claims.(jwt.MapClaims)
-	if !ok {
-		return models.User{}, echo.NewHTTPError(http.StatusInternalServerError, "User context is of the wrong type")
-	}
-	sub, subExists := claimsMap["sub"]
-	if !subExists {
-		return models.User{}, echo.NewHTTPError(http.StatusInternalServerError, "No user ID available in the user context")
-	}
-	email, emailExists := claimsMap["email"]
-	if !emailExists {
-		return models.User{}, echo.NewHTTPError(http.StatusInternalServerError, "No email available in the user context")
+	claims, err := customMiddleware.UserClaims(c)
+	if err != nil {
+		return models.User{}, err
 	}
 	u := models.User{
-		Token: sub.(string),
-		Email: email.(string),
+		Token: claims.Subject,
+		Email: claims.Email,
 	}
 	return u, nil
 }
+
+/**
+* Parses a list of ids passed as a query parameter. The list can be
+* sent either as "[31,63]" or as "31,63".
+**/
+func parseIDList(param string) ([]int, error) {
+	idStrings := strings.Split(strings.Trim(param, "[]"), ",")
+	ids := make([]int, 0, len(idStrings))
+	for _, idString := range idStrings {
+		if len(strings.TrimSpace(idString)) > 0 {
+			id, err := strconv.Atoi(strings.TrimSpace(idString))
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID "+idString)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/auth"
+)
+
+/**
+* Routes of the local identity provider. They are only registered when
+* AUTH_PROVIDER is local, which is refused outside of dev and test.
+**/
+type LocalAuthHandler struct {
+	Issuer *auth.LocalIssuer
+}
+
+func NewLocalAuthHandler(issuer *auth.LocalIssuer) *LocalAuthHandler {
+	return &LocalAuthHandler{Issuer: issuer}
+}
+
+// Mints a token for the test user of the request body
+func (h *LocalAuthHandler) Token(c echo.Context) error {
+	var req auth.TokenRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	token, err := h.Issuer.Mint(req)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, auth.ErrInvalidTokenRequest) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to mint token")
+	}
+	return c.JSON(http.StatusCreated, token)
+}
+
+// Public keys of the local issuer
+func (h *LocalAuthHandler) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Issuer.Keys())
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/services"
+)
+
+type MockExamHandler struct {
+	Service *services.MockExamService
+}
+
+func NewMockExamHandler(s *services.MockExamService) *MockExamHandler {
+	return &MockExamHandler{Service: s}
+}
+
+/**
+* Starts a mock verbal exam. The questions of the first section are served
+* through the session referenced by section1_session_id.
+**/
+func (h *MockExamHandler) Start(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	exam, err := h.Service.Start(ctx, u.Token)
+	if err != nil {
+		return mockExamError(err, "Failed to start mock exam")
+	}
+	return c.JSON(http.StatusCreated, exam)
+}
+
+// Retrieves every mock exam of the user
+func (h *MockExamHandler) GetByUserToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	exams, err := h.Service.GetByUserToken(ctx, u.Token)
+	if err != nil {
+		return mockExamError(err, "Failed to get mock exams")
+	}
+	return c.JSON(http.StatusOK, exams)
+}
+
+// Retrieves a single mock exam of the user
+func (h *MockExamHandler) Get(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	exam, err := h.Service.Get(ctx, u.Token, id)
+	if err != nil {
+		return mockExamError(err, "Failed to get mock exam")
+	}
+	return c.JSON(http.StatusOK, exam)
+}
+
+/**
+* Ends the first section and starts the second section, whose difficulty
+* depends on the performance in the first section.
+**/
+func (h *MockExamHandler) NextSection(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	exam, err := h.Service.NextSection(ctx, u.Token, id)
+	if err != nil {
+		return mockExamError(err, "Failed to start the next section")
+	}
+	return c.JSON(http.StatusOK, exam)
+}
+
+// Ends the mock exam and returns its scaled score estimate
+func (h *MockExamHandler) Finish(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	exam, err := h.Service.Finish(ctx, u.Token, id)
+	if err != nil {
+		return mockExamError(err, "Failed to finish mock exam")
+	}
+	return c.JSON(http.StatusOK, exam)
+}
+
+// Maps errors returned by the mock exam service to HTTP errors
+func mockExamError(err error, message string) error {
+	switch {
+	case errors.Is(err, services.ErrInsufficientQuestions):
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, services.ErrMockExamState):
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return sessionError(err, message)
+}
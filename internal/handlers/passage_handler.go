@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type PassageHandler struct {
+	Service *services.PassageService
+}
+
+func NewPassageHandler(s *services.PassageService) *PassageHandler {
+	return &PassageHandler{Service: s}
+}
+
+// Creates a new reading passage along with its vocabulary
+func (h *PassageHandler) Create(c echo.Context) error {
+	var p models.PassageRequest
+	if err := c.Bind(&p); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	err := h.Service.Create(c.Request().Context(), &p)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidPassage) || errors.Is(err, services.ErrUnknownWord) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create passage")
+	}
+	return c.JSON(http.StatusCreated, p)
+}
+
+// Retrieves a passage with the set of questions that belong to it
+func (h *PassageHandler) Get(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	p, err := h.Service.GetByID(c.Request().Context(), id)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Passage not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get passage")
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+/**
+* Retrieves a passage set at random. Passages that were already served
+* can be excluded with the exclude query parameter.
+**/
+func (h *PassageHandler) GetRandom(c echo.Context) error {
+	excludeIDs, err := parseIDList(c.QueryParam("exclude"))
+	if err != nil {
+		return err
+	}
+	p, err := h.Service.Random(c.Request().Context(), excludeIDs)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "No passage available")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get passage")
+	}
+	return c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type PracticeSessionHandler struct {
+	Service *services.PracticeSessionService
+}
+
+func NewPracticeSessionHandler(s *services.PracticeSessionService) *PracticeSessionHandler {
+	return &PracticeSessionHandler{Service: s}
+}
+
+/**
+* Starts a new practice session for the user with the criteria passed in
+* the request payload.
+**/
+func (h *PracticeSessionHandler) Start(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var criteria models.SessionCriteria
+	if err := c.Bind(&criteria); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	session, err := h.Service.Start(ctx, u.Token, criteria)
+	if err != nil {
+		return sessionError(err, "Failed to start session")
+	}
+	return c.JSON(http.StatusCreated, session)
+}
+
+// Retrieves every practice session of the user
+func (h *PracticeSessionHandler) GetByUserToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	sessions, err := h.Service.GetByUserToken(ctx, u.Token)
+	if err != nil {
+		return sessionError(err, "Failed to get sessions")
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// Retrieves a single practice session of the user
+func (h *PracticeSessionHandler) Get(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	session, err := h.Service.Get(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to get session")
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+/**
+* Retrieves the question waiting for an answer in the session. A new
+* question is selected once the previous one has been answered.
+**/
+func (h *PracticeSessionHandler) Next(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	question, session, err := h.Service.Next(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to get next question")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"question": question,
+		"session":  session,
+	})
+}
+
+// Grades and records the answer to the current question of the session
+func (h *PracticeSessionHandler) Answer(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	var req models.SessionAnswerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.QuestionID <= 0 || len(req.Answers) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body. Requires question_id and answers")
+	}
+	answer, err := h.Service.Answer(ctx, u.Token, id, req)
+	if err != nil {
+		return sessionError(err, "Failed to answer question")
+	}
+	return c.JSON(http.StatusCreated, answer)
+}
+
+// Pauses an active practice session
+func (h *PracticeSessionHandler) Pause(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	session, err := h.Service.Pause(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to pause session")
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+// Resumes a paused practice session
+func (h *PracticeSessionHandler) Resume(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	session, err := h.Service.Resume(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to resume session")
+	}
+	return c.JSON(http.StatusOK, session)
+}
+
+// Finishes a practice session and returns its summary
+func (h *PracticeSessionHandler) Finish(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	summary, err := h.Service.Finish(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to finish session")
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// Retrieves the summary of a practice session
+func (h *PracticeSessionHandler) Summary(c echo.Context) error {
+	ctx := c.Request().Context()
+	u, id, err := sessionParams(c)
+	if err != nil {
+		return err
+	}
+	summary, err := h.Service.Summary(ctx, u.Token, id)
+	if err != nil {
+		return sessionError(err, "Failed to get session summary")
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// Extracts the user and the session ID from the request
+func sessionParams(c echo.Context) (models.User, int, error) {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return u, 0, err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return u, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	return u, id, nil
+}
+
+// Maps errors returned by the practice session service to HTTP errors
+func sessionError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Session or question not found")
+	case errors.Is(err, services.ErrInvalidSessionCriteria), errors.Is(err, services.ErrInvalidAnswers):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrSessionNotActive),
+		errors.Is(err, services.ErrSessionNotPaused),
+		errors.Is(err, services.ErrSessionNotPausable),
+		errors.Is(err, services.ErrSessionComplete),
+		errors.Is(err, services.ErrNoPendingQuestion),
+		errors.Is(err, services.ErrQuestionNotCurrent):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, message)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/auth"
+	customMiddleware "grepandit.com/api/internal/middleware"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+	"grepandit.com/api/internal/services"
+)
+
+/**
+* Serves the practice session routes with the in-memory store behind the
+* JWT middleware and returns a function that sends authenticated requests
+* as the user u1.
+**/
+func practiceSessionServer(t *testing.T) func(method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	m := repository.NewMemory()
+	m.AddWord(models.Word{ID: 1, Word: "terse"})
+	m.AddQuestion(models.VerbalQuestion{ID: 1, Type: models.TextCompletion, FramedAs: models.MCQSingleAnswer,
+		Options: []models.Option{{Value: "terse", Correct: true}, {Value: "verbose"}}, IRT: models.IRTParams{A: 1}}, 1)
+	store := m.Store()
+	if err := store.Users.Create(context.Background(), &models.User{Token: "u1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := auth.NewLocalIssuer(auth.DefaultLocalIssuer, key)
+	if err != nil {
+		t.Fatalf("NewLocalIssuer() error = %v", err)
+	}
+	token, err := issuer.Mint(auth.TokenRequest{Subject: "u1"})
+	if err != nil {
+		t.Fatalf("Mint() error = %v", err)
+	}
+
+	e := echo.New()
+	h := NewPracticeSessionHandler(&services.PracticeSessionService{Store: store})
+	g := e.Group("/sessions", customMiddleware.JWTAuthMiddleware(issuer.Verifier()))
+	g.POST("", h.Start)
+	g.GET("/:id", h.Get)
+	g.GET("/:id/next", h.Next)
+	g.POST("/:id/answers", h.Answer)
+	g.GET("/:id/summary", h.Summary)
+	return func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+func TestPracticeSessionHandler(t *testing.T) {
+	serve := practiceSessionServer(t)
+	rec := serve(http.MethodPost, "/sessions", `{"length": 0}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /sessions with no length = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = serve(http.MethodPost, "/sessions", `{"type": "TextCompletion", "length": 1}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /sessions = %d %s, want %d", rec.Code, rec.Body, http.StatusCreated)
+	}
+	var session models.PracticeSession
+	json.Unmarshal(rec.Body.Bytes(), &session)
+	path := "/sessions/" + strconv.Itoa(session.ID)
+
+	if rec = serve(http.MethodGet, "/sessions/999", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /sessions/999 = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec = serve(http.MethodGet, path+"/next", ""); rec.Code != http.StatusOK {
+		t.Fatalf("GET next = %d %s, want %d", rec.Code, rec.Body, http.StatusOK)
+	}
+	rec = serve(http.MethodPost, path+"/answers", `{"question_id": 2, "answers": ["terse"]}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("POST answers to another question = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = serve(http.MethodPost, path+"/answers", `{"question_id": 1, "answers": ["terse"], "duration": 5}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST answers = %d %s, want %d", rec.Code, rec.Body, http.StatusCreated)
+	}
+	if rec = serve(http.MethodGet, path+"/next", ""); rec.Code != http.StatusConflict {
+		t.Errorf("GET next of a finished session = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = serve(http.MethodGet, path+"/summary", "")
+	var summary models.SessionSummary
+	json.Unmarshal(rec.Body.Bytes(), &summary)
+	if rec.Code != http.StatusOK || summary.Accuracy != 1 || summary.Session.Status != models.SessionFinished {
+		t.Errorf("GET summary = %d %+v, want the finished session answered correctly", rec.Code, summary)
+	}
+}
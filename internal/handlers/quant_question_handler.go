@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type QuantQuestionHandler struct {
+	Service *services.QuantQuestionService
+}
+
+func NewQuantQuestionHandler(s *services.QuantQuestionService) *QuantQuestionHandler {
+	return &QuantQuestionHandler{Service: s}
+}
+
+// Creates a new quant question with the data provided in the request payload
+func (h *QuantQuestionHandler) Create(c echo.Context) error {
+	var q models.QuantQuestion
+	if err := c.Bind(&q); err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	err := h.Service.Create(c.Request().Context(), &q)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidQuantQuestion) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create question")
+	}
+	return c.JSON(http.StatusCreated, q)
+}
+
+// Retrieves a quant question by its ID along with its data set
+func (h *QuantQuestionHandler) Get(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	q, err := h.Service.GetByID(c.Request().Context(), id)
+	if err != nil {
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found with id "+c.Param("id"))
+		}
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get question")
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// Replaces the content of a quant question with the one in the payload
+func (h *QuantQuestionHandler) Update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	var req models.QuantQuestion
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	q, err := h.Service.Update(c.Request().Context(), id, &req)
+	if err != nil {
+		return quantQuestionEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// Changes only the fields of a quant question that are sent in the payload
+func (h *QuantQuestionHandler) Patch(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil || len(patch) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	q, err := h.Service.Patch(c.Request().Context(), id, patch)
+	if err != nil {
+		return quantQuestionEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// Deletes a quant question, the quant stats recorded for it are kept
+func (h *QuantQuestionHandler) Delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	err = h.Service.Delete(c.Request().Context(), id)
+	if err != nil {
+		return quantQuestionEditError(err, id)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Retrieves the quant questions with the ids passed in the ids query parameter
+func (h *QuantQuestionHandler) GetAll(c echo.Context) error {
+	ids, err := parseIDList(c.QueryParam("ids"))
+	if err != nil {
+		return err
+	}
+	questions, err := h.Service.GetByIDs(c.Request().Context(), ids)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get questions")
+	}
+	return c.JSON(http.StatusOK, questions)
+}
+
+func (h *QuantQuestionHandler) GetRandomQuestions(c echo.Context) error {
+	req := models.QuantRandomQuestionsRequest{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if req.Limit <= 0 {
+		req.Limit = 5
+	}
+	questions, err := h.Service.Random(c.Request().Context(), req.Limit, req.Type, req.Topic, req.Difficulty, req.ExcludeIDs)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return c.JSON(http.StatusNotFound, "No more questions for given criteria")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve random questions")
+	}
+	return c.JSON(http.StatusOK, questions)
+}
+
+/**
+* Retrieves a question of each quant question type selected for the
+* current quant ability of the user. Questions to avoid are passed in
+* the questions query parameter.
+**/
+func (h *QuantQuestionHandler) GetAdaptiveQuestions(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	excludeIDs, err := parseIDList(c.QueryParam("questions"))
+	if err != nil {
+		return err
+	}
+	questions, err := h.Service.GetAdaptiveQuestions(c.Request().Context(), u.Token, 5, excludeIDs)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return c.JSON(http.StatusNotFound, "No more questions for given criteria")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve adaptive questions")
+	}
+	return c.JSON(http.StatusOK, questions)
+}
+
+// Creates a data interpretation set along with its questions
+func (h *QuantQuestionHandler) CreateDataSet(c echo.Context) error {
+	var ds models.QuantDataSet
+	if err := c.Bind(&ds); err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	err := h.Service.CreateDataSet(c.Request().Context(), &ds)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidQuantQuestion) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create data set")
+	}
+	return c.JSON(http.StatusCreated, ds)
+}
+
+// Retrieves a data interpretation set with all of its questions
+func (h *QuantQuestionHandler) GetDataSet(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	ds, err := h.Service.GetDataSet(c.Request().Context(), id)
+	if err != nil {
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Data set not found with id "+c.Param("id"))
+		}
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get data set")
+	}
+	return c.JSON(http.StatusOK, ds)
+}
+
+// Replaces the title, description and chart of a data set with the ones in the payload
+func (h *QuantQuestionHandler) UpdateDataSet(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	var req models.QuantDataSet
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	ds, err := h.Service.UpdateDataSet(c.Request().Context(), id, &req)
+	if err != nil {
+		return dataSetEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, ds)
+}
+
+// Changes only the fields of a data set that are sent in the payload
+func (h *QuantQuestionHandler) PatchDataSet(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil || len(patch) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	ds, err := h.Service.PatchDataSet(c.Request().Context(), id, patch)
+	if err != nil {
+		return dataSetEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, ds)
+}
+
+// Deletes a data set along with all of its questions
+func (h *QuantQuestionHandler) DeleteDataSet(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	err = h.Service.DeleteDataSet(c.Request().Context(), id)
+	if err != nil {
+		return dataSetEditError(err, id)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func quantQuestionEditError(err error, id int) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found with id "+strconv.Itoa(id))
+	case errors.Is(err, services.ErrInvalidQuantQuestion):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update question")
+	}
+}
+
+func dataSetEditError(err error, id int) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Data set not found with id "+strconv.Itoa(id))
+	case errors.Is(err, services.ErrInvalidQuantQuestion):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update data set")
+	}
+}
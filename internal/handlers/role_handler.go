@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	customMiddleware "grepandit.com/api/internal/middleware"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type RoleHandler struct {
+	Service *services.RoleService
+}
+
+func NewRoleHandler(s *services.RoleService) *RoleHandler {
+	return &RoleHandler{Service: s}
+}
+
+// Retrieves the roles and permissions of the authenticated user
+func (h *RoleHandler) GetMine(c echo.Context) error {
+	claims, err := customMiddleware.UserClaims(c)
+	if err != nil {
+		return err
+	}
+	access, err := h.Service.Access(c.Request().Context(), claims.Subject, claims.Groups)
+	if err != nil {
+		return roleError(err, "Failed to get roles")
+	}
+	return c.JSON(http.StatusOK, access)
+}
+
+/**
+* Retrieves the roles granted to the user of the token path parameter. The
+* roles that come from the Cognito groups of the user are not known until
+* the user signs in, so they are not included.
+**/
+func (h *RoleHandler) GetByUserToken(c echo.Context) error {
+	access, err := h.Service.Access(c.Request().Context(), c.Param("token"), nil)
+	if err != nil {
+		return roleError(err, "Failed to get roles")
+	}
+	return c.JSON(http.StatusOK, access)
+}
+
+// Grants the role of the request body to the user of the token path parameter
+func (h *RoleHandler) Grant(c echo.Context) error {
+	admin, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.RoleRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	granted, err := h.Service.Grant(c.Request().Context(), c.Param("token"), req.Role, admin.Token)
+	if err != nil {
+		return roleError(err, "Failed to grant role")
+	}
+	return c.JSON(http.StatusCreated, granted)
+}
+
+// Revokes the role path parameter from the user of the token path parameter
+func (h *RoleHandler) Revoke(c echo.Context) error {
+	admin, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	err = h.Service.Revoke(c.Request().Context(), c.Param("token"), models.Role(c.Param("role")), admin.Token)
+	if err != nil {
+		return roleError(err, "Failed to revoke role")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func roleError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrInvalidRole):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type SearchHandler struct {
+	Service *services.SearchService
+}
+
+func NewSearchHandler(s *services.SearchService) *SearchHandler {
+	return &SearchHandler{Service: s}
+}
+
+/**
+* Searches questions and words. Takes the q, scope, type, competence,
+* difficulty, page and limit query parameters. Type, competence and
+* difficulty take the same names as the question JSON, such as
+* type=TextCompletion or difficulty=Hard.
+**/
+func (h *SearchHandler) Search(c echo.Context) error {
+	req := models.SearchRequest{
+		Query: c.QueryParam("q"),
+		Scope: models.SearchScope(c.QueryParam("scope")),
+	}
+	filters := []struct {
+		name  string
+		value json.Unmarshaler
+	}{
+		{"type", &req.Type},
+		{"competence", &req.Competence},
+		{"difficulty", &req.Difficulty},
+	}
+	for _, filter := range filters {
+		if param := c.QueryParam(filter.name); param != "" {
+			if err := filter.value.UnmarshalJSON([]byte(strconv.Quote(param))); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+filter.name)
+			}
+		}
+	}
+	numbers := []struct {
+		name  string
+		value *int
+	}{
+		{"page", &req.Page},
+		{"limit", &req.Limit},
+	}
+	for _, number := range numbers {
+		if param := c.QueryParam(number.name); param != "" {
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid "+number.name)
+			}
+			*number.value = n
+		}
+	}
+	results, err := h.Service.Search(c.Request().Context(), &req)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidSearch) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to search")
+	}
+	return c.JSON(http.StatusOK, results)
+}
@@ -202,3 +202,21 @@ func (h *UserHandler) GetProblematicWordsByUserToken(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, problematicWords)
 }
+
+/**
+* Retrieves the ability estimates of the user for every question type and
+* competence based on the sub claims within the access token
+**/
+func (h *UserHandler) GetAbilitiesByUserToken(c echo.Context) error {
+	ctx := c.Request().Context()
+	user, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	abilities, err := h.Service.GetAbilities(ctx, user.Token)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get abilities")
+	}
+	return c.JSON(http.StatusOK, abilities)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type UserQuantStatHandler struct {
+	Service *services.UserQuantStatsService
+}
+
+func NewUserQuantStatHandler(s *services.UserQuantStatsService) *UserQuantStatHandler {
+	return &UserQuantStatHandler{Service: s}
+}
+
+/**
+* Records the answers of a user for a quant question. The answers are
+* graded on the server and the response contains the grading of each
+* option or the expected answer for numeric entry questions.
+**/
+func (h *UserQuantStatHandler) Create(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var stat models.UserQuantStat
+	if err := c.Bind(&stat); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if stat.QuestionID <= 0 || len(stat.Answers) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body. Requires questionID and answers")
+	}
+	err = h.Service.Create(c.Request().Context(), &stat, u.Token)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidAnswers) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found with id "+strconv.Itoa(stat.QuestionID))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user quant stat")
+	}
+	return c.JSON(http.StatusCreated, stat)
+}
+
+// Retrieves the quant stats of the user
+func (h *UserQuantStatHandler) GetQuantStatsByUserToken(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	quantStats, err := h.Service.GetQuantStatsByUserToken(c.Request().Context(), u.Token)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get quant stats")
+	}
+	return c.JSON(http.StatusOK, quantStats)
+}
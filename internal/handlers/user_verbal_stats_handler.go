@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"grepandit.com/api/internal/models"
@@ -20,6 +22,8 @@ func NewUserVerbalStatHandler(s *services.UserVerbalStatsService) *UserVerbalSta
 /**
 * Used to create a datapoint that represents the performance of a user
 * for a particular question at a particular time in a many to many table.
+* The answers are graded on the server and the response contains the
+* grading of each option.
 **/
 func (h *UserVerbalStatHandler) Create(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -32,11 +36,17 @@ func (h *UserVerbalStatHandler) Create(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
 	}
 	if stat.QuestionID <= 0 || len(stat.Answers) == 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body. Requires questionID, correct, answers, and date")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body. Requires questionID and answers")
 	}
 	err = h.Service.Create(ctx, &stat, u.Token)
 	if err != nil {
 		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidAnswers) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Question not found with id "+strconv.Itoa(stat.QuestionID))
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create user verbal stat")
 	}
 	return c.JSON(http.StatusCreated, stat)
@@ -44,7 +54,8 @@ func (h *UserVerbalStatHandler) Create(c echo.Context) error {
 
 /**
 * Function that is used to get the verbal stats for a particular user
-* token
+* token. The stats can be restricted to a single practice session with
+* the session_id query parameter.
 **/
 func (h *UserVerbalStatHandler) GetVerbalStatsByUserToken(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -52,10 +63,62 @@ func (h *UserVerbalStatHandler) GetVerbalStatsByUserToken(c echo.Context) error
 	if err != nil {
 		return err
 	}
-	verbalStats, err := h.Service.GetVerbalStatsByUserToken(ctx, u.Token)
+	var verbalStats []models.UserVerbalStat
+	if param := c.QueryParam("session_id"); param != "" {
+		sessionID, err := strconv.Atoi(param)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid session ID")
+		}
+		verbalStats, err = h.Service.GetVerbalStatsBySession(ctx, u.Token, sessionID)
+	} else {
+		verbalStats, err = h.Service.GetVerbalStatsByUserToken(ctx, u.Token)
+	}
 	if err != nil {
 		fmt.Println(err.Error())
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get verbal stats")
 	}
 	return c.JSON(http.StatusOK, verbalStats)
 }
+
+/**
+* Replays the verbal stats of a user under a scoring model and reports the
+* differences with the stored abilities. The replayed abilities are only
+* saved when apply is set.
+**/
+func (h *UserVerbalStatHandler) ReplayAbilities(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req models.AbilityReplayRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	replay, err := h.Service.ReplayAbilities(ctx, c.Param("token"), req)
+	if err != nil {
+		fmt.Println(err.Error())
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found with token "+c.Param("token"))
+		}
+		if errors.Is(err, services.ErrInvalidScoringModel) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to replay abilities")
+	}
+	return c.JSON(http.StatusOK, replay)
+}
+
+// Replays the verbal stats of every user, see ReplayAbilities
+func (h *UserVerbalStatHandler) ReplayAllAbilities(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req models.AbilityReplayRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	report, err := h.Service.ReplayAllAbilities(ctx, req)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidScoringModel) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to replay abilities")
+	}
+	return c.JSON(http.StatusOK, report)
+}
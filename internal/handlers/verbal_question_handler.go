@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -51,19 +53,244 @@ func NewVerbalQuestionHandler(s *services.VerbalQuestionService) *VerbalQuestion
 // @param c An echo.Context instance.
 // @return An error response or a JSON response with the created question data.
 func (h *VerbalQuestionHandler) Create(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
 	var q models.VerbalQuestionRequest
 	if err := c.Bind(&q); err != nil {
 		println(err.Error())
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
 	}
 	ctx := c.Request().Context()
-	err := h.Service.Create(ctx, &q)
+	err = h.Service.Create(ctx, &q, u)
 	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrUnknownWord) || errors.Is(err, services.ErrInvalidQuestion) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create question")
 	}
 	return c.JSON(http.StatusCreated, q)
 }
 
+/**
+* Replaces the content of a verbal question. The vocabulary links are
+* recomputed and the change is recorded as a new revision.
+**/
+func (h *VerbalQuestionHandler) Update(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	var req models.VerbalQuestionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	q, err := h.Service.Update(c.Request().Context(), id, &req, u)
+	if err != nil {
+		return questionEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+// Changes only the fields of a verbal question that are sent in the payload
+func (h *VerbalQuestionHandler) Patch(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	patch, err := io.ReadAll(c.Request().Body)
+	if err != nil || len(patch) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	q, err := h.Service.Patch(c.Request().Context(), id, patch, u)
+	if err != nil {
+		return questionEditError(err, id)
+	}
+	return c.JSON(http.StatusOK, q)
+}
+
+/**
+* Imports verbal questions from the JSON Lines or CSV file or the QTI
+* content package sent as the body.
+* The import is configured with the format, create_words, batch_size,
+* start_row and dry_run query parameters. Responds with the report of the
+* import, which lists the errors of each invalid row.
+**/
+func (h *VerbalQuestionHandler) Import(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	opts, err := importOptions(c)
+	if err != nil {
+		return err
+	}
+	report, err := h.Service.Import(c.Request().Context(), c.Request().Body, opts, u)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidImport) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		// Batches committed before the failure are kept and can be resumed from next_row
+		if report != nil {
+			return c.JSON(http.StatusInternalServerError, report)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to import questions")
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// Exports the verbal questions in the format of the format query parameter
+func (h *VerbalQuestionHandler) Export(c echo.Context) error {
+	format := transferFormat(c.QueryParam("format"))
+	switch format {
+	case models.FormatCSV:
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	case models.FormatJSONL:
+		c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	case models.FormatQTI:
+		c.Response().Header().Set(echo.HeaderContentType, "application/zip")
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid format. Use jsonl, csv or qti")
+	}
+	extension := string(format)
+	if format == models.FormatQTI {
+		extension = "zip"
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=verbal_questions."+extension)
+	c.Response().WriteHeader(http.StatusOK)
+	err := h.Service.Export(c.Request().Context(), c.Response(), format)
+	if err != nil {
+		// The response has already started, so the error can only be logged
+		fmt.Println(err.Error())
+	}
+	return nil
+}
+
+// Parses the options of an import from the query parameters
+func importOptions(c echo.Context) (models.ImportOptions, error) {
+	opts := models.ImportOptions{Format: transferFormat(c.QueryParam("format"))}
+	if opts.Format != models.FormatJSONL && opts.Format != models.FormatCSV && opts.Format != models.FormatQTI {
+		return opts, echo.NewHTTPError(http.StatusBadRequest, "Invalid format. Use jsonl, csv or qti")
+	}
+	flags := []struct {
+		name string
+		dest *bool
+	}{
+		{"create_words", &opts.CreateWords},
+		{"dry_run", &opts.DryRun},
+	}
+	for _, flag := range flags {
+		if param := c.QueryParam(flag.name); param != "" {
+			value, err := strconv.ParseBool(param)
+			if err != nil {
+				return opts, echo.NewHTTPError(http.StatusBadRequest, "Invalid "+flag.name)
+			}
+			*flag.dest = value
+		}
+	}
+	numbers := []struct {
+		name string
+		dest *int
+	}{
+		{"batch_size", &opts.BatchSize},
+		{"start_row", &opts.StartRow},
+	}
+	for _, number := range numbers {
+		if param := c.QueryParam(number.name); param != "" {
+			value, err := strconv.Atoi(param)
+			if err != nil || value < 0 {
+				return opts, echo.NewHTTPError(http.StatusBadRequest, "Invalid "+number.name)
+			}
+			*number.dest = value
+		}
+	}
+	return opts, nil
+}
+
+// Format of an import or export, which defaults to JSON Lines
+func transferFormat(param string) models.TransferFormat {
+	if param == "" {
+		return models.FormatJSONL
+	}
+	return models.TransferFormat(strings.ToLower(param))
+}
+
+// Deletes a verbal question so that it is no longer served
+func (h *VerbalQuestionHandler) Delete(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	err = h.Service.Delete(c.Request().Context(), id, u)
+	if err != nil {
+		return questionEditError(err, id)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Retrieves the revision history of a verbal question
+func (h *VerbalQuestionHandler) GetRevisions(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	revisions, err := h.Service.GetRevisions(c.Request().Context(), id)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get revisions")
+	}
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// Retrieves the content of a verbal question at a given revision
+func (h *VerbalQuestionHandler) GetRevision(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid revision")
+	}
+	r, err := h.Service.GetRevision(c.Request().Context(), id, revision)
+	if err != nil {
+		if err == echo.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, "Revision not found")
+		}
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get revision")
+	}
+	return c.JSON(http.StatusOK, r)
+}
+
+// Maps the errors of editing a question to HTTP errors
+func questionEditError(err error, id int) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Question not found with id "+strconv.Itoa(id))
+	case errors.Is(err, services.ErrInvalidQuestion), errors.Is(err, services.ErrUnknownWord):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update question")
+	}
+}
+
 // Get retrieves a verbal question from the database by its ID and returns its data.
 //
 // Example Request:
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type VocabQuizHandler struct {
+	Service *services.VocabQuizService
+}
+
+func NewVocabQuizHandler(s *services.VocabQuizService) *VocabQuizHandler {
+	return &VocabQuizHandler{Service: s}
+}
+
+// Generates a quiz for the user
+func (h *VocabQuizHandler) Generate(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.VocabQuizRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	quiz, err := h.Service.Generate(c.Request().Context(), u.Token, &req)
+	if err != nil {
+		return vocabQuizError(err, "Failed to generate quiz")
+	}
+	return c.JSON(http.StatusCreated, quiz)
+}
+
+func (h *VocabQuizHandler) Get(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	quiz, err := h.Service.Get(c.Request().Context(), u.Token, id)
+	if err != nil {
+		return vocabQuizError(err, "Failed to get quiz")
+	}
+	return c.JSON(http.StatusOK, quiz)
+}
+
+// Retrieves the quizzes of the user
+func (h *VocabQuizHandler) GetByUserToken(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	quizzes, err := h.Service.GetByUserToken(c.Request().Context(), u.Token)
+	if err != nil {
+		return vocabQuizError(err, "Failed to get quizzes")
+	}
+	return c.JSON(http.StatusOK, quizzes)
+}
+
+// Answers an item of a quiz and returns it with its correct answer
+func (h *VocabQuizHandler) Answer(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	var answer models.VocabQuizAnswer
+	if err := c.Bind(&answer); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	item, err := h.Service.Answer(c.Request().Context(), u.Token, id, &answer)
+	if err != nil {
+		return vocabQuizError(err, "Failed to answer quiz item")
+	}
+	return c.JSON(http.StatusOK, item)
+}
+
+// Maps the errors of the vocabulary quiz service to HTTP errors
+func vocabQuizError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrInvalidVocabQuiz):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrVocabItemAnswered):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
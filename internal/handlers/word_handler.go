@@ -134,26 +134,94 @@ func (h *WordHandler) GetByWord(c echo.Context) error {
 	return c.JSON(http.StatusOK, w)
 }
 
+/**
+* Suggests words starting with the q query parameter, matching base forms
+* and inflected variants, ranked by their use in questions. Returns at most
+* limit suggestions, 10 by default.
+**/
+func (h *WordHandler) Autocomplete(c echo.Context) error {
+	prefix := c.QueryParam("q")
+	limit := 10
+	if param := c.QueryParam("limit"); param != "" {
+		l, err := strconv.Atoi(param)
+		if err != nil || l <= 0 || l > 50 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = l
+	}
+	suggestions, err := h.Service.Autocomplete(c.Request().Context(), prefix, limit)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to autocomplete word")
+	}
+	return c.JSON(http.StatusOK, suggestions)
+}
+
+// Marks words for the authenticated user. Words that are not in the database are returned as unknown.
 func (h *WordHandler) MarkWords(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
 	// Bind the request payload to the req struct
 	req := models.MarkWordsReq{}
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
 	}
-	err := h.Service.MarkWords(c.Request().Context(), req.Words)
+	unknown, err := h.Service.MarkWords(c.Request().Context(), u.Token, req.Words)
 	if err != nil {
 		fmt.Println(err.Error())
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to mark words")
 	}
-	return c.JSON(http.StatusOK, req)
+	return c.JSON(http.StatusOK, models.MarkWordsRes{Words: req.Words, Unknown: unknown})
 }
 
+// Retrieves the words marked by the authenticated user
 func (h *WordHandler) GetMarkedWords(c echo.Context) error {
-	// Bind the request payload to the req struct
-	ctx := c.Request().Context()
-	w, err := h.Service.GetMarkedWords(ctx)
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	w, err := h.Service.GetMarkedWords(c.Request().Context(), u.Token)
 	if err != nil {
+		fmt.Println(err.Error())
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrive marked words")
 	}
 	return c.JSON(http.StatusOK, w)
 }
+
+// Retrieves the featured words curated by the admins
+func (h *WordHandler) GetFeaturedWords(c echo.Context) error {
+	w, err := h.Service.GetFeaturedWords(c.Request().Context())
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to retrieve featured words")
+	}
+	return c.JSON(http.StatusOK, w)
+}
+
+// Adds words to the featured list
+func (h *WordHandler) AddFeaturedWords(c echo.Context) error {
+	return h.featureWords(c, true)
+}
+
+// Removes words from the featured list
+func (h *WordHandler) RemoveFeaturedWords(c echo.Context) error {
+	return h.featureWords(c, false)
+}
+
+func (h *WordHandler) featureWords(c echo.Context, featured bool) error {
+	req := models.MarkWordsReq{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	if len(req.Words) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body. Requires words")
+	}
+	unknown, err := h.Service.FeatureWords(c.Request().Context(), req.Words, featured)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update featured words")
+	}
+	return c.JSON(http.StatusOK, models.MarkWordsRes{Words: req.Words, Unknown: unknown})
+}
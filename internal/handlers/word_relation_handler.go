@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type WordRelationHandler struct {
+	Service *services.WordRelationService
+}
+
+func NewWordRelationHandler(s *services.WordRelationService) *WordRelationHandler {
+	return &WordRelationHandler{Service: s}
+}
+
+// Relates two words
+func (h *WordRelationHandler) Create(c echo.Context) error {
+	var req models.WordRelationRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	relation, err := h.Service.Create(c.Request().Context(), &req)
+	if err != nil {
+		return wordRelationError(err, "Failed to create relation")
+	}
+	return c.JSON(http.StatusCreated, relation)
+}
+
+func (h *WordRelationHandler) Delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	if err := h.Service.Delete(c.Request().Context(), id); err != nil {
+		return wordRelationError(err, "Failed to delete relation")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Retrieves the relations of the word_id query parameter, optionally filtered by type
+func (h *WordRelationHandler) GetByWord(c echo.Context) error {
+	wordID, err := strconv.Atoi(c.QueryParam("word_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid word ID")
+	}
+	types, err := relationTypes(c.QueryParam("type"))
+	if err != nil {
+		return err
+	}
+	relations, err := h.Service.GetByWord(c.Request().Context(), wordID, types)
+	if err != nil {
+		return wordRelationError(err, "Failed to get relations")
+	}
+	return c.JSON(http.StatusOK, relations)
+}
+
+// Retrieves the words within depth relations (1 by default) of the word_id query parameter
+func (h *WordRelationHandler) GetNeighborhood(c echo.Context) error {
+	wordID, err := strconv.Atoi(c.QueryParam("word_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid word ID")
+	}
+	depth := 1
+	if param := c.QueryParam("depth"); param != "" {
+		if depth, err = strconv.Atoi(param); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid depth")
+		}
+	}
+	types, err := relationTypes(c.QueryParam("type"))
+	if err != nil {
+		return err
+	}
+	neighborhood, err := h.Service.GetNeighborhood(c.Request().Context(), wordID, depth, types)
+	if err != nil {
+		return wordRelationError(err, "Failed to get neighborhood")
+	}
+	return c.JSON(http.StatusOK, neighborhood)
+}
+
+// Generates count (5 by default) synonym drills, optionally involving word_id
+func (h *WordRelationHandler) GetDrills(c echo.Context) error {
+	count := 5
+	var err error
+	if param := c.QueryParam("count"); param != "" {
+		if count, err = strconv.Atoi(param); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid count")
+		}
+	}
+	wordID := 0
+	if param := c.QueryParam("word_id"); param != "" {
+		if wordID, err = strconv.Atoi(param); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid word ID")
+		}
+	}
+	drills, err := h.Service.GetDrills(c.Request().Context(), count, wordID)
+	if err != nil {
+		return wordRelationError(err, "Failed to generate drills")
+	}
+	return c.JSON(http.StatusOK, drills)
+}
+
+// Imports a thesaurus file in CSV format sent as the request body
+func (h *WordRelationHandler) Import(c echo.Context) error {
+	dryRun := false
+	if param := c.QueryParam("dry_run"); param != "" {
+		value, err := strconv.ParseBool(param)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dry_run")
+		}
+		dryRun = value
+	}
+	report, err := h.Service.Import(c.Request().Context(), c.Request().Body, dryRun)
+	if err != nil {
+		return wordRelationError(err, "Failed to import relations")
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// Parses a comma separated list of relation types
+func relationTypes(param string) ([]models.RelationType, error) {
+	types := make([]models.RelationType, 0)
+	for _, name := range strings.Split(param, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		relationType := models.RelationType(strings.ToLower(name))
+		if !relationType.Valid() {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid relation type "+name)
+		}
+		types = append(types, relationType)
+	}
+	return types, nil
+}
+
+// Maps the errors of the word relation service to HTTP errors
+func wordRelationError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrInvalidWordRelation), errors.Is(err, services.ErrUnknownWord),
+		errors.Is(err, services.ErrInvalidImport):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
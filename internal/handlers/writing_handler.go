@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/services"
+)
+
+type WritingHandler struct {
+	Service *services.WritingService
+}
+
+func NewWritingHandler(s *services.WritingService) *WritingHandler {
+	return &WritingHandler{Service: s}
+}
+
+// Creates a new issue or argument prompt
+func (h *WritingHandler) CreatePrompt(c echo.Context) error {
+	var p models.WritingPrompt
+	if err := c.Bind(&p); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	err := h.Service.CreatePrompt(c.Request().Context(), &p)
+	if err != nil {
+		fmt.Println(err.Error())
+		if errors.Is(err, services.ErrInvalidWritingPrompt) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create prompt")
+	}
+	return c.JSON(http.StatusCreated, p)
+}
+
+// Retrieves the prompts, optionally filtered with the type query parameter
+func (h *WritingHandler) GetPrompts(c echo.Context) error {
+	prompts, err := h.Service.GetPrompts(c.Request().Context(), models.WritingTaskType(c.QueryParam("type")))
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get prompts")
+	}
+	return c.JSON(http.StatusOK, prompts)
+}
+
+func (h *WritingHandler) GetPrompt(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	p, err := h.Service.GetPrompt(c.Request().Context(), id)
+	if err != nil {
+		return writingError(err, "Failed to get prompt")
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Retrieves a prompt at random, optionally filtered with the type query parameter
+func (h *WritingHandler) RandomPrompt(c echo.Context) error {
+	p, err := h.Service.RandomPrompt(c.Request().Context(), models.WritingTaskType(c.QueryParam("type")))
+	if err != nil {
+		return writingError(err, "Failed to get prompt")
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Starts the timer of a new essay for a prompt
+func (h *WritingHandler) StartEssay(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.EssayStartRequest
+	if err := c.Bind(&req); err != nil || req.PromptID <= 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload. Requires prompt_id")
+	}
+	e, err := h.Service.StartEssay(c.Request().Context(), u.Token, req.PromptID)
+	if err != nil {
+		return writingError(err, "Failed to start essay")
+	}
+	return c.JSON(http.StatusCreated, e)
+}
+
+// Submits the text of an essay and returns its score
+func (h *WritingHandler) SubmitEssay(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	var req models.EssaySubmission
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+	e, err := h.Service.SubmitEssay(c.Request().Context(), u.Token, id, req.Text)
+	if err != nil {
+		return writingError(err, "Failed to submit essay")
+	}
+	return c.JSON(http.StatusOK, e)
+}
+
+func (h *WritingHandler) GetEssay(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+	e, err := h.Service.GetEssay(c.Request().Context(), u.Token, id)
+	if err != nil {
+		return writingError(err, "Failed to get essay")
+	}
+	return c.JSON(http.StatusOK, e)
+}
+
+// Retrieves the essay history of the user
+func (h *WritingHandler) GetEssaysByUserToken(c echo.Context) error {
+	u, err := getUserClaims(c)
+	if err != nil {
+		return err
+	}
+	essays, err := h.Service.GetEssaysByUserToken(c.Request().Context(), u.Token)
+	if err != nil {
+		fmt.Println(err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get essays")
+	}
+	return c.JSON(http.StatusOK, essays)
+}
+
+// Maps the errors of the writing service to HTTP errors
+func writingError(err error, message string) error {
+	fmt.Println(err.Error())
+	switch {
+	case err == echo.ErrNotFound:
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	case errors.Is(err, services.ErrInvalidEssay):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrEssaySubmitted):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, message)
+	}
+}
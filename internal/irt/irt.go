@@ -0,0 +1,243 @@
+/**
+* Package irt implements a three parameter logistic (3PL) item response
+* theory engine. Abilities are estimated with expected a posteriori (EAP)
+* estimation over a quadrature grid and items are selected by maximum
+* Fisher information. The 2PL model is the special case where the guessing
+* parameter is zero.
+**/
+package irt
+
+import (
+	"math"
+)
+
+// Scaling constant that makes the logistic curve approximate the normal ogive
+const D = 1.702
+
+// Number of quadrature points used for the EAP estimation
+const quadraturePoints = 81
+
+// Abilities are kept within this range to guard against runaway estimates
+const (
+	MinTheta = -6.0
+	MaxTheta = 6.0
+)
+
+/**
+* Parameters of an item. A is the discrimination, B the difficulty on the
+* ability scale and C the lower asymptote (probability of guessing correctly).
+**/
+type Params struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	C float64 `json:"c"`
+}
+
+/**
+* An ability estimate on the theta scale together with its standard error
+* and the number of responses that were used to obtain it.
+**/
+type Estimate struct {
+	Theta     float64 `json:"theta"`
+	SE        float64 `json:"se"`
+	Responses int     `json:"responses"`
+}
+
+// A single scored response to an item
+type Response struct {
+	Params  Params
+	Correct bool
+}
+
+// An item that can be selected for administration
+type Item struct {
+	ID     int
+	Params Params
+}
+
+// The standard normal prior used before any response has been observed
+func Prior() Estimate {
+	return Estimate{Theta: 0, SE: 1}
+}
+
+// Probability of a correct response at ability theta
+func Probability(theta float64, p Params) float64 {
+	return p.C + (1-p.C)/(1+math.Exp(-D*p.A*(theta-p.B)))
+}
+
+// Fisher information provided by the item at ability theta
+func Information(theta float64, p Params) float64 {
+	prob := Probability(theta, p)
+	if prob <= 0 || prob >= 1 || p.C >= 1 {
+		return 0
+	}
+	ratio := (prob - p.C) / (1 - p.C)
+	return D * D * p.A * p.A * ((1 - prob) / prob) * ratio * ratio
+}
+
+/**
+* Computes the EAP estimate of ability given a normal prior and a set of
+* responses. The posterior is evaluated on a grid centred on the prior
+* mean that spans six prior standard deviations in each direction.
+**/
+func EAP(prior Estimate, responses []Response) Estimate {
+	sd := prior.SE
+	if sd <= 0 || math.IsNaN(sd) {
+		sd = 1
+	}
+	lo := math.Max(MinTheta, prior.Theta-6*sd)
+	hi := math.Min(MaxTheta, prior.Theta+6*sd)
+	if hi <= lo {
+		lo, hi = MinTheta, MaxTheta
+	}
+	step := (hi - lo) / float64(quadraturePoints-1)
+	// Work with log likelihoods to avoid underflow on long response patterns
+	logWeights := make([]float64, quadraturePoints)
+	maxLog := math.Inf(-1)
+	for i := range logWeights {
+		theta := lo + float64(i)*step
+		z := (theta - prior.Theta) / sd
+		logWeight := -0.5 * z * z
+		for _, r := range responses {
+			prob := clamp(Probability(theta, r.Params), 1e-9, 1-1e-9)
+			if r.Correct {
+				logWeight += math.Log(prob)
+			} else {
+				logWeight += math.Log(1 - prob)
+			}
+		}
+		logWeights[i] = logWeight
+		if logWeight > maxLog {
+			maxLog = logWeight
+		}
+	}
+	var total, mean float64
+	weights := make([]float64, quadraturePoints)
+	for i, logWeight := range logWeights {
+		weights[i] = math.Exp(logWeight - maxLog)
+		total += weights[i]
+		mean += weights[i] * (lo + float64(i)*step)
+	}
+	mean /= total
+	var variance float64
+	for i, weight := range weights {
+		diff := lo + float64(i)*step - mean
+		variance += weight * diff * diff
+	}
+	variance /= total
+	return Estimate{
+		Theta:     clamp(mean, MinTheta, MaxTheta),
+		SE:        math.Sqrt(variance),
+		Responses: prior.Responses + len(responses),
+	}
+}
+
+/**
+* Performs a Bayesian update of an estimate with a single response. The
+* current estimate is used as a normal prior for the update, which lets
+* the estimate be stored as a mean and standard error only.
+**/
+func Update(current Estimate, p Params, correct bool) Estimate {
+	return EAP(current, []Response{{Params: p, Correct: correct}})
+}
+
+/**
+* Returns the item that provides the most information at ability theta.
+* Ties are broken by the order of the items. The boolean is false when no
+* items were passed.
+**/
+func MostInformative(theta float64, items []Item) (Item, bool) {
+	best := -1
+	bestInfo := math.Inf(-1)
+	for i, item := range items {
+		info := Information(theta, item.Params)
+		if info > bestInfo {
+			best = i
+			bestInfo = info
+		}
+	}
+	if best < 0 {
+		return Item{}, false
+	}
+	return items[best], true
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// A response to an item together with the ability of the respondent
+type CalibrationResponse struct {
+	Theta   float64
+	Correct bool
+}
+
+/**
+* Classical statistics of an item computed from the responses used to
+* calibrate it. Discrimination is the point biserial correlation between
+* the score on the item and the ability of the respondents.
+**/
+type ItemStats struct {
+	Responses      int     `json:"responses"`
+	PValue         float64 `json:"p_value"`
+	Discrimination float64 `json:"discrimination"`
+	MeanTheta      float64 `json:"mean_theta"`
+}
+
+/**
+* Estimates the discrimination and difficulty of an item from responses
+* of examinees with known abilities using the normal ogive approximation.
+* The guessing parameter of the current parameters is kept. When the
+* discrimination is too low to be trusted the current discrimination is
+* used instead.
+**/
+func EstimateItem(current Params, responses []CalibrationResponse) (Params, ItemStats) {
+	stats := ItemStats{Responses: len(responses)}
+	if len(responses) == 0 {
+		return current, stats
+	}
+	var correctCount int
+	var sumTheta, sumCorrectTheta float64
+	for _, r := range responses {
+		sumTheta += r.Theta
+		if r.Correct {
+			correctCount++
+			sumCorrectTheta += r.Theta
+		}
+	}
+	n := float64(len(responses))
+	p := float64(correctCount) / n
+	mean := sumTheta / n
+	var variance float64
+	for _, r := range responses {
+		variance += (r.Theta - mean) * (r.Theta - mean)
+	}
+	variance /= n
+	stats.PValue = p
+	stats.MeanTheta = mean
+	if correctCount > 0 && correctCount < len(responses) && variance > 0 {
+		meanCorrect := sumCorrectTheta / float64(correctCount)
+		meanIncorrect := (sumTheta - sumCorrectTheta) / float64(len(responses)-correctCount)
+		stats.Discrimination = (meanCorrect - meanIncorrect) / math.Sqrt(variance) * math.Sqrt(p*(1-p))
+	}
+	params := current
+	// Convert the point biserial correlation to a biserial correlation and
+	// then to a discrimination parameter
+	if stats.Discrimination > 0.05 {
+		z := normalQuantile(clamp(p, 0.02, 0.98))
+		biserial := clamp(stats.Discrimination*math.Sqrt(p*(1-p))/normalDensity(z), 0.05, 0.95)
+		params.A = clamp(biserial/math.Sqrt(1-biserial*biserial), 0.3, 2.5)
+	}
+	// Remove the effect of guessing before locating the item on the scale
+	adjusted := clamp((p-current.C)/(1-current.C), 0.02, 0.98)
+	params.B = clamp(mean-normalQuantile(adjusted)*math.Sqrt(1+params.A*params.A*variance)/params.A, -4, 4)
+	return params, stats
+}
+
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+func normalDensity(z float64) float64 {
+	return math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+}
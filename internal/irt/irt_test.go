@@ -0,0 +1,189 @@
+package irt
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Builds a deterministic bank of items spread across the ability scale
+func simulatedBank(r *rand.Rand, size int) []Item {
+	items := make([]Item, size)
+	for i := range items {
+		items[i] = Item{
+			ID: i + 1,
+			Params: Params{
+				A: 0.8 + 1.2*r.Float64(),
+				B: -2.5 + 5*r.Float64(),
+				C: 0.2 * r.Float64(),
+			},
+		}
+	}
+	return items
+}
+
+// Runs an adaptive test for a learner with the given true ability
+func simulateLearner(r *rand.Rand, bank []Item, trueTheta float64, length int) Estimate {
+	remaining := append([]Item(nil), bank...)
+	estimate := Prior()
+	for i := 0; i < length; i++ {
+		item, ok := MostInformative(estimate.Theta, remaining)
+		if !ok {
+			break
+		}
+		for j := range remaining {
+			if remaining[j].ID == item.ID {
+				remaining = append(remaining[:j], remaining[j+1:]...)
+				break
+			}
+		}
+		correct := r.Float64() < Probability(trueTheta, item.Params)
+		estimate = Update(estimate, item.Params, correct)
+	}
+	return estimate
+}
+
+func TestProbability(t *testing.T) {
+	tests := []struct {
+		name  string
+		theta float64
+		p     Params
+		want  float64
+	}{
+		{"at difficulty 2PL", 0, Params{A: 1, B: 0}, 0.5},
+		{"at difficulty 3PL", 1, Params{A: 1.5, B: 1, C: 0.2}, 0.6},
+		{"far below difficulty", -10, Params{A: 1, B: 0, C: 0.25}, 0.25},
+		{"far above difficulty", 10, Params{A: 1, B: 0, C: 0.25}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Probability(tt.theta, tt.p)
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("Probability() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbabilityIsMonotonic(t *testing.T) {
+	p := Params{A: 1.2, B: 0.3, C: 0.15}
+	prev := Probability(MinTheta, p)
+	for theta := MinTheta + 0.1; theta <= MaxTheta; theta += 0.1 {
+		cur := Probability(theta, p)
+		if cur < prev {
+			t.Fatalf("probability decreased at theta %v: %v < %v", theta, cur, prev)
+		}
+		prev = cur
+	}
+}
+
+func TestInformationPeaksNearDifficulty(t *testing.T) {
+	p := Params{A: 1.5, B: 1}
+	if Information(1, p) <= Information(-1, p) || Information(1, p) <= Information(3, p) {
+		t.Errorf("information should peak at the difficulty of a 2PL item")
+	}
+}
+
+func TestMostInformative(t *testing.T) {
+	items := []Item{
+		{ID: 1, Params: Params{A: 1, B: -2}},
+		{ID: 2, Params: Params{A: 1, B: 0.1}},
+		{ID: 3, Params: Params{A: 1, B: 2}},
+	}
+	got, ok := MostInformative(0, items)
+	if !ok || got.ID != 2 {
+		t.Errorf("MostInformative() = %v, want item 2", got.ID)
+	}
+	if _, ok := MostInformative(0, nil); ok {
+		t.Errorf("MostInformative() with no items should report false")
+	}
+}
+
+func TestUpdateMovesTowardsResponse(t *testing.T) {
+	p := Params{A: 1, B: 0}
+	up := Update(Prior(), p, true)
+	down := Update(Prior(), p, false)
+	if up.Theta <= 0 || down.Theta >= 0 {
+		t.Errorf("correct answers should raise and incorrect lower theta, got %v and %v", up.Theta, down.Theta)
+	}
+	if up.SE >= 1 || down.SE >= 1 {
+		t.Errorf("a response should reduce the standard error")
+	}
+	if up.Responses != 1 {
+		t.Errorf("Responses = %d, want 1", up.Responses)
+	}
+}
+
+func TestSequentialUpdatesMatchBatchEAP(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	bank := simulatedBank(r, 20)
+	const trueTheta = 0.5
+	responses := make([]Response, len(bank))
+	sequential := Prior()
+	for i, item := range bank {
+		responses[i] = Response{Params: item.Params, Correct: r.Float64() < Probability(trueTheta, item.Params)}
+		sequential = Update(sequential, responses[i].Params, responses[i].Correct)
+	}
+	batch := EAP(Prior(), responses)
+	if math.Abs(sequential.Theta-batch.Theta) > 0.25 {
+		t.Errorf("sequential theta %v too far from batch theta %v", sequential.Theta, batch.Theta)
+	}
+}
+
+func TestSimulatedLearnersRecoverAbility(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	bank := simulatedBank(r, 300)
+	for _, trueTheta := range []float64{-1.5, -0.5, 0, 0.8, 1.6} {
+		var totalError float64
+		const learners = 20
+		for i := 0; i < learners; i++ {
+			estimate := simulateLearner(r, bank, trueTheta, 40)
+			totalError += math.Abs(estimate.Theta - trueTheta)
+			if estimate.SE > 0.45 {
+				t.Errorf("theta %v: standard error %v after 40 items is too large", trueTheta, estimate.SE)
+			}
+		}
+		if meanError := totalError / learners; meanError > 0.4 {
+			t.Errorf("theta %v: mean absolute error %v is too large", trueTheta, meanError)
+		}
+	}
+}
+
+func TestEstimateItemRecoversParameters(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	tests := []struct {
+		name string
+		true Params
+	}{
+		{"easy item", Params{A: 1, B: -1}},
+		{"medium item", Params{A: 1.4, B: 0}},
+		{"hard item", Params{A: 0.8, B: 1.2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := make([]CalibrationResponse, 4000)
+			for i := range responses {
+				theta := r.NormFloat64()
+				responses[i] = CalibrationResponse{Theta: theta, Correct: r.Float64() < Probability(theta, tt.true)}
+			}
+			got, stats := EstimateItem(Params{A: 1}, responses)
+			if math.Abs(got.B-tt.true.B) > 0.3 {
+				t.Errorf("B = %v, want close to %v", got.B, tt.true.B)
+			}
+			if math.Abs(got.A-tt.true.A) > 0.4 {
+				t.Errorf("A = %v, want close to %v", got.A, tt.true.A)
+			}
+			if stats.Responses != len(responses) || stats.Discrimination <= 0 {
+				t.Errorf("unexpected stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestEstimateItemWithoutResponses(t *testing.T) {
+	current := Params{A: 1.2, B: 0.4, C: 0.2}
+	got, stats := EstimateItem(current, nil)
+	if got != current || stats.Responses != 0 {
+		t.Errorf("EstimateItem() without responses should keep the current parameters")
+	}
+}
@@ -1,22 +1,25 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
-	"github.com/lestrrat-go/jwx/jwk"
+	"grepandit.com/api/internal/auth"
 )
 
+// Key of the claims of the authenticated user in the request context
+const userKey = "user"
+
 /**
 * Middleware that is used to authenticate the JWT token sent
 * by the front end with each request. It confirms that there is
-* an authorization header and it is issued from the user pool based
-* on the available public keyset
+* an authorization header and that the token is signed by the user pool,
+* issued for one of its app clients and not expired
 **/
-func JWTAuthMiddleware(jwkSet jwk.Set) echo.MiddlewareFunc {
+func JWTAuthMiddleware(verifier *auth.Verifier) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
@@ -28,28 +31,26 @@ func JWTAuthMiddleware(jwkSet jwk.Set) echo.MiddlewareFunc {
 			if tokenString == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid access token format")
 			}
-			// Parse and verify the token
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				kid, ok := token.Header["kid"].(string)
-				if !ok {
-					return nil, fmt.Errorf("invalid token")
-				}
-				key, ok := jwkSet.LookupKeyID(kid)
-				if !ok {
-					return nil, fmt.Errorf("unknown key ID")
-				}
-				var pubkey interface{}
-				if err := key.Raw(&pubkey); err != nil {
-					return nil, fmt.Errorf("failed to create public key: %s", err)
+			claims, err := verifier.Verify(c.Request().Context(), tokenString)
+			if err != nil {
+				fmt.Println(err.Error())
+				if errors.Is(err, auth.ErrExpired) {
+					return echo.NewHTTPError(http.StatusUnauthorized, "Expired access token")
 				}
-				return pubkey, nil
-			})
-			if err != nil || !token.Valid {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired access token")
+				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid access token")
 			}
 			// Store the token claims in the context
-			c.Set("user", token.Claims)
+			c.Set(userKey, claims)
 			return next(c)
 		}
 	}
 }
+
+// Claims of the user authenticated by JWTAuthMiddleware
+func UserClaims(c echo.Context) (*auth.Claims, error) {
+	claims, ok := c.Get(userKey).(*auth.Claims)
+	if !ok || claims == nil {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "No user context available")
+	}
+	return claims, nil
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+)
+
+// Key of the roles of the authenticated user in the request context
+const rolesKey = "roles"
+
+// Resolves the roles of a user from the user token and the Cognito groups
+type RoleSource interface {
+	Roles(ctx context.Context, userToken string, groups []string) ([]models.Role, error)
+}
+
+/**
+* Checks the permissions of the authenticated user on the routes that
+* require them. Roles are only resolved by the routes that check a
+* permission, once per request.
+**/
+type Authorizer struct {
+	Source RoleSource
+}
+
+func NewAuthorizer(source RoleSource) *Authorizer {
+	return &Authorizer{Source: source}
+}
+
+/**
+* Middleware that only lets through the users whose roles grant the
+* permission. It reads the claims stored by JWTAuthMiddleware, so it has to
+* be used after it.
+**/
+func (a *Authorizer) Require(permission models.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			roles, err := a.Roles(c)
+			if err != nil {
+				return err
+			}
+			if !models.HasPermission(roles, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "Requires the "+string(permission)+" permission")
+			}
+			return next(c)
+		}
+	}
+}
+
+// Roles of the authenticated user
+func (a *Authorizer) Roles(c echo.Context) ([]models.Role, error) {
+	if roles, ok := c.Get(rolesKey).([]models.Role); ok {
+		return roles, nil
+	}
+	claims, err := UserClaims(c)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := a.Source.Roles(c.Request().Context(), claims.Subject, claims.Groups)
+	if err != nil {
+		fmt.Println(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to get the roles of the user")
+	}
+	c.Set(rolesKey, roles)
+	return roles, nil
+}
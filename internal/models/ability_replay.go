@@ -0,0 +1,76 @@
+package models
+
+type ScoringModel string
+
+/**
+* Scoring models the verbal stats of a user can be replayed under.
+* irt-eap updates the estimate after each answer, as is done when answers
+* are recorded, and is the default. irt-eap-batch estimates the ability
+* from all the answers of a category at once, so the order of the answers
+* does not matter.
+**/
+const (
+	ScoringIRTEAP      ScoringModel = "irt-eap"
+	ScoringIRTEAPBatch ScoringModel = "irt-eap-batch"
+)
+
+var ScoringModels = []ScoringModel{ScoringIRTEAP, ScoringIRTEAPBatch}
+
+func (m ScoringModel) Valid() bool {
+	for _, model := range ScoringModels {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+* Replays the verbal stats under the model, the default model when it is
+* empty. The abilities only change when apply is set, otherwise the
+* replay is a dry run that reports the differences.
+**/
+type AbilityReplayRequest struct {
+	Model ScoringModel `json:"model"`
+	Apply bool         `json:"apply"`
+}
+
+type AbilityEstimate struct {
+	Theta         float64 `json:"theta"`
+	StandardError float64 `json:"standard_error"`
+	Responses     int     `json:"responses"`
+}
+
+// Stored and replayed estimate of a category. Before is nil for a new category.
+type AbilityChange struct {
+	Dimension string           `json:"dimension"`
+	Category  string           `json:"category"`
+	Before    *AbilityEstimate `json:"before"`
+	After     AbilityEstimate  `json:"after"`
+	Changed   bool             `json:"changed"`
+}
+
+/**
+* Result of the replay of the verbal stats of a user. Skipped counts the
+* stats of questions that no longer exist. Applied is set when the
+* replayed abilities were saved.
+**/
+type AbilityReplay struct {
+	UserToken           string          `json:"user_token"`
+	Model               ScoringModel    `json:"model"`
+	Replayed            int             `json:"replayed"`
+	Skipped             int             `json:"skipped"`
+	Abilities           []AbilityChange `json:"abilities"`
+	VerbalAbilityBefore map[string]int  `json:"verbal_ability_before"`
+	VerbalAbilityAfter  map[string]int  `json:"verbal_ability_after"`
+	Changed             bool            `json:"changed"`
+	Applied             bool            `json:"applied"`
+}
+
+// Replay of every user, with the replays of the users whose abilities changed
+type AbilityReplayReport struct {
+	Model ScoringModel `json:"model"`
+	Apply bool         `json:"apply"`
+	MaintenanceReport
+	Users []AbilityReplay `json:"users"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+/**
+* Record of a single calibration of a verbal question computed from the
+* verbal stats of the question. Proposals are stored even when they are not
+* applied so that content editors can review how questions drift.
+**/
+type QuestionCalibration struct {
+	ID                 int        `json:"id"`
+	QuestionID         int        `json:"question_id"`
+	Responses          int        `json:"responses"`
+	PValue             float64    `json:"p_value"`
+	MedianDuration     float64    `json:"median_duration"`
+	Discrimination     float64    `json:"discrimination"`
+	OldDifficulty      Difficulty `json:"old_difficulty"`
+	ProposedDifficulty Difficulty `json:"proposed_difficulty"`
+	OldIRT             IRTParams  `json:"old_irt"`
+	ProposedIRT        IRTParams  `json:"proposed_irt"`
+	Applied            bool       `json:"applied"`
+	AppliedAt          *time.Time `json:"applied_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+/**
+* Represents the data used to run a calibration. Questions with fewer
+* responses than MinResponses are skipped. When QuestionIDs is empty
+* every question is calibrated.
+**/
+type CalibrationRequest struct {
+	MinResponses int   `json:"min_responses"`
+	Apply        bool  `json:"apply"`
+	QuestionIDs  []int `json:"question_ids,omitempty"`
+}
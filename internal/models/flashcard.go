@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+/**
+* Spaced repetition card of a word for a user. The card shows the meanings
+* and examples of the word and is due again after Interval days, which is
+* zero while a forgotten card is being relearned. Marked words that were
+* never reviewed are served as new cards that have no ID yet.
+**/
+type Flashcard struct {
+	ID             int        `json:"id,omitempty"`
+	UserToken      string     `json:"u_id"`
+	Word           Word       `json:"word"`
+	New            bool       `json:"new"`
+	Repetitions    int        `json:"repetitions"`
+	Interval       int        `json:"interval"`
+	Ease           float64    `json:"ease"`
+	Lapses         int        `json:"lapses"`
+	DueAt          time.Time  `json:"due_at"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at,omitempty"`
+}
+
+/**
+* Cards to review now. Due cards come first, followed by as many new cards
+* as the daily limit of the user still allows.
+**/
+type FlashcardQueue struct {
+	Cards        []Flashcard `json:"cards"`
+	Due          int         `json:"due"`
+	New          int         `json:"new"`
+	NewToday     int         `json:"new_today"`
+	NewRemaining int         `json:"new_remaining"`
+}
+
+type FlashcardGradeRequest struct {
+	Grade string `json:"grade"`
+}
+
+type FlashcardSettings struct {
+	NewCardsPerDay int `json:"new_cards_per_day"`
+}
+
+/**
+* Review statistics of a user. Cards are young until their interval reaches
+* three weeks and mature afterwards. Retention is the share of reviews of cards
+* already learned that were recalled over the last Days days, and mature
+* retention restricts it to the reviews of mature cards.
+**/
+type FlashcardStats struct {
+	Cards           int     `json:"cards"`
+	Due             int     `json:"due"`
+	Young           int     `json:"young"`
+	Mature          int     `json:"mature"`
+	Unseen          int     `json:"unseen"`
+	Lapses          int     `json:"lapses"`
+	AverageEase     float64 `json:"average_ease"`
+	ReviewsToday    int     `json:"reviews_today"`
+	NewToday        int     `json:"new_today"`
+	Days            int     `json:"days"`
+	Reviews         int     `json:"reviews"`
+	Retention       float64 `json:"retention"`
+	MatureRetention float64 `json:"mature_retention"`
+}
@@ -0,0 +1,25 @@
+package models
+
+/**
+* Result of a maintenance task run over many questions or users. Changed
+* counts the items whose stored data was different from the recomputed
+* data.
+**/
+type MaintenanceReport struct {
+	Processed int                `json:"processed"`
+	Changed   int                `json:"changed"`
+	Failed    int                `json:"failed"`
+	Errors    []MaintenanceError `json:"errors"`
+}
+
+// Item of a maintenance task that failed, a question id or a user token
+type MaintenanceError struct {
+	Item  string `json:"item"`
+	Error string `json:"error"`
+}
+
+// Rows deleted from each table when the data of a user is purged
+type PurgeReport struct {
+	UserToken string           `json:"user_token"`
+	Deleted   map[string]int64 `json:"deleted"`
+}
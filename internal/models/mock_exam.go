@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type MockExamStatus string
+
+const (
+	MockExamSection1 MockExamStatus = "section1"
+	MockExamSection2 MockExamStatus = "section2"
+	MockExamFinished MockExamStatus = "finished"
+)
+
+// Routes to the second section of a mock exam
+const (
+	MockExamRouteEasier = "easier"
+	MockExamRouteHarder = "harder"
+)
+
+/**
+* Estimated score of a mock exam on the 130 to 170 scale. Low and High
+* bound the 95% confidence band of the estimate.
+**/
+type MockExamScore struct {
+	Theta         float64 `json:"theta"`
+	StandardError float64 `json:"standard_error"`
+	Scaled        int     `json:"scaled"`
+	Low           int     `json:"low"`
+	High          int     `json:"high"`
+	Correct       int     `json:"correct"`
+	Total         int     `json:"total"`
+}
+
+/**
+* Model that represents a section adaptive mock verbal exam. Each section
+* is a timed practice session with a fixed set of questions. The second
+* section is assembled once the first one is finished and its difficulty
+* depends on the performance in the first section.
+**/
+type MockExam struct {
+	ID                int            `json:"id"`
+	UserToken         string         `json:"u_id"`
+	Status            MockExamStatus `json:"status"`
+	Section1SessionID int            `json:"section1_session_id"`
+	Section2SessionID *int           `json:"section2_session_id"`
+	Route             string         `json:"route,omitempty"`
+	Score             *MockExamScore `json:"score"`
+	StartedAt         time.Time      `json:"started_at"`
+	FinishedAt        *time.Time     `json:"finished_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+/**
+* A reading passage shared by a set of reading comprehension questions.
+* Questions that belong to a passage are served together and receive the
+* passage text and vocabulary in place of their own paragraph.
+**/
+type Passage struct {
+	ID           int               `json:"id"`
+	Title        string            `json:"title"`
+	Text         string            `json:"text"`
+	Vocabulary   []Word            `json:"vocabulary"`
+	VocabWordMap map[string]string `json:"wordmap"`
+	Questions    []VerbalQuestion  `json:"questions"`
+}
+
+/**
+* Represents the Data used by the POST endpoint.
+* Vocabulary is passed as a list of words here.
+**/
+type PassageRequest struct {
+	ID         int      `json:"id"`
+	Title      string   `json:"title"`
+	Text       string   `json:"text"`
+	Vocabulary []string `json:"vocabulary"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+type SessionStatus string
+
+const (
+	SessionActive   SessionStatus = "active"
+	SessionPaused   SessionStatus = "paused"
+	SessionFinished SessionStatus = "finished"
+)
+
+// Modes in which a practice session can be run
+const (
+	SessionModePractice = "practice"
+	SessionModeMockExam = "mock_exam"
+)
+
+/**
+* Criteria used to select the questions of a practice session. Filters
+* that are not set are not applied. When no difficulty is set questions are
+* selected adaptively based on the ability of the user. TimeLimit is in
+* seconds and zero means the session is not timed.
+**/
+type SessionCriteria struct {
+	QuestionType QuestionType `json:"type,omitempty"`
+	Competence   Competence   `json:"competence,omitempty"`
+	Difficulty   Difficulty   `json:"difficulty,omitempty"`
+	Length       int          `json:"length"`
+	TimeLimit    int          `json:"time_limit,omitempty"`
+}
+
+/**
+* Model that represents a practice session of a user. QuestionIDs holds
+* the questions served so far in order, the question at index Answered is
+* the one currently waiting for an answer. Elapsed is the active time of
+* the session in seconds and excludes the time spent paused.
+**/
+type PracticeSession struct {
+	ID            int             `json:"id"`
+	UserToken     string          `json:"u_id"`
+	Mode          string          `json:"mode"`
+	Criteria      SessionCriteria `json:"criteria"`
+	Status        SessionStatus   `json:"status"`
+	QuestionIDs   []int           `json:"question_ids"`
+	Answered      int             `json:"answered"`
+	Correct       int             `json:"correct"`
+	Elapsed       int             `json:"elapsed"`
+	StartedAt     time.Time       `json:"started_at"`
+	LastResumedAt time.Time       `json:"last_resumed_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
+}
+
+// Represents the data used to answer the current question of a session
+type SessionAnswerRequest struct {
+	QuestionID int      `json:"question_id"`
+	Answers    []string `json:"answers"`
+	Duration   int      `json:"duration"`
+}
+
+// Result of answering a question within a session
+type SessionAnswer struct {
+	Stat    UserVerbalStat  `json:"stat"`
+	Session PracticeSession `json:"session"`
+}
+
+// Number of questions answered and answered correctly for a category
+type SessionBreakdown struct {
+	Answered int `json:"answered"`
+	Correct  int `json:"correct"`
+}
+
+/**
+* Summary of a practice session with the stats recorded during it and a
+* breakdown of the results per question type.
+**/
+type SessionSummary struct {
+	Session         PracticeSession             `json:"session"`
+	Accuracy        float64                     `json:"accuracy"`
+	AverageDuration float64                     `json:"average_duration"`
+	ByType          map[string]SessionBreakdown `json:"by_type"`
+	Stats           []UserVerbalStat            `json:"stats"`
+}
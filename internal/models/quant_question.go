@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+type QuantQuestionType int
+type QuantTopic int
+
+// ENUM types
+const (
+	QuantitativeComparison QuantQuestionType = iota + 1
+	QuantMCQSingleAnswer
+	QuantMCQMultipleChoices
+	NumericEntry
+)
+
+const (
+	Arithmetic QuantTopic = iota + 1
+	Algebra
+	Geometry
+	DataAnalysis
+)
+
+// String equivalents for ENUM types
+func (q QuantQuestionType) String() string {
+	switch q {
+	case QuantitativeComparison:
+		return "QuantitativeComparison"
+	case QuantMCQSingleAnswer:
+		return "MCQSingleAnswer"
+	case QuantMCQMultipleChoices:
+		return "MCQMultipleChoice"
+	case NumericEntry:
+		return "NumericEntry"
+	default:
+		return "Unknown"
+	}
+}
+
+func (t QuantTopic) String() string {
+	switch t {
+	case Arithmetic:
+		return "Arithmetic"
+	case Algebra:
+		return "Algebra"
+	case Geometry:
+		return "Geometry"
+	case DataAnalysis:
+		return "DataAnalysis"
+	default:
+		return "Unknown"
+	}
+}
+
+// StringToQuantQuestionType converts a string to its corresponding QuantQuestionType enum value
+func StringToQuantQuestionType(s string) (QuantQuestionType, error) {
+	switch s {
+	case "QuantitativeComparison":
+		return QuantitativeComparison, nil
+	case "MCQSingleAnswer":
+		return QuantMCQSingleAnswer, nil
+	case "MCQMultipleChoice":
+		return QuantMCQMultipleChoices, nil
+	case "NumericEntry":
+		return NumericEntry, nil
+	default:
+		return 0, nil
+	}
+}
+
+// StringToQuantTopic converts a string to its corresponding QuantTopic enum value
+func StringToQuantTopic(s string) (QuantTopic, error) {
+	switch s {
+	case "Arithmetic":
+		return Arithmetic, nil
+	case "Algebra":
+		return Algebra, nil
+	case "Geometry":
+		return Geometry, nil
+	case "DataAnalysis":
+		return DataAnalysis, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Marshal JSON
+func (q QuantQuestionType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+func (t QuantTopic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (q *QuantQuestionType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	qType, _ := StringToQuantQuestionType(s)
+	if qType == 0 {
+		return errors.New("invalid quant question type value")
+	}
+	*q = qType
+	return nil
+}
+
+func (t *QuantTopic) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	topic, _ := StringToQuantTopic(s)
+	if topic == 0 {
+		return errors.New("invalid quant topic value")
+	}
+	*t = topic
+	return nil
+}
+
+/**
+* Expected answer of a numeric entry question. When the denominator is
+* set the answer is a fraction and any equivalent fraction is accepted.
+* Otherwise a decimal answer is accepted when it is within the tolerance
+* of the value.
+**/
+type NumericAnswer struct {
+	Value       float64 `json:"value"`
+	Numerator   int64   `json:"numerator,omitempty"`
+	Denominator int64   `json:"denominator,omitempty"`
+	Tolerance   float64 `json:"tolerance,omitempty"`
+}
+
+/**
+* Model that represents a question in the quantitative reasoning
+* portion of the GRE exam. Quantitative comparison questions compare
+* QuantityA with QuantityB and numeric entry questions are graded
+* against Answer instead of Options.
+**/
+type QuantQuestion struct {
+	ID         int               `json:"id"`
+	Type       QuantQuestionType `json:"type"`
+	Topic      QuantTopic        `json:"topic"`
+	Question   string            `json:"question"`
+	QuantityA  string            `json:"quantity_a,omitempty"`
+	QuantityB  string            `json:"quantity_b,omitempty"`
+	Options    []Option          `json:"options,omitempty"`
+	Answer     *NumericAnswer    `json:"answer,omitempty"`
+	Difficulty Difficulty        `json:"difficulty"`
+	DataSetID  *int              `json:"data_set_id,omitempty"`
+	DataSet    *QuantDataSet     `json:"data_set,omitempty"`
+	IRT        IRTParams         `json:"irt"`
+}
+
+/**
+* A data interpretation set. The chart is stored as is and rendered by
+* the client, and every question of the set refers to it.
+**/
+type QuantDataSet struct {
+	ID          int             `json:"id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Chart       json.RawMessage `json:"chart"`
+	Questions   []QuantQuestion `json:"questions,omitempty"`
+}
+
+type QuantRandomQuestionsRequest struct {
+	Limit      int               `json:"limit"`
+	Type       QuantQuestionType `json:"type,omitempty"`
+	Topic      QuantTopic        `json:"topic,omitempty"`
+	Difficulty Difficulty        `json:"difficulty,omitempty"`
+	ExcludeIDs []int             `json:"exclude_ids,omitempty"`
+}
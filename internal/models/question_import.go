@@ -0,0 +1,57 @@
+package models
+
+// File formats supported by the bulk import and export of questions
+type TransferFormat string
+
+const (
+	FormatJSONL TransferFormat = "jsonl"
+	FormatCSV   TransferFormat = "csv"
+	FormatQTI   TransferFormat = "qti"
+)
+
+/**
+* Options of a bulk import. A batch size of zero imports every row in a
+* single transaction that is only committed when all rows are valid.
+* Otherwise each batch of rows is committed on its own, invalid rows are
+* skipped, and an interrupted import can be resumed from StartRow.
+**/
+type ImportOptions struct {
+	Format      TransferFormat `json:"format"`
+	CreateWords bool           `json:"create_words"`
+	BatchSize   int            `json:"batch_size"`
+	StartRow    int            `json:"start_row"`
+	DryRun      bool           `json:"dry_run"`
+}
+
+// A vocabulary word that is not in the words table
+type UnknownWord struct {
+	Word        string   `json:"word"`
+	Suggestions []string `json:"suggestions"`
+}
+
+/**
+* Error of a single row of an import. Rows are numbered from 1 and the
+* header of a CSV file is not counted. The rows of a QTI package are its
+* items in the order of the manifest.
+**/
+type ImportRowError struct {
+	Row          int           `json:"row"`
+	Message      string        `json:"message"`
+	UnknownWords []UnknownWord `json:"unknown_words,omitempty"`
+}
+
+/**
+* Result of a bulk import. NextRow is the row to resume from when the
+* import was interrupted and zero once every row was processed.
+**/
+type ImportReport struct {
+	Rows         int              `json:"rows"`
+	Imported     int              `json:"imported"`
+	Failed       int              `json:"failed"`
+	QuestionIDs  []int            `json:"question_ids"`
+	CreatedWords []string         `json:"created_words"`
+	Errors       []ImportRowError `json:"errors"`
+	Committed    bool             `json:"committed"`
+	NextRow      int              `json:"next_row,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+type Role string
+
+/**
+* Roles of the users. Every authenticated user is a learner. The other roles
+* come from the Cognito groups of the user or are granted by an admin.
+**/
+const (
+	RoleLearner    Role = "learner"
+	RoleInstructor Role = "instructor"
+	RoleEditor     Role = "editor"
+	RoleAdmin      Role = "admin"
+)
+
+var Roles = []Role{RoleLearner, RoleInstructor, RoleEditor, RoleAdmin}
+
+func (r Role) Valid() bool {
+	for _, role := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type Permission string
+
+const (
+	// Read the revisions, calibrations and exports of the question bank
+	PermissionReviewContent Permission = "content:review"
+	// Create, modify and delete questions, passages, words and their relations
+	PermissionEditContent Permission = "content:edit"
+	// Curate the featured words
+	PermissionFeatureWords Permission = "words:feature"
+	// Grant and revoke the roles of users
+	PermissionManageRoles Permission = "roles:manage"
+	// Rebuild the abilities of users from their verbal stats
+	PermissionReplayAbilities Permission = "abilities:replay"
+)
+
+// Permissions of each role. Learners only use their own data.
+var rolePermissions = map[Role][]Permission{
+	RoleLearner:    {},
+	RoleInstructor: {PermissionReviewContent},
+	RoleEditor:     {PermissionReviewContent, PermissionEditContent},
+	RoleAdmin:      {PermissionReviewContent, PermissionEditContent, PermissionFeatureWords, PermissionManageRoles, PermissionReplayAbilities},
+}
+
+// Whether any of the roles grants the permission
+func HasPermission(roles []Role, permission Permission) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Permissions granted by the roles, in alphabetical order
+func Permissions(roles []Role) []Permission {
+	unique := make(map[Permission]bool)
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			unique[p] = true
+		}
+	}
+	permissions := make([]Permission, 0, len(unique))
+	for p := range unique {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i] < permissions[j] })
+	return permissions
+}
+
+// Role granted to a user in the user roles table
+type UserRole struct {
+	UserToken string    `json:"user_token"`
+	Role      Role      `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+/**
+* Roles of a user with the permissions they grant. Groups are the roles
+* that come from the Cognito groups of the user and Granted the roles of
+* the user roles table.
+**/
+type UserAccess struct {
+	UserToken   string       `json:"user_token"`
+	Roles       []Role       `json:"roles"`
+	Groups      []Role       `json:"groups,omitempty"`
+	Granted     []UserRole   `json:"granted"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type RoleRequest struct {
+	Role Role `json:"role"`
+}
@@ -0,0 +1,58 @@
+package models
+
+// Kinds of content that are searched
+type SearchScope string
+
+const (
+	SearchAll       SearchScope = "all"
+	SearchQuestions SearchScope = "questions"
+	SearchWords     SearchScope = "words"
+)
+
+/**
+* Search across verbal questions and words. Type, competence and difficulty
+* only filter questions. Page starts at 1.
+**/
+type SearchRequest struct {
+	Query      string       `json:"q"`
+	Scope      SearchScope  `json:"scope"`
+	Type       QuestionType `json:"type,omitempty"`
+	Competence Competence   `json:"competence,omitempty"`
+	Difficulty Difficulty   `json:"difficulty,omitempty"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+}
+
+/**
+* Question matching a search. The snippet is an excerpt of the paragraph
+* and question with the matched terms wrapped in <mark> tags.
+**/
+type QuestionHit struct {
+	Question VerbalQuestion `json:"question"`
+	Rank     float64        `json:"rank"`
+	Snippet  string         `json:"snippet"`
+}
+
+/**
+* Word matching a search, either by full-text search on the word, its
+* meanings and examples or by the similarity of the word with the query.
+* Fuzzy is set for words that only match by similarity.
+**/
+type WordHit struct {
+	Word       Word    `json:"word"`
+	Rank       float64 `json:"rank"`
+	Similarity float64 `json:"similarity"`
+	Fuzzy      bool    `json:"fuzzy"`
+	Snippet    string  `json:"snippet"`
+}
+
+// Page of search results with the total number of matches of every kind
+type SearchResults struct {
+	Query          string        `json:"q"`
+	Page           int           `json:"page"`
+	Limit          int           `json:"limit"`
+	Questions      []QuestionHit `json:"questions"`
+	QuestionsTotal int           `json:"questions_total"`
+	Words          []WordHit     `json:"words"`
+	WordsTotal     int           `json:"words_total"`
+}
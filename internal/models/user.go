@@ -1,8 +1,32 @@
 package models
 
+import "time"
+
 type User struct {
 	ID            int            `json:"id"`
 	Token         string         `json:"token"`
 	Email         string         `json:"email"`
 	VerbalAbility map[string]int `json:"verbal_ability"`
+	QuantAbility  map[string]int `json:"quant_ability"`
+}
+
+// Dimensions along which the ability of a user is tracked
+const (
+	AbilityDimensionType       = "type"
+	AbilityDimensionCompetence = "competence"
+	AbilityDimensionQuantType  = "quant_type"
+	AbilityDimensionQuantTopic = "quant_topic"
+)
+
+/**
+* Ability estimate of a user on the item response theory scale for a
+* single question type or competence.
+**/
+type UserAbility struct {
+	Dimension     string    `json:"dimension"`
+	Category      string    `json:"category"`
+	Theta         float64   `json:"theta"`
+	StandardError float64   `json:"standard_error"`
+	Responses     int       `json:"responses"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
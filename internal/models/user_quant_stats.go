@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type UserQuantStat struct {
+	ID         int               `json:"id"`
+	UserToken  string            `json:"u_id"`
+	QuestionID int               `json:"question_id"`
+	Correct    bool              `json:"correct"`
+	Answers    []string          `json:"answers"`
+	Duration   int               `json:"duration"`
+	Date       time.Time         `json:"time"`
+	Type       QuantQuestionType `json:"type"`
+	Topic      QuantTopic        `json:"topic"`
+	Difficulty Difficulty        `json:"difficulty"`
+	Grading    []OptionGrade     `json:"grading,omitempty"`
+	Expected   string            `json:"expected,omitempty"`
+}
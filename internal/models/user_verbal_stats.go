@@ -3,18 +3,32 @@ package models
 import "time"
 
 type UserVerbalStat struct {
-	ID         int          `json:"id"`
-	UserToken  string       `json:"u_id"`
-	QuestionID int          `json:"question_id"`
-	Correct    bool         `json:"correct"`
-	Answers    []string     `json:"answers"`
-	Duration   int          `json:"duration"`
-	Date       time.Time    `json:"time"`
-	Competence Competence   `json:"competence"`
-	FramedAs   FramedAs     `json:"framed_as"`
-	Type       QuestionType `json:"type"`
-	Difficulty Difficulty   `json:"difficulty"`
-	Vocabulary []Word       `json:"vocabulary"`
+	ID         int           `json:"id"`
+	UserToken  string        `json:"u_id"`
+	QuestionID int           `json:"question_id"`
+	Revision   int           `json:"question_revision"`
+	Correct    bool          `json:"correct"`
+	Answers    []string      `json:"answers"`
+	Duration   int           `json:"duration"`
+	Date       time.Time     `json:"time"`
+	Competence Competence    `json:"competence"`
+	FramedAs   FramedAs      `json:"framed_as"`
+	Type       QuestionType  `json:"type"`
+	Difficulty Difficulty    `json:"difficulty"`
+	Vocabulary []Word        `json:"vocabulary"`
+	Grading    []OptionGrade `json:"grading,omitempty"`
+	SessionID  *int          `json:"session_id,omitempty"`
+}
+
+/**
+* Result of grading a single option of a question against the
+* answers submitted by the user
+**/
+type OptionGrade struct {
+	Value         string `json:"value"`
+	Selected      bool   `json:"selected"`
+	Correct       bool   `json:"correct"`
+	Justification string `json:"justification"`
 }
 
 type UserMarkedWord struct {
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RevisionAction string
+
+const (
+	// State of a question created before revisions were recorded
+	RevisionBaseline RevisionAction = "baseline"
+	RevisionCreate   RevisionAction = "create"
+	RevisionUpdate   RevisionAction = "update"
+	RevisionDelete   RevisionAction = "delete"
+	// Difficulty and parameters applied from a calibration, the content is unchanged
+	RevisionCalibrate RevisionAction = "calibrate"
+)
+
+// Value of a field before and after a revision
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+/**
+* Immutable record of a change to a verbal question. The snapshot is the
+* content of the question at this revision and the diff holds the fields
+* that changed compared to the previous revision.
+**/
+type VerbalQuestionRevision struct {
+	ID          int                    `json:"id"`
+	QuestionID  int                    `json:"question_id"`
+	Revision    int                    `json:"revision"`
+	Action      RevisionAction         `json:"action"`
+	Editor      string                 `json:"editor"`
+	EditorEmail string                 `json:"editor_email"`
+	Snapshot    VerbalQuestionRequest  `json:"snapshot"`
+	Diff        map[string]FieldChange `json:"diff,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 type Competence int
@@ -170,7 +171,7 @@ func (c *Competence) UnmarshalJSON(data []byte) error {
 		*c = AnalyzingAndDrawingConclusions
 	case "Reasoning from incomplete data":
 		*c = ReasoningFromIncompleteData
-	case "Identifying authors assumptions/perspective":
+	case "Identifying author's assumptions/perspective", "Identifying authors assumptions/perspective":
 		*c = IdentifyingAuthorsAssumptionsPerspective
 	case "Understanding multiple levels of meaning":
 		*c = UnderstandingMultipleLevelsOfMeaning
@@ -230,6 +231,16 @@ type Option struct {
 	Justification string `json:"justification"`
 }
 
+/**
+* Item response theory parameters of a question. A is the discrimination,
+* B the difficulty on the ability scale and C the guessing parameter.
+**/
+type IRTParams struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	C float64 `json:"c"`
+}
+
 /**
 * Model that represents a question in the verbal reading portion
 * of the GRE exam.
@@ -245,6 +256,10 @@ type VerbalQuestion struct {
 	Difficulty   Difficulty        `json:"difficulty"`
 	Vocabulary   []Word            `json:"vocabulary"`
 	VocabWordMap map[string]string `json:"wordmap"`
+	IRT          IRTParams         `json:"irt"`
+	Revision     int               `json:"revision"`
+	DeletedAt    *time.Time        `json:"deleted_at,omitempty"`
+	PassageID    *int              `json:"passage_id,omitempty"`
 }
 
 /**
@@ -261,6 +276,8 @@ type VerbalQuestionRequest struct {
 	Options    []Option     `json:"options"`
 	Difficulty Difficulty   `json:"difficulty"`
 	Vocabulary []string     `json:"vocabulary"`
+	IRT        *IRTParams   `json:"irt,omitempty"`
+	PassageID  *int         `json:"passage_id,omitempty"`
 }
 
 type RandomQuestionsRequest struct {
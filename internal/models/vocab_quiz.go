@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+type VocabQuizKind string
+
+const (
+	// Pick the word that matches a meaning
+	DefinitionToWord VocabQuizKind = "definition_to_word"
+	// Pick the meaning of a word
+	WordToDefinition VocabQuizKind = "word_to_definition"
+	// Pick the word missing from one of its examples
+	FillInBlank VocabQuizKind = "fill_in_blank"
+)
+
+// Words a quiz is generated from
+const (
+	VocabQuizSourceAll         = "all"
+	VocabQuizSourceMarked      = "marked"
+	VocabQuizSourceProblematic = "problematic"
+)
+
+/**
+* Multiple choice item of a vocabulary quiz about a word. The index of the
+* correct option is only sent once the item has been answered.
+**/
+type VocabQuizItem struct {
+	Kind     VocabQuizKind `json:"kind"`
+	WordID   int           `json:"word_id"`
+	Prompt   string        `json:"prompt"`
+	Options  []string      `json:"options"`
+	Answer   *int          `json:"answer,omitempty"`
+	Response *int          `json:"response,omitempty"`
+	Correct  *bool         `json:"correct,omitempty"`
+}
+
+type VocabQuiz struct {
+	ID        int             `json:"id"`
+	UserToken string          `json:"u_id"`
+	Items     []VocabQuizItem `json:"items"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+/**
+* Request to generate a quiz of Length items. The words are either the
+* given WordIDs or taken from Source, and the kinds of items default to
+* all of them.
+**/
+type VocabQuizRequest struct {
+	Length  int             `json:"length"`
+	Kinds   []VocabQuizKind `json:"kinds"`
+	WordIDs []int           `json:"word_ids"`
+	Source  string          `json:"source"`
+}
+
+type VocabQuizAnswer struct {
+	Item   int `json:"item"`
+	Answer int `json:"answer"`
+}
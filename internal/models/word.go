@@ -10,7 +10,7 @@ type Word struct {
 	Word     string    `json:"word"`
 	Meanings []Meaning `json:"meanings"`
 	Examples []string  `json:"examples"`
-	Marked   bool      `json:"marked"`
+	Featured bool      `json:"featured"`
 }
 
 type WordMap struct {
@@ -21,3 +21,20 @@ type WordMap struct {
 type MarkWordsReq struct {
 	Words []string `json:"words"`
 }
+
+// Response to marking or featuring words, with the words that are not in the database
+type MarkWordsRes struct {
+	Words   []string `json:"words"`
+	Unknown []string `json:"unknown"`
+}
+
+/**
+* Word suggested while typing. Match is the base form or the inflected
+* variant that matched, and Frequency the number of questions using the word.
+**/
+type WordSuggestion struct {
+	WordID    int    `json:"word_id"`
+	Word      string `json:"word"`
+	Match     string `json:"match"`
+	Frequency int    `json:"frequency"`
+}
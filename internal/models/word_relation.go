@@ -0,0 +1,75 @@
+package models
+
+type RelationType string
+
+// Relations between words. Every relation goes both ways.
+const (
+	RelationSynonym    RelationType = "synonym"
+	RelationAntonym    RelationType = "antonym"
+	RelationRelated    RelationType = "related"
+	RelationConfusable RelationType = "confusable"
+)
+
+var RelationTypes = []RelationType{RelationSynonym, RelationAntonym, RelationRelated, RelationConfusable}
+
+func (t RelationType) Valid() bool {
+	for _, relationType := range RelationTypes {
+		if t == relationType {
+			return true
+		}
+	}
+	return false
+}
+
+type WordRelation struct {
+	ID            int          `json:"id"`
+	WordID        int          `json:"word_id"`
+	Word          string       `json:"word"`
+	RelatedWordID int          `json:"related_word_id"`
+	RelatedWord   string       `json:"related_word"`
+	Type          RelationType `json:"type"`
+}
+
+type WordRelationRequest struct {
+	Word        string       `json:"word"`
+	RelatedWord string       `json:"related_word"`
+	Type        RelationType `json:"type"`
+}
+
+// Word reached from the start of a traversal and the number of relations crossed
+type WordNeighbor struct {
+	Word     Word `json:"word"`
+	Distance int  `json:"distance"`
+}
+
+/**
+* Words within Depth relations of a word, closest first, along with the
+* relations that connect them.
+**/
+type WordNeighborhood struct {
+	Word      Word           `json:"word"`
+	Depth     int            `json:"depth"`
+	Neighbors []WordNeighbor `json:"neighbors"`
+	Relations []WordRelation `json:"relations"`
+}
+
+/**
+* Sentence equivalence style drill. Exactly two of the options are synonyms,
+* given by the indexes in Answer, and none of the other options is a synonym
+* of another option.
+**/
+type SynonymDrill struct {
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options"`
+	WordIDs []int    `json:"word_ids"`
+	Answer  []int    `json:"answer"`
+}
+
+type WordRelationImportReport struct {
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Existing int              `json:"existing"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+	DryRun   bool             `json:"dry_run"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+type WritingTaskType string
+type EssayStatus string
+
+const (
+	WritingTaskIssue    WritingTaskType = "issue"
+	WritingTaskArgument WritingTaskType = "argument"
+)
+
+const (
+	EssayInProgress EssayStatus = "in_progress"
+	EssaySubmitted  EssayStatus = "submitted"
+)
+
+/**
+* Model that represents an Analytical Writing prompt. The time limit is in
+* seconds and defaults to the 30 minutes of the exam.
+**/
+type WritingPrompt struct {
+	ID           int             `json:"id"`
+	Type         WritingTaskType `json:"type"`
+	Prompt       string          `json:"prompt"`
+	Instructions string          `json:"instructions"`
+	TimeLimit    int             `json:"time_limit"`
+}
+
+/**
+* Estimated score of an essay on the 0 to 6 scale along with the rubric
+* components, each between 0 and 1, that it was built from.
+**/
+type EssayScore struct {
+	Estimate           float64  `json:"estimate"`
+	Length             float64  `json:"length"`
+	Paragraphing       float64  `json:"paragraphing"`
+	Vocabulary         float64  `json:"vocabulary"`
+	Transitions        float64  `json:"transitions"`
+	WordCount          int      `json:"word_count"`
+	ParagraphCount     int      `json:"paragraph_count"`
+	SentenceCount      int      `json:"sentence_count"`
+	LexicalDiversity   float64  `json:"lexical_diversity"`
+	SophisticatedWords []string `json:"sophisticated_words"`
+	TransitionsUsed    []string `json:"transitions_used"`
+}
+
+/**
+* Essay written by a user for a prompt. The essay is timed from the moment
+* it is started and marked as overtime when it is submitted after the time
+* limit of its prompt. Duration is in seconds.
+**/
+type Essay struct {
+	ID          int            `json:"id"`
+	UserToken   string         `json:"u_id"`
+	PromptID    int            `json:"prompt_id"`
+	Prompt      *WritingPrompt `json:"prompt,omitempty"`
+	Text        string         `json:"text"`
+	Status      EssayStatus    `json:"status"`
+	StartedAt   time.Time      `json:"started_at"`
+	SubmittedAt *time.Time     `json:"submitted_at,omitempty"`
+	Duration    int            `json:"duration"`
+	Overtime    bool           `json:"overtime"`
+	Score       *EssayScore    `json:"score,omitempty"`
+}
+
+type EssayStartRequest struct {
+	PromptID int `json:"prompt_id"`
+}
+
+type EssaySubmission struct {
+	Text string `json:"text"`
+}
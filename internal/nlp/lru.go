@@ -0,0 +1,57 @@
+package nlp
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Least recently used cache of lemmas, safe for concurrent use
+type lru struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	value string
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{capacity: capacity, order: list.New(), items: make(map[string]*list.Element, capacity)}
+}
+
+func (c *lru) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruItem).value, true
+}
+
+// Adds a value, evicting the least recently used one when the cache is full
+func (c *lru) Add(key string, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value.(*lruItem).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruItem{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *lru) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,125 @@
+/**
+* Package nlp holds the English lemmatizer shared by the services and the
+* tokenizer used to find the words of questions, passages and essays.
+* Loading the golem dictionary takes a long time, so a single lemmatizer
+* is built when the application starts and lemmas are cached.
+**/
+package nlp
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/aaaton/golem/v4"
+	"github.com/aaaton/golem/v4/dicts/en"
+)
+
+// Number of lemmas kept by the cache of a lemmatizer
+const DefaultCacheSize = 50000
+
+/**
+* English lemmatizer with a least recently used cache of lemmas. It is safe
+* for concurrent use, as the golem dictionary is only read.
+**/
+type Lemmatizer struct {
+	golem *golem.Lemmatizer
+	cache *lru
+}
+
+// Loads the English dictionary. A cache size of 0 disables the cache.
+func NewLemmatizer(cacheSize int) (*Lemmatizer, error) {
+	g, err := golem.New(en.New())
+	if err != nil {
+		return nil, err
+	}
+	l := &Lemmatizer{golem: g}
+	if cacheSize > 0 {
+		l.cache = newLRU(cacheSize)
+	}
+	return l, nil
+}
+
+/**
+* Returns the base form of a word, or the word itself when it is not in
+* the dictionary. Possessives such as "author's" and "authors'" are
+* lemmatized without their apostrophe.
+**/
+func (l *Lemmatizer) Lemma(word string) string {
+	if l.cache == nil {
+		return l.lemma(word)
+	}
+	if lemma, ok := l.cache.Get(word); ok {
+		return lemma
+	}
+	lemma := l.lemma(word)
+	l.cache.Add(word, lemma)
+	return lemma
+}
+
+// Whether the word is in the dictionary
+func (l *Lemmatizer) InDict(word string) bool {
+	return l.golem.InDict(word)
+}
+
+func (l *Lemmatizer) lemma(word string) string {
+	if !l.golem.InDict(word) {
+		if stem, ok := trimPossessive(word); ok {
+			return l.golem.Lemma(stem)
+		}
+	}
+	return l.golem.Lemma(word)
+}
+
+// Removes the possessive ending of a word
+func trimPossessive(word string) (string, bool) {
+	for _, suffix := range []string{"'s", "’s", "'S", "’S", "'", "’"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && stem != "" {
+			return stem, true
+		}
+	}
+	return word, false
+}
+
+/**
+* Splits text into words, keeping their case. Apostrophes and hyphens
+* within a word are kept, so that "author's", "don't" and "well-known"
+* are single words, while quotes, dashes and other punctuation around
+* words are dropped.
+**/
+func Tokenize(text string) []string {
+	words := make([]string, 0)
+	runes := []rune(text)
+	start := -1
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+		case isJoiner(r) && start >= 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]):
+			// Part of the word, such as the apostrophe of a contraction
+		default:
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+/**
+* Splits a hyphenated word into its parts, such as "well" and "known" for
+* "well-known". Other words are returned as is.
+**/
+func Parts(word string) []string {
+	return strings.FieldsFunc(word, func(r rune) bool { return r == '-' || r == '‐' })
+}
+
+// Apostrophes and hyphens that join the parts of a word
+func isJoiner(r rune) bool {
+	return r == '\'' || r == '’' || r == '-' || r == '‐'
+}
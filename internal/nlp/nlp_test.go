@@ -0,0 +1,165 @@
+package nlp
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aaaton/golem/v4"
+	"github.com/aaaton/golem/v4/dicts/en"
+)
+
+var (
+	sharedOnce       sync.Once
+	sharedLemmatizer *Lemmatizer
+)
+
+// Loading the dictionary is slow, so the tests share a lemmatizer
+func testLemmatizer(t testing.TB) *Lemmatizer {
+	sharedOnce.Do(func() {
+		l, err := NewLemmatizer(DefaultCacheSize)
+		if err != nil {
+			t.Fatalf("NewLemmatizer() error = %v", err)
+		}
+		sharedLemmatizer = l
+	})
+	return sharedLemmatizer
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"punctuation", "The critic's review (scathing!) was, indeed, terse.",
+			[]string{"The", "critic's", "review", "scathing", "was", "indeed", "terse"}},
+		{"contractions", "Don't they’re", []string{"Don't", "they’re"}},
+		{"hyphens", "a well-known, self-effacing author", []string{"a", "well-known", "self-effacing", "author"}},
+		{"quotes", `"laconic" and 'verbose' and “prolix”`, []string{"laconic", "and", "verbose", "and", "prolix"}},
+		{"dashes", "terse—even curt – replies", []string{"terse", "even", "curt", "replies"}},
+		{"plural possessive", "the authors' claims", []string{"the", "authors", "claims"}},
+		{"numbers", "in 1984 there were 3-4 drafts", []string{"in", "1984", "there", "were", "3", "4", "drafts"}},
+		{"empty", " -- ' ", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParts(t *testing.T) {
+	if got := Parts("well-known"); !reflect.DeepEqual(got, []string{"well", "known"}) {
+		t.Errorf("Parts() = %q", got)
+	}
+	if got := Parts("terse"); !reflect.DeepEqual(got, []string{"terse"}) {
+		t.Errorf("Parts() = %q", got)
+	}
+}
+
+func TestLemma(t *testing.T) {
+	l := testLemmatizer(t)
+	tests := map[string]string{
+		"abated":    "abate",
+		"ran":       "run",
+		"critic's":  "critic",
+		"critics’":  "critic",
+		"qwertyzzz": "qwertyzzz",
+	}
+	for word, want := range tests {
+		if got := l.Lemma(word); got != want {
+			t.Errorf("Lemma(%q) = %q, want %q", word, got, want)
+		}
+		// The second lookup is served from the cache
+		if got := l.Lemma(word); got != want {
+			t.Errorf("cached Lemma(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestLemmaConcurrent(t *testing.T) {
+	l := testLemmatizer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, word := range []string{"abated", "ran", "running", "words"} {
+				l.Lemma(word)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU(2)
+	c.Add("a", "1")
+	c.Add("b", "2")
+	c.Get("a")
+	c.Add("c", "3")
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Errorf("Get(a) = %q, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+}
+
+var benchmarkWords = Tokenize(`Although the critic's review was terse, even laconic, the
+	author's supporters abated their criticism; they were running out of well-known
+	arguments and the debates had become increasingly tedious for everyone involved.`)
+
+// Builds a golem lemmatizer per call, as the services used to do
+func BenchmarkLemmaNewPerCall(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		g, err := golem.New(en.New())
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, word := range benchmarkWords {
+			g.Lemma(word)
+		}
+	}
+}
+
+func BenchmarkLemmaShared(b *testing.B) {
+	l, err := NewLemmatizer(0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, word := range benchmarkWords {
+			l.Lemma(word)
+		}
+	}
+}
+
+func BenchmarkLemmaCached(b *testing.B) {
+	l := testLemmatizer(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, word := range benchmarkWords {
+			l.Lemma(word)
+		}
+	}
+}
+
+func BenchmarkLemmaCachedParallel(b *testing.B) {
+	l := testLemmatizer(b)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			l.Lemma(benchmarkWords[i%len(benchmarkWords)])
+			i++
+		}
+	})
+}
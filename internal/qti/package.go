@@ -0,0 +1,164 @@
+package qti
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"grepandit.com/api/internal/models"
+)
+
+const (
+	manifestName      = "imsmanifest.xml"
+	manifestNamespace = "http://www.imsglobal.org/xsd/imscp_v1p1"
+	itemResourceType  = "imsqti_item_xmlv2p1"
+)
+
+/**
+* An item of a content package. Err is set when the item could not be read
+* as a question, in which case Question is nil.
+**/
+type Entry struct {
+	Href     string
+	Question *models.VerbalQuestionRequest
+	Err      error
+}
+
+type manifest struct {
+	Resources []resource `xml:"resources>resource"`
+}
+
+type resource struct {
+	Identifier string `xml:"identifier,attr"`
+	Type       string `xml:"type,attr"`
+	Href       string `xml:"href,attr"`
+}
+
+/**
+* Reads the items of a QTI content package in the order of its manifest.
+* Fails when the package or its manifest can not be read, while the errors
+* of single items are returned in their entry.
+**/
+func ReadPackage(data []byte) ([]Entry, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[path.Clean(f.Name)] = f
+	}
+	manifestFile, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("%w: package has no %s", ErrInvalidItem, manifestName)
+	}
+	manifestData, err := readFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := xml.Unmarshal(manifestData, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidItem, manifestName, err.Error())
+	}
+	entries := make([]Entry, 0, len(m.Resources))
+	for _, r := range m.Resources {
+		// Tests and other resources of the package are not items
+		if !strings.HasPrefix(r.Type, "imsqti_item_xmlv2p") {
+			continue
+		}
+		entry := Entry{Href: r.Href}
+		f, ok := files[path.Clean(r.Href)]
+		if !ok {
+			entry.Err = fmt.Errorf("%w: %s is not in the package", ErrInvalidItem, r.Href)
+			entries = append(entries, entry)
+			continue
+		}
+		itemData, err := readFile(f)
+		if err == nil {
+			entry.Question, err = UnmarshalItem(itemData)
+		}
+		entry.Err = err
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalidItem, f.Name, err.Error())
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+/**
+* Writes a QTI content package. Items are streamed to the archive as they
+* are added and the manifest is written when the writer is closed.
+**/
+type Writer struct {
+	archive   *zip.Writer
+	resources []resource
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{archive: zip.NewWriter(w)}
+}
+
+// Adds a question to the package as an assessment item
+func (w *Writer) Add(q *models.VerbalQuestionRequest) error {
+	identifier := "item-" + strconv.Itoa(len(w.resources)+1)
+	if q.ID != 0 {
+		identifier = "item-" + strconv.Itoa(q.ID)
+	}
+	data, err := MarshalItem(q, identifier)
+	if err != nil {
+		return err
+	}
+	href := "items/" + identifier + ".xml"
+	f, err := w.archive.Create(href)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	w.resources = append(w.resources, resource{Identifier: identifier, Type: itemResourceType, Href: href})
+	return nil
+}
+
+// Writes the manifest and finishes the archive
+func (w *Writer) Close() error {
+	iw := newItemWriter()
+	iw.start("manifest", "xmlns", manifestNamespace, "identifier", "MANIFEST-1")
+	iw.start("metadata")
+	iw.element("schema", "QTIv2.1 Package")
+	iw.element("schemaversion", "1.0.0")
+	iw.end("metadata")
+	iw.empty("organizations")
+	iw.start("resources")
+	for _, r := range w.resources {
+		iw.start("resource", "identifier", r.Identifier, "type", r.Type, "href", r.Href)
+		iw.empty("file", "href", r.Href)
+		iw.end("resource")
+	}
+	iw.end("resources")
+	iw.end("manifest")
+	data, err := iw.bytes()
+	if err != nil {
+		return err
+	}
+	f, err := w.archive.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
@@ -0,0 +1,624 @@
+/**
+* Package qti converts verbal questions to and from IMS QTI 2.1 assessment
+* items and content packages. Multiple choice questions map to a
+* choiceInteraction and select sentence questions to a hottextInteraction
+* whose hottext elements are the sentences of the paragraph. The
+* justification of each option is kept as the feedback of its choice.
+**/
+package qti
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Namespaces of the documents written by the package
+const (
+	itemNamespace     = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	metadataNamespace = "http://grepandit.com/xsd/qti"
+)
+
+const responseIdentifier = "RESPONSE"
+
+// Returned when an item uses an interaction that has no verbal question equivalent
+var ErrUnsupportedInteraction = errors.New("unsupported interaction")
+
+// Returned when an item can not be mapped to a verbal question
+var ErrInvalidItem = errors.New("invalid item")
+
+/**
+* Writes a question as a QTI assessment item. The type, competence and
+* difficulty of the question are kept in attributes of the item in their
+* own namespace, which other tools ignore.
+**/
+func MarshalItem(q *models.VerbalQuestionRequest, identifier string) ([]byte, error) {
+	if len(q.Options) == 0 {
+		return nil, fmt.Errorf("%w: question has no options", ErrInvalidItem)
+	}
+	choiceIDs := make([]string, len(q.Options))
+	correct := make([]string, 0)
+	for i, option := range q.Options {
+		if q.FramedAs == models.SelectSentence {
+			choiceIDs[i] = "S" + strconv.Itoa(i+1)
+		} else {
+			choiceIDs[i] = choiceLetter(i)
+		}
+		if option.Correct {
+			correct = append(correct, choiceIDs[i])
+		}
+	}
+	cardinality := "single"
+	maxChoices := "1"
+	if q.FramedAs == models.MCQMultipleChoices {
+		cardinality = "multiple"
+		maxChoices = strconv.Itoa(len(q.Options))
+	}
+
+	w := newItemWriter()
+	attrs := []string{
+		"xmlns", itemNamespace,
+		"xmlns:gre", metadataNamespace,
+		"identifier", identifier,
+		"title", itemTitle(q),
+		"adaptive", "false",
+		"timeDependent", "false",
+	}
+	if q.Type != 0 {
+		attrs = append(attrs, "gre:type", q.Type.String())
+	}
+	if q.Competence != 0 {
+		attrs = append(attrs, "gre:competence", q.Competence.String())
+	}
+	if q.Difficulty != 0 {
+		attrs = append(attrs, "gre:difficulty", q.Difficulty.String())
+	}
+	w.start("assessmentItem", attrs...)
+
+	w.start("responseDeclaration", "identifier", responseIdentifier, "cardinality", cardinality, "baseType", "identifier")
+	w.start("correctResponse")
+	for _, id := range correct {
+		w.element("value", id)
+	}
+	w.end("correctResponse")
+	w.end("responseDeclaration")
+	w.empty("outcomeDeclaration", "identifier", "SCORE", "cardinality", "single", "baseType", "float")
+	w.empty("outcomeDeclaration", "identifier", "FEEDBACK", "cardinality", "multiple", "baseType", "identifier")
+
+	w.start("itemBody")
+	if q.FramedAs == models.SelectSentence {
+		w.start("hottextInteraction", "responseIdentifier", responseIdentifier, "maxChoices", maxChoices)
+		w.element("prompt", q.Question)
+		writeHottextParagraph(w, q.Paragraph, q.Options, choiceIDs)
+		w.end("hottextInteraction")
+	} else {
+		if strings.TrimSpace(q.Paragraph) != "" {
+			w.start("div", "class", "stimulus")
+			for _, line := range paragraphLines(q.Paragraph) {
+				w.element("p", line)
+			}
+			w.end("div")
+		}
+		w.start("choiceInteraction", "responseIdentifier", responseIdentifier, "shuffle", "false", "maxChoices", maxChoices)
+		w.element("prompt", q.Question)
+		for i, option := range q.Options {
+			w.element("simpleChoice", option.Value, "identifier", choiceIDs[i])
+		}
+		w.end("choiceInteraction")
+	}
+	w.end("itemBody")
+
+	// Scores the response and shows the feedback of every selected choice
+	w.start("responseProcessing")
+	w.start("responseCondition")
+	w.start("responseIf")
+	w.start("match")
+	w.empty("variable", "identifier", responseIdentifier)
+	w.empty("correct", "identifier", responseIdentifier)
+	w.end("match")
+	w.start("setOutcomeValue", "identifier", "SCORE")
+	w.element("baseValue", "1", "baseType", "float")
+	w.end("setOutcomeValue")
+	w.end("responseIf")
+	w.start("responseElse")
+	w.start("setOutcomeValue", "identifier", "SCORE")
+	w.element("baseValue", "0", "baseType", "float")
+	w.end("setOutcomeValue")
+	w.end("responseElse")
+	w.end("responseCondition")
+	w.start("setOutcomeValue", "identifier", "FEEDBACK")
+	w.start("multiple")
+	w.empty("variable", "identifier", responseIdentifier)
+	w.end("multiple")
+	w.end("setOutcomeValue")
+	w.end("responseProcessing")
+
+	for i, option := range q.Options {
+		if option.Justification != "" {
+			w.element("modalFeedback", option.Justification,
+				"outcomeIdentifier", "FEEDBACK", "identifier", choiceIDs[i], "showHide", "show")
+		}
+	}
+	w.end("assessmentItem")
+	return w.bytes()
+}
+
+/**
+* Reads a QTI assessment item as a question. Items with an
+* extendedTextInteraction or any other interaction than choice and hottext
+* fail with ErrUnsupportedInteraction, as free-response items have no
+* options to grade answers against. The type of items that were not
+* written by this package is inferred from their content, and their
+* difficulty defaults to medium. The options of a hottextInteraction are
+* read in the order their sentences appear in the paragraph.
+**/
+func UnmarshalItem(data []byte) (*models.VerbalQuestionRequest, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	q := &models.VerbalQuestionRequest{Vocabulary: make([]string, 0)}
+	var body itemBody
+	var correct []string
+	cardinality := ""
+	feedback := make(map[string]string)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "assessmentItem":
+			if err := readMetadata(se, q); err != nil {
+				return nil, err
+			}
+		case "responseDeclaration":
+			var decl responseDeclaration
+			if err := dec.DecodeElement(&decl, &se); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+			}
+			if decl.Identifier == responseIdentifier || correct == nil {
+				correct = decl.Values
+				cardinality = decl.Cardinality
+			}
+		case "itemBody":
+			if err := body.parse(dec); err != nil {
+				return nil, err
+			}
+		case "modalFeedback":
+			text, err := readText(dec)
+			if err != nil {
+				return nil, err
+			}
+			feedback[attr(se, "identifier")] = text
+		}
+	}
+	if body.interaction == "" {
+		return nil, fmt.Errorf("%w: item has no choice or hottext interaction", ErrInvalidItem)
+	}
+	if len(body.choices) == 0 {
+		return nil, fmt.Errorf("%w: item has no choices", ErrInvalidItem)
+	}
+	isCorrect := make(map[string]bool, len(correct))
+	for _, id := range correct {
+		isCorrect[strings.TrimSpace(id)] = true
+	}
+	q.Paragraph = strings.Join(body.blocks, "\n")
+	q.Question = body.prompt
+	q.Options = make([]models.Option, len(body.choices))
+	correctCount := 0
+	for i, c := range body.choices {
+		justification := c.feedback
+		if justification == "" {
+			justification = feedback[c.id]
+		}
+		q.Options[i] = models.Option{Value: c.text, Correct: isCorrect[c.id], Justification: justification}
+		if isCorrect[c.id] {
+			correctCount++
+		}
+	}
+	switch {
+	case body.interaction == "hottextInteraction":
+		q.FramedAs = models.SelectSentence
+	case cardinality == "multiple" || body.maxChoices != 1:
+		q.FramedAs = models.MCQMultipleChoices
+	default:
+		q.FramedAs = models.MCQSingleAnswer
+	}
+	if q.Type == 0 {
+		q.Type = inferType(q, correctCount)
+	}
+	if q.Difficulty == 0 {
+		q.Difficulty = models.Medium
+	}
+	return q, nil
+}
+
+/**
+* Infers the type of a question. Select sentence questions and questions
+* with a passage are reading comprehension, six options with two correct
+* answers are sentence equivalence and anything else is text completion.
+**/
+func inferType(q *models.VerbalQuestionRequest, correctCount int) models.QuestionType {
+	switch {
+	case q.FramedAs == models.SelectSentence:
+		return models.ReadingComprehension
+	case q.FramedAs == models.MCQMultipleChoices && len(q.Options) == 6 && correctCount == 2:
+		return models.SentenceEquivalence
+	case strings.Contains(q.Paragraph, "\n") || len(strings.Fields(q.Paragraph)) > 60:
+		return models.ReadingComprehension
+	default:
+		return models.TextCompletion
+	}
+}
+
+// Reads the type, competence and difficulty written by MarshalItem
+func readMetadata(se xml.StartElement, q *models.VerbalQuestionRequest) error {
+	fields := []struct {
+		name string
+		dest json.Unmarshaler
+	}{
+		{"type", &q.Type},
+		{"competence", &q.Competence},
+		{"difficulty", &q.Difficulty},
+	}
+	for _, field := range fields {
+		for _, a := range se.Attr {
+			if a.Name.Space == metadataNamespace && a.Name.Local == field.name {
+				if err := field.dest.UnmarshalJSON([]byte(strconv.Quote(a.Value))); err != nil {
+					return fmt.Errorf("%w: %s: %s", ErrInvalidItem, field.name, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type responseDeclaration struct {
+	Identifier  string   `xml:"identifier,attr"`
+	Cardinality string   `xml:"cardinality,attr"`
+	Values      []string `xml:"correctResponse>value"`
+}
+
+type choice struct {
+	id       string
+	text     string
+	feedback string
+}
+
+// Content of the item body that maps to a question
+type itemBody struct {
+	interaction string
+	maxChoices  int
+	prompt      string
+	choices     []choice
+	blocks      []string
+	current     strings.Builder
+}
+
+// Elements whose start and end separate the lines of the paragraph
+var blockElements = map[string]bool{
+	"p": true, "div": true, "blockquote": true, "br": true, "li": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// Walks the item body up to its end tag
+func (b *itemBody) parse(dec *xml.Decoder) error {
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			switch {
+			case name == "choiceInteraction":
+				if err := b.startInteraction(t); err != nil {
+					return err
+				}
+				if err := b.parseChoices(dec); err != nil {
+					return err
+				}
+			case name == "hottextInteraction":
+				if err := b.startInteraction(t); err != nil {
+					return err
+				}
+				depth++
+			case name == "extendedTextInteraction":
+				return fmt.Errorf("%w: %s is a free-response item that cannot be graded as a verbal question, "+
+					"create it as a writing prompt instead", ErrUnsupportedInteraction, name)
+			case strings.HasSuffix(name, "Interaction"):
+				return fmt.Errorf("%w: %s", ErrUnsupportedInteraction, name)
+			case name == "hottext":
+				text, err := readText(dec)
+				if err != nil {
+					return err
+				}
+				b.choices = append(b.choices, choice{id: attr(t, "identifier"), text: text})
+				b.current.WriteString(text)
+			case name == "prompt":
+				text, err := readText(dec)
+				if err != nil {
+					return err
+				}
+				b.prompt = text
+			case name == "feedbackInline" || name == "feedbackBlock" || name == "rubricBlock":
+				if err := dec.Skip(); err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+				}
+			default:
+				if blockElements[name] {
+					b.flush()
+				}
+				depth++
+			}
+		case xml.EndElement:
+			if blockElements[t.Name.Local] {
+				b.flush()
+			}
+			depth--
+		case xml.CharData:
+			b.current.Write(t)
+		}
+	}
+	b.flush()
+	return nil
+}
+
+func (b *itemBody) startInteraction(se xml.StartElement) error {
+	if b.interaction != "" {
+		return fmt.Errorf("%w: items with more than one interaction are not supported", ErrUnsupportedInteraction)
+	}
+	b.interaction = se.Name.Local
+	b.maxChoices = 1
+	if value := attr(se, "maxChoices"); value != "" {
+		maxChoices, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: maxChoices: %s", ErrInvalidItem, err.Error())
+		}
+		b.maxChoices = maxChoices
+	}
+	return nil
+}
+
+// Reads the prompt and choices of a choiceInteraction up to its end tag
+func (b *itemBody) parseChoices(dec *xml.Decoder) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "prompt":
+				if b.prompt, err = readText(dec); err != nil {
+					return err
+				}
+			case "simpleChoice":
+				c, err := readChoice(dec, t)
+				if err != nil {
+					return err
+				}
+				b.choices = append(b.choices, c)
+			default:
+				if err := dec.Skip(); err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// Finishes the current line of the paragraph
+func (b *itemBody) flush() {
+	if line := collapse(b.current.String()); line != "" {
+		b.blocks = append(b.blocks, line)
+	}
+	b.current.Reset()
+}
+
+// Reads a simpleChoice, whose feedbackInline is the justification of the option
+func readChoice(dec *xml.Decoder, se xml.StartElement) (choice, error) {
+	c := choice{id: attr(se, "identifier")}
+	var text strings.Builder
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return c, fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "feedbackInline" {
+				if c.feedback, err = readText(dec); err != nil {
+					return c, err
+				}
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	c.text = collapse(text.String())
+	return c, nil
+}
+
+// Reads the text content of the current element up to its end tag
+func readText(dec *xml.Decoder) (string, error) {
+	var text strings.Builder
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrInvalidItem, err.Error())
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	return collapse(text.String()), nil
+}
+
+/**
+* Writes the paragraph of a select sentence question with each option
+* marked as a hottext where it appears. Options that are not found in the
+* paragraph are added after it.
+**/
+func writeHottextParagraph(w *itemWriter, paragraph string, options []models.Option, ids []string) {
+	written := make([]bool, len(options))
+	for _, line := range paragraphLines(paragraph) {
+		w.start("p")
+		rest := line
+		for {
+			next, at := -1, len(rest)
+			for i, option := range options {
+				if written[i] || option.Value == "" {
+					continue
+				}
+				if pos := strings.Index(rest, option.Value); pos >= 0 && pos < at {
+					next, at = i, pos
+				}
+			}
+			if next < 0 {
+				break
+			}
+			w.text(rest[:at])
+			w.element("hottext", options[next].Value, "identifier", ids[next])
+			written[next] = true
+			rest = rest[at+len(options[next].Value):]
+		}
+		w.text(rest)
+		w.end("p")
+	}
+	for i, option := range options {
+		if !written[i] {
+			w.start("p")
+			w.element("hottext", option.Value, "identifier", ids[i])
+			w.end("p")
+		}
+	}
+}
+
+func paragraphLines(paragraph string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(paragraph, "\n") {
+		if line = collapse(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Title of an item, which is the start of its question
+func itemTitle(q *models.VerbalQuestionRequest) string {
+	words := strings.Fields(q.Question)
+	if len(words) > 8 {
+		return strings.Join(words[:8], " ") + "..."
+	}
+	if len(words) == 0 {
+		return q.FramedAs.String()
+	}
+	return strings.Join(words, " ")
+}
+
+func choiceLetter(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return "C" + strconv.Itoa(i+1)
+}
+
+// Collapses runs of whitespace the way markup is rendered
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Writes an XML document element by element, keeping the first error
+type itemWriter struct {
+	buf bytes.Buffer
+	enc *xml.Encoder
+	err error
+}
+
+func newItemWriter() *itemWriter {
+	w := &itemWriter{}
+	w.buf.WriteString(xml.Header)
+	w.enc = xml.NewEncoder(&w.buf)
+	return w
+}
+
+func (w *itemWriter) token(t xml.Token) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(t)
+	}
+}
+
+func (w *itemWriter) start(name string, attrs ...string) {
+	se := xml.StartElement{Name: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		se.Attr = append(se.Attr, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	w.token(se)
+}
+
+func (w *itemWriter) end(name string) {
+	w.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (w *itemWriter) text(s string) {
+	w.token(xml.CharData(s))
+}
+
+func (w *itemWriter) empty(name string, attrs ...string) {
+	w.start(name, attrs...)
+	w.end(name)
+}
+
+func (w *itemWriter) element(name string, text string, attrs ...string) {
+	w.start(name, attrs...)
+	w.text(text)
+	w.end(name)
+}
+
+func (w *itemWriter) bytes() ([]byte, error) {
+	if w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return w.buf.Bytes(), nil
+}
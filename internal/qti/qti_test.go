@@ -0,0 +1,267 @@
+package qti
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Zips a directory of testdata as a content package
+func samplePackage(t *testing.T, dir string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	root := filepath.Join("testdata", dir)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		f, err := archive.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readSample(t *testing.T) []Entry {
+	t.Helper()
+	entries, err := ReadPackage(samplePackage(t, "sample"))
+	if err != nil {
+		t.Fatalf("ReadPackage() error = %v", err)
+	}
+	return entries
+}
+
+func TestReadSamplePackage(t *testing.T) {
+	entries := readSample(t)
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want the 4 items of the manifest", len(entries))
+	}
+	tests := []struct {
+		href      string
+		framedAs  models.FramedAs
+		qType     models.QuestionType
+		paragraph string
+		question  string
+		options   []models.Option
+	}{
+		{
+			href:      "items/tc-1.xml",
+			framedAs:  models.MCQSingleAnswer,
+			qType:     models.TextCompletion,
+			paragraph: "Although the critic was known for her acerbic reviews, her assessment of the debut novel was surprisingly ______.",
+			question:  "Select the word that best completes the sentence.",
+			options: []models.Option{
+				{Value: "caustic", Justification: "Caustic matches her usual tone, not the contrast."},
+				{Value: "laudatory", Correct: true, Justification: "Although signals a contrast with acerbic."},
+				{Value: "scathing"},
+			},
+		},
+		{
+			href:      "items/se-1.xml",
+			framedAs:  models.MCQMultipleChoices,
+			qType:     models.SentenceEquivalence,
+			paragraph: "The senator's speech was so ______ that even her opponents praised its clarity.",
+			question:  "Select two answers that produce sentences alike in meaning.",
+			options: []models.Option{
+				{Value: "lucid", Correct: true, Justification: "Lucid means clear."},
+				{Value: "verbose", Justification: "Verbose speech is rarely praised for clarity."},
+				{Value: "opaque"},
+				{Value: "perspicuous", Correct: true, Justification: "Perspicuous means clearly expressed."},
+				{Value: "rambling"},
+				{Value: "strident"},
+			},
+		},
+		{
+			href:      "items/rc-1.xml",
+			framedAs:  models.SelectSentence,
+			qType:     models.ReadingComprehension,
+			paragraph: "Bees forage over wide areas. A mite infestation weakened the hive over the winter. By spring the colony had dwindled.\nBeekeepers now inspect hives more often. Inspections catch infestations early.",
+			question:  "Select the sentence that explains why the colony declined.",
+			options: []models.Option{
+				{Value: "Bees forage over wide areas."},
+				{Value: "A mite infestation weakened the hive over the winter.", Correct: true, Justification: "The infestation is given as the cause."},
+				{Value: "Inspections catch infestations early."},
+			},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.href, func(t *testing.T) {
+			entry := entries[i]
+			if entry.Href != tt.href {
+				t.Fatalf("entry %d is %s, want %s", i, entry.Href, tt.href)
+			}
+			if entry.Err != nil {
+				t.Fatalf("unexpected error %v", entry.Err)
+			}
+			q := entry.Question
+			if q.FramedAs != tt.framedAs || q.Type != tt.qType {
+				t.Errorf("got %s %s, want %s %s", q.Type, q.FramedAs, tt.qType, tt.framedAs)
+			}
+			if q.Difficulty != models.Medium {
+				t.Errorf("difficulty = %s, want the Medium default", q.Difficulty)
+			}
+			if q.Paragraph != tt.paragraph {
+				t.Errorf("paragraph = %q, want %q", q.Paragraph, tt.paragraph)
+			}
+			if q.Question != tt.question {
+				t.Errorf("question = %q, want %q", q.Question, tt.question)
+			}
+			if !reflect.DeepEqual(q.Options, tt.options) {
+				t.Errorf("options = %+v, want %+v", q.Options, tt.options)
+			}
+		})
+	}
+	if last := entries[3]; !errors.Is(last.Err, ErrUnsupportedInteraction) {
+		t.Errorf("extended text item error = %v, want ErrUnsupportedInteraction", last.Err)
+	} else if !strings.Contains(last.Err.Error(), "writing prompt") {
+		t.Errorf("extended text item error = %q, want it to point to writing prompts", last.Err)
+	}
+}
+
+// Writes the questions as a package and reads them back
+func roundTrip(t *testing.T, questions []models.VerbalQuestionRequest) []Entry {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := range questions {
+		if err := w.Add(&questions[i]); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	entries, err := ReadPackage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ReadPackage() error = %v", err)
+	}
+	if len(entries) != len(questions) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(questions))
+	}
+	return entries
+}
+
+func TestSamplePackageRoundTrip(t *testing.T) {
+	questions := make([]models.VerbalQuestionRequest, 0)
+	for _, entry := range readSample(t) {
+		if entry.Err == nil {
+			questions = append(questions, *entry.Question)
+		}
+	}
+	for i, entry := range roundTrip(t, questions) {
+		if entry.Err != nil {
+			t.Fatalf("%s: unexpected error %v", entry.Href, entry.Err)
+		}
+		if !reflect.DeepEqual(*entry.Question, questions[i]) {
+			t.Errorf("%s: got %+v, want %+v", entry.Href, *entry.Question, questions[i])
+		}
+	}
+}
+
+func TestQuestionRoundTrip(t *testing.T) {
+	questions := []models.VerbalQuestionRequest{
+		{
+			ID:         7,
+			Competence: models.IdentifyingAuthorsAssumptionsPerspective,
+			FramedAs:   models.MCQSingleAnswer,
+			Type:       models.ReadingComprehension,
+			Paragraph:  "The author argues that <cities> & suburbs \"converge\".\nA second paragraph follows.",
+			Question:   "The author assumes which of the following?",
+			Options: []models.Option{
+				{Value: "Suburbs grow faster", Justification: "Not stated."},
+				{Value: "Cities & suburbs share goals", Correct: true, Justification: "Implied by the argument."},
+			},
+			Difficulty: models.Hard,
+			Vocabulary: []string{},
+		},
+		{
+			FramedAs:   models.MCQMultipleChoices,
+			Type:       models.TextCompletion,
+			Question:   "Select all that apply.",
+			Options:    []models.Option{{Value: "one", Correct: true}, {Value: "two", Correct: true}, {Value: "three"}},
+			Difficulty: models.Easy,
+			Vocabulary: []string{},
+		},
+		{
+			Competence: models.SelectingImportantInfo,
+			FramedAs:   models.SelectSentence,
+			Type:       models.ReadingComprehension,
+			Paragraph:  "First claim. Second claim, with a clause.\nThird claim.",
+			Question:   "Select the sentence that states the main point.",
+			Options: []models.Option{
+				{Value: "Second claim, with a clause.", Correct: true, Justification: "It is the thesis."},
+				{Value: "Third claim."},
+				{Value: "First claim."},
+			},
+			Difficulty: models.Medium,
+			Vocabulary: []string{},
+		},
+	}
+	for i, entry := range roundTrip(t, questions) {
+		if entry.Err != nil {
+			t.Fatalf("%s: unexpected error %v", entry.Href, entry.Err)
+		}
+		want := questions[i]
+		want.ID = 0
+		got := *entry.Question
+		// Sentences are read back in the order they appear in the paragraph
+		if want.FramedAs == models.SelectSentence {
+			sortOptions(got.Options)
+			sortOptions(want.Options)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", entry.Href, *entry.Question, want)
+		}
+	}
+}
+
+func sortOptions(options []models.Option) {
+	sort.Slice(options, func(i, j int) bool { return options[i].Value < options[j].Value })
+}
+
+func TestUnmarshalItemErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		want error
+	}{
+		{"not xml", "<assessmentItem", ErrInvalidItem},
+		{"no interaction", `<assessmentItem><itemBody><p>Text</p></itemBody></assessmentItem>`, ErrInvalidItem},
+		{"no choices", `<assessmentItem><itemBody><choiceInteraction maxChoices="1"/></itemBody></assessmentItem>`, ErrInvalidItem},
+		{"extended text", `<assessmentItem><itemBody><extendedTextInteraction/></itemBody></assessmentItem>`, ErrUnsupportedInteraction},
+		{"unknown difficulty", `<assessmentItem xmlns:gre="http://grepandit.com/xsd/qti" gre:difficulty="Extreme"/>`, ErrInvalidItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnmarshalItem([]byte(tt.item)); !errors.Is(err, tt.want) {
+				t.Errorf("UnmarshalItem() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
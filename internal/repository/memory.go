@@ -0,0 +1,704 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+)
+
+/**
+* In-memory storage of every aggregate, meant for unit tests. It is safe
+* for concurrent use. Records are copied in and out, so changing a record
+* returned by the store does not change the stored one. Transactions run
+* one at a time and restore the records as they were when they fail, as a
+* rolled back transaction would.
+**/
+type Memory struct {
+	mu sync.RWMutex
+	// Held during a transaction, so that transactions run one at a time
+	txMu sync.Mutex
+	memoryState
+}
+
+// Records of the memory, which a failed transaction restores
+type memoryState struct {
+	nextID          int
+	questions       map[int]models.VerbalQuestion
+	questionWords   map[int][]int
+	passageWords    map[int][]int
+	words           map[int]models.Word
+	users           map[string]models.User
+	abilities       map[string][]models.UserAbility
+	stats           []models.UserVerbalStat
+	sessions        map[int]models.PracticeSession
+	quizResults     map[string][]quizResult
+	markedWords     map[string][]models.UserMarkedWord
+	markedQuestions map[string][]models.UserMarkedVerbalQuestion
+}
+
+// Answer of a user to a vocabulary quiz
+type quizResult struct {
+	wordID     int
+	correct    bool
+	answeredAt time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{memoryState: memoryState{
+		questions:       make(map[int]models.VerbalQuestion),
+		questionWords:   make(map[int][]int),
+		passageWords:    make(map[int][]int),
+		words:           make(map[int]models.Word),
+		users:           make(map[string]models.User),
+		abilities:       make(map[string][]models.UserAbility),
+		sessions:        make(map[int]models.PracticeSession),
+		quizResults:     make(map[string][]quizResult),
+		markedWords:     make(map[string][]models.UserMarkedWord),
+		markedQuestions: make(map[string][]models.UserMarkedVerbalQuestion),
+	}}
+}
+
+// Repositories backed by the memory
+func (m *Memory) Store() *Store {
+	store := m.repositories()
+	store.RunInTx = m.runInTx
+	return store
+}
+
+func (m *Memory) repositories() *Store {
+	return &Store{
+		Questions: memoryQuestions{m},
+		Words:     memoryWords{m},
+		Users:     memoryUsers{m},
+		Stats:     memoryStats{m},
+		Marks:     memoryMarks{m},
+		Sessions:  memorySessions{m},
+	}
+}
+
+/**
+* Runs fn with repositories of the memory, whose own transactions run
+* inline. The records are restored to their state before fn when it fails.
+**/
+func (m *Memory) runInTx(ctx context.Context, fn func(tx *Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	m.mu.RLock()
+	saved := m.memoryState.clone()
+	m.mu.RUnlock()
+	if err := fn(m.repositories()); err != nil {
+		m.mu.Lock()
+		m.memoryState = saved
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+/**
+* Copies the records. Records are replaced rather than changed in place,
+* except for the slices of the maps, which are copied as well.
+**/
+func (s memoryState) clone() memoryState {
+	c := s
+	c.questions = make(map[int]models.VerbalQuestion, len(s.questions))
+	for id, q := range s.questions {
+		c.questions[id] = q
+	}
+	c.questionWords = cloneIDs(s.questionWords)
+	c.passageWords = cloneIDs(s.passageWords)
+	c.words = make(map[int]models.Word, len(s.words))
+	for id, w := range s.words {
+		c.words[id] = w
+	}
+	c.users = make(map[string]models.User, len(s.users))
+	for token, u := range s.users {
+		c.users[token] = u
+	}
+	c.abilities = make(map[string][]models.UserAbility, len(s.abilities))
+	for token, abilities := range s.abilities {
+		c.abilities[token] = append([]models.UserAbility(nil), abilities...)
+	}
+	c.stats = append([]models.UserVerbalStat(nil), s.stats...)
+	c.sessions = make(map[int]models.PracticeSession, len(s.sessions))
+	for id, session := range s.sessions {
+		c.sessions[id] = session
+	}
+	c.quizResults = make(map[string][]quizResult, len(s.quizResults))
+	for token, results := range s.quizResults {
+		c.quizResults[token] = append([]quizResult(nil), results...)
+	}
+	c.markedWords = make(map[string][]models.UserMarkedWord, len(s.markedWords))
+	for token, marked := range s.markedWords {
+		c.markedWords[token] = append([]models.UserMarkedWord(nil), marked...)
+	}
+	c.markedQuestions = make(map[string][]models.UserMarkedVerbalQuestion, len(s.markedQuestions))
+	for token, marked := range s.markedQuestions {
+		c.markedQuestions[token] = append([]models.UserMarkedVerbalQuestion(nil), marked...)
+	}
+	return c
+}
+
+func cloneIDs(m map[int][]int) map[int][]int {
+	c := make(map[int][]int, len(m))
+	for id, ids := range m {
+		c[id] = append([]int(nil), ids...)
+	}
+	return c
+}
+
+// Adds or replaces a word
+func (m *Memory) AddWord(w models.Word) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.words[w.ID] = w
+}
+
+/**
+* Adds or replaces a question linked to the given vocabulary words. The
+* vocabulary of the question itself is ignored.
+**/
+func (m *Memory) AddQuestion(q models.VerbalQuestion, wordIDs ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q.Vocabulary = nil
+	m.questions[q.ID] = q
+	m.questionWords[q.ID] = append([]int(nil), wordIDs...)
+}
+
+// Links vocabulary words to a passage
+func (m *Memory) AddPassageWords(passageID int, wordIDs ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.passageWords[passageID] = append(m.passageWords[passageID], wordIDs...)
+}
+
+// Records the answer of a user to a vocabulary quiz
+func (m *Memory) AddQuizResult(userToken string, wordID int, correct bool, answeredAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quizResults[userToken] = append(m.quizResults[userToken], quizResult{wordID, correct, answeredAt})
+}
+
+// Answers recorded for a user in the order they were created
+func (m *Memory) Stats(userToken string) []models.UserVerbalStat {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := make([]models.UserVerbalStat, 0)
+	for _, stat := range m.stats {
+		if stat.UserToken == userToken {
+			stats = append(stats, stat)
+		}
+	}
+	return stats
+}
+
+func (m *Memory) id() int {
+	m.nextID++
+	return m.nextID
+}
+
+// Vocabulary of a question, including that of its passage, in alphabetical order
+func (m *Memory) vocabulary(q models.VerbalQuestion) []models.Word {
+	ids := m.questionWords[q.ID]
+	if q.PassageID != nil {
+		ids = append(append([]int(nil), ids...), m.passageWords[*q.PassageID]...)
+	}
+	return m.wordsByIDs(ids)
+}
+
+func (m *Memory) wordsByIDs(ids []int) []models.Word {
+	words := make([]models.Word, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if w, ok := m.words[id]; ok && !seen[id] {
+			seen[id] = true
+			words = append(words, w)
+		}
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i].Word < words[j].Word })
+	return words
+}
+
+type memoryQuestions struct {
+	m *Memory
+}
+
+func (r memoryQuestions) GetByID(ctx context.Context, id int) (*models.VerbalQuestion, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	q, ok := r.m.questions[id]
+	if !ok || q.DeletedAt != nil {
+		return nil, echo.ErrNotFound
+	}
+	q.Vocabulary = r.m.vocabulary(q)
+	return &q, nil
+}
+
+func (r memoryQuestions) GetByIDs(ctx context.Context, ids []int) ([]*models.VerbalQuestion, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	questions := make([]*models.VerbalQuestion, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		if q, ok := r.m.questions[id]; ok {
+			q.Vocabulary = r.m.vocabulary(q)
+			questions = append(questions, &q)
+		}
+	}
+	return questions, nil
+}
+
+func (r memoryQuestions) GetClosestToAbility(ctx context.Context, qType models.QuestionType,
+	competence models.Competence, theta float64, limit int, excludeIDs []int) ([]*models.VerbalQuestion, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	excluded := make(map[int]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		excluded[id] = true
+	}
+	questions := make([]*models.VerbalQuestion, 0)
+	for _, q := range r.m.questions {
+		if q.Type != qType || q.DeletedAt != nil || excluded[q.ID] || (competence != 0 && q.Competence != competence) {
+			continue
+		}
+		q := q
+		questions = append(questions, &q)
+	}
+	sort.Slice(questions, func(i, j int) bool {
+		di, dj := math.Abs(questions[i].IRT.B-theta), math.Abs(questions[j].IRT.B-theta)
+		if di != dj {
+			return di < dj
+		}
+		return questions[i].ID < questions[j].ID
+	})
+	if len(questions) > limit {
+		questions = questions[:limit]
+	}
+	return questions, nil
+}
+
+func (r memoryQuestions) GetVocabulary(ctx context.Context, questionIDs []int) (map[int][]models.Word, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	vocabulary := make(map[int][]models.Word)
+	for _, id := range questionIDs {
+		q, ok := r.m.questions[id]
+		if !ok {
+			continue
+		}
+		if words := r.m.vocabulary(q); len(words) > 0 {
+			vocabulary[id] = words
+		}
+	}
+	return vocabulary, nil
+}
+
+// The in-memory store returns the matching question with the lowest id, so that tests are repeatable
+func (r memoryQuestions) GetRandom(ctx context.Context, qType models.QuestionType, competence models.Competence,
+	difficulty models.Difficulty, excludeIDs []int) (*models.VerbalQuestion, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	var found *models.VerbalQuestion
+	for _, q := range r.m.questions {
+		if q.DeletedAt != nil || containsID(excludeIDs, q.ID) || (qType != 0 && q.Type != qType) ||
+			(competence != 0 && q.Competence != competence) || (difficulty != 0 && q.Difficulty != difficulty) {
+			continue
+		}
+		if found == nil || q.ID < found.ID {
+			q := q
+			found = &q
+		}
+	}
+	if found == nil {
+		return nil, echo.ErrNotFound
+	}
+	found.Vocabulary = nil
+	return found, nil
+}
+
+func (r memoryQuestions) GetPassageQuestionIDs(ctx context.Context, passageID int, excludeIDs []int) ([]int, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	ids := make([]int, 0)
+	for _, q := range r.m.questions {
+		if q.PassageID != nil && *q.PassageID == passageID && q.DeletedAt == nil && !containsID(excludeIDs, q.ID) {
+			ids = append(ids, q.ID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+type memoryWords struct {
+	m *Memory
+}
+
+func (r memoryWords) GetByIDs(ctx context.Context, ids []int) ([]models.Word, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return r.m.wordsByIDs(ids), nil
+}
+
+func (r memoryWords) GetQuestionWordIDs(ctx context.Context, questionIDs []int) ([]int, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	ids := make([]int, 0)
+	for _, id := range questionIDs {
+		ids = append(ids, r.m.questionWords[id]...)
+	}
+	return uniqueIDs(ids), nil
+}
+
+func (r memoryWords) GetMissedInQuizzes(ctx context.Context, userToken string) ([]int, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	latest := make(map[int]quizResult)
+	for _, result := range r.m.quizResults[userToken] {
+		if current, ok := latest[result.wordID]; !ok || result.answeredAt.After(current.answeredAt) {
+			latest[result.wordID] = result
+		}
+	}
+	ids := make([]int, 0)
+	for wordID, result := range latest {
+		if !result.correct {
+			ids = append(ids, wordID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+type memoryUsers struct {
+	m *Memory
+}
+
+func (r memoryUsers) Create(ctx context.Context, u *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if _, ok := r.m.users[u.Token]; ok {
+		return fmt.Errorf("User already exists")
+	}
+	u.ID = r.m.id()
+	r.m.users[u.Token] = copyUser(*u)
+	return nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, userToken string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	u, ok := r.m.users[userToken]
+	if !ok {
+		return nil, echo.ErrNotFound
+	}
+	u = copyUser(u)
+	return &u, nil
+}
+
+// Transactions of the in-memory store run one at a time, so the user needs no lock
+func (r memoryUsers) GetForUpdate(ctx context.Context, userToken string) (*models.User, error) {
+	return r.Get(ctx, userToken)
+}
+
+func (r memoryUsers) Update(ctx context.Context, u *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for token, existing := range r.m.users {
+		if existing.ID == u.ID {
+			delete(r.m.users, token)
+			r.m.users[u.Token] = copyUser(*u)
+			return nil
+		}
+	}
+	return echo.ErrNotFound
+}
+
+func (r memoryUsers) GetTokens(ctx context.Context) ([]string, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	users := make([]models.User, 0, len(r.m.users))
+	for _, u := range r.m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	tokens := make([]string, len(users))
+	for i, u := range users {
+		tokens[i] = u.Token
+	}
+	return tokens, nil
+}
+
+func (r memoryUsers) GetAbilities(ctx context.Context, userToken string) ([]models.UserAbility, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	abilities := append(make([]models.UserAbility, 0), r.m.abilities[userToken]...)
+	sort.Slice(abilities, func(i, j int) bool {
+		if abilities[i].Dimension != abilities[j].Dimension {
+			return abilities[i].Dimension < abilities[j].Dimension
+		}
+		return abilities[i].Category < abilities[j].Category
+	})
+	return abilities, nil
+}
+
+func (r memoryUsers) SaveAbility(ctx context.Context, userToken string, a *models.UserAbility) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	abilities := r.m.abilities[userToken]
+	for i := range abilities {
+		if abilities[i].Dimension == a.Dimension && abilities[i].Category == a.Category {
+			abilities[i] = *a
+			return nil
+		}
+	}
+	r.m.abilities[userToken] = append(abilities, *a)
+	return nil
+}
+
+// Copies the ability maps of a user
+func copyUser(u models.User) models.User {
+	copyMap := func(m map[string]int) map[string]int {
+		if m == nil {
+			return nil
+		}
+		c := make(map[string]int, len(m))
+		for k, v := range m {
+			c[k] = v
+		}
+		return c
+	}
+	u.VerbalAbility = copyMap(u.VerbalAbility)
+	u.QuantAbility = copyMap(u.QuantAbility)
+	return u
+}
+
+type memoryStats struct {
+	m *Memory
+}
+
+func (r memoryStats) Create(ctx context.Context, stat *models.UserVerbalStat) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	stat.ID = r.m.id()
+	r.m.stats = append(r.m.stats, *stat)
+	return nil
+}
+
+func (r memoryStats) GetByUser(ctx context.Context, userToken string) ([]models.UserVerbalStat, error) {
+	stats := r.m.Stats(userToken)
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Date.Before(stats[j].Date) })
+	return stats, nil
+}
+
+func (r memoryStats) GetBySession(ctx context.Context, userToken string, sessionID int) ([]models.UserVerbalStat, error) {
+	stats, _ := r.GetByUser(ctx, userToken)
+	inSession := make([]models.UserVerbalStat, 0)
+	for _, stat := range stats {
+		if stat.SessionID != nil && *stat.SessionID == sessionID {
+			inSession = append(inSession, stat)
+		}
+	}
+	return inSession, nil
+}
+
+func (r memoryStats) GetIncorrectQuestionIDs(ctx context.Context, userToken string) ([]int, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	ids := make([]int, 0)
+	for _, stat := range r.m.stats {
+		if stat.UserToken == userToken && !stat.Correct {
+			ids = append(ids, stat.QuestionID)
+		}
+	}
+	return uniqueIDs(ids), nil
+}
+
+type memorySessions struct {
+	m *Memory
+}
+
+func (r memorySessions) Create(ctx context.Context, session *models.PracticeSession) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	session.ID = r.m.id()
+	r.m.sessions[session.ID] = copySession(*session)
+	return nil
+}
+
+// Transactions of the in-memory store run one at a time, so the session needs no lock
+func (r memorySessions) GetForUpdate(ctx context.Context, userToken string, id int) (*models.PracticeSession, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	session, ok := r.m.sessions[id]
+	if !ok || session.UserToken != userToken {
+		return nil, echo.ErrNotFound
+	}
+	session = copySession(session)
+	return &session, nil
+}
+
+func (r memorySessions) GetByUser(ctx context.Context, userToken string) ([]models.PracticeSession, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	sessions := make([]models.PracticeSession, 0)
+	for _, session := range r.m.sessions {
+		if session.UserToken == userToken {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].StartedAt.Equal(sessions[j].StartedAt) {
+			return sessions[i].StartedAt.After(sessions[j].StartedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (r memorySessions) Update(ctx context.Context, session *models.PracticeSession) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	stored, ok := r.m.sessions[session.ID]
+	if !ok {
+		return echo.ErrNotFound
+	}
+	stored.Status = session.Status
+	stored.QuestionIDs = session.QuestionIDs
+	stored.Answered = session.Answered
+	stored.Correct = session.Correct
+	stored.Elapsed = session.Elapsed
+	stored.LastResumedAt = session.LastResumedAt
+	stored.FinishedAt = session.FinishedAt
+	r.m.sessions[session.ID] = copySession(stored)
+	return nil
+}
+
+// Copies the questions and the finish time of a session
+func copySession(session models.PracticeSession) models.PracticeSession {
+	session.QuestionIDs = append(make([]int, 0, len(session.QuestionIDs)), session.QuestionIDs...)
+	if session.FinishedAt != nil {
+		finishedAt := *session.FinishedAt
+		session.FinishedAt = &finishedAt
+	}
+	return session
+}
+
+type memoryMarks struct {
+	m *Memory
+}
+
+func (r memoryMarks) AddWords(ctx context.Context, userToken string, wordIDs []int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, id := range wordIDs {
+		if !containsMarkedWord(r.m.markedWords[userToken], id) {
+			r.m.markedWords[userToken] = append(r.m.markedWords[userToken],
+				models.UserMarkedWord{ID: r.m.id(), UserToken: userToken, WordID: id})
+		}
+	}
+	return nil
+}
+
+func (r memoryMarks) RemoveWords(ctx context.Context, userToken string, wordIDs []int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	kept := make([]models.UserMarkedWord, 0)
+	for _, marked := range r.m.markedWords[userToken] {
+		if !containsID(wordIDs, marked.WordID) {
+			kept = append(kept, marked)
+		}
+	}
+	r.m.markedWords[userToken] = kept
+	return nil
+}
+
+func (r memoryMarks) GetWords(ctx context.Context, userToken string) ([]models.UserMarkedWord, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	words := make([]models.UserMarkedWord, 0)
+	for _, marked := range r.m.markedWords[userToken] {
+		// Only marked words that exist are returned, as with a join
+		if w, ok := r.m.words[marked.WordID]; ok {
+			marked.Word = w
+			words = append(words, marked)
+		}
+	}
+	return words, nil
+}
+
+func (r memoryMarks) AddQuestions(ctx context.Context, userToken string, questionIDs []int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, id := range questionIDs {
+		if !containsMarkedQuestion(r.m.markedQuestions[userToken], id) {
+			r.m.markedQuestions[userToken] = append(r.m.markedQuestions[userToken],
+				models.UserMarkedVerbalQuestion{ID: r.m.id(), UserToken: userToken, VerbalQuestionID: id})
+		}
+	}
+	return nil
+}
+
+func (r memoryMarks) RemoveQuestions(ctx context.Context, userToken string, questionIDs []int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	kept := make([]models.UserMarkedVerbalQuestion, 0)
+	for _, marked := range r.m.markedQuestions[userToken] {
+		if !containsID(questionIDs, marked.VerbalQuestionID) {
+			kept = append(kept, marked)
+		}
+	}
+	r.m.markedQuestions[userToken] = kept
+	return nil
+}
+
+func (r memoryMarks) GetQuestions(ctx context.Context, userToken string) ([]models.UserMarkedVerbalQuestion, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+	return append(make([]models.UserMarkedVerbalQuestion, 0), r.m.markedQuestions[userToken]...), nil
+}
+
+func containsMarkedWord(marked []models.UserMarkedWord, wordID int) bool {
+	for _, m := range marked {
+		if m.WordID == wordID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMarkedQuestion(marked []models.UserMarkedVerbalQuestion, questionID int) bool {
+	for _, m := range marked {
+		if m.VerbalQuestionID == questionID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorted ids without duplicates
+func uniqueIDs(ids []int) []int {
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	sort.Ints(unique)
+	return unique
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+)
+
+func sampleMemory() *Memory {
+	m := NewMemory()
+	for _, w := range []models.Word{{ID: 1, Word: "terse"}, {ID: 2, Word: "abate"}, {ID: 3, Word: "laconic"}} {
+		m.AddWord(w)
+	}
+	passageID := 7
+	deletedAt := time.Now()
+	m.AddQuestion(models.VerbalQuestion{ID: 10, Type: models.TextCompletion, IRT: models.IRTParams{A: 1, B: -1}}, 1)
+	m.AddQuestion(models.VerbalQuestion{ID: 11, Type: models.TextCompletion, IRT: models.IRTParams{A: 1, B: 0.5}}, 2)
+	m.AddQuestion(models.VerbalQuestion{ID: 12, Type: models.TextCompletion, IRT: models.IRTParams{A: 1, B: 0.4},
+		DeletedAt: &deletedAt})
+	m.AddQuestion(models.VerbalQuestion{ID: 13, Type: models.ReadingComprehension, PassageID: &passageID,
+		Competence: models.ReasoningFromIncompleteData}, 1)
+	m.AddPassageWords(passageID, 3, 2)
+	return m
+}
+
+func TestMemoryQuestions(t *testing.T) {
+	ctx := context.Background()
+	store := sampleMemory().Store()
+	q, err := store.Questions.GetByID(ctx, 13)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	got := make([]string, 0)
+	for _, w := range q.Vocabulary {
+		got = append(got, w.Word)
+	}
+	if want := []string{"abate", "laconic", "terse"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("vocabulary = %v, want %v", got, want)
+	}
+	for _, id := range []int{12, 99} {
+		if _, err := store.Questions.GetByID(ctx, id); err != echo.ErrNotFound {
+			t.Errorf("GetByID(%d) error = %v, want not found", id, err)
+		}
+	}
+	questions, err := store.Questions.GetByIDs(ctx, []int{11, 99, 10, 12})
+	if err != nil || len(questions) != 3 {
+		t.Errorf("GetByIDs() = %v, %v, want 3 questions", questions, err)
+	}
+}
+
+func TestMemoryGetClosestToAbility(t *testing.T) {
+	ctx := context.Background()
+	store := sampleMemory().Store()
+	tests := []struct {
+		name       string
+		theta      float64
+		limit      int
+		excludeIDs []int
+		want       []int
+	}{
+		{"closest first without deleted", 0.6, 5, nil, []int{11, 10}},
+		{"limit", -0.8, 1, nil, []int{10}},
+		{"excluded", 0.6, 5, []int{11}, []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := store.Questions.GetClosestToAbility(ctx, models.TextCompletion, 0, tt.theta, tt.limit, tt.excludeIDs)
+			if err != nil {
+				t.Fatalf("GetClosestToAbility() error = %v", err)
+			}
+			got := make([]int, 0)
+			for _, q := range questions {
+				got = append(got, q.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetClosestToAbility() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	questions, _ := store.Questions.GetClosestToAbility(ctx, models.ReadingComprehension,
+		models.AnalyzingAndDrawingConclusions, 0, 5, nil)
+	if len(questions) != 0 {
+		t.Errorf("GetClosestToAbility() = %v, want no question of another competence", questions)
+	}
+}
+
+func TestMemoryMissedInQuizzes(t *testing.T) {
+	m := sampleMemory()
+	now := time.Now()
+	m.AddQuizResult("u1", 1, false, now.Add(-time.Hour))
+	m.AddQuizResult("u1", 1, true, now)
+	m.AddQuizResult("u1", 2, true, now.Add(-time.Hour))
+	m.AddQuizResult("u1", 2, false, now)
+	m.AddQuizResult("u2", 3, false, now)
+	got, err := m.Store().Words.GetMissedInQuizzes(context.Background(), "u1")
+	if err != nil || !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("GetMissedInQuizzes() = %v, %v, want [2]", got, err)
+	}
+}
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory().Store()
+	u := &models.User{Token: "u1", Email: "u1@example.com"}
+	if err := store.Users.Create(ctx, u); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := store.Users.Create(ctx, &models.User{Token: "u1"}); err == nil {
+		t.Error("Create() should reject an existing user")
+	}
+	u.VerbalAbility = map[string]int{"TextCompletion": 3}
+	if err := store.Users.Update(ctx, u); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	u.VerbalAbility["TextCompletion"] = 5
+	got, err := store.Users.Get(ctx, "u1")
+	if err != nil || got.VerbalAbility["TextCompletion"] != 3 {
+		t.Errorf("Get() = %+v, %v, want the stored ability", got, err)
+	}
+	for _, theta := range []float64{0.5, 1.5} {
+		err := store.Users.SaveAbility(ctx, "u1", &models.UserAbility{Dimension: "type", Category: "TextCompletion", Theta: theta})
+		if err != nil {
+			t.Fatalf("SaveAbility() error = %v", err)
+		}
+	}
+	abilities, _ := store.Users.GetAbilities(ctx, "u1")
+	if len(abilities) != 1 || abilities[0].Theta != 1.5 {
+		t.Errorf("GetAbilities() = %+v, want the replaced estimate", abilities)
+	}
+}
+
+func TestMemoryMarks(t *testing.T) {
+	ctx := context.Background()
+	store := sampleMemory().Store()
+	store.Marks.AddWords(ctx, "u1", []int{1, 2, 1, 99})
+	store.Marks.RemoveWords(ctx, "u1", []int{2})
+	words, _ := store.Marks.GetWords(ctx, "u1")
+	if len(words) != 1 || words[0].Word.Word != "terse" {
+		t.Errorf("GetWords() = %+v, want terse", words)
+	}
+	store.Marks.AddQuestions(ctx, "u1", []int{10, 11})
+	store.Marks.AddQuestions(ctx, "u1", []int{11})
+	store.Marks.RemoveQuestions(ctx, "u1", []int{10})
+	questions, _ := store.Marks.GetQuestions(ctx, "u1")
+	if len(questions) != 1 || questions[0].VerbalQuestionID != 11 {
+		t.Errorf("GetQuestions() = %+v, want question 11", questions)
+	}
+}
+
+func TestMemoryInTx(t *testing.T) {
+	ctx := context.Background()
+	m := sampleMemory()
+	store := m.Store()
+	store.Users.Create(ctx, &models.User{Token: "u1"})
+	failed := errors.New("failed")
+	err := store.InTx(ctx, func(tx *Store) error {
+		if err := tx.Users.Create(ctx, &models.User{Token: "u2"}); err != nil {
+			return err
+		}
+		tx.Marks.AddWords(ctx, "u1", []int{1})
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("InTx() error = %v, want %v", err, failed)
+	}
+	if _, err := store.Users.Get(ctx, "u2"); err != echo.ErrNotFound {
+		t.Errorf("Get() error = %v, want the user of the failed transaction rolled back", err)
+	}
+	if words, _ := store.Marks.GetWords(ctx, "u1"); len(words) != 0 {
+		t.Errorf("GetWords() = %+v, want the marks of the failed transaction rolled back", words)
+	}
+	err = store.InTx(ctx, func(tx *Store) error {
+		return tx.Users.Create(ctx, &models.User{Token: "u2"})
+	})
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+	if _, err := store.Users.Get(ctx, "u2"); err != nil {
+		t.Errorf("Get() error = %v, want the user of the committed transaction", err)
+	}
+}
+
+func TestMemorySessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory().Store()
+	now := time.Now()
+	first := &models.PracticeSession{UserToken: "u1", Status: models.SessionActive, QuestionIDs: []int{10},
+		StartedAt: now.Add(-time.Hour)}
+	second := &models.PracticeSession{UserToken: "u1", Status: models.SessionActive, StartedAt: now}
+	for _, session := range []*models.PracticeSession{first, second} {
+		if err := store.Sessions.Create(ctx, session); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	if _, err := store.Sessions.GetForUpdate(ctx, "u2", first.ID); err != echo.ErrNotFound {
+		t.Errorf("GetForUpdate() error = %v, want not found for another user", err)
+	}
+	session, err := store.Sessions.GetForUpdate(ctx, "u1", first.ID)
+	if err != nil {
+		t.Fatalf("GetForUpdate() error = %v", err)
+	}
+	session.QuestionIDs = append(session.QuestionIDs, 11)
+	session.Answered = 1
+	if err := store.Sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	session.QuestionIDs[0] = 99
+	sessions, err := store.Sessions.GetByUser(ctx, "u1")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("GetByUser() = %+v, %v, want 2 sessions", sessions, err)
+	}
+	if sessions[0].ID != second.ID {
+		t.Errorf("GetByUser() = %+v, want the most recent session first", sessions)
+	}
+	if got := sessions[1]; got.Answered != 1 || !reflect.DeepEqual(got.QuestionIDs, []int{10, 11}) {
+		t.Errorf("session = %+v, want the stored update", got)
+	}
+}
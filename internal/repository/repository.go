@@ -0,0 +1,119 @@
+/**
+* Package repository defines the storage of each aggregate used by the
+* services, so that they can run against PostgreSQL or against the
+* in-memory store of this package in unit tests. The PostgreSQL store is
+* built by services.NewPostgresStore, next to the SQL helpers it shares
+* with the rest of the services. Lookups of a single record return
+* echo.ErrNotFound when it does not exist, as the services do.
+**/
+package repository
+
+import (
+	"context"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Verbal questions with their vocabulary
+type QuestionRepository interface {
+	// Question that has not been deleted, so that it can be served and answered
+	GetByID(ctx context.Context, id int) (*models.VerbalQuestion, error)
+	// Questions in no particular order, deleted ones included, skipping the ids that do not exist
+	GetByIDs(ctx context.Context, ids []int) ([]*models.VerbalQuestion, error)
+	/**
+	* Returns at most limit active questions of a type, and of a competence
+	* unless it is zero, whose difficulty parameter is the closest to theta.
+	**/
+	GetClosestToAbility(ctx context.Context, qType models.QuestionType, competence models.Competence,
+		theta float64, limit int, excludeIDs []int) ([]*models.VerbalQuestion, error)
+	// Vocabulary of each question, including the vocabulary of its passage
+	GetVocabulary(ctx context.Context, questionIDs []int) (map[int][]models.Word, error)
+	/**
+	* Returns an active question at random, without its vocabulary, that
+	* matches the type, competence and difficulty that are not zero.
+	**/
+	GetRandom(ctx context.Context, qType models.QuestionType, competence models.Competence,
+		difficulty models.Difficulty, excludeIDs []int) (*models.VerbalQuestion, error)
+	// Ids of the active questions of a passage in order, leaving out the excluded ones
+	GetPassageQuestionIDs(ctx context.Context, passageID int, excludeIDs []int) ([]int, error)
+}
+
+// Vocabulary words
+type WordRepository interface {
+	// Words in alphabetical order, skipping the ids that do not exist
+	GetByIDs(ctx context.Context, ids []int) ([]models.Word, error)
+	// Ids of the words linked to the questions, without the vocabulary of their passages
+	GetQuestionWordIDs(ctx context.Context, questionIDs []int) ([]int, error)
+	// Ids of the words whose latest vocabulary quiz answer by the user is incorrect
+	GetMissedInQuizzes(ctx context.Context, userToken string) ([]int, error)
+}
+
+// Users and their ability estimates
+type UserRepository interface {
+	Create(ctx context.Context, u *models.User) error
+	Get(ctx context.Context, userToken string) (*models.User, error)
+	// Get that locks the user until the end of the transaction of the store
+	GetForUpdate(ctx context.Context, userToken string) (*models.User, error)
+	Update(ctx context.Context, u *models.User) error
+	// Tokens of every user in the order the users were created
+	GetTokens(ctx context.Context) ([]string, error)
+	GetAbilities(ctx context.Context, userToken string) ([]models.UserAbility, error)
+	// Inserts or replaces the estimate of the dimension and category of the ability
+	SaveAbility(ctx context.Context, userToken string, a *models.UserAbility) error
+}
+
+// Answers of users to verbal questions
+type StatsRepository interface {
+	Create(ctx context.Context, stat *models.UserVerbalStat) error
+	// Answers of a user in the order they were recorded, without their vocabulary
+	GetByUser(ctx context.Context, userToken string) ([]models.UserVerbalStat, error)
+	// Answers of a user during a practice session in the order they were recorded
+	GetBySession(ctx context.Context, userToken string, sessionID int) ([]models.UserVerbalStat, error)
+	// Ids of the questions the user has answered incorrectly at least once
+	GetIncorrectQuestionIDs(ctx context.Context, userToken string) ([]int, error)
+}
+
+// Practice sessions of users, including the sections of mock exams
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.PracticeSession) error
+	// Session of the user that is locked until the end of the transaction of the store
+	GetForUpdate(ctx context.Context, userToken string, id int) (*models.PracticeSession, error)
+	// Sessions of the user, most recent first
+	GetByUser(ctx context.Context, userToken string) ([]models.PracticeSession, error)
+	// Saves the status, questions, answers and clock of a session
+	Update(ctx context.Context, session *models.PracticeSession) error
+}
+
+// Words and questions marked by users
+type MarkRepository interface {
+	AddWords(ctx context.Context, userToken string, wordIDs []int) error
+	RemoveWords(ctx context.Context, userToken string, wordIDs []int) error
+	GetWords(ctx context.Context, userToken string) ([]models.UserMarkedWord, error)
+	AddQuestions(ctx context.Context, userToken string, questionIDs []int) error
+	RemoveQuestions(ctx context.Context, userToken string, questionIDs []int) error
+	GetQuestions(ctx context.Context, userToken string) ([]models.UserMarkedVerbalQuestion, error)
+}
+
+// Storage of every aggregate
+type Store struct {
+	Questions QuestionRepository
+	Words     WordRepository
+	Users     UserRepository
+	Stats     StatsRepository
+	Marks     MarkRepository
+	Sessions  SessionRepository
+	// Runs fn with a store in a new transaction, nil when the store cannot start one
+	RunInTx func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+/**
+* Runs fn with a store whose repositories share a single transaction, which
+* is committed when fn returns nil and rolled back otherwise. A store that
+* is already in a transaction runs fn on itself.
+**/
+func (s *Store) InTx(ctx context.Context, fn func(tx *Store) error) error {
+	if s.RunInTx == nil {
+		return fn(s)
+	}
+	return s.RunInTx(ctx, fn)
+}
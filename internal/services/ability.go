@@ -0,0 +1,87 @@
+package services
+
+import (
+	"math"
+
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+)
+
+// Upper bound of the legacy verbal ability score stored on the user
+const maxLegacyAbility = 4500
+
+/**
+* Returns the item response theory parameters assigned to a question that
+* has not been calibrated yet. The difficulty label is mapped onto the
+* ability scale and single answer questions get a guessing parameter based
+* on the number of options.
+**/
+func DefaultIRTParams(difficulty models.Difficulty, framedAs models.FramedAs, numOptions int) models.IRTParams {
+	params := models.IRTParams{A: 1}
+	switch difficulty {
+	case models.Easy:
+		params.B = -1
+	case models.Hard:
+		params.B = 1
+	}
+	if framedAs == models.MCQSingleAnswer && numOptions > 0 {
+		params.C = 1 / float64(numOptions)
+	}
+	return params
+}
+
+/**
+* Returns the default item response theory parameters of a quant question.
+* Quantitative comparison and single answer questions can be guessed, while
+* multiple answer and numeric entry questions practically cannot.
+**/
+func DefaultQuantIRTParams(difficulty models.Difficulty, qType models.QuantQuestionType, numOptions int) models.IRTParams {
+	framedAs := models.MCQMultipleChoices
+	if qType == models.QuantitativeComparison || qType == models.QuantMCQSingleAnswer {
+		framedAs = models.MCQSingleAnswer
+	}
+	return DefaultIRTParams(difficulty, framedAs, numOptions)
+}
+
+/**
+* Maps the difficulty parameter of a question back onto a difficulty label
+* using the midpoints between the default parameters of each label.
+**/
+func DifficultyFromIRT(p models.IRTParams) models.Difficulty {
+	switch {
+	case p.B < -0.5:
+		return models.Easy
+	case p.B > 0.5:
+		return models.Hard
+	default:
+		return models.Medium
+	}
+}
+
+// Converts the stored parameters of a question for use by the irt package
+func irtParams(p models.IRTParams) irt.Params {
+	return irt.Params{A: p.A, B: p.B, C: p.C}
+}
+
+/**
+* Finds the estimate of a user for a dimension and category. Returns the
+* prior when the user has not answered questions in that category yet.
+**/
+func findAbility(abilities []models.UserAbility, dimension string, category string) irt.Estimate {
+	for _, a := range abilities {
+		if a.Dimension == dimension && a.Category == category {
+			return irt.Estimate{Theta: a.Theta, SE: a.StandardError, Responses: a.Responses}
+		}
+	}
+	return irt.Prior()
+}
+
+/**
+* Projects an ability estimate onto the 0 to 4500 scale of the verbal
+* ability score stored on the user, which is still used by clients to
+* display progress.
+**/
+func legacyAbilityScore(theta float64) int {
+	score := math.Round(maxLegacyAbility/2 + theta*maxLegacyAbility/6)
+	return int(math.Max(0, math.Min(maxLegacyAbility, score)))
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+var ErrInvalidScoringModel = errors.New("invalid scoring model")
+
+// Estimates below this difference are the same, as they went through the database
+const replayTolerance = 1e-9
+
+// Estimates an ability from the responses of a category in the order they were given
+type scoringModel func(responses []irt.Response) irt.Estimate
+
+var scoringModels = map[models.ScoringModel]scoringModel{
+	models.ScoringIRTEAP:      incrementalEAP,
+	models.ScoringIRTEAPBatch: batchEAP,
+}
+
+// The updates UpdateUserPerformance makes as the answers are recorded
+func incrementalEAP(responses []irt.Response) irt.Estimate {
+	estimate := irt.Prior()
+	for _, r := range responses {
+		estimate = irt.Update(estimate, r.Params, r.Correct)
+	}
+	return estimate
+}
+
+func batchEAP(responses []irt.Response) irt.Estimate {
+	return irt.EAP(irt.Prior(), responses)
+}
+
+// Returns the scoring model of the request, the incremental EAP by default
+func replayScoringModel(name models.ScoringModel) (models.ScoringModel, scoringModel, error) {
+	if name == "" {
+		name = models.ScoringIRTEAP
+	}
+	model, ok := scoringModels[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q, use one of %v", ErrInvalidScoringModel, name, models.ScoringModels)
+	}
+	return name, model, nil
+}
+
+/**
+* Replays verbal stats under a scoring model with the current IRT parameters
+* of the questions. Stats are replayed in date order, stats recorded at the
+* same time in the order they are given, so the result only depends on the
+* stats and the questions. Stats of questions that are not in questions are
+* skipped and counted. Categories are kept at the prior until they have a
+* response.
+**/
+func replayVerbalAbilities(model scoringModel, stats []models.UserVerbalStat, questions map[int]*models.VerbalQuestion,
+	categories []abilityCategory) (map[abilityCategory]irt.Estimate, int) {
+	ordered := make([]models.UserVerbalStat, len(stats))
+	copy(ordered, stats)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Date.Before(ordered[j].Date) })
+
+	responses := make(map[abilityCategory][]irt.Response)
+	for _, c := range categories {
+		responses[c] = nil
+	}
+	skipped := 0
+	for _, stat := range ordered {
+		question, ok := questions[stat.QuestionID]
+		if !ok {
+			skipped++
+			continue
+		}
+		for _, c := range verbalAbilityCategories(question) {
+			responses[c] = append(responses[c], irt.Response{Params: irtParams(question.IRT), Correct: stat.Correct})
+		}
+	}
+	estimates := make(map[abilityCategory]irt.Estimate, len(responses))
+	for c, r := range responses {
+		if len(r) == 0 {
+			estimates[c] = irt.Prior()
+			continue
+		}
+		estimates[c] = model(r)
+	}
+	return estimates, skipped
+}
+
+/**
+* Rebuilds the verbal ability estimates of a user by replaying the verbal
+* stats of the user under the scoring model of the request. Estimates of
+* categories the user no longer has stats for go back to the prior. The
+* replayed abilities are compared with the stored ones and only saved when
+* the request applies them and they differ. An applied replay runs in a
+* single transaction that locks the user, so that an answer recorded
+* meanwhile waits for it instead of being overwritten.
+**/
+func (s *UserVerbalStatsService) ReplayAbilities(ctx context.Context, userToken string,
+	req models.AbilityReplayRequest) (*models.AbilityReplay, error) {
+	name, model, err := replayScoringModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Apply {
+		return replayAbilities(ctx, s.Store, userToken, name, model, false)
+	}
+	var replay *models.AbilityReplay
+	err = s.Store.InTx(ctx, func(tx *repository.Store) error {
+		replay, err = replayAbilities(ctx, tx, userToken, name, model, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// Replays the verbal stats of a user with the repositories of the store, see ReplayAbilities
+func replayAbilities(ctx context.Context, store *repository.Store, userToken string, name models.ScoringModel,
+	model scoringModel, apply bool) (*models.AbilityReplay, error) {
+	getUser := store.Users.Get
+	if apply {
+		getUser = store.Users.GetForUpdate
+	}
+	user, err := getUser(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := store.Stats.GetByUser(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	questionIDs := make([]int, 0, len(stats))
+	for _, stat := range stats {
+		if !contains(questionIDs, stat.QuestionID) {
+			questionIDs = append(questionIDs, stat.QuestionID)
+		}
+	}
+	questions, err := store.Questions.GetByIDs(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	questionsByID := make(map[int]*models.VerbalQuestion, len(questions))
+	for _, q := range questions {
+		questionsByID[q.ID] = q
+	}
+	abilities, err := store.Users.GetAbilities(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[abilityCategory]models.UserAbility)
+	categories := make([]abilityCategory, 0)
+	for _, a := range abilities {
+		if a.Dimension == models.AbilityDimensionType || a.Dimension == models.AbilityDimensionCompetence {
+			c := abilityCategory{a.Dimension, a.Category}
+			stored[c] = a
+			categories = append(categories, c)
+		}
+	}
+	estimates, skipped := replayVerbalAbilities(model, stats, questionsByID, categories)
+
+	replay := &models.AbilityReplay{
+		UserToken:           userToken,
+		Model:               name,
+		Replayed:            len(stats) - skipped,
+		Skipped:             skipped,
+		Abilities:           make([]models.AbilityChange, 0, len(estimates)),
+		VerbalAbilityBefore: nonNilAbilities(user.VerbalAbility),
+		VerbalAbilityAfter:  make(map[string]int),
+	}
+	for _, c := range sortedCategories(estimates) {
+		estimate := estimates[c]
+		change := models.AbilityChange{
+			Dimension: c.dimension,
+			Category:  c.category,
+			After:     models.AbilityEstimate{Theta: estimate.Theta, StandardError: estimate.SE, Responses: estimate.Responses},
+			Changed:   true,
+		}
+		if a, ok := stored[c]; ok {
+			change.Before = &models.AbilityEstimate{Theta: a.Theta, StandardError: a.StandardError, Responses: a.Responses}
+			change.Changed = !sameEstimate(*change.Before, change.After)
+		}
+		replay.Changed = replay.Changed || change.Changed
+		replay.Abilities = append(replay.Abilities, change)
+		if c.dimension == models.AbilityDimensionType && estimate.Responses > 0 {
+			replay.VerbalAbilityAfter[c.category] = legacyAbilityScore(estimate.Theta)
+		}
+	}
+	if !reflect.DeepEqual(replay.VerbalAbilityBefore, replay.VerbalAbilityAfter) {
+		replay.Changed = true
+	}
+	if !apply || !replay.Changed {
+		return replay, nil
+	}
+
+	now := time.Now()
+	for _, change := range replay.Abilities {
+		if !change.Changed {
+			continue
+		}
+		err = store.Users.SaveAbility(ctx, userToken, &models.UserAbility{
+			Dimension:     change.Dimension,
+			Category:      change.Category,
+			Theta:         change.After.Theta,
+			StandardError: change.After.StandardError,
+			Responses:     change.After.Responses,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	user.VerbalAbility = replay.VerbalAbilityAfter
+	if err := store.Users.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	replay.Applied = true
+	return replay, nil
+}
+
+/**
+* Replays the verbal stats of every user. A user that fails is reported
+* and does not stop the others. Only the replays of the users whose
+* abilities changed are included in the report.
+**/
+func (s *UserVerbalStatsService) ReplayAllAbilities(ctx context.Context, req models.AbilityReplayRequest) (*models.AbilityReplayReport, error) {
+	name, _, err := replayScoringModel(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = name
+	tokens, err := s.Store.Users.GetTokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.AbilityReplayReport{
+		Model:             name,
+		Apply:             req.Apply,
+		MaintenanceReport: models.MaintenanceReport{Errors: make([]models.MaintenanceError, 0)},
+		Users:             make([]models.AbilityReplay, 0),
+	}
+	for _, token := range tokens {
+		report.Processed++
+		replay, err := s.ReplayAbilities(ctx, token, req)
+		if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, models.MaintenanceError{Item: token, Error: err.Error()})
+			continue
+		}
+		if replay.Changed {
+			report.Changed++
+			report.Users = append(report.Users, *replay)
+		}
+	}
+	return report, nil
+}
+
+// Categories ordered by dimension then category, so that replays are saved and reported in a stable order
+func sortedCategories(estimates map[abilityCategory]irt.Estimate) []abilityCategory {
+	categories := make([]abilityCategory, 0, len(estimates))
+	for c := range estimates {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].dimension != categories[j].dimension {
+			return categories[i].dimension < categories[j].dimension
+		}
+		return categories[i].category < categories[j].category
+	})
+	return categories
+}
+
+func sameEstimate(a, b models.AbilityEstimate) bool {
+	return a.Responses == b.Responses &&
+		math.Abs(a.Theta-b.Theta) <= replayTolerance &&
+		math.Abs(a.StandardError-b.StandardError) <= replayTolerance
+}
+
+// Empty map in place of a nil one, so that both compare equal
+func nonNilAbilities(m map[string]int) map[string]int {
+	if m == nil {
+		return map[string]int{}
+	}
+	return m
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+)
+
+// Minimum number of responses used when a calibration request does not set one
+const defaultMinCalibrationResponses = 30
+
+// Errors returned when a calibration proposal can no longer be applied
+var (
+	ErrCalibrationApplied = errors.New("calibration has already been applied")
+	ErrCalibrationStale   = errors.New("question parameters changed after the calibration was computed")
+)
+
+type CalibrationService struct {
+	DB *pgxpool.Pool
+}
+
+func NewCalibrationService(db *pgxpool.Pool) *CalibrationService {
+	return &CalibrationService{DB: db}
+}
+
+// Actions of the revisions that change the content a question is answered on
+var contentRevisionActions = []string{string(models.RevisionCreate), string(models.RevisionUpdate)}
+
+// Responses collected for a single question during calibration
+type questionResponses struct {
+	question  models.VerbalQuestion
+	responses []irt.CalibrationResponse
+	durations []int
+}
+
+/**
+* Aggregates the verbal stats of every question and proposes new item
+* response theory parameters and a difficulty label for it. The ability of
+* each respondent is taken from their current estimate for the question
+* type. Only the answers to the current content of a question are used:
+* stats recorded before the last revision that changed its content are left
+* out, while the revisions made by calibrations only change the parameters
+* and keep the earlier answers. Every proposal is recorded in the
+* calibration history and applied to the question on behalf of the editor
+* when requested.
+**/
+func (s *CalibrationService) Calibrate(ctx context.Context, req models.CalibrationRequest,
+	editor models.User) ([]models.QuestionCalibration, error) {
+	if req.MinResponses <= 0 {
+		req.MinResponses = defaultMinCalibrationResponses
+	}
+	thetas, err := s.getTypeAbilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := squirrel.Select(
+		"q."+database.VerbalQuestionsIDField,
+		"q."+database.VerbalQuestionsTypeField,
+		"q."+database.VerbalQuestionsDifficultyField,
+		"q."+database.VerbalQuestionsIRTAField,
+		"q."+database.VerbalQuestionsIRTBField,
+		"q."+database.VerbalQuestionsIRTCField,
+		"vs."+database.VerbalStatsUserField,
+		"vs."+database.VerbalStatsCorrectField,
+		"COALESCE(vs."+database.VerbalStatsDurationField+", 0)",
+	).
+		From(database.VerbalStatsTable+" AS vs").
+		Join(database.VerbalQuestionsTable+" AS q ON vs."+database.VerbalStatsQuestionField+" = q."+database.VerbalQuestionsIDField).
+		Where("q."+database.VerbalQuestionsDeletedAtField+" IS NULL").
+		Where("vs."+database.VerbalStatsRevisionField+" >= ("+
+			"SELECT COALESCE(MAX(r."+database.VerbalQuestionRevisionsRevisionField+"), 0) "+
+			"FROM "+database.VerbalQuestionRevisionsTable+" AS r "+
+			"WHERE r."+database.VerbalQuestionRevisionsQuestionField+" = q."+database.VerbalQuestionsIDField+
+			" AND r."+database.VerbalQuestionRevisionsActionField+" = ANY(?))", contentRevisionActions).
+		OrderBy("q." + database.VerbalQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	if len(req.QuestionIDs) > 0 {
+		query = query.Where(squirrel.Eq{"q." + database.VerbalQuestionsIDField: req.QuestionIDs})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	grouped := make([]*questionResponses, 0)
+	var current *questionResponses
+	for rows.Next() {
+		var q models.VerbalQuestion
+		var userToken string
+		var correct bool
+		var duration int
+		err := rows.Scan(&q.ID, &q.Type, &q.Difficulty, &q.IRT.A, &q.IRT.B, &q.IRT.C, &userToken, &correct, &duration)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || current.question.ID != q.ID {
+			current = &questionResponses{question: q}
+			grouped = append(grouped, current)
+		}
+		theta, ok := thetas[userToken][q.Type.String()]
+		if !ok {
+			theta = irt.Prior().Theta
+		}
+		current.responses = append(current.responses, irt.CalibrationResponse{Theta: theta, Correct: correct})
+		current.durations = append(current.durations, duration)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	calibrations := make([]models.QuestionCalibration, 0)
+	for _, g := range grouped {
+		if len(g.responses) < req.MinResponses {
+			continue
+		}
+		params, stats := irt.EstimateItem(irtParams(g.question.IRT), g.responses)
+		proposed := models.IRTParams{A: params.A, B: params.B, C: params.C}
+		calibration := models.QuestionCalibration{
+			QuestionID:         g.question.ID,
+			Responses:          stats.Responses,
+			PValue:             stats.PValue,
+			MedianDuration:     median(g.durations),
+			Discrimination:     stats.Discrimination,
+			OldDifficulty:      g.question.Difficulty,
+			ProposedDifficulty: DifficultyFromIRT(proposed),
+			OldIRT:             g.question.IRT,
+			ProposedIRT:        proposed,
+			CreatedAt:          time.Now(),
+		}
+		err = s.create(ctx, &calibration)
+		if err != nil {
+			return nil, err
+		}
+		if req.Apply {
+			err = s.Apply(ctx, &calibration, editor)
+			if err != nil {
+				return nil, err
+			}
+		}
+		calibrations = append(calibrations, calibration)
+	}
+	return calibrations, nil
+}
+
+/**
+* Applies a recorded calibration by updating the difficulty label and item
+* response theory parameters of the question, which is recorded as a new
+* revision made by the editor. Only pending proposals are applied, and only
+* while the question still has the parameters the proposal was computed
+* from, so that newer parameters are not overwritten.
+**/
+func (s *CalibrationService) Apply(ctx context.Context, calibration *models.QuestionCalibration, editor models.User) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	var applied bool
+	err = tx.QueryRow(ctx, `
+		SELECT `+database.QuestionCalibrationsAppliedField+` FROM `+database.QuestionCalibrationsTable+`
+		WHERE `+database.QuestionCalibrationsIDField+` = $1 FOR UPDATE`, calibration.ID).Scan(&applied)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	if applied {
+		return ErrCalibrationApplied
+	}
+	current, snapshot, err := lockQuestion(ctx, tx, calibration.QuestionID)
+	if err != nil {
+		return err
+	}
+	if current.IRT != calibration.OldIRT {
+		return ErrCalibrationStale
+	}
+	next := *snapshot
+	next.Difficulty = calibration.ProposedDifficulty
+	next.IRT = &calibration.ProposedIRT
+	diff, err := diffQuestionRequests(snapshot, &next)
+	if err != nil {
+		return err
+	}
+	err = recordBaseline(ctx, tx, current, snapshot)
+	if err != nil {
+		return err
+	}
+	revision := current.Revision + 1
+	_, err = tx.Exec(ctx, `
+		UPDATE `+database.VerbalQuestionsTable+` SET `+
+		database.VerbalQuestionsDifficultyField+` = $1, `+
+		database.VerbalQuestionsIRTAField+` = $2, `+
+		database.VerbalQuestionsIRTBField+` = $3, `+
+		database.VerbalQuestionsIRTCField+` = $4, `+
+		database.VerbalQuestionsRevisionField+` = $5
+		WHERE `+database.VerbalQuestionsIDField+` = $6`,
+		calibration.ProposedDifficulty,
+		calibration.ProposedIRT.A,
+		calibration.ProposedIRT.B,
+		calibration.ProposedIRT.C,
+		revision,
+		calibration.QuestionID)
+	if err != nil {
+		return err
+	}
+	err = recordRevision(ctx, tx, calibration.QuestionID, revision, models.RevisionCalibrate, editor, &next, diff)
+	if err != nil {
+		return err
+	}
+	appliedAt := time.Now()
+	_, err = tx.Exec(ctx, `
+		UPDATE `+database.QuestionCalibrationsTable+` SET `+
+		database.QuestionCalibrationsAppliedField+` = TRUE, `+
+		database.QuestionCalibrationsAppliedAtField+` = $1
+		WHERE `+database.QuestionCalibrationsIDField+` = $2`,
+		appliedAt, calibration.ID)
+	if err != nil {
+		return err
+	}
+	calibration.Applied = true
+	calibration.AppliedAt = &appliedAt
+	return tx.Commit(ctx)
+}
+
+// Applies a previously recorded calibration proposal by its ID
+func (s *CalibrationService) ApplyByID(ctx context.Context, id int, editor models.User) (*models.QuestionCalibration, error) {
+	calibrations, err := s.list(ctx, squirrel.Eq{database.QuestionCalibrationsIDField: id}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(calibrations) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	calibration := calibrations[0]
+	err = s.Apply(ctx, &calibration, editor)
+	if err != nil {
+		return nil, err
+	}
+	return &calibration, nil
+}
+
+/**
+* Retrieves the calibration history, most recent first. When questionID is
+* zero the history of every question is returned.
+**/
+func (s *CalibrationService) GetHistory(ctx context.Context, questionID int, limit int) ([]models.QuestionCalibration, error) {
+	where := squirrel.And{}
+	if questionID > 0 {
+		where = append(where, squirrel.Eq{database.QuestionCalibrationsQuestionField: questionID})
+	}
+	return s.list(ctx, where, limit)
+}
+
+func (s *CalibrationService) list(ctx context.Context, where squirrel.Sqlizer, limit int) ([]models.QuestionCalibration, error) {
+	query := squirrel.Select(
+		database.QuestionCalibrationsIDField,
+		database.QuestionCalibrationsQuestionField,
+		database.QuestionCalibrationsResponsesField,
+		database.QuestionCalibrationsPValueField,
+		database.QuestionCalibrationsMedianDurationField,
+		database.QuestionCalibrationsDiscriminationField,
+		database.QuestionCalibrationsOldDifficultyField,
+		database.QuestionCalibrationsProposedDifficultyField,
+		database.QuestionCalibrationsOldIRTField,
+		database.QuestionCalibrationsProposedIRTField,
+		database.QuestionCalibrationsAppliedField,
+		database.QuestionCalibrationsAppliedAtField,
+		database.QuestionCalibrationsCreatedAtField,
+	).
+		From(database.QuestionCalibrationsTable).
+		Where(where).
+		OrderBy(database.QuestionCalibrationsCreatedAtField+" DESC", database.QuestionCalibrationsIDField+" DESC").
+		PlaceholderFormat(squirrel.Dollar)
+	if limit > 0 {
+		query = query.Limit(uint64(limit))
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	calibrations := make([]models.QuestionCalibration, 0)
+	for rows.Next() {
+		var c models.QuestionCalibration
+		var oldIRTJson []byte
+		var proposedIRTJson []byte
+		err := rows.Scan(&c.ID, &c.QuestionID, &c.Responses, &c.PValue, &c.MedianDuration, &c.Discrimination,
+			&c.OldDifficulty, &c.ProposedDifficulty, &oldIRTJson, &proposedIRTJson, &c.Applied, &c.AppliedAt, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(oldIRTJson, &c.OldIRT); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(proposedIRTJson, &c.ProposedIRT); err != nil {
+			return nil, err
+		}
+		calibrations = append(calibrations, c)
+	}
+	return calibrations, rows.Err()
+}
+
+// Records a calibration proposal in the history
+func (s *CalibrationService) create(ctx context.Context, c *models.QuestionCalibration) error {
+	oldIRTJson, err := json.Marshal(c.OldIRT)
+	if err != nil {
+		return err
+	}
+	proposedIRTJson, err := json.Marshal(c.ProposedIRT)
+	if err != nil {
+		return err
+	}
+	query := squirrel.Insert(database.QuestionCalibrationsTable).
+		Columns(
+			database.QuestionCalibrationsQuestionField,
+			database.QuestionCalibrationsResponsesField,
+			database.QuestionCalibrationsPValueField,
+			database.QuestionCalibrationsMedianDurationField,
+			database.QuestionCalibrationsDiscriminationField,
+			database.QuestionCalibrationsOldDifficultyField,
+			database.QuestionCalibrationsProposedDifficultyField,
+			database.QuestionCalibrationsOldIRTField,
+			database.QuestionCalibrationsProposedIRTField,
+			database.QuestionCalibrationsCreatedAtField).
+		Values(
+			c.QuestionID,
+			c.Responses,
+			c.PValue,
+			c.MedianDuration,
+			c.Discrimination,
+			c.OldDifficulty,
+			c.ProposedDifficulty,
+			oldIRTJson,
+			proposedIRTJson,
+			c.CreatedAt).
+		Suffix("RETURNING " + database.QuestionCalibrationsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	return s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&c.ID)
+}
+
+// Retrieves the ability of every user for every question type
+func (s *CalibrationService) getTypeAbilities(ctx context.Context) (map[string]map[string]float64, error) {
+	query := squirrel.Select(
+		database.UserAbilitiesUserField,
+		database.UserAbilitiesCategoryField,
+		database.UserAbilitiesThetaField,
+	).
+		From(database.UserAbilitiesTable).
+		Where(squirrel.Eq{database.UserAbilitiesDimensionField: models.AbilityDimensionType}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	thetas := make(map[string]map[string]float64)
+	for rows.Next() {
+		var userToken, category string
+		var theta float64
+		if err := rows.Scan(&userToken, &category, &theta); err != nil {
+			return nil, err
+		}
+		if thetas[userToken] == nil {
+			thetas[userToken] = make(map[string]float64)
+		}
+		thetas[userToken][category] = theta
+	}
+	return thetas, rows.Err()
+}
+
+// Helper function to compute the median of a list of durations
+func median(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return float64(sorted[mid-1]+sorted[mid]) / 2
+	}
+	return float64(sorted[mid])
+}
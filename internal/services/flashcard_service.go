@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/srs"
+)
+
+const (
+	// New cards introduced per day unless the user changed it
+	defaultNewCardsPerDay = 20
+	maxNewCardsPerDay     = 1000
+	// Interval in days from which a card is considered mature
+	matureInterval = 21
+)
+
+var ErrInvalidFlashcardSettings = errors.New("invalid flashcard settings")
+
+// Returned when reviewing a new card would exceed the daily limit of new cards of the user
+var ErrNewCardLimit = errors.New("daily limit of new cards reached")
+
+type FlashcardService struct {
+	DB *pgxpool.Pool
+}
+
+func NewFlashcardService(db *pgxpool.Pool) *FlashcardService {
+	return &FlashcardService{DB: db}
+}
+
+/**
+* Retrieves up to limit cards to review now. Cards that are due come first,
+* oldest first, and the rest of the queue is filled with the marked words
+* of the user that have no card yet, within what is left of the daily
+* limit of new cards.
+**/
+func (s *FlashcardService) GetQueue(ctx context.Context, userToken string, limit int) (*models.FlashcardQueue, error) {
+	now := time.Now()
+	query := squirrel.Select(append(flashcardColumns("f"), wordColumns("w")...)...).
+		From(database.FlashcardsTable + " AS f").
+		Join(database.WordsTable + " AS w ON w." + database.WordsIDField + " = f." + database.FlashcardsWordField).
+		Where(squirrel.Eq{"f." + database.FlashcardsUserField: userToken}).
+		Where(squirrel.LtOrEq{"f." + database.FlashcardsDueAtField: now}).
+		OrderBy("f." + database.FlashcardsDueAtField).
+		Limit(uint64(limit)).
+		PlaceholderFormat(squirrel.Dollar)
+	cards, err := s.queryCards(ctx, query, true)
+	if err != nil {
+		return nil, err
+	}
+	queue := &models.FlashcardQueue{Cards: cards, Due: len(cards)}
+
+	settings, err := s.GetSettings(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	queue.NewToday, err = s.countNewToday(ctx, s.DB, userToken, now)
+	if err != nil {
+		return nil, err
+	}
+	queue.NewRemaining = settings.NewCardsPerDay - queue.NewToday
+	if queue.NewRemaining < 0 {
+		queue.NewRemaining = 0
+	}
+	newLimit := minInt(queue.NewRemaining, limit-len(cards))
+	if newLimit <= 0 {
+		return queue, nil
+	}
+	query = squirrel.Select(wordColumns("w")...).
+		From(database.UserMarkedWordsTable + " AS m").
+		Join(database.WordsTable + " AS w ON w." + database.WordsIDField + " = m." + database.UserMarkedWordsWordField).
+		Where(squirrel.Eq{"m." + database.UserMarkedWordsUserField: userToken}).
+		Where(`NOT EXISTS (SELECT 1 FROM ` + database.FlashcardsTable + ` AS f
+			WHERE f.` + database.FlashcardsUserField + ` = m.` + database.UserMarkedWordsUserField + `
+			AND f.` + database.FlashcardsWordField + ` = m.` + database.UserMarkedWordsWordField + `)`).
+		OrderBy("m." + database.UserMarkedWordsIDField).
+		Limit(uint64(newLimit)).
+		PlaceholderFormat(squirrel.Dollar)
+	newCards, err := s.queryCards(ctx, query, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range newCards {
+		state := srs.New(now)
+		newCards[i].UserToken = userToken
+		newCards[i].New = true
+		newCards[i].Ease = state.Ease
+		newCards[i].DueAt = state.Due
+	}
+	queue.Cards = append(queue.Cards, newCards...)
+	queue.New = len(newCards)
+	return queue, nil
+}
+
+/**
+* Grades the review of the card of a word and schedules its next review.
+* Words without a card are reviewed as new cards, which creates the card,
+* and count against the daily limit of new cards of the user. Reviews of a
+* user are serialized so that concurrent reviews cannot exceed the limit.
+* Every review is logged to compute the retention of the user.
+**/
+func (s *FlashcardService) Grade(ctx context.Context, userToken string, wordID int, grade srs.Grade) (*models.Flashcard, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", "flashcards:"+userToken); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	card, err := s.loadCard(ctx, tx, userToken, wordID)
+	if err == echo.ErrNotFound {
+		if err := s.checkWord(ctx, tx, wordID); err != nil {
+			return nil, err
+		}
+		settings, err := s.getSettings(ctx, tx, userToken)
+		if err != nil {
+			return nil, err
+		}
+		newToday, err := s.countNewToday(ctx, tx, userToken, now)
+		if err != nil {
+			return nil, err
+		}
+		if newToday >= settings.NewCardsPerDay {
+			return nil, fmt.Errorf("%w: %d new cards were reviewed today", ErrNewCardLimit, newToday)
+		}
+		state := srs.New(now)
+		card = &models.Flashcard{
+			UserToken: userToken,
+			New:       true,
+			Ease:      state.Ease,
+			DueAt:     state.Due,
+		}
+		card.Word.ID = wordID
+	} else if err != nil {
+		return nil, err
+	}
+	previousInterval := card.Interval
+	state, err := srs.Review(srs.State{
+		Repetitions: card.Repetitions,
+		Interval:    card.Interval,
+		Ease:        card.Ease,
+		Lapses:      card.Lapses,
+		Due:         card.DueAt,
+	}, grade, now)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO ` + database.FlashcardsTable + ` (` +
+		database.FlashcardsUserField + `, ` +
+		database.FlashcardsWordField + `, ` +
+		database.FlashcardsRepetitionsField + `, ` +
+		database.FlashcardsIntervalField + `, ` +
+		database.FlashcardsEaseField + `, ` +
+		database.FlashcardsLapsesField + `, ` +
+		database.FlashcardsDueAtField + `, ` +
+		database.FlashcardsCreatedAtField + `, ` +
+		database.FlashcardsLastReviewedAtField + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (` + database.FlashcardsUserField + `, ` + database.FlashcardsWordField + `) DO UPDATE SET ` +
+		database.FlashcardsRepetitionsField + ` = EXCLUDED.` + database.FlashcardsRepetitionsField + `, ` +
+		database.FlashcardsIntervalField + ` = EXCLUDED.` + database.FlashcardsIntervalField + `, ` +
+		database.FlashcardsEaseField + ` = EXCLUDED.` + database.FlashcardsEaseField + `, ` +
+		database.FlashcardsLapsesField + ` = EXCLUDED.` + database.FlashcardsLapsesField + `, ` +
+		database.FlashcardsDueAtField + ` = EXCLUDED.` + database.FlashcardsDueAtField + `, ` +
+		database.FlashcardsLastReviewedAtField + ` = EXCLUDED.` + database.FlashcardsLastReviewedAtField + `
+		RETURNING ` + database.FlashcardsIDField
+	err = tx.QueryRow(ctx, query, userToken, wordID, state.Repetitions, state.Interval,
+		state.Ease, state.Lapses, state.Due, now).Scan(&card.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := squirrel.Insert(database.FlashcardReviewsTable).
+		Columns(
+			database.FlashcardReviewsFlashcardField,
+			database.FlashcardReviewsUserField,
+			database.FlashcardReviewsGradeField,
+			database.FlashcardReviewsNewField,
+			database.FlashcardReviewsPreviousIntervalField,
+			database.FlashcardReviewsIntervalField,
+			database.FlashcardReviewsEaseField,
+			database.FlashcardReviewsReviewedAtField).
+		Values(card.ID, userToken, int(grade), card.New, previousInterval, state.Interval, state.Ease, now).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := insert.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, sqlQuery, args...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.GetCard(ctx, userToken, wordID)
+}
+
+// Retrieves the card of a word along with the content of the word
+func (s *FlashcardService) GetCard(ctx context.Context, userToken string, wordID int) (*models.Flashcard, error) {
+	query := squirrel.Select(append(flashcardColumns("f"), wordColumns("w")...)...).
+		From(database.FlashcardsTable + " AS f").
+		Join(database.WordsTable + " AS w ON w." + database.WordsIDField + " = f." + database.FlashcardsWordField).
+		Where(squirrel.Eq{
+			"f." + database.FlashcardsUserField: userToken,
+			"f." + database.FlashcardsWordField: wordID,
+		}).
+		PlaceholderFormat(squirrel.Dollar)
+	cards, err := s.queryCards(ctx, query, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &cards[0], nil
+}
+
+/**
+* Computes the statistics of the cards of the user and of the reviews done
+* today and over the last days.
+**/
+func (s *FlashcardService) GetStats(ctx context.Context, userToken string, days int) (*models.FlashcardStats, error) {
+	now := time.Now()
+	stats := &models.FlashcardStats{Days: days}
+	query := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE ` + database.FlashcardsDueAtField + ` <= $2),
+			COUNT(*) FILTER (WHERE ` + database.FlashcardsIntervalField + ` < $3),
+			COUNT(*) FILTER (WHERE ` + database.FlashcardsIntervalField + ` >= $3),
+			COALESCE(SUM(` + database.FlashcardsLapsesField + `), 0),
+			COALESCE(AVG(` + database.FlashcardsEaseField + `), 0)
+		FROM ` + database.FlashcardsTable + `
+		WHERE ` + database.FlashcardsUserField + ` = $1`
+	err := s.DB.QueryRow(ctx, query, userToken, now, matureInterval).Scan(
+		&stats.Cards,
+		&stats.Due,
+		&stats.Young,
+		&stats.Mature,
+		&stats.Lapses,
+		&stats.AverageEase)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT COUNT(*)
+		FROM ` + database.UserMarkedWordsTable + ` AS m
+		WHERE m.` + database.UserMarkedWordsUserField + ` = $1
+		AND NOT EXISTS (SELECT 1 FROM ` + database.FlashcardsTable + ` AS f
+			WHERE f.` + database.FlashcardsUserField + ` = m.` + database.UserMarkedWordsUserField + `
+			AND f.` + database.FlashcardsWordField + ` = m.` + database.UserMarkedWordsWordField + `)`
+	if err := s.DB.QueryRow(ctx, query, userToken).Scan(&stats.Unseen); err != nil {
+		return nil, err
+	}
+
+	// Reviews of new cards are left out of the retention since the card
+	// could not be remembered yet
+	var learned, recalled, mature, matureRecalled int
+	reviewedAt := database.FlashcardReviewsReviewedAtField
+	learnedReview := `NOT ` + database.FlashcardReviewsNewField + ` AND ` + reviewedAt + ` >= $3`
+	recalledReview := database.FlashcardReviewsGradeField + ` >= $4`
+	matureReview := database.FlashcardReviewsPreviousIntervalField + ` >= $5`
+	query = `
+		SELECT COUNT(*) FILTER (WHERE ` + reviewedAt + ` >= $2),
+			COUNT(*) FILTER (WHERE ` + reviewedAt + ` >= $2 AND ` + database.FlashcardReviewsNewField + `),
+			COUNT(*) FILTER (WHERE ` + reviewedAt + ` >= $3),
+			COUNT(*) FILTER (WHERE ` + learnedReview + `),
+			COUNT(*) FILTER (WHERE ` + learnedReview + ` AND ` + recalledReview + `),
+			COUNT(*) FILTER (WHERE ` + learnedReview + ` AND ` + matureReview + `),
+			COUNT(*) FILTER (WHERE ` + learnedReview + ` AND ` + matureReview + ` AND ` + recalledReview + `)
+		FROM ` + database.FlashcardReviewsTable + `
+		WHERE ` + database.FlashcardReviewsUserField + ` = $1`
+	err = s.DB.QueryRow(ctx, query, userToken, startOfDay(now), now.AddDate(0, 0, -days),
+		int(srs.Hard), matureInterval).Scan(
+		&stats.ReviewsToday,
+		&stats.NewToday,
+		&stats.Reviews,
+		&learned,
+		&recalled,
+		&mature,
+		&matureRecalled)
+	if err != nil {
+		return nil, err
+	}
+	if learned > 0 {
+		stats.Retention = float64(recalled) / float64(learned)
+	}
+	if mature > 0 {
+		stats.MatureRetention = float64(matureRecalled) / float64(mature)
+	}
+	return stats, nil
+}
+
+// Retrieves the flashcard settings of the user, or the defaults
+func (s *FlashcardService) GetSettings(ctx context.Context, userToken string) (*models.FlashcardSettings, error) {
+	return s.getSettings(ctx, s.DB, userToken)
+}
+
+func (s *FlashcardService) getSettings(ctx context.Context, q querier, userToken string) (*models.FlashcardSettings, error) {
+	settings := &models.FlashcardSettings{NewCardsPerDay: defaultNewCardsPerDay}
+	query := squirrel.Select(database.FlashcardSettingsNewPerDayField).
+		From(database.FlashcardSettingsTable).
+		Where(squirrel.Eq{database.FlashcardSettingsUserField: userToken}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	err = q.QueryRow(ctx, sqlQuery, args...).Scan(&settings.NewCardsPerDay)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (s *FlashcardService) UpdateSettings(ctx context.Context, userToken string, settings *models.FlashcardSettings) error {
+	if settings.NewCardsPerDay < 0 || settings.NewCardsPerDay > maxNewCardsPerDay {
+		return fmt.Errorf("%w: new_cards_per_day must be between 0 and %d", ErrInvalidFlashcardSettings, maxNewCardsPerDay)
+	}
+	query := `
+		INSERT INTO ` + database.FlashcardSettingsTable + ` (` +
+		database.FlashcardSettingsUserField + `, ` +
+		database.FlashcardSettingsNewPerDayField + `)
+		VALUES ($1, $2)
+		ON CONFLICT (` + database.FlashcardSettingsUserField + `) DO UPDATE SET ` +
+		database.FlashcardSettingsNewPerDayField + ` = EXCLUDED.` + database.FlashcardSettingsNewPerDayField
+	_, err := s.DB.Exec(ctx, query, userToken, settings.NewCardsPerDay)
+	return err
+}
+
+// Loads and locks the card of a word
+func (s *FlashcardService) loadCard(ctx context.Context, q querier, userToken string, wordID int) (*models.Flashcard, error) {
+	query := squirrel.Select(flashcardColumns("f")...).
+		From(database.FlashcardsTable + " AS f").
+		Where(squirrel.Eq{
+			"f." + database.FlashcardsUserField: userToken,
+			"f." + database.FlashcardsWordField: wordID,
+		}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	card := &models.Flashcard{}
+	err = q.QueryRow(ctx, sqlQuery, args...).Scan(flashcardFields(card)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return card, nil
+}
+
+// Returns echo.ErrNotFound when the word does not exist
+func (s *FlashcardService) checkWord(ctx context.Context, q querier, wordID int) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM ` + database.WordsTable + ` WHERE ` + database.WordsIDField + ` = $1)`
+	if err := q.QueryRow(ctx, query, wordID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return echo.ErrNotFound
+	}
+	return nil
+}
+
+// Counts the new cards the user reviewed since the start of the day
+func (s *FlashcardService) countNewToday(ctx context.Context, q querier, userToken string, now time.Time) (int, error) {
+	query := squirrel.Select("COUNT(*)").
+		From(database.FlashcardReviewsTable).
+		Where(squirrel.Eq{
+			database.FlashcardReviewsUserField: userToken,
+			database.FlashcardReviewsNewField:  true,
+		}).
+		Where(squirrel.GtOrEq{database.FlashcardReviewsReviewedAtField: startOfDay(now)}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = q.QueryRow(ctx, sqlQuery, args...).Scan(&count)
+	return count, err
+}
+
+/**
+* Runs a query selecting words, preceded by the columns of their card when
+* withCard is set, and scans the cards
+**/
+func (s *FlashcardService) queryCards(ctx context.Context, query squirrel.SelectBuilder, withCard bool) ([]models.Flashcard, error) {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cards := make([]models.Flashcard, 0)
+	for rows.Next() {
+		var card models.Flashcard
+		fields := make([]interface{}, 0)
+		if withCard {
+			fields = append(fields, flashcardFields(&card)...)
+		}
+		fields = append(fields, wordFields(&card.Word)...)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+// Columns of a card in the order of flashcardFields
+func flashcardColumns(alias string) []string {
+	return []string{
+		alias + "." + database.FlashcardsIDField,
+		alias + "." + database.FlashcardsUserField,
+		alias + "." + database.FlashcardsRepetitionsField,
+		alias + "." + database.FlashcardsIntervalField,
+		alias + "." + database.FlashcardsEaseField,
+		alias + "." + database.FlashcardsLapsesField,
+		alias + "." + database.FlashcardsDueAtField,
+		alias + "." + database.FlashcardsLastReviewedAtField,
+	}
+}
+
+func flashcardFields(card *models.Flashcard) []interface{} {
+	return []interface{}{
+		&card.ID,
+		&card.UserToken,
+		&card.Repetitions,
+		&card.Interval,
+		&card.Ease,
+		&card.Lapses,
+		&card.DueAt,
+		&card.LastReviewedAt,
+	}
+}
+
+// Midnight of the day of the given time, from which daily limits are counted
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Returned when the answers submitted for a question cannot be graded
+var ErrInvalidAnswers = errors.New("invalid answers")
+
+/**
+* Grades the answers submitted by a user against the stored options of
+* a verbal question. Every answer must match the value of one of the
+* options. The submission is correct only when the set of selected options
+* is exactly the set of correct options. Returns the per option breakdown
+* along with the justification of each option.
+**/
+func GradeVerbalAnswers(q *models.VerbalQuestion, answers []string) (bool, []models.OptionGrade, error) {
+	selected, err := selectOptions(q.ID, q.Options, answers)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := validateSelectionCount(q, len(selected)); err != nil {
+		return false, nil, err
+	}
+	correct, grading := gradeOptions(q.Options, selected)
+	return correct, grading, nil
+}
+
+/**
+* Grades the answers submitted by a user for a quant question. Numeric
+* entry questions are graded against the expected answer, which is also
+* returned in its display form. Every other question type is graded
+* against its options in the same way as verbal questions.
+**/
+func GradeQuantAnswers(q *models.QuantQuestion, answers []string) (bool, []models.OptionGrade, string, error) {
+	if q.Type == models.NumericEntry {
+		if q.Answer == nil {
+			return false, nil, "", fmt.Errorf("%w: question %d has no answer", ErrInvalidAnswers, q.ID)
+		}
+		if len(answers) != 1 {
+			return false, nil, "", fmt.Errorf("%w: numeric entry requires exactly 1 answer, got %d", ErrInvalidAnswers, len(answers))
+		}
+		correct, err := gradeNumericAnswer(q.Answer, answers[0])
+		if err != nil {
+			return false, nil, "", err
+		}
+		return correct, nil, formatNumericAnswer(q.Answer), nil
+	}
+	selected, err := selectOptions(q.ID, q.Options, answers)
+	if err != nil {
+		return false, nil, "", err
+	}
+	if q.Type != models.QuantMCQMultipleChoices && len(selected) != 1 {
+		return false, nil, "", fmt.Errorf("%w: %s requires exactly 1 answer, got %d", ErrInvalidAnswers, q.Type.String(), len(selected))
+	}
+	correct, grading := gradeOptions(q.Options, selected)
+	return correct, grading, "", nil
+}
+
+/**
+* Grades a numeric entry. Both decimals and fractions can be entered.
+* Fraction answers only accept entries with exactly the same value, so
+* any equivalent fraction is correct. Decimal answers accept entries
+* within the tolerance of the expected value.
+**/
+func gradeNumericAnswer(expected *models.NumericAnswer, answer string) (bool, error) {
+	entry := strings.ReplaceAll(strings.TrimSpace(answer), ",", "")
+	value, ok := new(big.Rat).SetString(entry)
+	if !ok {
+		return false, fmt.Errorf("%w: %q is not a number", ErrInvalidAnswers, answer)
+	}
+	if expected.Denominator != 0 {
+		return value.Cmp(big.NewRat(expected.Numerator, expected.Denominator)) == 0, nil
+	}
+	submitted, _ := value.Float64()
+	// Allow for the rounding error of the float conversion
+	return math.Abs(submitted-expected.Value) <= expected.Tolerance+1e-9, nil
+}
+
+// Display form of the expected answer of a numeric entry question
+func formatNumericAnswer(a *models.NumericAnswer) string {
+	if a.Denominator != 0 {
+		return strconv.FormatInt(a.Numerator, 10) + "/" + strconv.FormatInt(a.Denominator, 10)
+	}
+	return strconv.FormatFloat(a.Value, 'f', -1, 64)
+}
+
+/**
+* Maps each answer to the index of the option it selects. Answers that do
+* not match the value of any option are rejected.
+**/
+func selectOptions(questionID int, options []models.Option, answers []string) (map[int]struct{}, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: question %d has no options", ErrInvalidAnswers, questionID)
+	}
+	optionIndex := make(map[string]int, len(options))
+	for i, option := range options {
+		optionIndex[strings.TrimSpace(option.Value)] = i
+	}
+	selected := make(map[int]struct{})
+	for _, answer := range answers {
+		i, ok := optionIndex[strings.TrimSpace(answer)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not an option of question %d", ErrInvalidAnswers, answer, questionID)
+		}
+		selected[i] = struct{}{}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("%w: no answers selected", ErrInvalidAnswers)
+	}
+	return selected, nil
+}
+
+/**
+* Grades the selected options. The selection is correct only when it is
+* exactly the set of correct options.
+**/
+func gradeOptions(options []models.Option, selected map[int]struct{}) (bool, []models.OptionGrade) {
+	correct := true
+	grading := make([]models.OptionGrade, len(options))
+	for i, option := range options {
+		_, isSelected := selected[i]
+		if isSelected != option.Correct {
+			correct = false
+		}
+		grading[i] = models.OptionGrade{
+			Value:         option.Value,
+			Selected:      isSelected,
+			Correct:       option.Correct,
+			Justification: option.Justification,
+		}
+	}
+	return correct, grading
+}
+
+/**
+* Checks that the number of distinct options selected is allowed for the
+* way the question is framed. Sentence equivalence questions always require
+* a pair of answers.
+**/
+func validateSelectionCount(q *models.VerbalQuestion, count int) error {
+	if q.Type == models.SentenceEquivalence {
+		if count != 2 {
+			return fmt.Errorf("%w: sentence equivalence requires exactly 2 answers, got %d", ErrInvalidAnswers, count)
+		}
+		return nil
+	}
+	switch q.FramedAs {
+	case models.MCQSingleAnswer, models.SelectSentence:
+		if count != 1 {
+			return fmt.Errorf("%w: %s requires exactly 1 answer, got %d", ErrInvalidAnswers, q.FramedAs.String(), count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+// Errors returned when a mock exam cannot be assembled or advanced
+var (
+	ErrInsufficientQuestions = errors.New("not enough questions to assemble the section")
+	ErrMockExamState         = errors.New("mock exam is not in the required state")
+)
+
+// Number of questions of a type in a section of a mock exam
+type blueprintItem struct {
+	qType models.QuestionType
+	count int
+}
+
+/**
+* Layout of a section of a mock exam. Questions are served in the order of
+* the items. TimeLimit is in seconds.
+**/
+type sectionBlueprint struct {
+	timeLimit int
+	items     []blueprintItem
+}
+
+// Blueprints of the two verbal sections of the mock exam
+var (
+	mockSection1Blueprint = sectionBlueprint{
+		timeLimit: 18 * 60,
+		items: []blueprintItem{
+			{models.TextCompletion, 4},
+			{models.SentenceEquivalence, 3},
+			{models.ReadingComprehension, 5},
+		},
+	}
+	mockSection2Blueprint = sectionBlueprint{
+		timeLimit: 23 * 60,
+		items: []blueprintItem{
+			{models.TextCompletion, 5},
+			{models.SentenceEquivalence, 4},
+			{models.ReadingComprehension, 6},
+		},
+	}
+)
+
+// Competences that reading comprehension questions of a section rotate through
+var mockCompetences = []models.Competence{
+	models.AnalyzingAndDrawingConclusions,
+	models.ReasoningFromIncompleteData,
+	models.IdentifyingAuthorsAssumptionsPerspective,
+	models.UnderstandingMultipleLevelsOfMeaning,
+	models.SelectingImportantInfo,
+	models.DistinguishMajorMinorPoints,
+}
+
+// Mapping of the ability scale onto the 130 to 170 score scale
+const (
+	scaledMean  = 150
+	scaledSlope = 8.5
+	minScaled   = 130
+	maxScaled   = 170
+)
+
+type MockExamService struct {
+	DB *pgxpool.Pool
+}
+
+func NewMockExamService(db *pgxpool.Pool) *MockExamService {
+	return &MockExamService{DB: db}
+}
+
+/**
+* Starts a mock exam by assembling the first section from a medium
+* difficulty blueprint and starting a timed session for it. The session and
+* the exam are created in one transaction.
+**/
+func (s *MockExamService) Start(ctx context.Context, userToken string) (*models.MockExam, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	store := newPostgresStore(tx)
+	questionIDs, err := assembleSection(ctx, store, mockSection1Blueprint,
+		[]models.Difficulty{models.Medium, models.Easy, models.Hard}, nil)
+	if err != nil {
+		return nil, err
+	}
+	pss := &PracticeSessionService{Store: store}
+	section, err := pss.StartWithQuestions(ctx, userToken, models.SessionModeMockExam, mockSection1Blueprint.timeLimit, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	exam := &models.MockExam{
+		UserToken:         userToken,
+		Status:            models.MockExamSection1,
+		Section1SessionID: section.ID,
+		StartedAt:         time.Now(),
+	}
+	query := squirrel.Insert(database.MockExamsTable).
+		Columns(
+			database.MockExamsUserField,
+			database.MockExamsStatusField,
+			database.MockExamsSection1Field,
+			database.MockExamsStartedAtField).
+		Values(
+			exam.UserToken,
+			string(exam.Status),
+			exam.Section1SessionID,
+			exam.StartedAt).
+		Suffix("RETURNING " + database.MockExamsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(ctx, sqlQuery, args...).Scan(&exam.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return exam, nil
+}
+
+/**
+* Finishes the first section and routes the user to an easier or harder
+* second section depending on the ability estimated from the first section.
+* The exam is locked while the section is finished and the second section
+* is assembled and started, so that the exam is never left in the first
+* section with a finished section or a second session that is not linked.
+**/
+func (s *MockExamService) NextSection(ctx context.Context, userToken string, id int) (*models.MockExam, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	exam, err := s.getForUpdate(ctx, tx, userToken, id)
+	if err != nil {
+		return nil, err
+	}
+	if exam.Status != models.MockExamSection1 {
+		return nil, fmt.Errorf("%w: first section already completed", ErrMockExamState)
+	}
+	store := newPostgresStore(tx)
+	pss := &PracticeSessionService{Store: store}
+	if _, err := pss.Finish(ctx, userToken, exam.Section1SessionID); err != nil {
+		return nil, err
+	}
+	estimate, _, err := estimateAbility(ctx, store, userToken, []int{exam.Section1SessionID})
+	if err != nil {
+		return nil, err
+	}
+	route := models.MockExamRouteEasier
+	difficulties := []models.Difficulty{models.Easy, models.Medium, models.Hard}
+	if estimate.Theta >= 0 {
+		route = models.MockExamRouteHarder
+		difficulties = []models.Difficulty{models.Hard, models.Medium, models.Easy}
+	}
+	section1, err := pss.Get(ctx, userToken, exam.Section1SessionID)
+	if err != nil {
+		return nil, err
+	}
+	questionIDs, err := assembleSection(ctx, store, mockSection2Blueprint, difficulties, section1.QuestionIDs)
+	if err != nil {
+		return nil, err
+	}
+	section2, err := pss.StartWithQuestions(ctx, userToken, models.SessionModeMockExam, mockSection2Blueprint.timeLimit, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	exam.Status = models.MockExamSection2
+	exam.Section2SessionID = &section2.ID
+	exam.Route = route
+	err = updateMockExam(ctx, tx, exam, models.MockExamSection1)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return exam, nil
+}
+
+/**
+* Finishes the mock exam and estimates the scaled score from every question
+* of both sections. Questions that were not answered count as incorrect.
+* The exam is locked until the second section is finished and the score
+* is saved.
+**/
+func (s *MockExamService) Finish(ctx context.Context, userToken string, id int) (*models.MockExam, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	exam, err := s.getForUpdate(ctx, tx, userToken, id)
+	if err != nil {
+		return nil, err
+	}
+	if exam.Status == models.MockExamFinished {
+		return exam, nil
+	}
+	if exam.Status != models.MockExamSection2 {
+		return nil, fmt.Errorf("%w: second section has not started", ErrMockExamState)
+	}
+	store := newPostgresStore(tx)
+	pss := &PracticeSessionService{Store: store}
+	if _, err := pss.Finish(ctx, userToken, *exam.Section2SessionID); err != nil {
+		return nil, err
+	}
+	estimate, correct, err := estimateAbility(ctx, store, userToken, []int{exam.Section1SessionID, *exam.Section2SessionID})
+	if err != nil {
+		return nil, err
+	}
+	band := 1.96 * scaledSlope * estimate.SE
+	exam.Score = &models.MockExamScore{
+		Theta:         estimate.Theta,
+		StandardError: estimate.SE,
+		Scaled:        scaledScore(estimate.Theta, 0),
+		Low:           scaledScore(estimate.Theta, -band),
+		High:          scaledScore(estimate.Theta, band),
+		Correct:       correct,
+		Total:         estimate.Responses,
+	}
+	now := time.Now()
+	exam.Status = models.MockExamFinished
+	exam.FinishedAt = &now
+	err = updateMockExam(ctx, tx, exam, models.MockExamSection2)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return exam, nil
+}
+
+// Retrieves a mock exam of the user
+func (s *MockExamService) Get(ctx context.Context, userToken string, id int) (*models.MockExam, error) {
+	exams, err := listMockExams(ctx, s.DB, squirrel.Eq{
+		database.MockExamsIDField:   id,
+		database.MockExamsUserField: userToken,
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(exams) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &exams[0], nil
+}
+
+// Retrieves a mock exam of the user and locks it until the end of the transaction
+func (s *MockExamService) getForUpdate(ctx context.Context, tx pgx.Tx, userToken string, id int) (*models.MockExam, error) {
+	exams, err := listMockExams(ctx, tx, squirrel.Eq{
+		database.MockExamsIDField:   id,
+		database.MockExamsUserField: userToken,
+	}, "FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	if len(exams) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &exams[0], nil
+}
+
+// Retrieves every mock exam of the user, most recent first
+func (s *MockExamService) GetByUserToken(ctx context.Context, userToken string) ([]models.MockExam, error) {
+	return listMockExams(ctx, s.DB, squirrel.Eq{database.MockExamsUserField: userToken}, "")
+}
+
+// Lists the mock exams that match where, with a locking clause in suffix when it is set
+func listMockExams(ctx context.Context, db querier, where squirrel.Sqlizer, suffix string) ([]models.MockExam, error) {
+	query := squirrel.Select(
+		database.MockExamsIDField,
+		database.MockExamsUserField,
+		database.MockExamsStatusField,
+		database.MockExamsSection1Field,
+		database.MockExamsSection2Field,
+		"COALESCE("+database.MockExamsRouteField+", '')",
+		database.MockExamsScoreField,
+		database.MockExamsStartedAtField,
+		database.MockExamsFinishedAtField,
+	).
+		From(database.MockExamsTable).
+		Where(where).
+		OrderBy(database.MockExamsStartedAtField + " DESC").
+		PlaceholderFormat(squirrel.Dollar)
+	if suffix != "" {
+		query = query.Suffix(suffix)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	exams := make([]models.MockExam, 0)
+	for rows.Next() {
+		var exam models.MockExam
+		var status string
+		var scoreJson []byte
+		err := rows.Scan(&exam.ID, &exam.UserToken, &status, &exam.Section1SessionID, &exam.Section2SessionID,
+			&exam.Route, &scoreJson, &exam.StartedAt, &exam.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		exam.Status = models.MockExamStatus(status)
+		if scoreJson != nil {
+			if err := json.Unmarshal(scoreJson, &exam.Score); err != nil {
+				return nil, err
+			}
+		}
+		exams = append(exams, exam)
+	}
+	return exams, rows.Err()
+}
+
+/**
+* Saves the state of a mock exam. The update only succeeds when the exam is
+* still in the expected status so a section cannot be advanced twice.
+**/
+func updateMockExam(ctx context.Context, db querier, exam *models.MockExam, expected models.MockExamStatus) error {
+	var scoreJson []byte
+	if exam.Score != nil {
+		var err error
+		scoreJson, err = json.Marshal(exam.Score)
+		if err != nil {
+			return err
+		}
+	}
+	query := squirrel.Update(database.MockExamsTable).
+		Set(database.MockExamsStatusField, string(exam.Status)).
+		Set(database.MockExamsSection2Field, exam.Section2SessionID).
+		Set(database.MockExamsRouteField, exam.Route).
+		Set(database.MockExamsScoreField, scoreJson).
+		Set(database.MockExamsFinishedAtField, exam.FinishedAt).
+		Where(squirrel.Eq{
+			database.MockExamsIDField:     exam.ID,
+			database.MockExamsStatusField: string(expected),
+		}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	tag, err := db.Exec(ctx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMockExamState
+	}
+	return nil
+}
+
+/**
+* Assembles the questions of a section from its blueprint. Questions of each
+* type are taken from the difficulties in order of preference, and reading
+* comprehension questions rotate through the competences. Questions that
+* belong to a passage are followed by the rest of their passage set.
+**/
+func assembleSection(ctx context.Context, store *repository.Store, blueprint sectionBlueprint,
+	difficulties []models.Difficulty, excludeIDs []int) ([]int, error) {
+	vqs := &VerbalQuestionService{Store: store}
+	used := append([]int(nil), excludeIDs...)
+	questionIDs := make([]int, 0)
+	for _, item := range blueprint.items {
+		for i := 0; i < item.count; {
+			competences := []models.Competence{0}
+			if item.qType == models.ReadingComprehension {
+				competences = []models.Competence{mockCompetences[i%len(mockCompetences)], 0}
+			}
+			question, err := pickQuestion(ctx, vqs, item.qType, competences, difficulties, used)
+			if err != nil {
+				return nil, err
+			}
+			used = append(used, question.ID)
+			questionIDs = append(questionIDs, question.ID)
+			i++
+			if question.PassageID == nil {
+				continue
+			}
+			// Serve the rest of the passage set together, within the quota of the item
+			siblings, err := vqs.GetPassageQuestionIDs(ctx, *question.PassageID, used)
+			if err != nil {
+				return nil, err
+			}
+			for _, sibling := range siblings {
+				if i == item.count {
+					break
+				}
+				used = append(used, sibling)
+				questionIDs = append(questionIDs, sibling)
+				i++
+			}
+		}
+	}
+	return questionIDs, nil
+}
+
+// Picks the first available question in order of preference of competence and difficulty
+func pickQuestion(ctx context.Context, vqs *VerbalQuestionService, qType models.QuestionType,
+	competences []models.Competence, difficulties []models.Difficulty, excludeIDs []int) (*models.VerbalQuestion, error) {
+	for _, competence := range competences {
+		for _, difficulty := range difficulties {
+			question, err := vqs.GetRandom(ctx, qType, competence, difficulty, excludeIDs)
+			if err == echo.ErrNotFound {
+				continue
+			}
+			return question, err
+		}
+	}
+	return nil, fmt.Errorf("%w: no %s question available", ErrInsufficientQuestions, qType.String())
+}
+
+/**
+* Estimates the ability of the user from every question served in the
+* given sessions. Returns the estimate and the number of correct answers.
+**/
+func estimateAbility(ctx context.Context, store *repository.Store, userToken string, sessionIDs []int) (irt.Estimate, int, error) {
+	pss := &PracticeSessionService{Store: store}
+	uvss := &UserVerbalStatsService{Store: store}
+	vqs := &VerbalQuestionService{Store: store}
+	questionIDs := make([]int, 0)
+	correctByQuestion := make(map[int]bool)
+	for _, sessionID := range sessionIDs {
+		session, err := pss.Get(ctx, userToken, sessionID)
+		if err != nil {
+			return irt.Estimate{}, 0, err
+		}
+		stats, err := uvss.GetVerbalStatsBySession(ctx, userToken, sessionID)
+		if err != nil {
+			return irt.Estimate{}, 0, err
+		}
+		for _, stat := range stats {
+			correctByQuestion[stat.QuestionID] = stat.Correct
+		}
+		// Only the questions assigned to the section count, including unanswered ones
+		questionIDs = append(questionIDs, session.QuestionIDs...)
+	}
+	questions, err := vqs.GetByIDs(ctx, questionIDs)
+	if err != nil {
+		return irt.Estimate{}, 0, err
+	}
+	responses := make([]irt.Response, 0, len(questions))
+	correct := 0
+	for _, q := range questions {
+		if correctByQuestion[q.ID] {
+			correct++
+		}
+		responses = append(responses, irt.Response{Params: irtParams(q.IRT), Correct: correctByQuestion[q.ID]})
+	}
+	return irt.EAP(irt.Prior(), responses), correct, nil
+}
+
+// Converts an ability estimate with an offset on the score scale to a scaled score
+func scaledScore(theta float64, offset float64) int {
+	score := math.Round(scaledMean + scaledSlope*theta + offset)
+	return int(math.Max(minScaled, math.Min(maxScaled, score)))
+}
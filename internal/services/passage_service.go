@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+)
+
+// Returned when the content of a passage is not valid
+var ErrInvalidPassage = errors.New("invalid passage")
+
+type PassageService struct {
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+}
+
+func NewPassageService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *PassageService {
+	return &PassageService{DB: db, Lemmatizer: lemmatizer}
+}
+
+/**
+* Creates a new passage. The vocabulary of the passage is linked through
+* the passage words join table and the variations of each word used in the
+* text are stored in its wordmap, which the questions of the passage share.
+**/
+func (s *PassageService) Create(ctx context.Context, p *models.PassageRequest) error {
+	if strings.TrimSpace(p.Title) == "" || strings.TrimSpace(p.Text) == "" {
+		return fmt.Errorf("%w: title and text are required", ErrInvalidPassage)
+	}
+	vocabBaseForms, variations := vocabularyWordMap(s.Lemmatizer, p.Vocabulary, p.Text)
+	wordmapJson, err := json.Marshal(variations)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	query := squirrel.Insert(database.PassagesTable).
+		Columns(
+			database.PassagesTitleField,
+			database.PassagesTextField,
+			database.PassagesWordmapField).
+		Values(p.Title, p.Text, wordmapJson).
+		Suffix("RETURNING " + database.PassagesIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, sqlQuery, args...).Scan(&p.ID)
+	if err != nil {
+		return err
+	}
+	err = linkVocabulary(ctx, tx, database.PassageWordsJoinTable, database.PassageWordJoinPassageField,
+		database.PassageWordJoinWordField, p.ID, vocabBaseForms)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+/**
+* Retrieves a passage with its vocabulary and the set of active questions
+* that belong to it in the order they are served.
+**/
+func (s *PassageService) GetByID(ctx context.Context, id int) (*models.Passage, error) {
+	p := &models.Passage{}
+	var wordmapJson []byte
+	query := squirrel.Select(
+		database.PassagesIDField,
+		database.PassagesTitleField,
+		database.PassagesTextField,
+		database.PassagesWordmapField).
+		From(database.PassagesTable).
+		Where(squirrel.Eq{database.PassagesIDField: id}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&p.ID, &p.Title, &p.Text, &wordmapJson)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	if wordmapJson != nil {
+		if err := json.Unmarshal(wordmapJson, &p.VocabWordMap); err != nil {
+			return nil, err
+		}
+	}
+	p.Vocabulary, err = s.getVocabulary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	vqs := NewVerbalQuestionService(s.DB, s.Lemmatizer)
+	questionIDs, err := vqs.GetPassageQuestionIDs(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.Questions = make([]models.VerbalQuestion, 0, len(questionIDs))
+	if len(questionIDs) == 0 {
+		return p, nil
+	}
+	questions, err := vqs.GetByIDs(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(questions, func(i, j int) bool { return questions[i].ID < questions[j].ID })
+	for _, q := range questions {
+		p.Questions = append(p.Questions, *q)
+	}
+	return p, nil
+}
+
+/**
+* Retrieves a passage at random among the ones that have active questions,
+* leaving out the excluded passages.
+**/
+func (s *PassageService) Random(ctx context.Context, excludeIDs []int) (*models.Passage, error) {
+	query := squirrel.Select(database.PassagesIDField).
+		From(database.PassagesTable).
+		Where(database.PassagesIDField + " IN (SELECT " + database.VerbalQuestionsPassageField +
+			" FROM " + database.VerbalQuestionsTable + " WHERE " + database.VerbalQuestionsDeletedAtField + " IS NULL)").
+		OrderBy("RANDOM()").
+		Limit(1).
+		PlaceholderFormat(squirrel.Dollar)
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.PassagesIDField: excludeIDs})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var id int
+	err = s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *PassageService) getVocabulary(ctx context.Context, id int) ([]models.Word, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT w.`+database.WordsIDField+`, w.`+database.WordsWordField+`, w.`+database.WordsMeaningsField+`, w.`+database.WordsExamplesField+`
+		FROM `+database.WordsTable+` AS w
+		INNER JOIN `+database.PassageWordsJoinTable+` AS pw ON w.`+database.WordsIDField+` = pw.`+database.PassageWordJoinWordField+`
+		WHERE pw.`+database.PassageWordJoinPassageField+` = $1
+		ORDER BY w.`+database.WordsWordField, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vocabulary := make([]models.Word, 0)
+	var meaningsJson []byte
+	for rows.Next() {
+		var word models.Word
+		err = rows.Scan(&word.ID, &word.Word, &meaningsJson, &word.Examples)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(meaningsJson, &word.Meanings)
+		if err != nil {
+			return nil, err
+		}
+		vocabulary = append(vocabulary, word)
+	}
+	return vocabulary, rows.Err()
+}
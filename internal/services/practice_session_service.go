@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+// Upper bound on the number of questions in a single session
+const maxSessionLength = 100
+
+// Errors returned when an operation is not allowed in the current state of a session
+var (
+	ErrInvalidSessionCriteria = errors.New("invalid session criteria")
+	ErrSessionNotActive       = errors.New("session is not active")
+	ErrSessionNotPaused       = errors.New("session is not paused")
+	ErrSessionNotPausable     = errors.New("session cannot be paused")
+	ErrSessionComplete        = errors.New("session has no more questions")
+	ErrNoPendingQuestion      = errors.New("no question is waiting for an answer")
+	ErrQuestionNotCurrent     = errors.New("question is not the current question of the session")
+)
+
+// Subset of pgx used to read and write within or outside a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+type PracticeSessionService struct {
+	Store *repository.Store
+}
+
+func NewPracticeSessionService(db *pgxpool.Pool) *PracticeSessionService {
+	return &PracticeSessionService{Store: NewPostgresStore(db)}
+}
+
+/**
+* Starts a new practice session for the user with the given criteria.
+**/
+func (s *PracticeSessionService) Start(ctx context.Context, userToken string, criteria models.SessionCriteria) (*models.PracticeSession, error) {
+	if criteria.Length <= 0 || criteria.Length > maxSessionLength {
+		return nil, fmt.Errorf("%w: length must be between 1 and %d", ErrInvalidSessionCriteria, maxSessionLength)
+	}
+	if criteria.TimeLimit < 0 {
+		return nil, fmt.Errorf("%w: time limit cannot be negative", ErrInvalidSessionCriteria)
+	}
+	return s.create(ctx, userToken, models.SessionModePractice, criteria, nil)
+}
+
+/**
+* Starts a session that serves a fixed list of questions in order. The
+* length of the session is the number of questions.
+**/
+func (s *PracticeSessionService) StartWithQuestions(ctx context.Context, userToken string, mode string,
+	timeLimit int, questionIDs []int) (*models.PracticeSession, error) {
+	if len(questionIDs) == 0 {
+		return nil, fmt.Errorf("%w: no questions", ErrInvalidSessionCriteria)
+	}
+	criteria := models.SessionCriteria{Length: len(questionIDs), TimeLimit: timeLimit}
+	return s.create(ctx, userToken, mode, criteria, questionIDs)
+}
+
+/**
+* Creates a session in the given mode. Questions that are passed are served
+* in order before any further question is selected from the criteria.
+**/
+func (s *PracticeSessionService) create(ctx context.Context, userToken string, mode string,
+	criteria models.SessionCriteria, questionIDs []int) (*models.PracticeSession, error) {
+	now := time.Now()
+	if questionIDs == nil {
+		questionIDs = make([]int, 0)
+	}
+	session := &models.PracticeSession{
+		UserToken:     userToken,
+		Mode:          mode,
+		Criteria:      criteria,
+		Status:        models.SessionActive,
+		QuestionIDs:   questionIDs,
+		StartedAt:     now,
+		LastResumedAt: now,
+	}
+	if err := s.Store.Sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Retrieves a session of the user. Sessions that ran out of time are finished.
+func (s *PracticeSessionService) Get(ctx context.Context, userToken string, id int) (*models.PracticeSession, error) {
+	return s.withSession(ctx, userToken, id, nil)
+}
+
+// Retrieves every session of the user, most recent first
+func (s *PracticeSessionService) GetByUserToken(ctx context.Context, userToken string) ([]models.PracticeSession, error) {
+	sessions, err := s.Store.Sessions.GetByUser(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range sessions {
+		sessions[i] = liveSession(sessions[i], now)
+	}
+	return sessions, nil
+}
+
+/**
+* Returns the question that is waiting for an answer in the session. When
+* the previous question has been answered a new question is selected from
+* the criteria of the session, adaptively when no difficulty is set. The
+* same question is returned until it is answered so the session can be
+* continued from another device. A waiting question that has been deleted
+* is dropped from the session and another question is selected instead.
+**/
+func (s *PracticeSessionService) Next(ctx context.Context, userToken string, id int) (*models.VerbalQuestion, *models.PracticeSession, error) {
+	var question *models.VerbalQuestion
+	session, err := s.withSession(ctx, userToken, id, func(tx *repository.Store, session *models.PracticeSession) error {
+		if session.Status != models.SessionActive {
+			return ErrSessionNotActive
+		}
+		for session.Answered < len(session.QuestionIDs) {
+			var err error
+			question, err = tx.Questions.GetByID(ctx, session.QuestionIDs[session.Answered])
+			if err != echo.ErrNotFound {
+				return err
+			}
+			ids := session.QuestionIDs
+			session.QuestionIDs = append(ids[:session.Answered:session.Answered], ids[session.Answered+1:]...)
+		}
+		if session.Answered >= session.Criteria.Length {
+			return ErrSessionComplete
+		}
+		questionID, err := selectQuestion(ctx, tx, session)
+		if err != nil {
+			return err
+		}
+		session.QuestionIDs = append(session.QuestionIDs, questionID)
+		question, err = tx.Questions.GetByID(ctx, questionID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return question, session, nil
+}
+
+/**
+* Grades and records the answer to the current question of the session.
+* The session is finished once the last question has been answered.
+**/
+func (s *PracticeSessionService) Answer(ctx context.Context, userToken string, id int,
+	req models.SessionAnswerRequest) (*models.SessionAnswer, error) {
+	stat := models.UserVerbalStat{
+		QuestionID: req.QuestionID,
+		Answers:    req.Answers,
+		Duration:   req.Duration,
+	}
+	session, err := s.withSession(ctx, userToken, id, func(tx *repository.Store, session *models.PracticeSession) error {
+		if session.Status != models.SessionActive {
+			return ErrSessionNotActive
+		}
+		if session.Answered >= len(session.QuestionIDs) {
+			return ErrNoPendingQuestion
+		}
+		if session.QuestionIDs[session.Answered] != req.QuestionID {
+			return ErrQuestionNotCurrent
+		}
+		stat.SessionID = &session.ID
+		// The answer is recorded in the transaction of the session, so that
+		// it is not recorded again when saving the session fails
+		uvss := &UserVerbalStatsService{Store: tx}
+		err := uvss.record(ctx, &stat, userToken)
+		if err != nil {
+			return err
+		}
+		session.Answered++
+		if stat.Correct {
+			session.Correct++
+		}
+		if session.Answered >= session.Criteria.Length {
+			finishSession(session, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.SessionAnswer{Stat: stat, Session: *session}, nil
+}
+
+/**
+* Pauses an active session. Time spent paused does not count towards the
+* time limit. Sections of a mock exam cannot be paused.
+**/
+func (s *PracticeSessionService) Pause(ctx context.Context, userToken string, id int) (*models.PracticeSession, error) {
+	return s.withSession(ctx, userToken, id, func(tx *repository.Store, session *models.PracticeSession) error {
+		if session.Status != models.SessionActive {
+			return ErrSessionNotActive
+		}
+		if session.Mode == models.SessionModeMockExam {
+			return ErrSessionNotPausable
+		}
+		session.Elapsed = elapsedAt(session, time.Now())
+		session.Status = models.SessionPaused
+		return nil
+	})
+}
+
+// Resumes a paused session
+func (s *PracticeSessionService) Resume(ctx context.Context, userToken string, id int) (*models.PracticeSession, error) {
+	return s.withSession(ctx, userToken, id, func(tx *repository.Store, session *models.PracticeSession) error {
+		if session.Status != models.SessionPaused {
+			return ErrSessionNotPaused
+		}
+		session.Status = models.SessionActive
+		session.LastResumedAt = time.Now()
+		return nil
+	})
+}
+
+// Finishes a session and returns its summary. Finishing a finished session has no effect.
+func (s *PracticeSessionService) Finish(ctx context.Context, userToken string, id int) (*models.SessionSummary, error) {
+	_, err := s.withSession(ctx, userToken, id, func(tx *repository.Store, session *models.PracticeSession) error {
+		if session.Status != models.SessionFinished {
+			finishSession(session, time.Now())
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrSessionNotActive) {
+		return nil, err
+	}
+	return s.Summary(ctx, userToken, id)
+}
+
+/**
+* Builds the summary of a session from the verbal stats that were recorded
+* during it.
+**/
+func (s *PracticeSessionService) Summary(ctx context.Context, userToken string, id int) (*models.SessionSummary, error) {
+	session, err := s.Get(ctx, userToken, id)
+	if err != nil {
+		return nil, err
+	}
+	uvss := &UserVerbalStatsService{Store: s.Store}
+	stats, err := uvss.GetVerbalStatsBySession(ctx, userToken, id)
+	if err != nil {
+		return nil, err
+	}
+	summary := &models.SessionSummary{
+		Session: *session,
+		ByType:  make(map[string]models.SessionBreakdown),
+		Stats:   stats,
+	}
+	var correct, duration int
+	for _, stat := range stats {
+		breakdown := summary.ByType[stat.Type.String()]
+		breakdown.Answered++
+		if stat.Correct {
+			breakdown.Correct++
+			correct++
+		}
+		summary.ByType[stat.Type.String()] = breakdown
+		duration += stat.Duration
+	}
+	if len(stats) > 0 {
+		summary.Accuracy = float64(correct) / float64(len(stats))
+		summary.AverageDuration = float64(duration) / float64(len(stats))
+	}
+	return summary, nil
+}
+
+/**
+* Loads the session of the user within a transaction that locks it, applies
+* the time limit, runs the update function in the transaction and saves the
+* result. Sessions that ran out of time are finished and saved before
+* ErrSessionNotActive is returned. The returned session has its elapsed time
+* brought up to date.
+**/
+func (s *PracticeSessionService) withSession(ctx context.Context, userToken string, id int,
+	update func(tx *repository.Store, session *models.PracticeSession) error) (*models.PracticeSession, error) {
+	var session *models.PracticeSession
+	expired := false
+	err := s.Store.InTx(ctx, func(tx *repository.Store) error {
+		var err error
+		session, err = tx.Sessions.GetForUpdate(ctx, userToken, id)
+		if err != nil {
+			return err
+		}
+		if expireSession(session, time.Now()) {
+			expired = true
+			return tx.Sessions.Update(ctx, session)
+		}
+		if update == nil {
+			return nil
+		}
+		if err := update(tx, session); err != nil {
+			return err
+		}
+		return tx.Sessions.Update(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired && update != nil {
+		return nil, fmt.Errorf("%w: time limit reached", ErrSessionNotActive)
+	}
+	live := liveSession(*session, time.Now())
+	return &live, nil
+}
+
+/**
+* Selects the next question of a session. Questions of a fixed difficulty
+* are picked at random, otherwise the most informative question at the
+* ability of the user is picked. Sessions without a question type rotate
+* through the verbal question types. Once a question of a passage is served
+* the rest of the passage set follows it.
+**/
+func selectQuestion(ctx context.Context, store *repository.Store, session *models.PracticeSession) (int, error) {
+	vqs := &VerbalQuestionService{Store: store}
+	criteria := session.Criteria
+	// Finish the passage set of the last question before moving on
+	if n := len(session.QuestionIDs); n > 0 {
+		last, err := vqs.GetByID(ctx, session.QuestionIDs[n-1])
+		// A question deleted since it was served has no passage set to finish
+		if err != nil && err != echo.ErrNotFound {
+			return 0, err
+		}
+		if err == nil && last.PassageID != nil {
+			siblings, err := vqs.GetPassageQuestionIDs(ctx, *last.PassageID, session.QuestionIDs)
+			if err != nil {
+				return 0, err
+			}
+			if len(siblings) > 0 {
+				return siblings[0], nil
+			}
+		}
+	}
+	if criteria.Difficulty != 0 {
+		question, err := vqs.GetRandom(ctx, criteria.QuestionType, criteria.Competence, criteria.Difficulty, session.QuestionIDs)
+		if err != nil {
+			return 0, err
+		}
+		return question.ID, nil
+	}
+	qTypes := []models.QuestionType{criteria.QuestionType}
+	if criteria.QuestionType == 0 {
+		all := []models.QuestionType{models.ReadingComprehension, models.TextCompletion, models.SentenceEquivalence}
+		qTypes = make([]models.QuestionType, len(all))
+		for i := range all {
+			qTypes[i] = all[(len(session.QuestionIDs)+i)%len(all)]
+		}
+	}
+	abilities, err := store.Users.GetAbilities(ctx, session.UserToken)
+	if err != nil {
+		return 0, err
+	}
+	for _, qType := range qTypes {
+		estimate := findAbility(abilities, models.AbilityDimensionType, qType.String())
+		question, err := vqs.GetMostInformative(ctx, qType, criteria.Competence, estimate.Theta, session.QuestionIDs)
+		if err == echo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return question.ID, nil
+	}
+	return 0, echo.ErrNotFound
+}
+
+// Active time of a session in seconds at the given time
+func elapsedAt(session *models.PracticeSession, now time.Time) int {
+	if session.Status != models.SessionActive {
+		return session.Elapsed
+	}
+	return session.Elapsed + int(now.Sub(session.LastResumedAt).Seconds())
+}
+
+// Finishes a session and stops its clock
+func finishSession(session *models.PracticeSession, now time.Time) {
+	session.Elapsed = elapsedAt(session, now)
+	if limit := session.Criteria.TimeLimit; limit > 0 && session.Elapsed > limit {
+		session.Elapsed = limit
+	}
+	session.Status = models.SessionFinished
+	session.FinishedAt = &now
+}
+
+// Finishes an active session whose time limit has been reached
+func expireSession(session *models.PracticeSession, now time.Time) bool {
+	limit := session.Criteria.TimeLimit
+	if session.Status != models.SessionActive || limit <= 0 || elapsedAt(session, now) < limit {
+		return false
+	}
+	finishSession(session, now)
+	return true
+}
+
+// Copy of a session with its elapsed time brought up to date for display
+func liveSession(session models.PracticeSession, now time.Time) models.PracticeSession {
+	session.Elapsed = elapsedAt(&session, now)
+	return session
+}
+
+// Columns of a practice session in the order expected by scanPracticeSession
+func practiceSessionColumns() []string {
+	return []string{
+		database.PracticeSessionsIDField,
+		database.PracticeSessionsUserField,
+		database.PracticeSessionsModeField,
+		database.PracticeSessionsCriteriaField,
+		database.PracticeSessionsStatusField,
+		database.PracticeSessionsQuestionsField,
+		database.PracticeSessionsAnsweredField,
+		database.PracticeSessionsCorrectField,
+		database.PracticeSessionsElapsedField,
+		database.PracticeSessionsStartedAtField,
+		database.PracticeSessionsLastResumedAtField,
+		database.PracticeSessionsFinishedAtField,
+	}
+}
+
+func scanPracticeSession(row pgx.Row, session *models.PracticeSession) error {
+	var criteriaJson []byte
+	var status string
+	err := row.Scan(
+		&session.ID,
+		&session.UserToken,
+		&session.Mode,
+		&criteriaJson,
+		&status,
+		&session.QuestionIDs,
+		&session.Answered,
+		&session.Correct,
+		&session.Elapsed,
+		&session.StartedAt,
+		&session.LastResumedAt,
+		&session.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+	session.Status = models.SessionStatus(status)
+	return json.Unmarshal(criteriaJson, &session.Criteria)
+}
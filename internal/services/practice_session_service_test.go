@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+func TestPracticeSession(t *testing.T) {
+	ctx := context.Background()
+	_, store := memoryStore(t)
+	s := &PracticeSessionService{Store: store}
+	session, err := s.Start(ctx, "u1", models.SessionCriteria{QuestionType: models.TextCompletion, Length: 2})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := s.Get(ctx, "u2", session.ID); err == nil {
+		t.Error("Get() should not return the session of another user")
+	}
+	for i := 0; i < 2; i++ {
+		question, _, err := s.Next(ctx, "u1", session.ID)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		// The question is served again until it is answered
+		again, _, err := s.Next(ctx, "u1", session.ID)
+		if err != nil || again.ID != question.ID {
+			t.Fatalf("Next() = %+v, %v, want question %d again", again, err, question.ID)
+		}
+		other := 3 - question.ID
+		_, err = s.Answer(ctx, "u1", session.ID, models.SessionAnswerRequest{QuestionID: other, Answers: []string{"terse"}})
+		if !errors.Is(err, ErrQuestionNotCurrent) {
+			t.Errorf("Answer() error = %v, want %v", err, ErrQuestionNotCurrent)
+		}
+		answer, err := s.Answer(ctx, "u1", session.ID, models.SessionAnswerRequest{QuestionID: question.ID,
+			Answers: []string{"terse"}, Duration: 10})
+		if err != nil {
+			t.Fatalf("Answer() error = %v", err)
+		}
+		if answer.Session.Answered != i+1 || answer.Stat.SessionID == nil {
+			t.Errorf("answer = %+v, want %d answered questions", answer, i+1)
+		}
+		session = &answer.Session
+	}
+	if session.Status != models.SessionFinished || session.FinishedAt == nil {
+		t.Errorf("session = %+v, want finished after its last question", session)
+	}
+	if _, _, err := s.Next(ctx, "u1", session.ID); !errors.Is(err, ErrSessionNotActive) {
+		t.Errorf("Next() error = %v, want %v", err, ErrSessionNotActive)
+	}
+	summary, err := s.Summary(ctx, "u1", session.ID)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	breakdown := summary.ByType[models.TextCompletion.String()]
+	if len(summary.Stats) != 2 || summary.Accuracy != 1 || summary.AverageDuration != 10 || breakdown.Correct != 2 {
+		t.Errorf("summary = %+v, want 2 correct text completions", summary)
+	}
+}
+
+func TestPracticeSessionNextDeletedQuestion(t *testing.T) {
+	ctx := context.Background()
+	m, store := memoryStore(t)
+	s := &PracticeSessionService{Store: store}
+	session, err := s.Start(ctx, "u1", models.SessionCriteria{QuestionType: models.TextCompletion, Length: 1})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	question, _, err := s.Next(ctx, "u1", session.ID)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	deletedAt := time.Now()
+	deleted := *question
+	deleted.DeletedAt = &deletedAt
+	m.AddQuestion(deleted, 1)
+
+	replacement, session, err := s.Next(ctx, "u1", session.ID)
+	if err != nil {
+		t.Fatalf("Next() error = %v, want a replacement for the deleted question", err)
+	}
+	if replacement.ID == question.ID || !reflect.DeepEqual(session.QuestionIDs, []int{replacement.ID}) {
+		t.Errorf("Next() = %d with questions %v, want question %d replaced", replacement.ID, session.QuestionIDs, question.ID)
+	}
+	answer, err := s.Answer(ctx, "u1", session.ID, models.SessionAnswerRequest{QuestionID: replacement.ID, Answers: []string{"terse"}})
+	if err != nil || answer.Session.Status != models.SessionFinished {
+		t.Errorf("Answer() = %+v, %v, want the session finished", answer, err)
+	}
+}
+
+// Sessions that cannot be saved
+type failingSessions struct {
+	repository.SessionRepository
+}
+
+func (r failingSessions) Update(ctx context.Context, session *models.PracticeSession) error {
+	return errors.New("update failed")
+}
+
+func TestPracticeSessionAnswerRollsBack(t *testing.T) {
+	ctx := context.Background()
+	m, store := memoryStore(t)
+	s := &PracticeSessionService{Store: store}
+	session, err := s.Start(ctx, "u1", models.SessionCriteria{QuestionType: models.TextCompletion, Length: 1})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	question, _, err := s.Next(ctx, "u1", session.ID)
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	runInTx := store.RunInTx
+	store.RunInTx = func(ctx context.Context, fn func(tx *repository.Store) error) error {
+		return runInTx(ctx, func(tx *repository.Store) error {
+			tx.Sessions = failingSessions{tx.Sessions}
+			return fn(tx)
+		})
+	}
+	_, err = s.Answer(ctx, "u1", session.ID, models.SessionAnswerRequest{QuestionID: question.ID, Answers: []string{"terse"}})
+	if err == nil {
+		t.Fatal("Answer() should fail when the session cannot be saved")
+	}
+	if stats := m.Stats("u1"); len(stats) != 0 {
+		t.Errorf("Stats() = %+v, want the answer rolled back with the session", stats)
+	}
+	if abilities, _ := store.Users.GetAbilities(ctx, "u1"); len(abilities) != 0 {
+		t.Errorf("GetAbilities() = %+v, want the estimates rolled back with the session", abilities)
+	}
+}
@@ -0,0 +1,768 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+)
+
+// Returned when a quant question cannot be stored as it is
+var ErrInvalidQuantQuestion = errors.New("invalid quant question")
+
+// Quant question types in the order they are served by adaptive selection
+var quantQuestionTypes = []models.QuantQuestionType{
+	models.QuantitativeComparison,
+	models.QuantMCQSingleAnswer,
+	models.QuantMCQMultipleChoices,
+	models.NumericEntry,
+}
+
+// Restricts a query to quant questions that have not been deleted
+var activeQuantQuestion = squirrel.Expr(database.QuantQuestionsDeletedAtField + " IS NULL")
+
+// Restricts a query to data sets that have not been deleted
+var activeQuantDataSet = squirrel.Expr(database.QuantDataSetsDeletedAtField + " IS NULL")
+
+type QuantQuestionService struct {
+	DB *pgxpool.Pool
+}
+
+func NewQuantQuestionService(db *pgxpool.Pool) *QuantQuestionService {
+	return &QuantQuestionService{DB: db}
+}
+
+/**
+* Creates a new record in the Db for a quant question. Questions that
+* are not calibrated yet get the default IRT parameters for their
+* difficulty.
+**/
+func (s *QuantQuestionService) Create(ctx context.Context, q *models.QuantQuestion) error {
+	return insertQuantQuestion(ctx, s.DB, q)
+}
+
+/**
+* Creates a data interpretation set along with all of its questions in
+* a single transaction. Every question is linked to the new set.
+**/
+func (s *QuantQuestionService) CreateDataSet(ctx context.Context, ds *models.QuantDataSet) error {
+	if err := validateQuantDataSet(ds); err != nil {
+		return err
+	}
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	query := squirrel.Insert(database.QuantDataSetsTable).
+		Columns(
+			database.QuantDataSetsTitleField,
+			database.QuantDataSetsDescriptionField,
+			database.QuantDataSetsChartField).
+		Values(ds.Title, ds.Description, []byte(ds.Chart)).
+		Suffix("RETURNING " + database.QuantDataSetsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, sqlQuery, args...).Scan(&ds.ID)
+	if err != nil {
+		return err
+	}
+	for i := range ds.Questions {
+		ds.Questions[i].DataSetID = &ds.ID
+		err = insertQuantQuestion(ctx, tx, &ds.Questions[i])
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+/**
+* Replaces the content of a quant question, which stays in its data set.
+* When no IRT parameters are sent the current ones are kept, unless the
+* type, difficulty or number of options changed in which case the defaults
+* for the new values are used.
+**/
+func (s *QuantQuestionService) Update(ctx context.Context, id int, q *models.QuantQuestion) (*models.QuantQuestion, error) {
+	return s.modify(ctx, id, func(next *models.QuantQuestion) error {
+		*next = *q
+		return nil
+	})
+}
+
+/**
+* Applies a partial update to a quant question. Only the fields present in
+* the JSON patch are changed.
+**/
+func (s *QuantQuestionService) Patch(ctx context.Context, id int, patch []byte) (*models.QuantQuestion, error) {
+	return s.modify(ctx, id, quantQuestionPatch(patch))
+}
+
+/**
+* Soft deletes a quant question so that it is no longer served while the
+* quant stats recorded for it are kept.
+**/
+func (s *QuantQuestionService) Delete(ctx context.Context, id int) error {
+	tag, err := s.DB.Exec(ctx, `
+		UPDATE `+database.QuantQuestionsTable+` SET `+database.QuantQuestionsDeletedAtField+` = $1
+		WHERE `+database.QuantQuestionsIDField+` = $2 AND `+database.QuantQuestionsDeletedAtField+` IS NULL`,
+		time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return echo.ErrNotFound
+	}
+	return nil
+}
+
+/**
+* Replaces the title, description and chart of a data set. Its questions
+* are changed through the quant question endpoints.
+**/
+func (s *QuantQuestionService) UpdateDataSet(ctx context.Context, id int, ds *models.QuantDataSet) (*models.QuantDataSet, error) {
+	return s.modifyDataSet(ctx, id, func(next *models.QuantDataSet) error {
+		*next = *ds
+		return nil
+	})
+}
+
+// Applies a partial update to a data set. Only the fields present in the JSON patch are changed.
+func (s *QuantQuestionService) PatchDataSet(ctx context.Context, id int, patch []byte) (*models.QuantDataSet, error) {
+	return s.modifyDataSet(ctx, id, func(next *models.QuantDataSet) error {
+		if err := json.Unmarshal(patch, next); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidQuantQuestion, err.Error())
+		}
+		return nil
+	})
+}
+
+// Soft deletes a data set along with all of its questions
+func (s *QuantQuestionService) DeleteDataSet(ctx context.Context, id int) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	now := time.Now()
+	tag, err := tx.Exec(ctx, `
+		UPDATE `+database.QuantDataSetsTable+` SET `+database.QuantDataSetsDeletedAtField+` = $1
+		WHERE `+database.QuantDataSetsIDField+` = $2 AND `+database.QuantDataSetsDeletedAtField+` IS NULL`,
+		now, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return echo.ErrNotFound
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE `+database.QuantQuestionsTable+` SET `+database.QuantQuestionsDeletedAtField+` = $1
+		WHERE `+database.QuantQuestionsDataSetField+` = $2 AND `+database.QuantQuestionsDeletedAtField+` IS NULL`,
+		now, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Locks a quant question, applies a change to it and stores the result
+func (s *QuantQuestionService) modify(
+	ctx context.Context,
+	id int,
+	apply func(q *models.QuantQuestion) error,
+) (*models.QuantQuestion, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	query := squirrel.Select(quantQuestionColumns()...).
+		From(database.QuantQuestionsTable).
+		Where(squirrel.Eq{database.QuantQuestionsIDField: id}).
+		Where(activeQuantQuestion).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	current := &models.QuantQuestion{}
+	err = scanQuantQuestion(tx.QueryRow(ctx, sqlQuery, args...), current)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	next, err := applyQuantQuestionChange(current, apply)
+	if err != nil {
+		return nil, err
+	}
+	optionsJson, err := json.Marshal(next.Options)
+	if err != nil {
+		return nil, err
+	}
+	answerJson, err := json.Marshal(next.Answer)
+	if err != nil {
+		return nil, err
+	}
+	update := squirrel.Update(database.QuantQuestionsTable).
+		Set(database.QuantQuestionsTypeField, next.Type).
+		Set(database.QuantQuestionsTopicField, next.Topic).
+		Set(database.QuantQuestionsQuestionField, next.Question).
+		Set(database.QuantQuestionsQuantityAField, next.QuantityA).
+		Set(database.QuantQuestionsQuantityBField, next.QuantityB).
+		Set(database.QuantQuestionsOptionsField, optionsJson).
+		Set(database.QuantQuestionsAnswerField, answerJson).
+		Set(database.QuantQuestionsDifficultyField, next.Difficulty).
+		Set(database.QuantQuestionsIRTAField, next.IRT.A).
+		Set(database.QuantQuestionsIRTBField, next.IRT.B).
+		Set(database.QuantQuestionsIRTCField, next.IRT.C).
+		Where(squirrel.Eq{database.QuantQuestionsIDField: id}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err = update.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+// Locks a data set, applies a change to it and stores the result
+func (s *QuantQuestionService) modifyDataSet(
+	ctx context.Context,
+	id int,
+	apply func(ds *models.QuantDataSet) error,
+) (*models.QuantDataSet, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	current := models.QuantDataSet{ID: id}
+	var chart []byte
+	err = tx.QueryRow(ctx, `
+		SELECT `+database.QuantDataSetsTitleField+`, `+database.QuantDataSetsDescriptionField+`, `+database.QuantDataSetsChartField+`
+		FROM `+database.QuantDataSetsTable+`
+		WHERE `+database.QuantDataSetsIDField+` = $1 AND `+database.QuantDataSetsDeletedAtField+` IS NULL
+		FOR UPDATE`, id).Scan(&current.Title, &current.Description, &chart)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	current.Chart = json.RawMessage(chart)
+	next := current
+	if err := apply(&next); err != nil {
+		return nil, err
+	}
+	next.ID = id
+	next.Questions = nil
+	if err := validateQuantDataSet(&next); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE `+database.QuantDataSetsTable+` SET
+			`+database.QuantDataSetsTitleField+` = $1,
+			`+database.QuantDataSetsDescriptionField+` = $2,
+			`+database.QuantDataSetsChartField+` = $3
+		WHERE `+database.QuantDataSetsIDField+` = $4`,
+		next.Title, next.Description, []byte(next.Chart), id)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetDataSet(ctx, id)
+}
+
+/**
+* Returns the change that applies a JSON patch to a quant question. The
+* options and the answer sent in the patch replace the current ones instead
+* of being merged into them, as decoding into them would keep the fields
+* the patch leaves out.
+**/
+func quantQuestionPatch(patch []byte) func(q *models.QuantQuestion) error {
+	return func(q *models.QuantQuestion) error {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &fields); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidQuantQuestion, err.Error())
+		}
+		if _, ok := fields["options"]; ok {
+			q.Options = nil
+		}
+		if _, ok := fields["answer"]; ok {
+			q.Answer = nil
+		}
+		if err := json.Unmarshal(patch, q); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidQuantQuestion, err.Error())
+		}
+		return nil
+	}
+}
+
+/**
+* Applies a change to a copy of a quant question and validates the result.
+* The question keeps its ID and data set. When the change leaves the IRT
+* parameters unset the current ones are kept, unless the type, difficulty
+* or number of options changed in which case the defaults for the new
+* values are used.
+**/
+func applyQuantQuestionChange(current *models.QuantQuestion,
+	apply func(q *models.QuantQuestion) error) (*models.QuantQuestion, error) {
+	next := *current
+	next.IRT = models.IRTParams{}
+	next.Options = append([]models.Option(nil), current.Options...)
+	if current.Answer != nil {
+		answer := *current.Answer
+		next.Answer = &answer
+	}
+	if err := apply(&next); err != nil {
+		return nil, err
+	}
+	next.ID = current.ID
+	next.DataSetID = current.DataSetID
+	next.DataSet = nil
+	if err := validateQuantQuestion(&next); err != nil {
+		return nil, err
+	}
+	if next.IRT.A == 0 {
+		next.IRT = current.IRT
+		if next.Type != current.Type || next.Difficulty != current.Difficulty || len(next.Options) != len(current.Options) {
+			next.IRT = DefaultQuantIRTParams(next.Difficulty, next.Type, len(next.Options))
+		}
+	}
+	return &next, nil
+}
+
+/**
+* Retrieve a quant question by its ID. The data set of data
+* interpretation questions is included.
+**/
+func (s *QuantQuestionService) GetByID(ctx context.Context, id int) (*models.QuantQuestion, error) {
+	questions, err := s.GetByIDs(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return questions[0], nil
+}
+
+func (s *QuantQuestionService) GetByIDs(ctx context.Context, ids []int) ([]*models.QuantQuestion, error) {
+	query := squirrel.Select(quantQuestionColumns()...).
+		From(database.QuantQuestionsTable).
+		Where(squirrel.Eq{database.QuantQuestionsIDField: ids}).
+		Where(activeQuantQuestion).
+		OrderBy(database.QuantQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	questions, err := s.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	err = s.attachDataSets(ctx, questions)
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+/**
+* Retrieve a data interpretation set with all of its questions so that
+* they can be served together.
+**/
+func (s *QuantQuestionService) GetDataSet(ctx context.Context, id int) (*models.QuantDataSet, error) {
+	dataSets, err := s.getDataSets(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	ds, ok := dataSets[id]
+	if !ok {
+		return nil, echo.ErrNotFound
+	}
+	query := squirrel.Select(quantQuestionColumns()...).
+		From(database.QuantQuestionsTable).
+		Where(squirrel.Eq{database.QuantQuestionsDataSetField: id}).
+		Where(activeQuantQuestion).
+		OrderBy(database.QuantQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	questions, err := s.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ds.Questions = make([]models.QuantQuestion, len(questions))
+	for i, q := range questions {
+		ds.Questions[i] = *q
+	}
+	return ds, nil
+}
+
+/**
+* Retrieve quant questions at random based on particular parameters
+* to display to the user. Filters that are zero are not applied.
+**/
+func (s *QuantQuestionService) Random(
+	ctx context.Context,
+	limit int,
+	questionType models.QuantQuestionType,
+	topic models.QuantTopic,
+	difficulty models.Difficulty,
+	excludeIDs []int,
+) ([]*models.QuantQuestion, error) {
+	query := squirrel.Select(quantQuestionColumns()...).
+		From(database.QuantQuestionsTable).
+		Where(activeQuantQuestion).
+		OrderBy("RANDOM()").
+		Limit(uint64(limit)).
+		PlaceholderFormat(squirrel.Dollar)
+	if questionType != 0 {
+		query = query.Where(squirrel.Eq{database.QuantQuestionsTypeField: questionType})
+	}
+	if topic != 0 {
+		query = query.Where(squirrel.Eq{database.QuantQuestionsTopicField: topic})
+	}
+	if difficulty != 0 {
+		query = query.Where(squirrel.Eq{database.QuantQuestionsDifficultyField: difficulty})
+	}
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.QuantQuestionsIDField: excludeIDs})
+	}
+	questions, err := s.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	err = s.attachDataSets(ctx, questions)
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+/**
+* Fetch a list of questions that are adaptive based on the user. For each
+* quant question type the question that provides the most information at
+* the current quant ability estimate of the user is selected.
+**/
+func (s *QuantQuestionService) GetAdaptiveQuestions(ctx context.Context, userToken string,
+	numQuestions int, excludeIds []int) ([]*models.QuantQuestion, error) {
+	us := NewUserService(s.DB)
+	abilities, err := us.GetAbilities(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]*models.QuantQuestion, 0, len(quantQuestionTypes))
+	for _, qType := range quantQuestionTypes {
+		if len(questions) == numQuestions {
+			break
+		}
+		estimate := findAbility(abilities, models.AbilityDimensionQuantType, qType.String())
+		question, err := s.GetMostInformative(ctx, qType, estimate.Theta, excludeIds)
+		if err != nil {
+			// If an error occurred, just move to the next one.
+			print(err.Error())
+			continue
+		}
+		questions = append(questions, question)
+	}
+	if len(questions) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	err = s.attachDataSets(ctx, questions)
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+/**
+* Retrieve the quant question of the given type that provides the most
+* information at ability theta among the candidates whose difficulty
+* parameter is closest to theta.
+**/
+func (s *QuantQuestionService) GetMostInformative(
+	ctx context.Context,
+	qType models.QuantQuestionType,
+	theta float64,
+	excludeIDs []int,
+) (*models.QuantQuestion, error) {
+	query := squirrel.Select(quantQuestionColumns()...).
+		From(database.QuantQuestionsTable).
+		Where(squirrel.Eq{database.QuantQuestionsTypeField: qType}).
+		Where(activeQuantQuestion).
+		OrderByClause("ABS("+database.QuantQuestionsIRTBField+" - ?)", theta).
+		Limit(adaptiveCandidates).
+		PlaceholderFormat(squirrel.Dollar)
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.QuantQuestionsIDField: excludeIDs})
+	}
+	candidates, err := s.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]irt.Item, len(candidates))
+	for i, q := range candidates {
+		items[i] = irt.Item{ID: i, Params: irtParams(q.IRT)}
+	}
+	item, ok := irt.MostInformative(theta, items)
+	if !ok {
+		return nil, echo.ErrNotFound
+	}
+	return candidates[item.ID], nil
+}
+
+func (s *QuantQuestionService) query(ctx context.Context, query squirrel.SelectBuilder) ([]*models.QuantQuestion, error) {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	questions := make([]*models.QuantQuestion, 0)
+	for rows.Next() {
+		q := &models.QuantQuestion{}
+		err = scanQuantQuestion(rows, q)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// Sets the data set of every data interpretation question in the list
+func (s *QuantQuestionService) attachDataSets(ctx context.Context, questions []*models.QuantQuestion) error {
+	ids := make([]int, 0)
+	for _, q := range questions {
+		if q.DataSetID != nil {
+			ids = append(ids, *q.DataSetID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	dataSets, err := s.getDataSets(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, q := range questions {
+		if q.DataSetID != nil {
+			q.DataSet = dataSets[*q.DataSetID]
+		}
+	}
+	return nil
+}
+
+// Retrieves data sets without their questions keyed by their ID
+func (s *QuantQuestionService) getDataSets(ctx context.Context, ids []int) (map[int]*models.QuantDataSet, error) {
+	query := squirrel.Select(
+		database.QuantDataSetsIDField,
+		database.QuantDataSetsTitleField,
+		database.QuantDataSetsDescriptionField,
+		database.QuantDataSetsChartField).
+		From(database.QuantDataSetsTable).
+		Where(squirrel.Eq{database.QuantDataSetsIDField: ids}).
+		Where(activeQuantDataSet).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dataSets := make(map[int]*models.QuantDataSet)
+	for rows.Next() {
+		ds := &models.QuantDataSet{}
+		var chart []byte
+		err = rows.Scan(&ds.ID, &ds.Title, &ds.Description, &chart)
+		if err != nil {
+			return nil, err
+		}
+		ds.Chart = json.RawMessage(chart)
+		dataSets[ds.ID] = ds
+	}
+	return dataSets, rows.Err()
+}
+
+/**
+* Validates a quant question and inserts it using the given querier so
+* that it can take part in the transaction of a data set.
+**/
+func insertQuantQuestion(ctx context.Context, db querier, q *models.QuantQuestion) error {
+	err := validateQuantQuestion(q)
+	if err != nil {
+		return err
+	}
+	if q.IRT.A == 0 {
+		q.IRT = DefaultQuantIRTParams(q.Difficulty, q.Type, len(q.Options))
+	}
+	optionsJson, err := json.Marshal(q.Options)
+	if err != nil {
+		return err
+	}
+	answerJson, err := json.Marshal(q.Answer)
+	if err != nil {
+		return err
+	}
+	query := squirrel.Insert(database.QuantQuestionsTable).
+		Columns(
+			database.QuantQuestionsTypeField,
+			database.QuantQuestionsTopicField,
+			database.QuantQuestionsQuestionField,
+			database.QuantQuestionsQuantityAField,
+			database.QuantQuestionsQuantityBField,
+			database.QuantQuestionsOptionsField,
+			database.QuantQuestionsAnswerField,
+			database.QuantQuestionsDifficultyField,
+			database.QuantQuestionsDataSetField,
+			database.QuantQuestionsIRTAField,
+			database.QuantQuestionsIRTBField,
+			database.QuantQuestionsIRTCField).
+		Values(
+			q.Type,
+			q.Topic,
+			q.Question,
+			q.QuantityA,
+			q.QuantityB,
+			optionsJson,
+			answerJson,
+			q.Difficulty,
+			q.DataSetID,
+			q.IRT.A,
+			q.IRT.B,
+			q.IRT.C).
+		Suffix("RETURNING " + database.QuantQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	return db.QueryRow(ctx, sqlQuery, args...).Scan(&q.ID)
+}
+
+// Checks that a data set has a chart for its questions to refer to
+func validateQuantDataSet(ds *models.QuantDataSet) error {
+	if len(ds.Chart) == 0 || string(ds.Chart) == "null" {
+		return fmt.Errorf("%w: data set requires a chart", ErrInvalidQuantQuestion)
+	}
+	return nil
+}
+
+/**
+* Checks that a quant question can be graded. Numeric entry questions
+* need an expected answer while every other type needs options with the
+* right number of correct ones.
+**/
+func validateQuantQuestion(q *models.QuantQuestion) error {
+	if q.Type == 0 || q.Topic == 0 || q.Difficulty == 0 {
+		return fmt.Errorf("%w: type, topic and difficulty are required", ErrInvalidQuantQuestion)
+	}
+	if q.Type == models.NumericEntry {
+		if q.Answer == nil {
+			return fmt.Errorf("%w: numeric entry requires an answer", ErrInvalidQuantQuestion)
+		}
+		if q.Answer.Denominator < 0 || q.Answer.Tolerance < 0 {
+			return fmt.Errorf("%w: denominator and tolerance must not be negative", ErrInvalidQuantQuestion)
+		}
+		if q.Answer.Denominator != 0 {
+			q.Answer.Value = float64(q.Answer.Numerator) / float64(q.Answer.Denominator)
+		}
+		q.Options = nil
+		return nil
+	}
+	if q.Type == models.QuantitativeComparison && (q.QuantityA == "" || q.QuantityB == "") {
+		return fmt.Errorf("%w: quantitative comparison requires both quantities", ErrInvalidQuantQuestion)
+	}
+	correct := 0
+	for _, option := range q.Options {
+		if option.Correct {
+			correct++
+		}
+	}
+	if correct == 0 || (q.Type != models.QuantMCQMultipleChoices && correct != 1) {
+		return fmt.Errorf("%w: %s has %d correct options", ErrInvalidQuantQuestion, q.Type.String(), correct)
+	}
+	q.Answer = nil
+	return nil
+}
+
+// Columns of a quant question in the order expected by scanQuantQuestion
+func quantQuestionColumns() []string {
+	return []string{
+		database.QuantQuestionsIDField,
+		database.QuantQuestionsTypeField,
+		database.QuantQuestionsTopicField,
+		database.QuantQuestionsQuestionField,
+		database.QuantQuestionsQuantityAField,
+		database.QuantQuestionsQuantityBField,
+		database.QuantQuestionsOptionsField,
+		database.QuantQuestionsAnswerField,
+		database.QuantQuestionsDifficultyField,
+		database.QuantQuestionsDataSetField,
+		database.QuantQuestionsIRTAField,
+		database.QuantQuestionsIRTBField,
+		database.QuantQuestionsIRTCField,
+	}
+}
+
+// Scans a row selected with quantQuestionColumns into a quant question
+func scanQuantQuestion(row pgx.Row, q *models.QuantQuestion) error {
+	var optionsJson []byte
+	var answerJson []byte
+	err := row.Scan(
+		&q.ID,
+		&q.Type,
+		&q.Topic,
+		&q.Question,
+		&q.QuantityA,
+		&q.QuantityB,
+		&optionsJson,
+		&answerJson,
+		&q.Difficulty,
+		&q.DataSetID,
+		&q.IRT.A,
+		&q.IRT.B,
+		&q.IRT.C,
+	)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(optionsJson, &q.Options)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(answerJson, &q.Answer)
+}
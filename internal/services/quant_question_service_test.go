@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"grepandit.com/api/internal/models"
+)
+
+func quantQuestion() *models.QuantQuestion {
+	dataSetID := 7
+	return &models.QuantQuestion{
+		ID:         3,
+		Type:       models.QuantMCQSingleAnswer,
+		Topic:      models.Arithmetic,
+		Question:   "What is 2 + 2?",
+		Options:    []models.Option{{Value: "3"}, {Value: "4", Correct: true}},
+		Difficulty: models.Medium,
+		DataSetID:  &dataSetID,
+		IRT:        models.IRTParams{A: 1.3, B: 0.4, C: 0.1},
+	}
+}
+
+func TestApplyQuantQuestionChange(t *testing.T) {
+	current := quantQuestion()
+
+	next, err := applyQuantQuestionChange(current, quantQuestionPatch([]byte(`{"question": "What is 3 + 1?"}`)))
+	if err != nil {
+		t.Fatalf("applyQuantQuestionChange() error = %v", err)
+	}
+	if next.Question != "What is 3 + 1?" || len(next.Options) != 2 || next.IRT != current.IRT {
+		t.Errorf("patched question = %+v, want the new text with the current options and IRT", next)
+	}
+	if current.Question != "What is 2 + 2?" {
+		t.Errorf("current question = %q, want it unchanged", current.Question)
+	}
+
+	next, err = applyQuantQuestionChange(current, quantQuestionPatch([]byte(`{"difficulty": "Hard"}`)))
+	if err != nil {
+		t.Fatalf("applyQuantQuestionChange() error = %v", err)
+	}
+	if want := DefaultQuantIRTParams(models.Hard, models.QuantMCQSingleAnswer, 2); next.IRT != want {
+		t.Errorf("IRT after a difficulty change = %+v, want the defaults %+v", next.IRT, want)
+	}
+
+	next, err = applyQuantQuestionChange(current, quantQuestionPatch([]byte(`{"difficulty": "Hard", "irt": {"a": 2, "b": 1, "c": 0.2}}`)))
+	if err != nil {
+		t.Fatalf("applyQuantQuestionChange() error = %v", err)
+	}
+	if want := (models.IRTParams{A: 2, B: 1, C: 0.2}); next.IRT != want {
+		t.Errorf("IRT sent with the change = %+v, want %+v", next.IRT, want)
+	}
+
+	// A replacement keeps the ID and data set of the question
+	next, err = applyQuantQuestionChange(current, func(q *models.QuantQuestion) error {
+		*q = models.QuantQuestion{Type: models.NumericEntry, Topic: models.Arithmetic, Question: "2 + 2 = ?",
+			Difficulty: models.Medium, Answer: &models.NumericAnswer{Value: 4}}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("applyQuantQuestionChange() error = %v", err)
+	}
+	if next.ID != current.ID || next.DataSetID != current.DataSetID || next.Options != nil {
+		t.Errorf("replaced question = %+v, want the ID and data set kept and no options", next)
+	}
+}
+
+func TestApplyQuantQuestionChangeInvalid(t *testing.T) {
+	current := quantQuestion()
+	for name, patch := range map[string]string{
+		"no correct option": `{"options": [{"value": "3"}, {"value": "4"}]}`,
+		"no answer":         `{"type": "NumericEntry"}`,
+	} {
+		_, err := applyQuantQuestionChange(current, quantQuestionPatch([]byte(patch)))
+		if !errors.Is(err, ErrInvalidQuantQuestion) {
+			t.Errorf("%s: applyQuantQuestionChange() error = %v, want %v", name, err, ErrInvalidQuantQuestion)
+		}
+	}
+	if !current.Options[1].Correct {
+		t.Errorf("current options = %+v, want them unchanged by an invalid change", current.Options)
+	}
+}
+
+func TestValidateQuantDataSet(t *testing.T) {
+	for _, chart := range []string{"", "null"} {
+		err := validateQuantDataSet(&models.QuantDataSet{Title: "Sales", Chart: json.RawMessage(chart)})
+		if !errors.Is(err, ErrInvalidQuantQuestion) {
+			t.Errorf("validateQuantDataSet(%q) error = %v, want %v", chart, err, ErrInvalidQuantQuestion)
+		}
+	}
+	if err := validateQuantDataSet(&models.QuantDataSet{Chart: json.RawMessage(`{"type": "bar"}`)}); err != nil {
+		t.Errorf("validateQuantDataSet() error = %v", err)
+	}
+}
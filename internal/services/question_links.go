@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"github.com/Masterminds/squirrel"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+)
+
+/**
+* Recomputes the vocabulary links and the wordmap of a question from its
+* content, for example after the lemmatizer changed. The vocabulary is the
+* base forms of the words the question is linked to. The content of the
+* question does not change, so no revision is recorded. Returns whether
+* the stored links or wordmap were different.
+**/
+func (s *VerbalQuestionService) Relink(ctx context.Context, id int) (bool, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	current, snapshot, err := lockQuestion(ctx, tx, id)
+	if err != nil {
+		return false, err
+	}
+	vocabBaseForms, variations := vocabularyWordMap(s.Lemmatizer, snapshot.Vocabulary, questionTexts(snapshot)...)
+	vocabulary := baseFormList(vocabBaseForms)
+	if reflect.DeepEqual(vocabulary, snapshot.Vocabulary) && reflect.DeepEqual(variations, nonNilWordmap(current.VocabWordMap)) {
+		return false, nil
+	}
+	wordmapJson, err := json.Marshal(variations)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, "UPDATE "+database.VerbalQuestionsTable+" SET "+database.VerbalQuestionsWordmapField+" = $1 WHERE "+
+		database.VerbalQuestionsIDField+" = $2", wordmapJson, id)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM "+database.VerbalQuestionWordsJoinTable+" WHERE "+database.VerbalQuestionWordJoinVerbalField+" = $1", id)
+	if err != nil {
+		return false, err
+	}
+	err = linkVocabulary(ctx, tx, database.VerbalQuestionWordsJoinTable, database.VerbalQuestionWordJoinVerbalField,
+		database.VerbalQuestionWordJoinWordField, id, vocabBaseForms)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+/**
+* Recomputes the vocabulary links and the wordmap of every question that
+* has not been deleted. A question that fails, for example because the
+* lemma of one of its words is not in the words table, is reported and
+* keeps its links.
+**/
+func (s *VerbalQuestionService) RelinkAll(ctx context.Context) (*models.MaintenanceReport, error) {
+	query := squirrel.Select(database.VerbalQuestionsIDField).
+		From(database.VerbalQuestionsTable).
+		Where(activeQuestion).
+		OrderBy(database.VerbalQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := queryIDs(ctx, s.DB, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.MaintenanceReport{Errors: make([]models.MaintenanceError, 0)}
+	for _, id := range ids {
+		report.Processed++
+		changed, err := s.Relink(ctx, id)
+		if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, models.MaintenanceError{Item: strconv.Itoa(id), Error: err.Error()})
+			continue
+		}
+		if changed {
+			report.Changed++
+		}
+	}
+	return report, nil
+}
+
+// Empty wordmap in place of a nil one, so that both compare equal
+func nonNilWordmap(wordmap map[string]string) map[string]string {
+	if wordmap == nil {
+		return map[string]string{}
+	}
+	return wordmap
+}
@@ -0,0 +1,638 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"grepandit.com/api/internal/autocomplete"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/qti"
+)
+
+// Returned when an import file can not be read at all
+var ErrInvalidImport = errors.New("invalid import")
+
+// Maximum number of suggestions given for an unknown vocabulary word
+const maxWordSuggestions = 3
+
+// Columns of the CSV format in the order they are exported
+var questionCSVColumns = []string{
+	"id", "type", "competence", "framed_as", "difficulty", "paragraph", "question",
+	"options", "vocabulary", "passage_id", "irt_a", "irt_b", "irt_c",
+}
+
+// A question read from an import file along with the error found reading it
+type importRow struct {
+	row      int
+	question *models.VerbalQuestionRequest
+	err      error
+}
+
+/**
+* Imports verbal questions from a JSON Lines or CSV file. Every row is
+* validated first and the rows with errors are reported, including the
+* vocabulary words that are not in the words table along with the closest
+* known words. Missing words are created without meanings when CreateWords
+* is set. See models.ImportOptions for how rows are committed.
+**/
+func (s *VerbalQuestionService) Import(
+	ctx context.Context,
+	r io.Reader,
+	opts models.ImportOptions,
+	editor models.User,
+) (*models.ImportReport, error) {
+	rows, err := decodeQuestions(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]importRow, 0, len(rows))
+	for _, row := range rows {
+		if row.row >= opts.StartRow {
+			pending = append(pending, row)
+		}
+	}
+	report := &models.ImportReport{
+		Rows:         len(pending),
+		QuestionIDs:  make([]int, 0),
+		CreatedWords: make([]string, 0),
+		Errors:       make([]models.ImportRowError, 0),
+	}
+	missingWords, err := s.validateImportRows(ctx, pending, opts, report)
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[int]bool, len(report.Errors))
+	for _, rowErr := range report.Errors {
+		failed[rowErr.Row] = true
+	}
+	report.Failed = len(failed)
+	if opts.DryRun {
+		return report, nil
+	}
+	// A single transaction is all or nothing
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		if report.Failed > 0 {
+			return report, nil
+		}
+		batchSize = len(pending)
+	}
+	for start := 0; start < len(pending); start += batchSize {
+		end := start + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := make([]importRow, 0, end-start)
+		for _, row := range pending[start:end] {
+			if !failed[row.row] {
+				batch = append(batch, row)
+			}
+		}
+		created, ids, err := s.importBatch(ctx, batch, missingWords, editor)
+		if err != nil {
+			report.NextRow = pending[start].row
+			report.Error = err.Error()
+			return report, err
+		}
+		report.Committed = true
+		report.CreatedWords = append(report.CreatedWords, created...)
+		report.QuestionIDs = append(report.QuestionIDs, ids...)
+		report.Imported += len(ids)
+	}
+	return report, nil
+}
+
+/**
+* Validates the rows of an import and records their errors in the report.
+* Returns, for each row, the vocabulary words that have to be created.
+**/
+func (s *VerbalQuestionService) validateImportRows(
+	ctx context.Context,
+	rows []importRow,
+	opts models.ImportOptions,
+	report *models.ImportReport,
+) (map[int][]string, error) {
+	baseForms := make(map[int][]string, len(rows))
+	allWords := make([]string, 0)
+	passageIDs := make([]int, 0)
+	for _, row := range rows {
+		if row.err == nil {
+			row.err = validateQuestionRequest(row.question)
+		}
+		if row.err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row.row, Message: row.err.Error()})
+			continue
+		}
+		vocabBaseForms, _ := vocabularyWordMap(s.Lemmatizer, row.question.Vocabulary)
+		baseForms[row.row] = baseFormList(vocabBaseForms)
+		allWords = append(allWords, baseForms[row.row]...)
+		if row.question.PassageID != nil {
+			passageIDs = append(passageIDs, *row.question.PassageID)
+		}
+	}
+	knownWords, err := s.existing(ctx, database.WordsTable, database.WordsWordField, allWords)
+	if err != nil {
+		return nil, err
+	}
+	knownPassages, err := s.existing(ctx, database.PassagesTable, database.PassagesIDField, passageIDs)
+	if err != nil {
+		return nil, err
+	}
+	var dictionary []string
+	missingWords := make(map[int][]string)
+	for _, row := range rows {
+		words, ok := baseForms[row.row]
+		if !ok {
+			continue
+		}
+		if id := row.question.PassageID; id != nil && !knownPassages[strconv.Itoa(*id)] {
+			report.Errors = append(report.Errors, models.ImportRowError{
+				Row:     row.row,
+				Message: fmt.Sprintf("%s: unknown passage %d", ErrInvalidQuestion.Error(), *id),
+			})
+			continue
+		}
+		for _, word := range words {
+			if !knownWords[word] {
+				missingWords[row.row] = append(missingWords[row.row], word)
+			}
+		}
+		if len(missingWords[row.row]) == 0 || opts.CreateWords {
+			continue
+		}
+		if dictionary == nil {
+			dictionary, err = s.allWords(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		rowErr := models.ImportRowError{Row: row.row, Message: ErrUnknownWord.Error()}
+		for _, word := range missingWords[row.row] {
+			rowErr.UnknownWords = append(rowErr.UnknownWords, models.UnknownWord{
+				Word:        word,
+				Suggestions: suggestWords(word, dictionary, maxWordSuggestions),
+			})
+		}
+		report.Errors = append(report.Errors, rowErr)
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	return missingWords, nil
+}
+
+/**
+* Creates the questions of a batch in a single transaction along with the
+* vocabulary words they need. The words created are added to the
+* autocomplete index once the batch is committed. Returns the words created
+* and the ids of the new questions.
+**/
+func (s *VerbalQuestionService) importBatch(
+	ctx context.Context,
+	batch []importRow,
+	missingWords map[int][]string,
+	editor models.User,
+) ([]string, []int, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+	created := make([]string, 0)
+	entries := make([]autocomplete.Entry, 0)
+	ids := make([]int, 0, len(batch))
+	for _, row := range batch {
+		for _, word := range missingWords[row.row] {
+			var wordID int
+			err := tx.QueryRow(ctx, `
+				INSERT INTO `+database.WordsTable+` (`+database.WordsWordField+`, `+database.WordsMeaningsField+`, `+database.WordsExamplesField+`)
+				VALUES ($1, '[]'::jsonb, '{}')
+				ON CONFLICT (`+database.WordsWordField+`) DO NOTHING
+				RETURNING `+database.WordsIDField, word).Scan(&wordID)
+			// No row is returned when the word exists
+			if err == pgx.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			created = append(created, word)
+			entries = append(entries, autocomplete.Entry{WordID: wordID, Word: word})
+		}
+		err = createQuestion(ctx, tx, s.Lemmatizer, row.question, editor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("row %d: %w", row.row, err)
+		}
+		ids = append(ids, row.question.ID)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	if s.Words != nil {
+		s.Words.IndexWords(entries...)
+	}
+	return created, ids, nil
+}
+
+/**
+* Returns which of the values exist in a column of a table. Values are
+* keyed by their string representation.
+**/
+func (s *VerbalQuestionService) existing(ctx context.Context, table string, field string, values interface{}) (map[string]bool, error) {
+	rows, err := s.DB.Query(ctx, "SELECT "+field+"::TEXT FROM "+table+" WHERE "+field+" = ANY($1)", values)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[string]bool)
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		found[value] = true
+	}
+	return found, rows.Err()
+}
+
+// Retrieves every word of the words table
+func (s *VerbalQuestionService) allWords(ctx context.Context) ([]string, error) {
+	rows, err := s.DB.Query(ctx, "SELECT "+database.WordsWordField+" FROM "+database.WordsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	words := make([]string, 0)
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
+
+/**
+* Writes every question that has not been deleted in the given format. The
+* output can be imported back as it is. Questions of a passage are exported
+* without the passage text, except in QTI packages which have no passages.
+**/
+func (s *VerbalQuestionService) Export(ctx context.Context, w io.Writer, format models.TransferFormat) error {
+	encode, flush, err := questionEncoder(w, format)
+	if err != nil {
+		return err
+	}
+	paragraph := "COALESCE(q." + database.VerbalQuestionsParagraphField + ", '')"
+	if format == models.FormatQTI {
+		paragraph = "COALESCE(NULLIF(q." + database.VerbalQuestionsParagraphField + ", ''), " +
+			passageColumn("q", database.PassagesTextField) + ", '')"
+	}
+	rows, err := s.DB.Query(ctx, `
+		SELECT q.`+database.VerbalQuestionsIDField+`, q.`+database.VerbalQuestionsCompetenceField+`,
+			q.`+database.VerbalQuestionsFramedAsField+`, q.`+database.VerbalQuestionsTypeField+`,
+			`+paragraph+`, q.`+database.VerbalQuestionsQuestionField+`,
+			q.`+database.VerbalQuestionsOptionsField+`, q.`+database.VerbalQuestionsDifficultyField+`,
+			q.`+database.VerbalQuestionsIRTAField+`, q.`+database.VerbalQuestionsIRTBField+`, q.`+database.VerbalQuestionsIRTCField+`,
+			q.`+database.VerbalQuestionsPassageField+`,
+			COALESCE((SELECT ARRAY_AGG(w.`+database.WordsWordField+` ORDER BY w.`+database.WordsWordField+`)
+				FROM `+database.WordsTable+` AS w
+				INNER JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON w.`+database.WordsIDField+` = vqw.`+database.VerbalQuestionWordJoinWordField+`
+				WHERE vqw.`+database.VerbalQuestionWordJoinVerbalField+` = q.`+database.VerbalQuestionsIDField+`), '{}')
+		FROM `+database.VerbalQuestionsTable+` AS q
+		WHERE q.`+database.VerbalQuestionsDeletedAtField+` IS NULL
+		ORDER BY q.`+database.VerbalQuestionsIDField)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var q models.VerbalQuestionRequest
+		var params models.IRTParams
+		var optionsJson []byte
+		err = rows.Scan(&q.ID, &q.Competence, &q.FramedAs, &q.Type, &q.Paragraph, &q.Question, &optionsJson,
+			&q.Difficulty, &params.A, &params.B, &params.C, &q.PassageID, &q.Vocabulary)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(optionsJson, &q.Options); err != nil {
+			return err
+		}
+		q.IRT = &params
+		if err := encode(&q); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// Reads the questions of an import file
+func decodeQuestions(r io.Reader, format models.TransferFormat) ([]importRow, error) {
+	switch format {
+	case models.FormatJSONL:
+		return decodeQuestionsJSONL(r)
+	case models.FormatCSV:
+		return decodeQuestionsCSV(r)
+	case models.FormatQTI:
+		return decodeQuestionsQTI(r)
+	}
+	return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, format)
+}
+
+// Reads one question per line. Rows are numbered by line and blank lines are skipped.
+func decodeQuestionsJSONL(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	rows := make([]importRow, 0)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		q := &models.VerbalQuestionRequest{}
+		err := json.Unmarshal([]byte(text), q)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrInvalidQuestion, err.Error())
+		}
+		rows = append(rows, importRow{row: line, question: q, err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+	}
+	return rows, nil
+}
+
+// Reads the items of a QTI content package in the order of its manifest
+func decodeQuestionsQTI(r io.Reader) ([]importRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := qti.ReadPackage(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+	}
+	rows := make([]importRow, len(entries))
+	for i, entry := range entries {
+		rows[i] = importRow{row: i + 1, question: entry.Question}
+		if entry.Err != nil {
+			rows[i].question = &models.VerbalQuestionRequest{}
+			rows[i].err = fmt.Errorf("%w: %s: %s", ErrInvalidQuestion, entry.Href, entry.Err.Error())
+		}
+	}
+	return rows, nil
+}
+
+/**
+* Reads questions from a CSV file with a header naming its columns. The
+* options column holds the options as JSON and the vocabulary column the
+* words separated by semicolons.
+**/
+func decodeQuestionsCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header: %s", ErrInvalidImport, err.Error())
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !containsString(questionCSVColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"type", "framed_as", "difficulty", "question", "options"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, required)
+		}
+	}
+	rows := make([]importRow, 0)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		q, err := parseQuestionRecord(cell)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrInvalidQuestion, err.Error())
+		}
+		rows = append(rows, importRow{row: row, question: q, err: err})
+	}
+	return rows, nil
+}
+
+// Builds a question from the cells of a CSV record
+func parseQuestionRecord(cell func(name string) string) (*models.VerbalQuestionRequest, error) {
+	q := &models.VerbalQuestionRequest{
+		Paragraph:  cell("paragraph"),
+		Question:   cell("question"),
+		Vocabulary: make([]string, 0),
+	}
+	// Enums are parsed the same way as in the JSON format
+	enums := []struct {
+		name string
+		dest json.Unmarshaler
+	}{
+		{"type", &q.Type},
+		{"competence", &q.Competence},
+		{"framed_as", &q.FramedAs},
+		{"difficulty", &q.Difficulty},
+	}
+	for _, enum := range enums {
+		if value := cell(enum.name); value != "" {
+			if err := enum.dest.UnmarshalJSON([]byte(strconv.Quote(value))); err != nil {
+				return q, fmt.Errorf("%s: %s", enum.name, err.Error())
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(cell("options")), &q.Options); err != nil {
+		return q, fmt.Errorf("options: %s", err.Error())
+	}
+	for _, word := range strings.Split(cell("vocabulary"), ";") {
+		if word = strings.TrimSpace(word); word != "" {
+			q.Vocabulary = append(q.Vocabulary, word)
+		}
+	}
+	if value := cell("passage_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return q, fmt.Errorf("passage_id: %s", err.Error())
+		}
+		q.PassageID = &id
+	}
+	irtCells := []string{cell("irt_a"), cell("irt_b"), cell("irt_c")}
+	if irtCells[0] != "" || irtCells[1] != "" || irtCells[2] != "" {
+		values := make([]float64, len(irtCells))
+		for i, value := range irtCells {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return q, fmt.Errorf("irt_a, irt_b and irt_c must be set together: %s", err.Error())
+			}
+			values[i] = parsed
+		}
+		q.IRT = &models.IRTParams{A: values[0], B: values[1], C: values[2]}
+	}
+	return q, nil
+}
+
+/**
+* Returns a function that writes a question in the given format and a
+* function that flushes the output once every question is written.
+**/
+func questionEncoder(w io.Writer, format models.TransferFormat) (func(q *models.VerbalQuestionRequest) error, func() error, error) {
+	switch format {
+	case models.FormatJSONL:
+		encoder := json.NewEncoder(w)
+		return func(q *models.VerbalQuestionRequest) error { return encoder.Encode(q) },
+			func() error { return nil }, nil
+	case models.FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(questionCSVColumns); err != nil {
+			return nil, nil, err
+		}
+		encode := func(q *models.VerbalQuestionRequest) error {
+			record, err := questionRecord(q)
+			if err != nil {
+				return err
+			}
+			return writer.Write(record)
+		}
+		flush := func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+		return encode, flush, nil
+	case models.FormatQTI:
+		writer := qti.NewWriter(w)
+		return writer.Add, writer.Close, nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImport, format)
+}
+
+// Cells of a question in the order of questionCSVColumns
+func questionRecord(q *models.VerbalQuestionRequest) ([]string, error) {
+	optionsJson, err := json.Marshal(q.Options)
+	if err != nil {
+		return nil, err
+	}
+	passageID := ""
+	if q.PassageID != nil {
+		passageID = strconv.Itoa(*q.PassageID)
+	}
+	competence := ""
+	if q.Competence != 0 {
+		competence = q.Competence.String()
+	}
+	irtCells := []string{"", "", ""}
+	if q.IRT != nil {
+		for i, value := range []float64{q.IRT.A, q.IRT.B, q.IRT.C} {
+			irtCells[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	return append([]string{
+		strconv.Itoa(q.ID),
+		q.Type.String(),
+		competence,
+		q.FramedAs.String(),
+		q.Difficulty.String(),
+		q.Paragraph,
+		q.Question,
+		string(optionsJson),
+		strings.Join(q.Vocabulary, ";"),
+		passageID,
+	}, irtCells...), nil
+}
+
+/**
+* Suggests the known words closest to an unknown word by edit distance.
+* Only words within a third of the length of the word are suggested.
+**/
+func suggestWords(word string, dictionary []string, limit int) []string {
+	type candidate struct {
+		word     string
+		distance int
+	}
+	maxDistance := len([]rune(word)) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	candidates := make([]candidate, 0)
+	for _, known := range dictionary {
+		if d := editDistance(word, known); d <= maxDistance {
+			candidates = append(candidates, candidate{known, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].word < candidates[j].word
+	})
+	suggestions := make([]string, 0, limit)
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, candidates[i].word)
+	}
+	return suggestions
+}
+
+// Levenshtein distance between two words
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func containsString(slice []string, val string) bool {
+	for _, item := range slice {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
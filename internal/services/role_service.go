@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+)
+
+var ErrInvalidRole = errors.New("invalid role")
+
+type RoleService struct {
+	DB *pgxpool.Pool
+}
+
+func NewRoleService(db *pgxpool.Pool) *RoleService {
+	return &RoleService{DB: db}
+}
+
+/**
+* Retrieves the roles of a user: learner, the roles named by the Cognito
+* groups of the user and the roles granted in the user roles table. Groups
+* that are not roles are ignored.
+**/
+func (s *RoleService) Roles(ctx context.Context, userToken string, groups []string) ([]models.Role, error) {
+	access, err := s.Access(ctx, userToken, groups)
+	if err != nil {
+		return nil, err
+	}
+	return access.Roles, nil
+}
+
+// Retrieves the roles of a user along with where they come from and the permissions they grant
+func (s *RoleService) Access(ctx context.Context, userToken string, groups []string) (*models.UserAccess, error) {
+	granted, err := s.GetGranted(ctx, userToken)
+	if err != nil {
+		return nil, err
+	}
+	access := &models.UserAccess{UserToken: userToken, Granted: granted}
+	roles := map[models.Role]bool{models.RoleLearner: true}
+	for _, group := range groups {
+		if role := models.Role(group); role.Valid() {
+			access.Groups = append(access.Groups, role)
+			roles[role] = true
+		}
+	}
+	for _, g := range granted {
+		roles[g.Role] = true
+	}
+	// Keep the order of the roles from the least to the most privileged
+	for _, role := range models.Roles {
+		if roles[role] {
+			access.Roles = append(access.Roles, role)
+		}
+	}
+	access.Permissions = models.Permissions(access.Roles)
+	return access, nil
+}
+
+// Retrieves the roles granted to a user in the user roles table
+func (s *RoleService) GetGranted(ctx context.Context, userToken string) ([]models.UserRole, error) {
+	query := squirrel.Select(
+		database.UserRolesUserField,
+		database.UserRolesRoleField,
+		database.UserRolesGrantedByField,
+		database.UserRolesGrantedAtField,
+	).
+		From(database.UserRolesTable).
+		Where(squirrel.Eq{database.UserRolesUserField: userToken}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	granted := make([]models.UserRole, 0)
+	for rows.Next() {
+		var r models.UserRole
+		if err := rows.Scan(&r.UserToken, &r.Role, &r.GrantedBy, &r.GrantedAt); err != nil {
+			return nil, err
+		}
+		// Ignore the roles that are no longer defined
+		if r.Role.Valid() {
+			granted = append(granted, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(granted, func(i, j int) bool { return granted[i].Role < granted[j].Role })
+	return granted, nil
+}
+
+/**
+* Grants a role to a user. Every user is a learner, so the learner role
+* cannot be granted. Granting a role the user already has keeps the
+* original grant.
+**/
+func (s *RoleService) Grant(ctx context.Context, userToken string, role models.Role, grantedBy string) (*models.UserRole, error) {
+	if !role.Valid() || role == models.RoleLearner {
+		return nil, fmt.Errorf("%w: %q cannot be granted", ErrInvalidRole, role)
+	}
+	if userToken == "" {
+		return nil, fmt.Errorf("%w: no user token", ErrInvalidRole)
+	}
+	query := `
+		INSERT INTO ` + database.UserRolesTable + ` (` +
+		database.UserRolesUserField + `, ` +
+		database.UserRolesRoleField + `, ` +
+		database.UserRolesGrantedByField + `, ` +
+		database.UserRolesGrantedAtField + `)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (` + database.UserRolesUserField + `, ` + database.UserRolesRoleField + `) DO UPDATE SET ` +
+		database.UserRolesRoleField + ` = EXCLUDED.` + database.UserRolesRoleField + `
+		RETURNING ` + database.UserRolesGrantedByField + `, ` + database.UserRolesGrantedAtField
+	r := &models.UserRole{UserToken: userToken, Role: role}
+	err := s.DB.QueryRow(ctx, query, userToken, role, grantedBy, time.Now()).Scan(&r.GrantedBy, &r.GrantedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/**
+* Revokes a role granted to a user. Roles that come from Cognito groups are
+* managed in Cognito. Admins cannot revoke their own admin role, so that
+* there is always an admin left to grant it back.
+**/
+func (s *RoleService) Revoke(ctx context.Context, userToken string, role models.Role, revokedBy string) error {
+	if role == models.RoleAdmin && userToken == revokedBy {
+		return fmt.Errorf("%w: admins cannot revoke their own admin role", ErrInvalidRole)
+	}
+	tag, err := s.DB.Exec(ctx, `
+		DELETE FROM `+database.UserRolesTable+`
+		WHERE `+database.UserRolesUserField+` = $1 AND `+database.UserRolesRoleField+` = $2`, userToken, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return echo.ErrNotFound
+	}
+	return nil
+}
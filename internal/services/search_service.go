@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// Options of the snippets with the matched terms wrapped in <mark> tags
+	searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=25, MinWords=8"
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+type SearchService struct {
+	DB *pgxpool.Pool
+}
+
+func NewSearchService(db *pgxpool.Pool) *SearchService {
+	return &SearchService{DB: db}
+}
+
+/**
+* Searches verbal questions and words with PostgreSQL full-text search.
+* The query accepts the web search syntax: quoted phrases, "or" and "-"
+* to exclude terms. Words are also matched by trigram similarity so that
+* misspelled words are found. Results are ranked and paginated separately
+* for questions and words.
+**/
+func (s *SearchService) Search(ctx context.Context, req *models.SearchRequest) (*models.SearchResults, error) {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidSearch)
+	}
+	switch req.Scope {
+	case "":
+		req.Scope = models.SearchAll
+	case models.SearchAll, models.SearchQuestions, models.SearchWords:
+	default:
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidSearch, req.Scope)
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+	if req.Page < 1 || req.Limit < 1 || req.Limit > maxSearchLimit {
+		return nil, fmt.Errorf("%w: page must be positive and limit between 1 and %d", ErrInvalidSearch, maxSearchLimit)
+	}
+	results := &models.SearchResults{
+		Query:     req.Query,
+		Page:      req.Page,
+		Limit:     req.Limit,
+		Questions: make([]models.QuestionHit, 0),
+		Words:     make([]models.WordHit, 0),
+	}
+	var err error
+	if req.Scope != models.SearchWords {
+		results.Questions, results.QuestionsTotal, err = s.searchQuestions(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.Scope != models.SearchQuestions {
+		results.Words, results.WordsTotal, err = s.searchWords(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Searches the paragraph, question and options of the questions that are not deleted
+func (s *SearchService) searchQuestions(ctx context.Context, req *models.SearchRequest) ([]models.QuestionHit, int, error) {
+	from := func(query squirrel.SelectBuilder) squirrel.SelectBuilder {
+		query = query.
+			From(database.VerbalQuestionsTable+" AS vq").
+			LeftJoin(database.PassagesTable+" AS ps ON ps."+database.PassagesIDField+" = vq."+database.VerbalQuestionsPassageField).
+			JoinClause("CROSS JOIN websearch_to_tsquery('"+database.SearchConfig+"', ?) AS query", req.Query).
+			Where("vq." + database.VerbalQuestionsDeletedAtField + " IS NULL").
+			Where("(vq." + database.VerbalQuestionsSearchField + " @@ query OR ps." + database.PassagesSearchField + " @@ query)")
+		if req.Type != 0 {
+			query = query.Where(squirrel.Eq{"vq." + database.VerbalQuestionsTypeField: req.Type})
+		}
+		if req.Competence != 0 {
+			query = query.Where(squirrel.Eq{"vq." + database.VerbalQuestionsCompetenceField: req.Competence})
+		}
+		if req.Difficulty != 0 {
+			query = query.Where(squirrel.Eq{"vq." + database.VerbalQuestionsDifficultyField: req.Difficulty})
+		}
+		return query.PlaceholderFormat(squirrel.Dollar)
+	}
+	total, err := s.count(ctx, from(squirrel.Select("COUNT(*)")))
+	if err != nil || total == 0 {
+		return make([]models.QuestionHit, 0), total, err
+	}
+	document := "COALESCE(NULLIF(vq." + database.VerbalQuestionsParagraphField + ", ''), ps." +
+		database.PassagesTextField + ", '') || ' ' || COALESCE(vq." + database.VerbalQuestionsQuestionField + ", '')"
+	query := from(squirrel.Select(verbalQuestionColumns("vq")...)).
+		Column("ts_rank(vq."+database.VerbalQuestionsSearchField+", query) + COALESCE(ts_rank(ps."+
+			database.PassagesSearchField+", query), 0) AS rank").
+		Column("ts_headline('"+database.SearchConfig+"', "+document+", query, '"+searchHeadlineOptions+"')").
+		OrderBy("rank DESC", "vq."+database.VerbalQuestionsIDField).
+		Limit(uint64(req.Limit)).
+		Offset(uint64((req.Page - 1) * req.Limit))
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	hits := make([]models.QuestionHit, 0)
+	ids := make([]int, 0)
+	for rows.Next() {
+		var hit models.QuestionHit
+		if err := scanVerbalQuestion(rows, &hit.Question, &hit.Rank, &hit.Snippet); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, hit)
+		ids = append(ids, hit.Question.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	vocabulary, err := questionVocabulary(ctx, s.DB, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range hits {
+		hits[i].Question.Vocabulary = vocabulary[hits[i].Question.ID]
+		if hits[i].Question.Vocabulary == nil {
+			hits[i].Question.Vocabulary = make([]models.Word, 0)
+		}
+	}
+	return hits, total, nil
+}
+
+/**
+* Searches the words, their meanings and examples. Words that are similar
+* to the query are included after the full-text matches as fuzzy matches.
+**/
+func (s *SearchService) searchWords(ctx context.Context, req *models.SearchRequest) ([]models.WordHit, int, error) {
+	term := strings.ToLower(req.Query)
+	from := func(query squirrel.SelectBuilder) squirrel.SelectBuilder {
+		return query.
+			From(database.WordsTable+" AS w").
+			JoinClause("CROSS JOIN websearch_to_tsquery('"+database.SearchConfig+"', ?) AS query", req.Query).
+			Where("(w."+database.WordsSearchField+" @@ query OR w."+database.WordsWordField+" % ?)", term).
+			PlaceholderFormat(squirrel.Dollar)
+	}
+	total, err := s.count(ctx, from(squirrel.Select("COUNT(*)")))
+	if err != nil || total == 0 {
+		return make([]models.WordHit, 0), total, err
+	}
+	document := "COALESCE((SELECT string_agg(m->>'meaning', '; ') FROM jsonb_array_elements(COALESCE(w." +
+		database.WordsMeaningsField + ", '[]')) AS m), '') || ' ' || COALESCE(immutable_array_to_string(w." +
+		database.WordsExamplesField + ", ' '), '')"
+	query := from(squirrel.Select(wordColumns("w")...)).
+		Column("ts_rank(w."+database.WordsSearchField+", query) AS rank").
+		Column(squirrel.Expr("similarity(w."+database.WordsWordField+", ?) AS similarity", term)).
+		Column("NOT (w."+database.WordsSearchField+" @@ query) AS fuzzy").
+		Column("ts_headline('"+database.SearchConfig+"', "+document+", query, '"+searchHeadlineOptions+"')").
+		OrderBy("fuzzy", "rank DESC", "similarity DESC", "w."+database.WordsWordField).
+		Limit(uint64(req.Limit)).
+		Offset(uint64((req.Page - 1) * req.Limit))
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	hits := make([]models.WordHit, 0)
+	for rows.Next() {
+		var hit models.WordHit
+		fields := append(wordFields(&hit.Word), &hit.Rank, &hit.Similarity, &hit.Fuzzy, &hit.Snippet)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, total, rows.Err()
+}
+
+func (s *SearchService) count(ctx context.Context, query squirrel.SelectBuilder) (int, error) {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	err = s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,745 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+// Builds the repositories of every aggregate on top of the PostgreSQL pool
+func NewPostgresStore(db *pgxpool.Pool) *repository.Store {
+	store := newPostgresStore(db)
+	store.RunInTx = func(ctx context.Context, fn func(tx *repository.Store) error) error {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		// Rollback in case of error. This is a no-op if the transaction has been committed.
+		defer tx.Rollback(ctx)
+		if err := fn(newPostgresStore(tx)); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	return store
+}
+
+// Builds the repositories on top of the pool or of a transaction
+func newPostgresStore(q querier) *repository.Store {
+	return &repository.Store{
+		Questions: &postgresQuestions{DB: q},
+		Words:     &postgresWords{DB: q},
+		Users:     &postgresUsers{DB: q},
+		Stats:     &postgresStats{DB: q},
+		Marks:     &postgresMarks{DB: q},
+		Sessions:  &postgresSessions{DB: q},
+	}
+}
+
+type postgresQuestions struct {
+	DB querier
+}
+
+/**
+* Retrieve verbal question by its ID. A join operation is done using the join table
+* to get the list of words associated with this table.
+**/
+func (r *postgresQuestions) GetByID(ctx context.Context, id int) (*models.VerbalQuestion, error) {
+	q := &models.VerbalQuestion{}
+	query := squirrel.Select(verbalQuestionColumns("")...).
+		From(database.VerbalQuestionsTable).
+		Where(squirrel.Eq{database.VerbalQuestionsIDField: id}).
+		Where(activeQuestion).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	err = scanVerbalQuestion(r.DB.QueryRow(ctx, sqlQuery, args...), q)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	// Now get the vocabulary words, including those of the passage.
+	vocabulary, err := r.GetVocabulary(ctx, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	q.Vocabulary = vocabulary[id]
+	if q.Vocabulary == nil {
+		q.Vocabulary = make([]models.Word, 0)
+	}
+	return q, nil
+}
+
+func (r *postgresQuestions) GetByIDs(ctx context.Context, ids []int) ([]*models.VerbalQuestion, error) {
+	questions := make([]*models.VerbalQuestion, 0)
+	query := squirrel.Select(verbalQuestionColumns("")...).
+		From(database.VerbalQuestionsTable).
+		Where(squirrel.Eq{database.VerbalQuestionsIDField: ids}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		q := &models.VerbalQuestion{}
+		err = scanVerbalQuestion(rows, q)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Now get the vocabulary words, including those of the passages.
+	vocabulary, err := r.GetVocabulary(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		q.Vocabulary = vocabulary[q.ID]
+		if q.Vocabulary == nil {
+			q.Vocabulary = make([]models.Word, 0)
+		}
+	}
+	return questions, nil
+}
+
+func (r *postgresQuestions) GetClosestToAbility(ctx context.Context, qType models.QuestionType,
+	competence models.Competence, theta float64, limit int, excludeIDs []int) ([]*models.VerbalQuestion, error) {
+	query := squirrel.Select(verbalQuestionColumns("")...).
+		From(database.VerbalQuestionsTable).
+		Where(squirrel.Eq{database.VerbalQuestionsTypeField: qType}).
+		Where(activeQuestion).
+		OrderByClause("ABS("+database.VerbalQuestionsIRTBField+" - ?)", theta).
+		Limit(uint64(limit)).
+		PlaceholderFormat(squirrel.Dollar)
+	if competence != 0 {
+		query = query.Where(squirrel.Eq{database.VerbalQuestionsCompetenceField: competence})
+	}
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.VerbalQuestionsIDField: excludeIDs})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	questions := make([]*models.VerbalQuestion, 0, limit)
+	for rows.Next() {
+		q := &models.VerbalQuestion{}
+		err = scanVerbalQuestion(rows, q)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (r *postgresQuestions) GetVocabulary(ctx context.Context, questionIDs []int) (map[int][]models.Word, error) {
+	return questionVocabulary(ctx, r.DB, questionIDs)
+}
+
+func (r *postgresQuestions) GetRandom(ctx context.Context, qType models.QuestionType, competence models.Competence,
+	difficulty models.Difficulty, excludeIDs []int) (*models.VerbalQuestion, error) {
+	query := squirrel.Select(verbalQuestionColumns("")...).
+		From(database.VerbalQuestionsTable).
+		Where(activeQuestion).
+		OrderBy("RANDOM()").
+		Limit(1).
+		PlaceholderFormat(squirrel.Dollar)
+	if qType != 0 {
+		query = query.Where(squirrel.Eq{database.VerbalQuestionsTypeField: qType})
+	}
+	if competence != 0 {
+		query = query.Where(squirrel.Eq{database.VerbalQuestionsCompetenceField: competence})
+	}
+	if difficulty != 0 {
+		query = query.Where(squirrel.Eq{database.VerbalQuestionsDifficultyField: difficulty})
+	}
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.VerbalQuestionsIDField: excludeIDs})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	q := &models.VerbalQuestion{}
+	err = scanVerbalQuestion(r.DB.QueryRow(ctx, sqlQuery, args...), q)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return q, nil
+}
+
+func (r *postgresQuestions) GetPassageQuestionIDs(ctx context.Context, passageID int, excludeIDs []int) ([]int, error) {
+	query := squirrel.Select(database.VerbalQuestionsIDField).
+		From(database.VerbalQuestionsTable).
+		Where(squirrel.Eq{database.VerbalQuestionsPassageField: passageID}).
+		Where(activeQuestion).
+		OrderBy(database.VerbalQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{database.VerbalQuestionsIDField: excludeIDs})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return queryIDs(ctx, r.DB, sqlQuery, args...)
+}
+
+type postgresWords struct {
+	DB querier
+}
+
+func (r *postgresWords) GetByIDs(ctx context.Context, ids []int) ([]models.Word, error) {
+	query := squirrel.Select(wordColumns("w")...).
+		From(database.WordsTable + " AS w").
+		Where(squirrel.Eq{"w." + database.WordsIDField: ids}).
+		OrderBy("w." + database.WordsWordField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	words := make([]models.Word, 0)
+	for rows.Next() {
+		var w models.Word
+		err := rows.Scan(wordFields(&w)...)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
+
+func (r *postgresWords) GetQuestionWordIDs(ctx context.Context, questionIDs []int) ([]int, error) {
+	query := squirrel.Select("DISTINCT vw." + database.VerbalQuestionWordJoinWordField).
+		From(database.VerbalQuestionWordsJoinTable + " AS vw").
+		Where(squirrel.Eq{"vw." + database.VerbalQuestionWordJoinVerbalField: questionIDs}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return queryIDs(ctx, r.DB, sqlQuery, args...)
+}
+
+func (r *postgresWords) GetMissedInQuizzes(ctx context.Context, userToken string) ([]int, error) {
+	query := `
+		SELECT ` + database.VocabQuizResultsWordField + ` FROM (
+			SELECT DISTINCT ON (` + database.VocabQuizResultsWordField + `) ` +
+		database.VocabQuizResultsWordField + `, ` + database.VocabQuizResultsCorrectField + `
+			FROM ` + database.VocabQuizResultsTable + `
+			WHERE ` + database.VocabQuizResultsUserField + ` = $1
+			ORDER BY ` + database.VocabQuizResultsWordField + `, ` + database.VocabQuizResultsAnsweredAtField + ` DESC
+		) AS latest
+		WHERE NOT ` + database.VocabQuizResultsCorrectField
+	return queryIDs(ctx, r.DB, query, userToken)
+}
+
+type postgresUsers struct {
+	DB querier
+}
+
+func (r *postgresUsers) Create(ctx context.Context, u *models.User) error {
+	queryInsert := `
+		INSERT INTO ` + database.UsersTable + ` (` +
+		database.UserTokenField + `, ` +
+		database.UserEmailField + `, ` +
+		database.UserVerbalAbilityField + `)
+		VALUES ($1, $2, $3)
+		RETURNING ` + database.UserIDField
+	err := r.DB.QueryRow(ctx, queryInsert, u.Token, u.Email, nil).Scan(&u.ID)
+	if err != nil {
+		// Check if it is a unique constraint violation error
+		if pgErr, ok := err.(*pq.Error); ok {
+			if pgErr.Code.Name() == "unique_violation" {
+				return fmt.Errorf("User already exists")
+			}
+		}
+		// Return other database-related errors as is
+		return err
+	}
+	return nil
+}
+
+func (r *postgresUsers) Update(ctx context.Context, u *models.User) error {
+	queryUpdate := `
+		UPDATE ` + database.UsersTable + `
+		SET ` +
+		database.UserTokenField + ` = $1, ` +
+		database.UserEmailField + ` = $2, ` +
+		database.UserVerbalAbilityField + ` = $3, ` +
+		database.UserQuantAbilityField + ` = $4
+		WHERE ` + database.UserIDField + ` = $5
+		RETURNING ` + database.UserIDField
+	err := r.DB.QueryRow(ctx, queryUpdate, u.Token, u.Email, u.VerbalAbility, u.QuantAbility, u.ID).Scan(&u.ID)
+	if err != nil {
+		// Return other database-related errors as is
+		return err
+	}
+	return nil
+}
+
+func (r *postgresUsers) Get(ctx context.Context, userToken string) (*models.User, error) {
+	return r.get(ctx, userToken, "")
+}
+
+func (r *postgresUsers) GetForUpdate(ctx context.Context, userToken string) (*models.User, error) {
+	return r.get(ctx, userToken, " FOR UPDATE")
+}
+
+func (r *postgresUsers) get(ctx context.Context, userToken string, lock string) (*models.User, error) {
+	u := &models.User{}
+	query := `
+		SELECT ` +
+		database.UserIDField + `, ` +
+		database.UserTokenField + `, ` +
+		database.UserEmailField + `, ` +
+		database.UserVerbalAbilityField + `, ` +
+		database.UserQuantAbilityField + `
+		FROM ` + database.UsersTable + `
+		WHERE ` + database.UserTokenField + ` = $1` + lock
+
+	err := r.DB.QueryRow(ctx, query, userToken).Scan(&u.ID, &u.Token, &u.Email, &u.VerbalAbility, &u.QuantAbility)
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *postgresUsers) GetTokens(ctx context.Context) ([]string, error) {
+	rows, err := r.DB.Query(ctx, "SELECT "+database.UserTokenField+" FROM "+database.UsersTable+" ORDER BY "+database.UserIDField)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := make([]string, 0)
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *postgresUsers) GetAbilities(ctx context.Context, userToken string) ([]models.UserAbility, error) {
+	query := squirrel.Select(
+		database.UserAbilitiesDimensionField,
+		database.UserAbilitiesCategoryField,
+		database.UserAbilitiesThetaField,
+		database.UserAbilitiesSEField,
+		database.UserAbilitiesResponsesField,
+		database.UserAbilitiesUpdatedAtField,
+	).
+		From(database.UserAbilitiesTable).
+		Where(squirrel.Eq{database.UserAbilitiesUserField: userToken}).
+		OrderBy(database.UserAbilitiesDimensionField, database.UserAbilitiesCategoryField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	abilities := make([]models.UserAbility, 0)
+	for rows.Next() {
+		var a models.UserAbility
+		err := rows.Scan(&a.Dimension, &a.Category, &a.Theta, &a.StandardError, &a.Responses, &a.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		abilities = append(abilities, a)
+	}
+	return abilities, rows.Err()
+}
+
+func (r *postgresUsers) SaveAbility(ctx context.Context, userToken string, a *models.UserAbility) error {
+	query := `
+		INSERT INTO ` + database.UserAbilitiesTable + ` (` +
+		database.UserAbilitiesUserField + `, ` +
+		database.UserAbilitiesDimensionField + `, ` +
+		database.UserAbilitiesCategoryField + `, ` +
+		database.UserAbilitiesThetaField + `, ` +
+		database.UserAbilitiesSEField + `, ` +
+		database.UserAbilitiesResponsesField + `, ` +
+		database.UserAbilitiesUpdatedAtField + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (` + database.UserAbilitiesUserField + `, ` +
+		database.UserAbilitiesDimensionField + `, ` +
+		database.UserAbilitiesCategoryField + `) DO UPDATE SET ` +
+		database.UserAbilitiesThetaField + ` = EXCLUDED.` + database.UserAbilitiesThetaField + `, ` +
+		database.UserAbilitiesSEField + ` = EXCLUDED.` + database.UserAbilitiesSEField + `, ` +
+		database.UserAbilitiesResponsesField + ` = EXCLUDED.` + database.UserAbilitiesResponsesField + `, ` +
+		database.UserAbilitiesUpdatedAtField + ` = EXCLUDED.` + database.UserAbilitiesUpdatedAtField
+	_, err := r.DB.Exec(ctx, query, userToken, a.Dimension, a.Category, a.Theta, a.StandardError, a.Responses, a.UpdatedAt)
+	return err
+}
+
+type postgresStats struct {
+	DB querier
+}
+
+func (r *postgresStats) Create(ctx context.Context, stat *models.UserVerbalStat) error {
+	query := `
+		INSERT INTO ` + database.VerbalStatsTable + ` (` +
+		database.VerbalStatsUserField + `, ` +
+		database.VerbalStatsQuestionField + `, ` +
+		database.VerbalStatsCorrectField + `, ` +
+		database.VerbalStatsAnswersField + `, ` +
+		database.VerbalStatsDurationField + `, ` +
+		database.VerbalStatsDateField + `, ` +
+		database.VerbalStatsSessionField + `, ` +
+		database.VerbalStatsRevisionField + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + database.VerbalStatsIDField
+	return r.DB.QueryRow(ctx, query, stat.UserToken, stat.QuestionID, stat.Correct, stat.Answers, stat.Duration,
+		stat.Date, stat.SessionID, stat.Revision).Scan(&stat.ID)
+}
+
+func (r *postgresStats) GetByUser(ctx context.Context, userToken string) ([]models.UserVerbalStat, error) {
+	return r.list(ctx, squirrel.Eq{database.VerbalStatsUserField: userToken})
+}
+
+func (r *postgresStats) GetBySession(ctx context.Context, userToken string, sessionID int) ([]models.UserVerbalStat, error) {
+	return r.list(ctx, squirrel.Eq{
+		database.VerbalStatsUserField:    userToken,
+		database.VerbalStatsSessionField: sessionID,
+	})
+}
+
+func (r *postgresStats) list(ctx context.Context, where squirrel.Sqlizer) ([]models.UserVerbalStat, error) {
+	query := squirrel.Select(
+		database.VerbalStatsIDField,
+		database.VerbalStatsUserField,
+		database.VerbalStatsQuestionField,
+		database.VerbalStatsCorrectField,
+		database.VerbalStatsAnswersField,
+		database.VerbalStatsDurationField,
+		database.VerbalStatsDateField,
+		database.VerbalStatsSessionField,
+		database.VerbalStatsRevisionField,
+	).
+		From(database.VerbalStatsTable).
+		Where(where).
+		OrderBy(database.VerbalStatsDateField, database.VerbalStatsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := make([]models.UserVerbalStat, 0)
+	for rows.Next() {
+		var stat models.UserVerbalStat
+		err := rows.Scan(&stat.ID, &stat.UserToken, &stat.QuestionID, &stat.Correct, &stat.Answers, &stat.Duration,
+			&stat.Date, &stat.SessionID, &stat.Revision)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+func (r *postgresStats) GetIncorrectQuestionIDs(ctx context.Context, userToken string) ([]int, error) {
+	query := squirrel.Select("DISTINCT vs."+database.VerbalStatsQuestionField).
+		From(database.VerbalStatsTable+" AS vs").
+		Where(
+			squirrel.Eq{"vs." + database.VerbalStatsUserField: userToken},
+			squirrel.Eq{"vs." + database.VerbalStatsCorrectField: false},
+		).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return queryIDs(ctx, r.DB, sqlQuery, args...)
+}
+
+type postgresMarks struct {
+	DB querier
+}
+
+func (r *postgresMarks) AddWords(ctx context.Context, userToken string, wordIDs []int) error {
+	// Create slice of user tokens for batch insert.
+	userTokens := make([]string, len(wordIDs))
+	for i := range userTokens {
+		userTokens[i] = userToken
+	}
+	query := `
+		INSERT INTO ` + database.UserMarkedWordsTable + ` (` +
+		database.UserMarkedWordsUserField + `, ` +
+		database.UserMarkedWordsWordField + `)
+		SELECT * FROM UNNEST($1::TEXT[], $2::INT[])
+		ON CONFLICT (` + database.UserMarkedWordsUserField + `, ` +
+		database.UserMarkedWordsWordField + `) DO NOTHING`
+	_, err := r.DB.Exec(ctx, query, userTokens, wordIDs)
+	return err
+}
+
+func (r *postgresMarks) RemoveWords(ctx context.Context, userToken string, wordIDs []int) error {
+	array := pq.Array(wordIDs)
+	query := `
+		DELETE FROM ` + database.UserMarkedWordsTable + `
+		WHERE ` + database.UserMarkedWordsUserField + ` = $1
+		AND ` + database.UserMarkedWordsWordField + ` = ANY($2)`
+	_, err := r.DB.Exec(ctx, query, userToken, array)
+	return err
+}
+
+func (r *postgresMarks) GetWords(ctx context.Context, userToken string) ([]models.UserMarkedWord, error) {
+	columns := append([]string{
+		"u." + database.UserMarkedWordsIDField,
+		"u." + database.UserMarkedWordsUserField,
+		"u." + database.UserMarkedWordsWordField,
+	}, wordColumns("w")...)
+	query := squirrel.
+		Select(columns...).
+		From(database.UserMarkedWordsTable + " AS u").
+		Join(database.WordsTable + " AS w ON u.word_id = w.id").
+		Where(squirrel.Eq{"u.user_token": userToken}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var markedWords []models.UserMarkedWord
+	for rows.Next() {
+		var markedWord models.UserMarkedWord
+		fields := append([]interface{}{
+			&markedWord.ID,
+			&markedWord.UserToken,
+			&markedWord.WordID,
+		}, wordFields(&markedWord.Word)...)
+		err := rows.Scan(fields...)
+		if err != nil {
+			return nil, err
+		}
+		markedWords = append(markedWords, markedWord)
+	}
+	return markedWords, nil
+}
+
+func (r *postgresMarks) AddQuestions(ctx context.Context, userToken string, questionIDs []int) error {
+	// Create slice of user tokens for batch insert.
+	userTokens := make([]string, len(questionIDs))
+	for i := range userTokens {
+		userTokens[i] = userToken
+	}
+	query := `
+		INSERT INTO ` + database.UserMarkedVerbalQuestionsTable + ` (` +
+		database.UserMarkedVerbalQuestionsUserField + `, ` +
+		database.UserMarkedVerbalQuestionsQuestionField + `)
+		SELECT * FROM UNNEST($1::TEXT[], $2::INT[])
+		ON CONFLICT (` + database.UserMarkedVerbalQuestionsUserField + `, ` +
+		database.UserMarkedVerbalQuestionsQuestionField + `) DO NOTHING`
+	_, err := r.DB.Exec(ctx, query, userTokens, questionIDs)
+	return err
+}
+
+func (r *postgresMarks) RemoveQuestions(ctx context.Context, userToken string, questionIDs []int) error {
+	array := pq.Array(questionIDs)
+	query := `
+		DELETE FROM ` + database.UserMarkedVerbalQuestionsTable + `
+		WHERE ` + database.UserMarkedVerbalQuestionsUserField + ` = $1
+		AND ` + database.UserMarkedVerbalQuestionsQuestionField + ` = ANY($2)`
+	_, err := r.DB.Exec(ctx, query, userToken, array)
+	return err
+}
+
+func (r *postgresMarks) GetQuestions(ctx context.Context, userToken string) ([]models.UserMarkedVerbalQuestion, error) {
+	query := `
+		SELECT * FROM ` + database.UserMarkedVerbalQuestionsTable + `
+		WHERE ` + database.UserMarkedVerbalQuestionsUserField + ` = $1`
+	rows, err := r.DB.Query(ctx, query, userToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	markedQuestions := make([]models.UserMarkedVerbalQuestion, 0)
+	for rows.Next() {
+		var markedQuestion models.UserMarkedVerbalQuestion
+		err := rows.Scan(&markedQuestion.ID, &markedQuestion.UserToken, &markedQuestion.VerbalQuestionID)
+		if err != nil {
+			return nil, err
+		}
+		markedQuestions = append(markedQuestions, markedQuestion)
+	}
+	return markedQuestions, nil
+}
+
+type postgresSessions struct {
+	DB querier
+}
+
+func (r *postgresSessions) Create(ctx context.Context, session *models.PracticeSession) error {
+	criteriaJson, err := json.Marshal(session.Criteria)
+	if err != nil {
+		return err
+	}
+	query := squirrel.Insert(database.PracticeSessionsTable).
+		Columns(
+			database.PracticeSessionsUserField,
+			database.PracticeSessionsModeField,
+			database.PracticeSessionsCriteriaField,
+			database.PracticeSessionsStatusField,
+			database.PracticeSessionsQuestionsField,
+			database.PracticeSessionsStartedAtField,
+			database.PracticeSessionsLastResumedAtField).
+		Values(
+			session.UserToken,
+			session.Mode,
+			criteriaJson,
+			string(session.Status),
+			session.QuestionIDs,
+			session.StartedAt,
+			session.LastResumedAt).
+		Suffix("RETURNING " + database.PracticeSessionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	return r.DB.QueryRow(ctx, sqlQuery, args...).Scan(&session.ID)
+}
+
+func (r *postgresSessions) GetForUpdate(ctx context.Context, userToken string, id int) (*models.PracticeSession, error) {
+	query := squirrel.Select(practiceSessionColumns()...).
+		From(database.PracticeSessionsTable).
+		Where(squirrel.Eq{
+			database.PracticeSessionsIDField:   id,
+			database.PracticeSessionsUserField: userToken,
+		}).
+		// Verbal stats referencing the session are inserted while the lock is
+		// held, so the lock must not conflict with their foreign key check
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	session := &models.PracticeSession{}
+	err = scanPracticeSession(r.DB.QueryRow(ctx, sqlQuery, args...), session)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *postgresSessions) GetByUser(ctx context.Context, userToken string) ([]models.PracticeSession, error) {
+	query := squirrel.Select(practiceSessionColumns()...).
+		From(database.PracticeSessionsTable).
+		Where(squirrel.Eq{database.PracticeSessionsUserField: userToken}).
+		OrderBy(database.PracticeSessionsStartedAtField+" DESC", database.PracticeSessionsIDField+" DESC").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make([]models.PracticeSession, 0)
+	for rows.Next() {
+		var session models.PracticeSession
+		if err := scanPracticeSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *postgresSessions) Update(ctx context.Context, session *models.PracticeSession) error {
+	query := squirrel.Update(database.PracticeSessionsTable).
+		Set(database.PracticeSessionsStatusField, string(session.Status)).
+		Set(database.PracticeSessionsQuestionsField, session.QuestionIDs).
+		Set(database.PracticeSessionsAnsweredField, session.Answered).
+		Set(database.PracticeSessionsCorrectField, session.Correct).
+		Set(database.PracticeSessionsElapsedField, session.Elapsed).
+		Set(database.PracticeSessionsLastResumedAtField, session.LastResumedAt).
+		Set(database.PracticeSessionsFinishedAtField, session.FinishedAt).
+		Where(squirrel.Eq{database.PracticeSessionsIDField: session.ID}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Exec(ctx, sqlQuery, args...)
+	return err
+}
+
+// Runs a query whose single column is an id
+func queryIDs(ctx context.Context, db querier, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
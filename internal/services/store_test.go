@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
+)
+
+func memoryStore(t *testing.T) (*repository.Memory, *repository.Store) {
+	m := repository.NewMemory()
+	for _, w := range []models.Word{{ID: 1, Word: "terse"}, {ID: 2, Word: "abate"}, {ID: 3, Word: "laconic"}} {
+		m.AddWord(w)
+	}
+	options := []models.Option{{Value: "terse", Correct: true}, {Value: "verbose"}}
+	m.AddQuestion(models.VerbalQuestion{ID: 1, Type: models.TextCompletion, FramedAs: models.MCQSingleAnswer,
+		Competence: models.ReasoningFromIncompleteData, Options: options, IRT: models.IRTParams{A: 1, B: -2}}, 1)
+	m.AddQuestion(models.VerbalQuestion{ID: 2, Type: models.TextCompletion, FramedAs: models.MCQSingleAnswer,
+		Competence: models.ReasoningFromIncompleteData, Options: options, IRT: models.IRTParams{A: 1.5, B: 0.1}}, 2)
+	m.AddQuestion(models.VerbalQuestion{ID: 3, Type: models.ReadingComprehension, FramedAs: models.MCQSingleAnswer,
+		Options: options, IRT: models.IRTParams{A: 1, B: 0}}, 3)
+	store := m.Store()
+	if err := store.Users.Create(context.Background(), &models.User{Token: "u1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return m, store
+}
+
+func TestGetAdaptiveQuestions(t *testing.T) {
+	_, store := memoryStore(t)
+	s := &VerbalQuestionService{Store: store}
+	questions, err := s.GetAdaptiveQuestions(context.Background(), "u1", 3, nil)
+	if err != nil {
+		t.Fatalf("GetAdaptiveQuestions() error = %v", err)
+	}
+	got := make([]int, 0)
+	for _, q := range questions {
+		got = append(got, q.ID)
+	}
+	// The most informative text completion at the prior ability is the one closest to it
+	if want := []int{3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAdaptiveQuestions() = %v, want %v", got, want)
+	}
+	if len(questions[1].Vocabulary) != 1 || questions[1].Vocabulary[0].Word != "abate" {
+		t.Errorf("vocabulary = %+v, want abate", questions[1].Vocabulary)
+	}
+	questions, _ = s.GetAdaptiveQuestions(context.Background(), "u1", 3, []int{2})
+	if len(questions) != 2 || questions[1].ID != 1 {
+		t.Errorf("GetAdaptiveQuestions() = %+v, want question 1 once 2 is excluded", questions)
+	}
+}
+
+func TestCreateVerbalStatUpdatesPerformance(t *testing.T) {
+	ctx := context.Background()
+	m, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	stat := &models.UserVerbalStat{QuestionID: 2, Answers: []string{"verbose"}, Correct: true}
+	if err := s.Create(ctx, stat, "u1"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if stat.Correct || stat.ID == 0 {
+		t.Errorf("stat = %+v, want a recorded incorrect answer", stat)
+	}
+	if stats := m.Stats("u1"); len(stats) != 1 || stats[0].Type != models.TextCompletion {
+		t.Errorf("Stats() = %+v, want the graded answer", stats)
+	}
+	abilities, _ := store.Users.GetAbilities(ctx, "u1")
+	if len(abilities) != 2 {
+		t.Fatalf("GetAbilities() = %+v, want type and competence estimates", abilities)
+	}
+	for _, a := range abilities {
+		if a.Theta >= 0 || a.Responses != 1 {
+			t.Errorf("ability %+v should decrease after an incorrect answer", a)
+		}
+	}
+	user, _ := store.Users.Get(ctx, "u1")
+	if score := user.VerbalAbility[models.TextCompletion.String()]; score != legacyAbilityScore(abilities[1].Theta) {
+		t.Errorf("verbal ability = %d, want the score of the type estimate", score)
+	}
+}
+
+func TestCreateVerbalStatIgnoresSession(t *testing.T) {
+	m, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	sessionID := 7
+	stat := &models.UserVerbalStat{QuestionID: 1, Answers: []string{"terse"}, SessionID: &sessionID}
+	if err := s.Create(context.Background(), stat, "u1"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if stat.SessionID != nil {
+		t.Errorf("session = %d, want none", *stat.SessionID)
+	}
+	if stats := m.Stats("u1"); len(stats) != 1 || stats[0].SessionID != nil {
+		t.Errorf("Stats() = %+v, want a stat without a session", stats)
+	}
+}
+
+func TestCreateVerbalStatDeletedQuestion(t *testing.T) {
+	m, store := memoryStore(t)
+	deletedAt := time.Now()
+	m.AddQuestion(models.VerbalQuestion{ID: 1, Type: models.TextCompletion, FramedAs: models.MCQSingleAnswer,
+		Options: []models.Option{{Value: "terse", Correct: true}}, IRT: models.IRTParams{A: 1}, DeletedAt: &deletedAt}, 1)
+	s := &UserVerbalStatsService{Store: store}
+	stat := &models.UserVerbalStat{QuestionID: 1, Answers: []string{"terse"}}
+	if err := s.Create(context.Background(), stat, "u1"); err != echo.ErrNotFound {
+		t.Fatalf("Create() error = %v, want not found", err)
+	}
+	if stats := m.Stats("u1"); len(stats) != 0 {
+		t.Errorf("Stats() = %+v, want none", stats)
+	}
+}
+
+func TestGetProblematicWords(t *testing.T) {
+	ctx := context.Background()
+	m, store := memoryStore(t)
+	stats := &UserVerbalStatsService{Store: store}
+	for _, stat := range []*models.UserVerbalStat{
+		{QuestionID: 1, Answers: []string{"verbose"}},
+		{QuestionID: 2, Answers: []string{"terse"}},
+	} {
+		if err := stats.Create(ctx, stat, "u1"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	m.AddQuizResult("u1", 3, false, time.Now())
+	m.AddQuizResult("u1", 2, false, time.Now().Add(-time.Hour))
+	m.AddQuizResult("u1", 2, true, time.Now())
+	s := &UserService{Store: store}
+	words, err := s.GetProblematicWordsByUserToken(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetProblematicWordsByUserToken() error = %v", err)
+	}
+	got := make([]string, 0)
+	for _, w := range words {
+		got = append(got, w.Word)
+	}
+	if want := []string{"laconic", "terse"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetProblematicWordsByUserToken() = %v, want %v", got, want)
+	}
+	words, _ = s.GetProblematicWordsByUserToken(ctx, "u2")
+	if len(words) != 0 {
+		t.Errorf("GetProblematicWordsByUserToken() = %v, want none", words)
+	}
+}
+
+func TestRecalculateAbilities(t *testing.T) {
+	ctx := context.Background()
+	_, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	for _, stat := range []*models.UserVerbalStat{
+		{QuestionID: 1, Answers: []string{"terse"}},
+		{QuestionID: 2, Answers: []string{"verbose"}},
+		{QuestionID: 2, Answers: []string{"terse"}},
+	} {
+		if err := s.Create(ctx, stat, "u1"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	// A stale estimate of a category without stats goes back to the prior
+	store.Users.SaveAbility(ctx, "u1", &models.UserAbility{Dimension: models.AbilityDimensionType,
+		Category: models.ReadingComprehension.String(), Theta: 2, StandardError: 0.5, Responses: 4})
+	want, _ := store.Users.Get(ctx, "u1")
+	before, _ := store.Users.GetAbilities(ctx, "u1")
+
+	user, err := s.RecalculateAbilities(ctx, "u1")
+	if err != nil {
+		t.Fatalf("RecalculateAbilities() error = %v", err)
+	}
+	if !reflect.DeepEqual(user.VerbalAbility, want.VerbalAbility) {
+		t.Errorf("verbal ability = %v, want %v", user.VerbalAbility, want.VerbalAbility)
+	}
+	after, _ := store.Users.GetAbilities(ctx, "u1")
+	if len(after) != len(before) {
+		t.Fatalf("GetAbilities() = %+v, want %d estimates", after, len(before))
+	}
+	for i, a := range after {
+		b := before[i]
+		if a.Category == models.ReadingComprehension.String() {
+			if a.Theta != 0 || a.Responses != 0 {
+				t.Errorf("stale ability %+v was not reset", a)
+			}
+			continue
+		}
+		if a.Theta != b.Theta || a.StandardError != b.StandardError || a.Responses != b.Responses {
+			t.Errorf("replayed ability %+v, want %+v", a, b)
+		}
+	}
+
+	report, err := s.RecalculateAllAbilities(ctx)
+	if err != nil {
+		t.Fatalf("RecalculateAllAbilities() error = %v", err)
+	}
+	if report.Processed != 1 || report.Changed != 0 || report.Failed != 0 {
+		t.Errorf("report = %+v, want one unchanged user", report)
+	}
+}
+
+func TestReplayAbilities(t *testing.T) {
+	ctx := context.Background()
+	m, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	for _, stat := range []*models.UserVerbalStat{
+		{QuestionID: 1, Answers: []string{"terse"}},
+		{QuestionID: 2, Answers: []string{"verbose"}},
+		{QuestionID: 2, Answers: []string{"terse"}},
+	} {
+		if err := s.Create(ctx, stat, "u1"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	// Replaying under the default model gives the estimates recorded along the way
+	replay, err := s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{Apply: true})
+	if err != nil {
+		t.Fatalf("ReplayAbilities() error = %v", err)
+	}
+	if replay.Model != models.ScoringIRTEAP || replay.Replayed != 3 || replay.Changed || replay.Applied {
+		t.Errorf("replay = %+v, want an unchanged irt-eap replay of 3 stats", replay)
+	}
+
+	// A stale estimate of a category without stats goes back to the prior
+	store.Users.SaveAbility(ctx, "u1", &models.UserAbility{Dimension: models.AbilityDimensionType,
+		Category: models.ReadingComprehension.String(), Theta: 2, StandardError: 0.5, Responses: 4})
+	// A corrected difficulty changes the estimates of the answers to the question
+	m.AddQuestion(models.VerbalQuestion{ID: 2, Type: models.TextCompletion, FramedAs: models.MCQSingleAnswer,
+		Competence: models.ReasoningFromIncompleteData, Options: []models.Option{{Value: "terse", Correct: true}, {Value: "verbose"}},
+		IRT: models.IRTParams{A: 1.5, B: 1.5}}, 2)
+	before, _ := store.Users.GetAbilities(ctx, "u1")
+	replay, err = s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{})
+	if err != nil {
+		t.Fatalf("ReplayAbilities() error = %v", err)
+	}
+	if !replay.Changed || replay.Applied {
+		t.Fatalf("replay = %+v, want a dry run with changes", replay)
+	}
+	for _, change := range replay.Abilities {
+		if !change.Changed || change.Before == nil {
+			t.Errorf("change = %+v, want a changed stored estimate", change)
+		}
+		if change.Category == models.ReadingComprehension.String() && (change.After.Theta != 0 || change.After.Responses != 0) {
+			t.Errorf("stale ability %+v was not reset", change.After)
+		}
+	}
+	if after, _ := store.Users.GetAbilities(ctx, "u1"); !reflect.DeepEqual(after, before) {
+		t.Errorf("GetAbilities() = %+v after a dry run, want %+v", after, before)
+	}
+
+	replay, err = s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{Apply: true})
+	if err != nil || !replay.Applied {
+		t.Fatalf("ReplayAbilities() = %+v, %v, want applied", replay, err)
+	}
+	user, _ := store.Users.Get(ctx, "u1")
+	if !reflect.DeepEqual(user.VerbalAbility, replay.VerbalAbilityAfter) {
+		t.Errorf("verbal ability = %v, want %v", user.VerbalAbility, replay.VerbalAbilityAfter)
+	}
+	report, err := s.ReplayAllAbilities(ctx, models.AbilityReplayRequest{Apply: true})
+	if err != nil {
+		t.Fatalf("ReplayAllAbilities() error = %v", err)
+	}
+	if report.Processed != 1 || report.Changed != 0 || report.Failed != 0 || len(report.Users) != 0 {
+		t.Errorf("report = %+v, want one unchanged user", report)
+	}
+}
+
+func TestReplayAbilitiesScoringModel(t *testing.T) {
+	ctx := context.Background()
+	_, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	for _, stat := range []*models.UserVerbalStat{
+		{QuestionID: 1, Answers: []string{"verbose"}},
+		{QuestionID: 2, Answers: []string{"terse"}},
+	} {
+		if err := s.Create(ctx, stat, "u1"); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	replay, err := s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{Model: models.ScoringIRTEAPBatch})
+	if err != nil {
+		t.Fatalf("ReplayAbilities() error = %v", err)
+	}
+	if replay.Model != models.ScoringIRTEAPBatch || !replay.Changed {
+		t.Errorf("replay = %+v, want batch estimates that differ from the incremental ones", replay)
+	}
+	for _, change := range replay.Abilities {
+		if change.After.Responses != 2 {
+			t.Errorf("change = %+v, want 2 responses", change)
+		}
+	}
+	if _, err := s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{Model: "elo"}); !errors.Is(err, ErrInvalidScoringModel) {
+		t.Errorf("ReplayAbilities() error = %v, want %v", err, ErrInvalidScoringModel)
+	}
+	if _, err := s.ReplayAllAbilities(ctx, models.AbilityReplayRequest{Model: "elo"}); !errors.Is(err, ErrInvalidScoringModel) {
+		t.Errorf("ReplayAllAbilities() error = %v, want %v", err, ErrInvalidScoringModel)
+	}
+}
+
+// Users whose locks are recorded
+type lockingUsers struct {
+	repository.UserRepository
+	locked map[string]bool
+}
+
+func (r lockingUsers) GetForUpdate(ctx context.Context, userToken string) (*models.User, error) {
+	r.locked[userToken] = true
+	return r.UserRepository.GetForUpdate(ctx, userToken)
+}
+
+func TestReplayAbilitiesApplyLocksUser(t *testing.T) {
+	ctx := context.Background()
+	_, store := memoryStore(t)
+	s := &UserVerbalStatsService{Store: store}
+	if err := s.Create(ctx, &models.UserVerbalStat{QuestionID: 1, Answers: []string{"terse"}}, "u1"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// A stale estimate that the applied replay resets
+	store.Users.SaveAbility(ctx, "u1", &models.UserAbility{Dimension: models.AbilityDimensionType,
+		Category: models.ReadingComprehension.String(), Theta: 2, StandardError: 0.5, Responses: 4})
+	transactions := 0
+	locked := make(map[string]bool)
+	store.RunInTx = func(ctx context.Context, fn func(tx *repository.Store) error) error {
+		transactions++
+		tx := *store
+		tx.RunInTx = nil
+		tx.Users = lockingUsers{store.Users, locked}
+		return fn(&tx)
+	}
+	if _, err := s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{}); err != nil {
+		t.Fatalf("ReplayAbilities() error = %v", err)
+	}
+	if transactions != 0 || len(locked) != 0 {
+		t.Errorf("dry run started %d transactions and locked %v, want neither", transactions, locked)
+	}
+	replay, err := s.ReplayAbilities(ctx, "u1", models.AbilityReplayRequest{Apply: true})
+	if err != nil || !replay.Applied {
+		t.Fatalf("ReplayAbilities() = %+v, %v, want applied", replay, err)
+	}
+	if transactions != 1 || !locked["u1"] {
+		t.Errorf("transactions = %d, locked = %v, want the user locked in one transaction", transactions, locked)
+	}
+}
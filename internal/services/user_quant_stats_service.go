@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
+	"grepandit.com/api/internal/models"
+)
+
+type UserQuantStatsService struct {
+	DB *pgxpool.Pool
+}
+
+func NewUserQuantStatsService(db *pgxpool.Pool) *UserQuantStatsService {
+	return &UserQuantStatsService{DB: db}
+}
+
+/**
+* Grades the submitted answers against the stored quant question and
+* records the result before updating the quant ability of the user.
+**/
+func (s *UserQuantStatsService) Create(ctx context.Context, stat *models.UserQuantStat, userToken string) error {
+	qqs := NewQuantQuestionService(s.DB)
+	question, err := qqs.GetByID(ctx, stat.QuestionID)
+	if err != nil {
+		return err
+	}
+	correct, grading, expected, err := GradeQuantAnswers(question, stat.Answers)
+	if err != nil {
+		return err
+	}
+	stat.Correct = correct
+	stat.Grading = grading
+	stat.Expected = expected
+	stat.UserToken = userToken
+	stat.Type = question.Type
+	stat.Topic = question.Topic
+	stat.Difficulty = question.Difficulty
+	stat.Date = time.Now()
+	query := `
+		INSERT INTO ` + database.QuantStatsTable + ` (` +
+		database.QuantStatsUserField + `, ` +
+		database.QuantStatsQuestionField + `, ` +
+		database.QuantStatsCorrectField + `, ` +
+		database.QuantStatsAnswersField + `, ` +
+		database.QuantStatsDurationField + `, ` +
+		database.QuantStatsDateField + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + database.QuantStatsIDField
+	err = s.DB.QueryRow(ctx, query, userToken, stat.QuestionID, stat.Correct, stat.Answers, stat.Duration, stat.Date).Scan(&stat.ID)
+	if err != nil {
+		return err
+	}
+	return s.UpdateUserPerformance(ctx, userToken, question, stat.Correct)
+}
+
+/**
+* Updates the quant ability estimates of the user for the type and topic
+* of the question. The quant ability score stored on the user mirrors the
+* estimate for the question type in the same way as the verbal one.
+**/
+func (s *UserQuantStatsService) UpdateUserPerformance(ctx context.Context, userToken string,
+	question *models.QuantQuestion, correct bool) error {
+	us := NewUserService(s.DB)
+	user, err := us.Get(ctx, userToken)
+	if err != nil {
+		return err
+	}
+	abilities, err := us.GetAbilities(ctx, userToken)
+	if err != nil {
+		return err
+	}
+	if user.QuantAbility == nil {
+		user.QuantAbility = make(map[string]int)
+	}
+	params := irtParams(question.IRT)
+	now := time.Now()
+	categories := []struct {
+		dimension string
+		category  string
+	}{
+		{models.AbilityDimensionQuantType, question.Type.String()},
+		{models.AbilityDimensionQuantTopic, question.Topic.String()},
+	}
+	for _, c := range categories {
+		estimate := irt.Update(findAbility(abilities, c.dimension, c.category), params, correct)
+		err = us.SaveAbility(ctx, userToken, &models.UserAbility{
+			Dimension:     c.dimension,
+			Category:      c.category,
+			Theta:         estimate.Theta,
+			StandardError: estimate.SE,
+			Responses:     estimate.Responses,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+		if c.dimension == models.AbilityDimensionQuantType {
+			user.QuantAbility[c.category] = legacyAbilityScore(estimate.Theta)
+		}
+	}
+	return us.Update(ctx, user)
+}
+
+// Retrieves the quant stats of a user in the order they were recorded
+func (s *UserQuantStatsService) GetQuantStatsByUserToken(ctx context.Context, userToken string) ([]models.UserQuantStat, error) {
+	query := squirrel.Select(
+		"qs."+database.QuantStatsIDField,
+		"qs."+database.QuantStatsUserField,
+		"qs."+database.QuantStatsQuestionField,
+		"qs."+database.QuantStatsCorrectField,
+		"qs."+database.QuantStatsAnswersField,
+		"qs."+database.QuantStatsDurationField,
+		"qs."+database.QuantStatsDateField,
+		"q."+database.QuantQuestionsTypeField,
+		"q."+database.QuantQuestionsTopicField,
+		"q."+database.QuantQuestionsDifficultyField,
+	).
+		From(database.QuantStatsTable+" AS qs").
+		Join(database.QuantQuestionsTable+" AS q ON qs."+database.QuantStatsQuestionField+" = q."+database.QuantQuestionsIDField).
+		Where(squirrel.Eq{"qs." + database.QuantStatsUserField: userToken}).
+		OrderBy("qs."+database.QuantStatsDateField, "qs."+database.QuantStatsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	quantStats := make([]models.UserQuantStat, 0)
+	for rows.Next() {
+		var stat models.UserQuantStat
+		err := rows.Scan(&stat.ID, &stat.UserToken, &stat.QuestionID, &stat.Correct, &stat.Answers, &stat.Duration, &stat.Date,
+			&stat.Type, &stat.Topic, &stat.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		quantStats = append(quantStats, stat)
+	}
+	return quantStats, rows.Err()
+}
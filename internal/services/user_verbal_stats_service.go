@@ -2,180 +2,216 @@ package services
 
 import (
 	"context"
-	"encoding/json"
-	"math"
 	"time"
 
-	"github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
 	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/repository"
 )
 
 type UserVerbalStatsService struct {
-	DB *pgxpool.Pool
+	Store *repository.Store
 }
 
 func NewUserVerbalStatsService(db *pgxpool.Pool) *UserVerbalStatsService {
-	return &UserVerbalStatsService{DB: db}
+	return &UserVerbalStatsService{Store: NewPostgresStore(db)}
 }
 
+/**
+* Grades the submitted answers against the stored question and records
+* the result. The correct flag sent by the client is ignored and replaced
+* by the server side grading, which is also used to update the user
+* performance. The session sent by the client is ignored as well: answers
+* are only attached to a session by PracticeSessionService.Answer.
+**/
 func (s *UserVerbalStatsService) Create(ctx context.Context, stat *models.UserVerbalStat, userToken string) error {
-	query := `
-		INSERT INTO ` + database.VerbalStatsTable + ` (` +
-		database.VerbalStatsUserField + `, ` +
-		database.VerbalStatsQuestionField + `, ` +
-		database.VerbalStatsCorrectField + `, ` +
-		database.VerbalStatsAnswersField + `, ` +
-		database.VerbalStatsDurationField + `, ` +
-		database.VerbalStatsDateField + `)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING ` + database.VerbalStatsIDField
-	err := s.DB.QueryRow(ctx, query, userToken, stat.QuestionID, stat.Correct, stat.Answers, stat.Duration, time.Now()).Scan(&stat.ID)
-	if err != nil {
-		return err
-	}
-	// Get the question to determine the problem type
-	vqs := NewVerbalQuestionService(s.DB)
-	question, err := vqs.GetByID(ctx, stat.QuestionID)
+	stat.SessionID = nil
+	return s.record(ctx, stat, userToken)
+}
+
+/**
+* Grades and records an answer, within the session the stat refers to if
+* any. The stat and the updated estimates are saved in one transaction that
+* locks the user first, so that the answers of a user update the estimates
+* one at a time.
+**/
+func (s *UserVerbalStatsService) record(ctx context.Context, stat *models.UserVerbalStat, userToken string) error {
+	// Get the question to grade the answers and determine the problem type
+	question, err := s.Store.Questions.GetByID(ctx, stat.QuestionID)
 	if err != nil {
 		return err
 	}
-	problemType := question.Type
-	problemDifficulty := question.Difficulty
-	// After a new stat has been created, update the user performance
-	err = s.UpdateUserPerformance(ctx, userToken, problemType.String(), problemDifficulty.String(), stat.Correct)
+	correct, grading, err := GradeVerbalAnswers(question, stat.Answers)
 	if err != nil {
 		return err
 	}
-	return nil
+	stat.Correct = correct
+	stat.Grading = grading
+	stat.UserToken = userToken
+	stat.Competence = question.Competence
+	stat.FramedAs = question.FramedAs
+	stat.Type = question.Type
+	stat.Difficulty = question.Difficulty
+	stat.Revision = question.Revision
+	stat.Date = time.Now()
+	return s.Store.InTx(ctx, func(tx *repository.Store) error {
+		user, err := tx.Users.GetForUpdate(ctx, userToken)
+		if err != nil {
+			return err
+		}
+		err = tx.Stats.Create(ctx, stat)
+		if err != nil {
+			return err
+		}
+		// After a new stat has been created, update the user performance
+		return updateVerbalPerformance(ctx, tx, user, question, stat.Correct)
+	})
 }
 
-func (s *UserVerbalStatsService) UpdateUserPerformance(ctx context.Context, userToken string, problemType string,
-	problemDifficulty string, correct bool) error {
-	us := NewUserService(s.DB)
-	user, err := us.Get(ctx, userToken)
+/**
+* Updates the ability estimates of the user for the type and competence of
+* the question with a Bayesian (EAP) update using the item response theory
+* parameters of the question. The verbal ability score stored on the user
+* is kept in sync with the estimate for the question type.
+**/
+func (s *UserVerbalStatsService) UpdateUserPerformance(ctx context.Context, userToken string,
+	question *models.VerbalQuestion, correct bool) error {
+	return s.Store.InTx(ctx, func(tx *repository.Store) error {
+		user, err := tx.Users.GetForUpdate(ctx, userToken)
+		if err != nil {
+			return err
+		}
+		return updateVerbalPerformance(ctx, tx, user, question, correct)
+	})
+}
+
+// Updates the estimates of a user that is locked by the transaction of the store
+func updateVerbalPerformance(ctx context.Context, store *repository.Store, user *models.User,
+	question *models.VerbalQuestion, correct bool) error {
+	abilities, err := store.Users.GetAbilities(ctx, user.Token)
 	if err != nil {
 		return err
 	}
-	print("USer found")
-	print(user.VerbalAbility)
-	// Initialize VerbalAbility and VerbalAbilityCount if they are nil
+	// Initialize VerbalAbility if it is nil
 	if user.VerbalAbility == nil {
 		user.VerbalAbility = make(map[string]int)
 	}
-	combination := problemType
-	// Initialize the counts and success rates for this problem type if necessary
-	// Update the success rate and count
-	maxElo := 4500
-	if correct {
-		if problemDifficulty == "Easy" {
-			user.VerbalAbility[combination] = int(math.Min(float64(maxElo), float64(user.VerbalAbility[combination]+100)))
-		} else if problemDifficulty == "Medium" {
-			user.VerbalAbility[combination] = int(math.Min(float64(maxElo), float64(user.VerbalAbility[combination]+150)))
-		} else {
-			user.VerbalAbility[combination] = int(math.Min(float64(maxElo), float64(user.VerbalAbility[combination]+200)))
+	params := irtParams(question.IRT)
+	now := time.Now()
+	for _, c := range verbalAbilityCategories(question) {
+		estimate := irt.Update(findAbility(abilities, c.dimension, c.category), params, correct)
+		err = store.Users.SaveAbility(ctx, user.Token, &models.UserAbility{
+			Dimension:     c.dimension,
+			Category:      c.category,
+			Theta:         estimate.Theta,
+			StandardError: estimate.SE,
+			Responses:     estimate.Responses,
+			UpdatedAt:     now,
+		})
+		if err != nil {
+			return err
 		}
-	} else {
-		if problemDifficulty == "Easy" {
-			user.VerbalAbility[combination] = int(math.Max(0, float64(user.VerbalAbility[combination]-100)))
-		} else if problemDifficulty == "Medium" {
-			user.VerbalAbility[combination] = int(math.Max(0, float64(user.VerbalAbility[combination]-150)))
-		} else {
-			user.VerbalAbility[combination] = int(math.Max(0, float64(user.VerbalAbility[combination]-200)))
+		if c.dimension == models.AbilityDimensionType {
+			user.VerbalAbility[c.category] = legacyAbilityScore(estimate.Theta)
 		}
 	}
 	// Save the updated user record
-	err = us.Update(ctx, user)
-	if err != nil {
-		return err
+	return store.Users.Update(ctx, user)
+}
+
+// Dimension and category of an ability estimate
+type abilityCategory struct {
+	dimension string
+	category  string
+}
+
+// Categories of the verbal abilities updated by an answer to the question
+func verbalAbilityCategories(question *models.VerbalQuestion) []abilityCategory {
+	return []abilityCategory{
+		{models.AbilityDimensionType, question.Type.String()},
+		{models.AbilityDimensionCompetence, question.Competence.String()},
 	}
-	return nil
 }
 
-func (s *UserVerbalStatsService) GetVocabularyByQuestionIDs(ctx context.Context, ids []int) (map[int][]models.Word, error) {
-	query := squirrel.Select(
-		"vqw."+database.VerbalQuestionWordJoinVerbalField,
-		"w."+database.WordsIDField,
-		"w."+database.WordsWordField,
-		"w."+database.WordsMeaningsField,
-		"w."+database.WordsExamplesField,
-	).
-		From(database.WordsTable + " AS w").
-		Join(database.VerbalQuestionWordsJoinTable + " AS vqw ON w." + database.WordsIDField + " = vqw." + database.VerbalQuestionWordJoinWordField).
-		Where(squirrel.Eq{"vqw." + database.VerbalQuestionWordJoinVerbalField: ids}).
-		PlaceholderFormat(squirrel.Dollar)
-	sqlQuery, args, err := query.ToSql()
+/**
+* Rebuilds the verbal ability estimates of a user from scratch by replaying
+* the verbal stats of the user under the default scoring model, and saves
+* them. See ReplayAbilities. Returns the updated user.
+**/
+func (s *UserVerbalStatsService) RecalculateAbilities(ctx context.Context, userToken string) (*models.User, error) {
+	_, err := s.ReplayAbilities(ctx, userToken, models.AbilityReplayRequest{Apply: true})
 	if err != nil {
 		return nil, err
 	}
-	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	return s.Store.Users.Get(ctx, userToken)
+}
+
+/**
+* Rebuilds the verbal abilities of every user. A user that fails is
+* reported and does not stop the others.
+**/
+func (s *UserVerbalStatsService) RecalculateAllAbilities(ctx context.Context) (*models.MaintenanceReport, error) {
+	report, err := s.ReplayAllAbilities(ctx, models.AbilityReplayRequest{Apply: true})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	vocabulary := make(map[int][]models.Word)
-	var meaningsJson []byte
-	for rows.Next() {
-		var questionID int
-		var word models.Word
-		err = rows.Scan(&questionID, &word.ID, &word.Word, &meaningsJson, &word.Examples)
-		if err != nil {
-			return nil, err
-		}
-		err = json.Unmarshal(meaningsJson, &word.Meanings)
-		if err != nil {
-			return nil, err
-		}
-		vocabulary[questionID] = append(vocabulary[questionID], word)
-	}
-	return vocabulary, nil
+	return &report.MaintenanceReport, nil
+}
+
+// Retrieves the vocabulary of each question, including that of its passage
+func (s *UserVerbalStatsService) GetVocabularyByQuestionIDs(ctx context.Context, ids []int) (map[int][]models.Word, error) {
+	return s.Store.Questions.GetVocabulary(ctx, ids)
 }
 
+// Retrieves the verbal stats of a user in the order they were recorded
 func (s *UserVerbalStatsService) GetVerbalStatsByUserToken(ctx context.Context, userToken string) ([]models.UserVerbalStat, error) {
-	query := squirrel.Select(
-		"vs.*",
-		"q."+database.VerbalQuestionsCompetenceField,
-		"q."+database.VerbalQuestionsFramedAsField,
-		"q."+database.VerbalQuestionsTypeField,
-		"q."+database.VerbalQuestionsDifficultyField,
-	).
-		From(database.VerbalStatsTable + " AS vs").
-		Join(database.VerbalQuestionsTable + " AS q ON vs." + database.VerbalStatsQuestionField + " = q." + database.VerbalQuestionsIDField).
-		Where(squirrel.Eq{"vs." + database.VerbalStatsUserField: userToken}).
-		PlaceholderFormat(squirrel.Dollar)
-	sqlQuery, args, err := query.ToSql()
+	stats, err := s.Store.Stats.GetByUser(ctx, userToken)
 	if err != nil {
 		return nil, err
 	}
-	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	return s.withQuestions(ctx, stats)
+}
+
+// Retrieves the verbal stats recorded by a user during a practice session
+func (s *UserVerbalStatsService) GetVerbalStatsBySession(ctx context.Context, userToken string, sessionID int) ([]models.UserVerbalStat, error) {
+	stats, err := s.Store.Stats.GetBySession(ctx, userToken, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	verbalStats := make([]models.UserVerbalStat, 0)
-	for rows.Next() {
-		var verbalStat models.UserVerbalStat
-		err := rows.Scan(&verbalStat.ID, &verbalStat.UserToken, &verbalStat.QuestionID, &verbalStat.Correct, &verbalStat.Answers, &verbalStat.Duration, &verbalStat.Date,
-			&verbalStat.Competence, &verbalStat.FramedAs, &verbalStat.Type, &verbalStat.Difficulty)
-		if err != nil {
-			return nil, err
-		}
-		verbalStats = append(verbalStats, verbalStat)
-	}
-	// After you get the list of UserVerbalStat:
-	questionIDs := make([]int, len(verbalStats))
-	for i, verbalStat := range verbalStats {
-		questionIDs[i] = verbalStat.QuestionID
-	}
-	// Get the vocabulary words for each question.
-	vocabulary, err := s.GetVocabularyByQuestionIDs(ctx, questionIDs)
+	return s.withQuestions(ctx, stats)
+}
+
+/**
+* Fills the type, competence, framing, difficulty and vocabulary of the
+* questions of the stats, including deleted questions. Stats of questions
+* that no longer exist are left out.
+**/
+func (s *UserVerbalStatsService) withQuestions(ctx context.Context, stats []models.UserVerbalStat) ([]models.UserVerbalStat, error) {
+	questionIDs := make([]int, len(stats))
+	for i, stat := range stats {
+		questionIDs[i] = stat.QuestionID
+	}
+	questions, err := s.Store.Questions.GetByIDs(ctx, questionIDs)
 	if err != nil {
 		return nil, err
 	}
-	// Add vocabulary words to each question.
-	for i, verbalStat := range verbalStats {
-		verbalStats[i].Vocabulary = vocabulary[verbalStat.QuestionID]
+	questionsByID := make(map[int]*models.VerbalQuestion, len(questions))
+	for _, q := range questions {
+		questionsByID[q.ID] = q
+	}
+	verbalStats := make([]models.UserVerbalStat, 0, len(stats))
+	for _, stat := range stats {
+		question, ok := questionsByID[stat.QuestionID]
+		if !ok {
+			continue
+		}
+		stat.Competence = question.Competence
+		stat.FramedAs = question.FramedAs
+		stat.Type = question.Type
+		stat.Difficulty = question.Difficulty
+		stat.Vocabulary = question.Vocabulary
+		verbalStats = append(verbalStats, stat)
 	}
 	return verbalStats, nil
 }
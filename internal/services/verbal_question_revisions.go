@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+)
+
+// Returned when the content of a verbal question is not valid
+var ErrInvalidQuestion = errors.New("invalid question")
+
+/**
+* Checks that a question has the fields needed to serve and grade it.
+**/
+func validateQuestionRequest(q *models.VerbalQuestionRequest) error {
+	if q.Type == 0 || q.FramedAs == 0 || q.Difficulty == 0 {
+		return fmt.Errorf("%w: type, framed_as and difficulty are required", ErrInvalidQuestion)
+	}
+	if len(q.Options) == 0 {
+		return fmt.Errorf("%w: options are required", ErrInvalidQuestion)
+	}
+	if q.PassageID != nil && q.Type != models.ReadingComprehension {
+		return fmt.Errorf("%w: only reading comprehension questions can belong to a passage", ErrInvalidQuestion)
+	}
+	for _, option := range q.Options {
+		if option.Correct {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: at least one option must be correct", ErrInvalidQuestion)
+}
+
+/**
+* Selects a question that has not been deleted for update along with the
+* snapshot of its content, including its vocabulary in alphabetical order.
+**/
+func lockQuestion(ctx context.Context, tx pgx.Tx, id int) (*models.VerbalQuestion, *models.VerbalQuestionRequest, error) {
+	query := squirrel.Select(verbalQuestionColumns("")...).
+		From(database.VerbalQuestionsTable).
+		Where(squirrel.Eq{database.VerbalQuestionsIDField: id}).
+		Where(activeQuestion).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, nil, err
+	}
+	q := &models.VerbalQuestion{}
+	err = scanVerbalQuestion(tx.QueryRow(ctx, sqlQuery, args...), q)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, echo.ErrNotFound
+		}
+		return nil, nil, err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT w.`+database.WordsWordField+`
+		FROM `+database.WordsTable+` AS w
+		INNER JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON w.`+database.WordsIDField+` = vqw.`+database.VerbalQuestionWordJoinWordField+`
+		WHERE vqw.`+database.VerbalQuestionWordJoinVerbalField+` = $1
+		ORDER BY w.`+database.WordsWordField, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	vocabulary := make([]string, 0)
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, nil, err
+		}
+		vocabulary = append(vocabulary, word)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	params := q.IRT
+	snapshot := &models.VerbalQuestionRequest{
+		ID:         q.ID,
+		Competence: q.Competence,
+		FramedAs:   q.FramedAs,
+		Type:       q.Type,
+		Paragraph:  q.Paragraph,
+		Question:   q.Question,
+		Options:    q.Options,
+		Difficulty: q.Difficulty,
+		Vocabulary: vocabulary,
+		IRT:        &params,
+		PassageID:  q.PassageID,
+	}
+	// The paragraph of a question in a passage is the text of the passage
+	if q.PassageID != nil {
+		snapshot.Paragraph = ""
+	}
+	return q, snapshot, nil
+}
+
+/**
+* Checks that the passage a question refers to exists. The paragraph of a
+* question that belongs to a passage is not stored as it is served from
+* the passage.
+**/
+func preparePassageQuestion(ctx context.Context, db querier, q *models.VerbalQuestionRequest) error {
+	if q.PassageID == nil {
+		return nil
+	}
+	var exists bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM `+database.PassagesTable+`
+		WHERE `+database.PassagesIDField+` = $1)`, *q.PassageID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: unknown passage %d", ErrInvalidQuestion, *q.PassageID)
+	}
+	q.Paragraph = ""
+	return nil
+}
+
+/**
+* Records the current state of a question that was created before
+* revisions were tracked so that its history starts from a known state.
+**/
+func recordBaseline(ctx context.Context, tx pgx.Tx, q *models.VerbalQuestion, snapshot *models.VerbalQuestionRequest) error {
+	var exists bool
+	err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM `+database.VerbalQuestionRevisionsTable+`
+		WHERE `+database.VerbalQuestionRevisionsQuestionField+` = $1
+		AND `+database.VerbalQuestionRevisionsRevisionField+` = $2)`, q.ID, q.Revision).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+	return recordRevision(ctx, tx, q.ID, q.Revision, models.RevisionBaseline, models.User{}, snapshot, nil)
+}
+
+// Inserts an immutable revision of a question
+func recordRevision(
+	ctx context.Context,
+	db querier,
+	questionID int,
+	revision int,
+	action models.RevisionAction,
+	editor models.User,
+	snapshot *models.VerbalQuestionRequest,
+	diff map[string]models.FieldChange,
+) error {
+	stored := *snapshot
+	stored.ID = questionID
+	snapshotJson, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	var diffJson []byte
+	if diff != nil {
+		diffJson, err = json.Marshal(diff)
+		if err != nil {
+			return err
+		}
+	}
+	query := squirrel.Insert(database.VerbalQuestionRevisionsTable).
+		Columns(
+			database.VerbalQuestionRevisionsQuestionField,
+			database.VerbalQuestionRevisionsRevisionField,
+			database.VerbalQuestionRevisionsActionField,
+			database.VerbalQuestionRevisionsEditorField,
+			database.VerbalQuestionRevisionsEditorEmailField,
+			database.VerbalQuestionRevisionsSnapshotField,
+			database.VerbalQuestionRevisionsDiffField,
+			database.VerbalQuestionRevisionsCreatedAtField).
+		Values(questionID, revision, string(action), editor.Token, editor.Email, snapshotJson, diffJson, time.Now()).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, sqlQuery, args...)
+	return err
+}
+
+/**
+* Compares two versions of a question field by field using their JSON
+* representation. Both versions are expected to list their vocabulary as
+* base forms in alphabetical order.
+**/
+func diffQuestionRequests(old, next *models.VerbalQuestionRequest) (map[string]models.FieldChange, error) {
+	oldFields, err := jsonFields(old)
+	if err != nil {
+		return nil, err
+	}
+	nextFields, err := jsonFields(next)
+	if err != nil {
+		return nil, err
+	}
+	diff := make(map[string]models.FieldChange)
+	for field, value := range nextFields {
+		if field == "id" {
+			continue
+		}
+		if !bytes.Equal(oldFields[field], value) {
+			diff[field] = models.FieldChange{Old: oldFields[field], New: value}
+		}
+	}
+	return diff, nil
+}
+
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	return fields, json.Unmarshal(data, &fields)
+}
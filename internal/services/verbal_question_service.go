@@ -4,87 +4,98 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"math/rand"
-	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
-	"github.com/aaaton/golem/v4"
-	"github.com/aaaton/golem/v4/dicts/en"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/labstack/echo/v4"
 	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/irt"
 	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+	"grepandit.com/api/internal/repository"
 )
 
+// Number of candidate questions considered during adaptive selection
+const adaptiveCandidates = 25
+
+// Restricts a query to questions that have not been deleted
+var activeQuestion = squirrel.Expr(database.VerbalQuestionsDeletedAtField + " IS NULL")
+
 type VerbalQuestionService struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+	Store      *repository.Store
+	// Service whose autocomplete index gets the words created by imports, may be nil
+	Words *WordService
 }
 
-func NewVerbalQuestionService(db *pgxpool.Pool) *VerbalQuestionService {
-	return &VerbalQuestionService{DB: db}
+// The lemmatizer is only used to create, modify and import questions and may be nil to read them.
+func NewVerbalQuestionService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *VerbalQuestionService {
+	return &VerbalQuestionService{DB: db, Lemmatizer: lemmatizer, Store: NewPostgresStore(db)}
 }
 
 /**
 * Creates a new record in the Db for verbal question. It also retrieves
 * the id of words based on the string words sent for the question and creates
 * a new record in the join table for each word associated with the question.
+* The question starts at its first revision, which is recorded along with
+* the editor that created it.
 **/
 func (s *VerbalQuestionService) Create(
 	ctx context.Context,
 	q *models.VerbalQuestionRequest,
+	editor models.User,
 ) error {
-	// Lemmetize to get base forms of words and find variations
-	lemmatizer, err := golem.New(en.New())
+	if q.PassageID != nil && q.Type != models.ReadingComprehension {
+		return fmt.Errorf("%w: only reading comprehension questions can belong to a passage", ErrInvalidQuestion)
+	}
+	// Begin a transaction.
+	tx, err := s.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	// Convert vocab list to base forms
-	vocabBaseForms := make(map[string]string)
-	for _, word := range q.Vocabulary {
-		lemmatized := lemmatizer.Lemma(word)
-		vocabBaseForms[lemmatized] = word
-	}
-	// Find variations in paragraph and options
-	variations := make(map[string]string)
-	// Check paragraph
-	// Split paragraph by whitespaces and periods
-	paragraphWords := strings.FieldsFunc(q.Paragraph, func(r rune) bool {
-		return r == ' ' || r == '.' || r == ',' || r == '!' || r == '(' || r == ')'
-	})
-	for _, word := range paragraphWords {
-		lemmatized := lemmatizer.Lemma(word)
-		if _, ok := vocabBaseForms[lemmatized]; ok {
-			variations[word] = lemmatized
-		}
-	}
-	// Iterate over Options
-	for _, option := range q.Options {
-		optionWords := strings.FieldsFunc(option.Value, func(r rune) bool {
-			return r == ' ' || r == '.' || r == ',' || r == '!' || r == '(' || r == ')'
-		})
-		for _, word := range optionWords {
-			lemmatized := lemmatizer.Lemma(word)
-			if _, ok := vocabBaseForms[lemmatized]; ok {
-				variations[word] = lemmatized
-			}
-		}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	err = createQuestion(ctx, tx, s.Lemmatizer, q, editor)
+	if err != nil {
+		return err
 	}
-	wordmapJson, err := json.Marshal(variations)
+	// If we reach this point, all database operations have been successful. Commit the transaction.
+	return tx.Commit(ctx)
+}
+
+/**
+* Inserts a question, its vocabulary links and its first revision within
+* the given transaction.
+**/
+func createQuestion(
+	ctx context.Context,
+	tx pgx.Tx,
+	lemmatizer *nlp.Lemmatizer,
+	q *models.VerbalQuestionRequest,
+	editor models.User,
+) error {
+	err := preparePassageQuestion(ctx, tx, q)
 	if err != nil {
 		return err
 	}
-	// Begin a transaction.
-	tx, err := s.DB.Begin(ctx)
+	vocabBaseForms, variations := vocabularyWordMap(lemmatizer, q.Vocabulary, questionTexts(q)...)
+	wordmapJson, err := json.Marshal(variations)
 	if err != nil {
 		return err
 	}
-	// Rollback in case of error. This is a no-op if the transaction has been committed.
-	defer tx.Rollback(ctx)
 	optionsJson, err := json.Marshal(q.Options)
 	if err != nil {
 		return err
 	}
+	if q.IRT == nil {
+		params := DefaultIRTParams(q.Difficulty, q.FramedAs, len(q.Options))
+		q.IRT = &params
+	}
 	query := squirrel.Insert(database.VerbalQuestionsTable).
 		Columns(
 			database.VerbalQuestionsCompetenceField,
@@ -94,7 +105,12 @@ func (s *VerbalQuestionService) Create(
 			database.VerbalQuestionsQuestionField,
 			database.VerbalQuestionsOptionsField,
 			database.VerbalQuestionsDifficultyField,
-			database.VerbalQuestionsWordmapField).
+			database.VerbalQuestionsWordmapField,
+			database.VerbalQuestionsIRTAField,
+			database.VerbalQuestionsIRTBField,
+			database.VerbalQuestionsIRTCField,
+			database.VerbalQuestionsRevisionField,
+			database.VerbalQuestionsPassageField).
 		Values(
 			q.Competence,
 			q.FramedAs,
@@ -103,7 +119,12 @@ func (s *VerbalQuestionService) Create(
 			q.Question,
 			optionsJson,
 			q.Difficulty,
-			wordmapJson).
+			wordmapJson,
+			q.IRT.A,
+			q.IRT.B,
+			q.IRT.C,
+			1,
+			q.PassageID).
 		Suffix("RETURNING " + database.VerbalQuestionsIDField).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
@@ -115,125 +136,228 @@ func (s *VerbalQuestionService) Create(
 		return err
 	}
 	// Now associate the words with the new verbal question.
-	for word := range vocabBaseForms {
-		// Get the ID of the word.
-		var wordID int
-		err = tx.QueryRow(ctx, "SELECT "+database.WordsIDField+" FROM "+database.WordsTable+" WHERE "+database.WordsWordField+" = $1", word).Scan(&wordID)
-		if err != nil {
-			return err
-		}
-		// Create a new record in the verbal_question_words table.
-		_, err = tx.Exec(ctx, "INSERT INTO "+database.VerbalQuestionWordsJoinTable+" ("+database.VerbalQuestionWordJoinVerbalField+", "+database.VerbalQuestionWordJoinWordField+") VALUES ($1, $2)", q.ID, wordID)
-		if err != nil {
-			return err
+	err = linkVocabulary(ctx, tx, database.VerbalQuestionWordsJoinTable, database.VerbalQuestionWordJoinVerbalField,
+		database.VerbalQuestionWordJoinWordField, q.ID, vocabBaseForms)
+	if err != nil {
+		return err
+	}
+	snapshot := *q
+	snapshot.Vocabulary = baseFormList(vocabBaseForms)
+	return recordRevision(ctx, tx, q.ID, 1, models.RevisionCreate, editor, &snapshot, nil)
+}
+
+/**
+* Replaces the content of a question. When no IRT parameters are sent the
+* current ones are kept, unless the difficulty or framing changed in which
+* case the defaults for the new values are used.
+**/
+func (s *VerbalQuestionService) Update(
+	ctx context.Context,
+	id int,
+	req *models.VerbalQuestionRequest,
+	editor models.User,
+) (*models.VerbalQuestion, error) {
+	return s.modify(ctx, id, editor, func(q *models.VerbalQuestionRequest) error {
+		*q = *req
+		return nil
+	})
+}
+
+/**
+* Applies a partial update to a question. Only the fields present in the
+* JSON patch are changed.
+**/
+func (s *VerbalQuestionService) Patch(
+	ctx context.Context,
+	id int,
+	patch []byte,
+	editor models.User,
+) (*models.VerbalQuestion, error) {
+	return s.modify(ctx, id, editor, func(q *models.VerbalQuestionRequest) error {
+		if err := json.Unmarshal(patch, q); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidQuestion, err.Error())
 		}
+		return nil
+	})
+}
+
+/**
+* Soft deletes a question so that it is no longer served while the stats
+* recorded for it stay interpretable. The deletion is recorded as a new
+* revision.
+**/
+func (s *VerbalQuestionService) Delete(ctx context.Context, id int, editor models.User) error {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	current, snapshot, err := lockQuestion(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	err = recordBaseline(ctx, tx, current, snapshot)
+	if err != nil {
+		return err
+	}
+	revision := current.Revision + 1
+	_, err = tx.Exec(ctx, `
+		UPDATE `+database.VerbalQuestionsTable+` SET `+
+		database.VerbalQuestionsDeletedAtField+` = $1, `+
+		database.VerbalQuestionsRevisionField+` = $2
+		WHERE `+database.VerbalQuestionsIDField+` = $3`, time.Now(), revision, id)
+	if err != nil {
+		return err
+	}
+	err = recordRevision(ctx, tx, id, revision, models.RevisionDelete, editor, snapshot, nil)
+	if err != nil {
+		return err
 	}
-	// If we reach this point, all database operations have been successful. Commit the transaction.
 	return tx.Commit(ctx)
 }
 
+// Retrieves the revision history of a question in order
+func (s *VerbalQuestionService) GetRevisions(ctx context.Context, id int) ([]models.VerbalQuestionRevision, error) {
+	return s.listRevisions(ctx, squirrel.Eq{database.VerbalQuestionRevisionsQuestionField: id})
+}
+
+// Retrieves a single revision of a question
+func (s *VerbalQuestionService) GetRevision(ctx context.Context, id int, revision int) (*models.VerbalQuestionRevision, error) {
+	revisions, err := s.listRevisions(ctx, squirrel.Eq{
+		database.VerbalQuestionRevisionsQuestionField: id,
+		database.VerbalQuestionRevisionsRevisionField: revision,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &revisions[0], nil
+}
+
 /**
-* Retrieve verbal question by its ID. A join operation is done using the join table
-* to get the list of words associated with this table.
+* Locks a question, applies a change to its content and stores the result
+* as a new revision along with the diff to the previous one. The vocabulary
+* links and the wordmap are recomputed from the new content. Changes that
+* leave the content as it is do not create a revision.
 **/
-func (s *VerbalQuestionService) GetByID(
+func (s *VerbalQuestionService) modify(
 	ctx context.Context,
 	id int,
+	editor models.User,
+	apply func(q *models.VerbalQuestionRequest) error,
 ) (*models.VerbalQuestion, error) {
-	q := &models.VerbalQuestion{}
-	query := squirrel.Select(
-		database.VerbalQuestionsIDField,
-		database.VerbalQuestionsCompetenceField,
-		database.VerbalQuestionsFramedAsField,
-		database.VerbalQuestionsTypeField,
-		database.VerbalQuestionsParagraphField,
-		database.VerbalQuestionsQuestionField,
-		database.VerbalQuestionsOptionsField,
-		database.VerbalQuestionsDifficultyField,
-		database.VerbalQuestionsWordmapField,
-	).
-		From(database.VerbalQuestionsTable).
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	current, old, err := lockQuestion(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	next := *old
+	next.IRT = nil
+	next.Options = append([]models.Option(nil), old.Options...)
+	next.Vocabulary = append([]string(nil), old.Vocabulary...)
+	if err := apply(&next); err != nil {
+		return nil, err
+	}
+	next.ID = id
+	if next.IRT == nil {
+		params := current.IRT
+		if next.Difficulty != old.Difficulty || next.FramedAs != old.FramedAs {
+			params = DefaultIRTParams(next.Difficulty, next.FramedAs, len(next.Options))
+		}
+		next.IRT = &params
+	}
+	if err := validateQuestionRequest(&next); err != nil {
+		return nil, err
+	}
+	if err := preparePassageQuestion(ctx, tx, &next); err != nil {
+		return nil, err
+	}
+	vocabBaseForms, variations := vocabularyWordMap(s.Lemmatizer, next.Vocabulary, questionTexts(&next)...)
+	next.Vocabulary = baseFormList(vocabBaseForms)
+	diff, err := diffQuestionRequests(old, &next)
+	if err != nil {
+		return nil, err
+	}
+	if len(diff) == 0 {
+		return current, nil
+	}
+	err = recordBaseline(ctx, tx, current, old)
+	if err != nil {
+		return nil, err
+	}
+	wordmapJson, err := json.Marshal(variations)
+	if err != nil {
+		return nil, err
+	}
+	optionsJson, err := json.Marshal(next.Options)
+	if err != nil {
+		return nil, err
+	}
+	revision := current.Revision + 1
+	query := squirrel.Update(database.VerbalQuestionsTable).
+		Set(database.VerbalQuestionsCompetenceField, next.Competence).
+		Set(database.VerbalQuestionsFramedAsField, next.FramedAs).
+		Set(database.VerbalQuestionsTypeField, next.Type).
+		Set(database.VerbalQuestionsParagraphField, next.Paragraph).
+		Set(database.VerbalQuestionsQuestionField, next.Question).
+		Set(database.VerbalQuestionsOptionsField, optionsJson).
+		Set(database.VerbalQuestionsDifficultyField, next.Difficulty).
+		Set(database.VerbalQuestionsWordmapField, wordmapJson).
+		Set(database.VerbalQuestionsIRTAField, next.IRT.A).
+		Set(database.VerbalQuestionsIRTBField, next.IRT.B).
+		Set(database.VerbalQuestionsIRTCField, next.IRT.C).
+		Set(database.VerbalQuestionsRevisionField, revision).
+		Set(database.VerbalQuestionsPassageField, next.PassageID).
 		Where(squirrel.Eq{database.VerbalQuestionsIDField: id}).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
-	var optionsJson []byte
-	var wordMapJson []byte
-	err = s.DB.QueryRow(ctx, sqlQuery, args...).
-		Scan(
-			&q.ID,
-			&q.Competence,
-			&q.FramedAs,
-			&q.Type,
-			&q.Paragraph,
-			&q.Question,
-			&optionsJson,
-			&q.Difficulty,
-			&wordMapJson,
-		)
+	_, err = tx.Exec(ctx, sqlQuery, args...)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, echo.ErrNotFound
-		}
 		return nil, err
 	}
-	err = json.Unmarshal(optionsJson, &q.Options)
+	_, err = tx.Exec(ctx, "DELETE FROM "+database.VerbalQuestionWordsJoinTable+" WHERE "+database.VerbalQuestionWordJoinVerbalField+" = $1", id)
 	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(wordMapJson, &q.VocabWordMap)
+	err = linkVocabulary(ctx, tx, database.VerbalQuestionWordsJoinTable, database.VerbalQuestionWordJoinVerbalField,
+		database.VerbalQuestionWordJoinWordField, id, vocabBaseForms)
 	if err != nil {
 		return nil, err
 	}
-	// Now get the vocabulary words.
-	rows, err := s.DB.Query(ctx, `
-		SELECT w.`+database.WordsIDField+`, w.`+database.WordsWordField+`, w.`+database.WordsMeaningsField+`, w.`+database.WordsExamplesField+`
-		FROM `+database.WordsTable+` AS w
-		INNER JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON w.`+database.WordsIDField+` = vqw.`+database.VerbalQuestionWordJoinWordField+`
-		WHERE vqw.`+database.VerbalQuestionWordJoinVerbalField+` = $1
-	`, id)
+	err = recordRevision(ctx, tx, id, revision, models.RevisionUpdate, editor, &next, diff)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	q.Vocabulary = make([]models.Word, 0)
-	var meaningsJson []byte
-	for rows.Next() {
-		var word models.Word
-		err = rows.Scan(&word.ID, &word.Word, &meaningsJson, &word.Examples)
-		if err != nil {
-			return nil, err
-		}
-		err = json.Unmarshal(meaningsJson, &word.Meanings)
-		if err != nil {
-			return nil, err
-		}
-		q.Vocabulary = append(q.Vocabulary, word)
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return q, nil
+	return s.GetByID(ctx, id)
 }
 
-func (s *VerbalQuestionService) GetByIDs(
-	ctx context.Context,
-	ids []int,
-) ([]*models.VerbalQuestion, error) {
-	questions := make([]*models.VerbalQuestion, 0)
+func (s *VerbalQuestionService) listRevisions(ctx context.Context, where squirrel.Sqlizer) ([]models.VerbalQuestionRevision, error) {
 	query := squirrel.Select(
-		database.VerbalQuestionsIDField,
-		database.VerbalQuestionsCompetenceField,
-		database.VerbalQuestionsFramedAsField,
-		database.VerbalQuestionsTypeField,
-		database.VerbalQuestionsParagraphField,
-		database.VerbalQuestionsQuestionField,
-		database.VerbalQuestionsOptionsField,
-		database.VerbalQuestionsDifficultyField,
-		database.VerbalQuestionsWordmapField,
-	).
-		From(database.VerbalQuestionsTable).
-		Where(squirrel.Eq{database.VerbalQuestionsIDField: ids}).
+		database.VerbalQuestionRevisionsIDField,
+		database.VerbalQuestionRevisionsQuestionField,
+		database.VerbalQuestionRevisionsRevisionField,
+		database.VerbalQuestionRevisionsActionField,
+		database.VerbalQuestionRevisionsEditorField,
+		database.VerbalQuestionRevisionsEditorEmailField,
+		database.VerbalQuestionRevisionsSnapshotField,
+		database.VerbalQuestionRevisionsDiffField,
+		database.VerbalQuestionRevisionsCreatedAtField).
+		From(database.VerbalQuestionRevisionsTable).
+		Where(where).
+		OrderBy(database.VerbalQuestionRevisionsRevisionField).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
@@ -244,138 +368,81 @@ func (s *VerbalQuestionService) GetByIDs(
 		return nil, err
 	}
 	defer rows.Close()
+	revisions := make([]models.VerbalQuestionRevision, 0)
 	for rows.Next() {
-		q := &models.VerbalQuestion{}
-		var optionsJson []byte
-		var wordMapJson []byte
-		err = rows.Scan(
-			&q.ID,
-			&q.Competence,
-			&q.FramedAs,
-			&q.Type,
-			&q.Paragraph,
-			&q.Question,
-			&optionsJson,
-			&q.Difficulty,
-			&wordMapJson,
-		)
+		var r models.VerbalQuestionRevision
+		var action string
+		var snapshotJson, diffJson []byte
+		err = rows.Scan(&r.ID, &r.QuestionID, &r.Revision, &action, &r.Editor, &r.EditorEmail, &snapshotJson, &diffJson, &r.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal(optionsJson, &q.Options)
-		if err != nil {
+		r.Action = models.RevisionAction(action)
+		if err := json.Unmarshal(snapshotJson, &r.Snapshot); err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal(wordMapJson, &q.VocabWordMap)
-		if err != nil {
-			return nil, err
-		}
-		// Now get the vocabulary words.
-		wordsRows, err := s.DB.Query(ctx, `
-			SELECT w.`+database.WordsIDField+`, w.`+database.WordsWordField+`, w.`+database.WordsMeaningsField+`, w.`+database.WordsExamplesField+`
-			FROM `+database.WordsTable+` AS w
-			INNER JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON w.`+database.WordsIDField+` = vqw.`+database.VerbalQuestionWordJoinWordField+`
-			WHERE vqw.`+database.VerbalQuestionWordJoinVerbalField+` = $1
-		`, q.ID)
-		if err != nil {
-			return nil, err
-		}
-		q.Vocabulary = make([]models.Word, 0)
-		var meaningsJson []byte
-		for wordsRows.Next() {
-			var word models.Word
-			err = wordsRows.Scan(&word.ID, &word.Word, &meaningsJson, &word.Examples)
-			if err != nil {
-				return nil, err
-			}
-			err = json.Unmarshal(meaningsJson, &word.Meanings)
-			if err != nil {
+		if diffJson != nil {
+			if err := json.Unmarshal(diffJson, &r.Diff); err != nil {
 				return nil, err
 			}
-			q.Vocabulary = append(q.Vocabulary, word)
 		}
-		wordsRows.Close()
-		questions = append(questions, q)
+		revisions = append(revisions, r)
 	}
-	return questions, nil
+	return revisions, rows.Err()
+}
+
+/**
+* Retrieve verbal question by its ID along with its vocabulary, including
+* the vocabulary of its passage.
+**/
+func (s *VerbalQuestionService) GetByID(
+	ctx context.Context,
+	id int,
+) (*models.VerbalQuestion, error) {
+	return s.Store.Questions.GetByID(ctx, id)
+}
+
+func (s *VerbalQuestionService) GetByIDs(
+	ctx context.Context,
+	ids []int,
+) ([]*models.VerbalQuestion, error) {
+	return s.Store.Questions.GetByIDs(ctx, ids)
 }
 
 /**
-* Fetch a list of questions that are adaptive based on the user
+* Fetch a list of questions that are adaptive based on the user. For each
+* question type the question that provides the most information at the
+* current ability estimate of the user is selected.
 **/
 func (s *VerbalQuestionService) GetAdaptiveQuestions(ctx context.Context, userToken string,
 	numQuestions int, excludeIds []int) ([]*models.VerbalQuestion, error) {
-	// Get the user's performance stats
-	us := NewUserService(s.DB)
-	user, err := us.Get(ctx, userToken)
+	// Get the user's ability estimates
+	abilities, err := s.Store.Users.GetAbilities(ctx, userToken)
 	if err != nil {
 		return nil, err
 	}
-	// Create a reward source
-	userElo := user.VerbalAbility
-	// Initialize a new epsilon-greedy bandit with epsilon=0.4 and the reward source
-	qTypes := [3]string{"ReadingComprehension", "TextCompletion", "SentenceEquivalence"}
-	// Get a question from each selected competency
-	questions := make([]*models.VerbalQuestion, 3)
-	successCount := 0
+	qTypes := [3]models.QuestionType{models.ReadingComprehension, models.TextCompletion, models.SentenceEquivalence}
+	// Get a question for each question type
+	questions := make([]*models.VerbalQuestion, 0, len(qTypes))
 	for _, qType := range qTypes {
-		value, ok := userElo[qType]
-		if !ok {
-			question, err := s.GetByCriteria(ctx, "Easy", qType, excludeIds)
-			if err != nil {
-				// If an error occurred, just move to the next one.
-				print(err.Error())
-				continue
-			}
-			// Only assign to the slice when we have a successful question.
-			questions[successCount] = question
-			successCount++
-		} else {
-			if value < 1000 {
-				question, err := s.GetByCriteria(ctx, "Easy", qType, excludeIds)
-				if err != nil {
-					// If an error occurred, just move to the next one.
-					print(err.Error())
-					continue
-				}
-				// Only assign to the slice when we have a successful question.
-				questions[successCount] = question
-				successCount++
-			} else if value < 2500 {
-				question, err := s.GetByCriteria(ctx, "Medium", qType, excludeIds)
-				if err != nil {
-					// If an error occurred, just move to the next one.
-					print(err.Error())
-					continue
-				}
-				// Only assign to the slice when we have a successful question.
-				questions[successCount] = question
-				successCount++
-			} else {
-				question, err := s.GetByCriteria(ctx, "Hard", qType, excludeIds)
-				if err != nil {
-					// If an error occurred, just move to the next one.
-					print(err.Error())
-					continue
-				}
-				// Only assign to the slice when we have a successful question.
-				questions[successCount] = question
-				successCount++
-			}
+		if len(questions) == numQuestions {
+			break
 		}
+		estimate := findAbility(abilities, models.AbilityDimensionType, qType.String())
+		question, err := s.GetMostInformative(ctx, qType, 0, estimate.Theta, excludeIds)
+		if err != nil {
+			// If an error occurred, just move to the next one.
+			print(err.Error())
+			continue
+		}
+		questions = append(questions, question)
 	}
-	// If we have less than numQuestions, resize the slice.
-	if successCount < numQuestions {
-		questions = questions[:successCount]
-	}
-	// New UserVerbalStatsService instance
-	uvss := NewUserVerbalStatsService(s.DB)
 	// Get the question IDs
-	questionIDs := make([]int, successCount)
+	questionIDs := make([]int, len(questions))
 	for i, question := range questions {
 		questionIDs[i] = question.ID
 	}
-	vocabulary, err := uvss.GetVocabularyByQuestionIDs(ctx, questionIDs)
+	vocabulary, err := s.Store.Questions.GetVocabulary(ctx, questionIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -385,6 +452,36 @@ func (s *VerbalQuestionService) GetAdaptiveQuestions(ctx context.Context, userTo
 	return questions, nil
 }
 
+/**
+* Retrieve the question of the given type that provides the most information
+* at ability theta. Only the questions whose difficulty parameter is closest
+* to theta are considered as candidates. The competence filter is ignored
+* when it is zero.
+**/
+func (s *VerbalQuestionService) GetMostInformative(
+	ctx context.Context,
+	qType models.QuestionType,
+	competence models.Competence,
+	theta float64,
+	excludeIDs []int,
+) (*models.VerbalQuestion, error) {
+	questions, err := s.Store.Questions.GetClosestToAbility(ctx, qType, competence, theta, adaptiveCandidates, excludeIDs)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make(map[int]*models.VerbalQuestion, len(questions))
+	items := make([]irt.Item, 0, len(questions))
+	for _, q := range questions {
+		candidates[q.ID] = q
+		items = append(items, irt.Item{ID: q.ID, Params: irtParams(q.IRT)})
+	}
+	item, ok := irt.MostInformative(theta, items)
+	if !ok {
+		return nil, echo.ErrNotFound
+	}
+	return candidates[item.ID], nil
+}
+
 /**
 * Retrieve verbal questions at random based on particular parameters
 * to display to the user.
@@ -404,18 +501,9 @@ func (s *VerbalQuestionService) GetByCriteria(
 		return nil, err
 	}
 	// Build the SQL query
-	query := squirrel.Select(
-		database.VerbalQuestionsIDField,
-		database.VerbalQuestionsCompetenceField,
-		database.VerbalQuestionsFramedAsField,
-		database.VerbalQuestionsTypeField,
-		database.VerbalQuestionsParagraphField,
-		database.VerbalQuestionsQuestionField,
-		database.VerbalQuestionsOptionsField,
-		database.VerbalQuestionsDifficultyField,
-		database.VerbalQuestionsWordmapField,
-	).
+	query := squirrel.Select(verbalQuestionColumns("")...).
 		From(database.VerbalQuestionsTable).
+		Where(activeQuestion).
 		OrderBy("RANDOM()").
 		PlaceholderFormat(squirrel.Dollar)
 	query = query.Where(squirrel.Eq{database.VerbalQuestionsTypeField: qTypeEnum})
@@ -429,37 +517,30 @@ func (s *VerbalQuestionService) GetByCriteria(
 		return nil, err
 	}
 	q := &models.VerbalQuestion{}
-	var optionsJson []byte
-	var wordMapJson []byte
-	err = s.DB.QueryRow(ctx, sqlQuery, args...).
-		Scan(
-			&q.ID,
-			&q.Competence,
-			&q.FramedAs,
-			&q.Type,
-			&q.Paragraph,
-			&q.Question,
-			&optionsJson,
-			&q.Difficulty,
-			&wordMapJson,
-		)
+	err = scanVerbalQuestion(s.DB.QueryRow(ctx, sqlQuery, args...), q)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, echo.ErrNotFound
 		}
 		return nil, err
 	}
-	err = json.Unmarshal(optionsJson, &q.Options)
-	if err != nil {
-		return nil, err
-	}
-	err = json.Unmarshal(wordMapJson, &q.VocabWordMap)
-	if err != nil {
-		return nil, err
-	}
 	return q, nil
 }
 
+/**
+* Retrieve a single verbal question at random that matches the criteria.
+* Filters that are zero are not applied.
+**/
+func (s *VerbalQuestionService) GetRandom(
+	ctx context.Context,
+	questionType models.QuestionType,
+	competence models.Competence,
+	difficulty models.Difficulty,
+	excludeIDs []int,
+) (*models.VerbalQuestion, error) {
+	return s.Store.Questions.GetRandom(ctx, questionType, competence, difficulty, excludeIDs)
+}
+
 /**
 * Retrieve verbal questions at random based on particular parameters
 * to display to the user.
@@ -477,6 +558,7 @@ func (s *VerbalQuestionService) Random(
 	sb := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
 	query := sb.Select(database.VerbalQuestionsIDField).
 		From(database.VerbalQuestionsTable + " as q").
+		Where(activeQuestion).
 		OrderBy("RANDOM()").
 		Limit(uint64(limit))
 	if questionType != 0 {
@@ -512,77 +594,110 @@ func (s *VerbalQuestionService) Random(
 		}
 		questionIDs = append(questionIDs, id)
 	}
-	// Based on retrieved question ids we can execute another query to get all the
-	// words using the join table and fill up the vocabulary for each question
-	vocabularyQuery := sb.Select(
-		"q."+database.VerbalQuestionsIDField,
-		"q."+database.VerbalQuestionsCompetenceField,
-		"q."+database.VerbalQuestionsFramedAsField,
-		"q."+database.VerbalQuestionsTypeField,
-		"q."+database.VerbalQuestionsParagraphField,
-		"q."+database.VerbalQuestionsQuestionField,
-		"q."+database.VerbalQuestionsOptionsField,
-		"q."+database.VerbalQuestionsDifficultyField,
-		"q."+database.VerbalQuestionsWordmapField,
-		"w."+database.WordsIDField,
-		"w."+database.WordsWordField,
-		"w."+database.WordsMeaningsField,
-		"w."+database.WordsExamplesField,
-	).
-		From(database.VerbalQuestionsTable + " AS q").
-		Join(database.VerbalQuestionWordsJoinTable + " AS a ON q." + database.VerbalQuestionsIDField + " = a." + database.VerbalQuestionWordJoinVerbalField).
-		Join(database.WordsTable + " AS w ON a." + database.VerbalQuestionWordJoinWordField + " = w." + database.WordsIDField).
-		Where(squirrel.Eq{"q." + database.VerbalQuestionsIDField: questionIDs})
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Questions that belong to a passage are served along with the rest of
+	// the passage set, as in the real exam
+	questionIDs, err = s.expandPassageSets(ctx, questionIDs, limit, excludeIDs)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := s.GetByIDs(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.VerbalQuestion, len(fetched))
+	for _, q := range fetched {
+		byID[q.ID] = q
+	}
+	questions := make([]models.VerbalQuestion, 0, len(questionIDs))
+	for _, id := range questionIDs {
+		if q, ok := byID[id]; ok {
+			questions = append(questions, *q)
+		}
+	}
+	return questions, nil
+}
 
-	vocabularySQL, vocabularyArgs, err := vocabularyQuery.ToSql()
-	if err != nil {
-		return nil, err
-	}
-	vocabularyRows, err := s.DB.Query(ctx, vocabularySQL, vocabularyArgs...)
-	if err != nil {
-		return nil, err
-	}
-	defer vocabularyRows.Close()
-	questionsMap := make(map[int]models.VerbalQuestion)
-	for vocabularyRows.Next() {
-		var q models.VerbalQuestion
-		var word models.Word
-		var meaningsJSON []byte
-		err := vocabularyRows.Scan(
-			&q.ID,
-			&q.Competence,
-			&q.FramedAs,
-			&q.Type,
-			&q.Paragraph,
-			&q.Question,
-			&q.Options,
-			&q.Difficulty,
-			&q.VocabWordMap,
-			&word.ID,
-			&word.Word,
-			&meaningsJSON,
-			&word.Examples,
-		)
-		if err != nil {
-			return nil, err
+/**
+* Replaces each question that belongs to a passage with the questions of
+* the passage set in order. Sets are never split, so the result may exceed
+* the limit to complete the last set, but no further set is started once the
+* limit is reached.
+**/
+func (s *VerbalQuestionService) expandPassageSets(ctx context.Context, ids []int, limit int, excludeIDs []int) ([]int, error) {
+	expanded := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		if len(expanded) >= limit {
+			break
 		}
-		err = json.Unmarshal(meaningsJSON, &word.Meanings)
+		set, err := s.passageSet(ctx, id, excludeIDs)
 		if err != nil {
 			return nil, err
 		}
-		if existingQ, ok := questionsMap[q.ID]; ok {
-			existingQ.Vocabulary = append(existingQ.Vocabulary, word)
-			questionsMap[q.ID] = existingQ
-		} else {
-			q.Vocabulary = []models.Word{word}
-			questionsMap[q.ID] = q
+		for _, member := range set {
+			if !seen[member] {
+				seen[member] = true
+				expanded = append(expanded, member)
+			}
 		}
 	}
-	questions := make([]models.VerbalQuestion, 0, len(questionsMap))
-	for _, question := range questionsMap {
-		questions = append(questions, question)
+	return expanded, nil
+}
+
+/**
+* Retrieves the ids of the active questions that share the passage of the
+* given question ordered by id. A question without a passage is its own set.
+**/
+func (s *VerbalQuestionService) passageSet(ctx context.Context, id int, excludeIDs []int) ([]int, error) {
+	query := squirrel.Select("q." + database.VerbalQuestionsIDField).
+		From(database.VerbalQuestionsTable + " AS q").
+		Join(database.VerbalQuestionsTable + " AS origin ON origin." + database.VerbalQuestionsPassageField +
+			" = q." + database.VerbalQuestionsPassageField).
+		Where(squirrel.Eq{"origin." + database.VerbalQuestionsIDField: id}).
+		Where("q." + database.VerbalQuestionsDeletedAtField + " IS NULL").
+		OrderBy("q." + database.VerbalQuestionsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	if len(excludeIDs) > 0 {
+		query = query.Where(squirrel.NotEq{"q." + database.VerbalQuestionsIDField: excludeIDs})
 	}
-	return questions, nil
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	set := make([]int, 0)
+	for rows.Next() {
+		var member int
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		set = append(set, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(set) == 0 {
+		return []int{id}, nil
+	}
+	return set, nil
+}
+
+/**
+* Retrieves the ids of the active questions of a passage ordered by id,
+* leaving out the excluded questions.
+**/
+func (s *VerbalQuestionService) GetPassageQuestionIDs(ctx context.Context, passageID int, excludeIDs []int) ([]int, error) {
+	return s.Store.Questions.GetPassageQuestionIDs(ctx, passageID, excludeIDs)
 }
 
 func (s *VerbalQuestionService) GetQuestionsOnVocab(
@@ -595,6 +710,8 @@ func (s *VerbalQuestionService) GetQuestionsOnVocab(
 	query := squirrel.Select(database.VerbalQuestionWordJoinVerbalField).
 		From(database.VerbalQuestionWordsJoinTable).
 		Where(squirrel.Eq{database.VerbalQuestionWordJoinWordField: wordIDs}).
+		Where(database.VerbalQuestionWordJoinVerbalField + " IN (SELECT " + database.VerbalQuestionsIDField +
+			" FROM " + database.VerbalQuestionsTable + " WHERE " + database.VerbalQuestionsDeletedAtField + " IS NULL)").
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
@@ -627,6 +744,82 @@ func (s *VerbalQuestionService) GetQuestionsOnVocab(
 	return s.GetByIDs(ctx, questionIDs)
 }
 
+/**
+* Columns of a verbal question in the order expected by scanVerbalQuestion.
+* Questions that belong to a passage take the paragraph from the passage
+* text and extend their wordmap with the one of the passage.
+**/
+func verbalQuestionColumns(alias string) []string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	return []string{
+		prefix + database.VerbalQuestionsIDField,
+		prefix + database.VerbalQuestionsCompetenceField,
+		prefix + database.VerbalQuestionsFramedAsField,
+		prefix + database.VerbalQuestionsTypeField,
+		"COALESCE(NULLIF(" + prefix + database.VerbalQuestionsParagraphField + ", ''), " +
+			passageColumn(alias, database.PassagesTextField) + ", '')",
+		prefix + database.VerbalQuestionsQuestionField,
+		prefix + database.VerbalQuestionsOptionsField,
+		prefix + database.VerbalQuestionsDifficultyField,
+		"COALESCE(" + prefix + database.VerbalQuestionsWordmapField + ", '{}'::jsonb) || COALESCE(" +
+			passageColumn(alias, database.PassagesWordmapField) + ", '{}'::jsonb)",
+		prefix + database.VerbalQuestionsIRTAField,
+		prefix + database.VerbalQuestionsIRTBField,
+		prefix + database.VerbalQuestionsIRTCField,
+		prefix + database.VerbalQuestionsRevisionField,
+		prefix + database.VerbalQuestionsDeletedAtField,
+		prefix + database.VerbalQuestionsPassageField,
+	}
+}
+
+// Selects a field of the passage of a question, which is null for questions without one
+func passageColumn(alias string, field string) string {
+	prefix := ""
+	if alias != "" {
+		prefix = alias + "."
+	}
+	return "(SELECT pg." + field + " FROM " + database.PassagesTable + " AS pg WHERE pg." +
+		database.PassagesIDField + " = " + prefix + database.VerbalQuestionsPassageField + ")"
+}
+
+/**
+* Scans a row selected with verbalQuestionColumns into a verbal question.
+* Any extra destinations are scanned from the columns that follow.
+**/
+func scanVerbalQuestion(row pgx.Row, q *models.VerbalQuestion, extra ...interface{}) error {
+	var optionsJson []byte
+	var wordMapJson []byte
+	dest := append([]interface{}{
+		&q.ID,
+		&q.Competence,
+		&q.FramedAs,
+		&q.Type,
+		&q.Paragraph,
+		&q.Question,
+		&optionsJson,
+		&q.Difficulty,
+		&wordMapJson,
+		&q.IRT.A,
+		&q.IRT.B,
+		&q.IRT.C,
+		&q.Revision,
+		&q.DeletedAt,
+		&q.PassageID,
+	}, extra...)
+	err := row.Scan(dest...)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(optionsJson, &q.Options)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(wordMapJson, &q.VocabWordMap)
+}
+
 // Helper function to check if a slice contains a value
 func contains(slice []int, val int) bool {
 	for _, item := range slice {
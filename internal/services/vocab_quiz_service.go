@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+	"grepandit.com/api/internal/vocabquiz"
+)
+
+const (
+	defaultVocabQuizLength = 10
+	maxVocabQuizLength     = 50
+	// Number of random words distractors are drawn from
+	vocabQuizPoolSize = 200
+)
+
+var (
+	ErrInvalidVocabQuiz  = errors.New("invalid vocabulary quiz")
+	ErrVocabItemAnswered = errors.New("quiz item has already been answered")
+)
+
+type VocabQuizService struct {
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+}
+
+func NewVocabQuizService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *VocabQuizService {
+	return &VocabQuizService{DB: db, Lemmatizer: lemmatizer}
+}
+
+/**
+* Generates a quiz about the requested words, the marked or problematic
+* words of the user, or random words. Distractors are drawn from a random
+* sample of the words table along with the quizzed words themselves.
+**/
+func (s *VocabQuizService) Generate(ctx context.Context, userToken string, req *models.VocabQuizRequest) (*models.VocabQuiz, error) {
+	length := req.Length
+	if length == 0 {
+		length = defaultVocabQuizLength
+	}
+	if length < 0 || length > maxVocabQuizLength {
+		return nil, fmt.Errorf("%w: length must be between 1 and %d", ErrInvalidVocabQuiz, maxVocabQuizLength)
+	}
+	for _, kind := range req.Kinds {
+		if kind != models.DefinitionToWord && kind != models.WordToDefinition && kind != models.FillInBlank {
+			return nil, fmt.Errorf("%w: unknown kind %s", ErrInvalidVocabQuiz, kind)
+		}
+	}
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	words, err := s.quizWords(ctx, userToken, req, length)
+	if err != nil {
+		return nil, err
+	}
+	r.Shuffle(len(words), func(i, j int) { words[i], words[j] = words[j], words[i] })
+	pool, err := s.queryWords(ctx, squirrel.Select(wordColumns("w")...).
+		From(database.WordsTable+" AS w").
+		OrderBy("RANDOM()").
+		Limit(vocabQuizPoolSize))
+	if err != nil {
+		return nil, err
+	}
+	generator := vocabquiz.NewGenerator(append(pool, words...), s.Lemmatizer, r)
+	quiz := &models.VocabQuiz{
+		UserToken: userToken,
+		Items:     generator.Quiz(words, req.Kinds, length),
+		CreatedAt: time.Now(),
+	}
+	if len(quiz.Items) == 0 {
+		return nil, fmt.Errorf("%w: no items could be generated from the words", ErrInvalidVocabQuiz)
+	}
+	itemsJson, err := json.Marshal(quiz.Items)
+	if err != nil {
+		return nil, err
+	}
+	query := squirrel.Insert(database.VocabQuizzesTable).
+		Columns(
+			database.VocabQuizzesUserField,
+			database.VocabQuizzesItemsField,
+			database.VocabQuizzesCreatedAtField).
+		Values(userToken, itemsJson, quiz.CreatedAt).
+		Suffix("RETURNING " + database.VocabQuizzesIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&quiz.ID); err != nil {
+		return nil, err
+	}
+	return hideAnswers(quiz), nil
+}
+
+func (s *VocabQuizService) Get(ctx context.Context, userToken string, id int) (*models.VocabQuiz, error) {
+	quiz, err := s.load(ctx, s.DB, userToken, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return hideAnswers(quiz), nil
+}
+
+// Retrieves the quizzes of the user, most recent first
+func (s *VocabQuizService) GetByUserToken(ctx context.Context, userToken string) ([]models.VocabQuiz, error) {
+	query := squirrel.Select(vocabQuizColumns()...).
+		From(database.VocabQuizzesTable).
+		Where(squirrel.Eq{database.VocabQuizzesUserField: userToken}).
+		OrderBy(database.VocabQuizzesIDField + " DESC").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	quizzes := make([]models.VocabQuiz, 0)
+	for rows.Next() {
+		var quiz models.VocabQuiz
+		if err := rows.Scan(vocabQuizFields(&quiz)...); err != nil {
+			return nil, err
+		}
+		quizzes = append(quizzes, *hideAnswers(&quiz))
+	}
+	return quizzes, rows.Err()
+}
+
+/**
+* Answers an item of a quiz with the index of an option and records the
+* result, which makes the word problematic for the user while its latest
+* result is incorrect. Returns the item along with its correct answer.
+**/
+func (s *VocabQuizService) Answer(ctx context.Context, userToken string, id int, answer *models.VocabQuizAnswer) (*models.VocabQuizItem, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	quiz, err := s.load(ctx, tx, userToken, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if answer.Item < 0 || answer.Item >= len(quiz.Items) {
+		return nil, fmt.Errorf("%w: quiz has no item %d", ErrInvalidVocabQuiz, answer.Item)
+	}
+	item := &quiz.Items[answer.Item]
+	if item.Response != nil {
+		return nil, ErrVocabItemAnswered
+	}
+	if answer.Answer < 0 || answer.Answer >= len(item.Options) {
+		return nil, fmt.Errorf("%w: item has no option %d", ErrInvalidVocabQuiz, answer.Answer)
+	}
+	correct := item.Answer != nil && *item.Answer == answer.Answer
+	response := answer.Answer
+	item.Response = &response
+	item.Correct = &correct
+
+	itemsJson, err := json.Marshal(quiz.Items)
+	if err != nil {
+		return nil, err
+	}
+	update := squirrel.Update(database.VocabQuizzesTable).
+		Set(database.VocabQuizzesItemsField, itemsJson).
+		Where(squirrel.Eq{database.VocabQuizzesIDField: quiz.ID}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := update.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, sqlQuery, args...); err != nil {
+		return nil, err
+	}
+	insert := squirrel.Insert(database.VocabQuizResultsTable).
+		Columns(
+			database.VocabQuizResultsUserField,
+			database.VocabQuizResultsQuizField,
+			database.VocabQuizResultsItemField,
+			database.VocabQuizResultsWordField,
+			database.VocabQuizResultsKindField,
+			database.VocabQuizResultsCorrectField,
+			database.VocabQuizResultsAnsweredAtField).
+		Values(userToken, quiz.ID, answer.Item, item.WordID, string(item.Kind), correct, time.Now()).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err = insert.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, sqlQuery, args...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Retrieves the words a quiz is generated about
+func (s *VocabQuizService) quizWords(ctx context.Context, userToken string, req *models.VocabQuizRequest, length int) ([]models.Word, error) {
+	query := squirrel.Select(wordColumns("w")...).From(database.WordsTable + " AS w")
+	switch {
+	case len(req.WordIDs) > 0:
+		query = query.Where(squirrel.Eq{"w." + database.WordsIDField: req.WordIDs})
+	case req.Source == models.VocabQuizSourceMarked:
+		query = query.
+			Join(database.UserMarkedWordsTable + " AS m ON m." + database.UserMarkedWordsWordField + " = w." + database.WordsIDField).
+			Where(squirrel.Eq{"m." + database.UserMarkedWordsUserField: userToken})
+	case req.Source == models.VocabQuizSourceProblematic:
+		return NewUserService(s.DB).GetProblematicWordsByUserToken(ctx, userToken)
+	case req.Source == "" || req.Source == models.VocabQuizSourceAll:
+		// Words without content are skipped, so more words than needed are picked
+		query = query.OrderBy("RANDOM()").Limit(uint64(length * 3))
+	default:
+		return nil, fmt.Errorf("%w: unknown source %s", ErrInvalidVocabQuiz, req.Source)
+	}
+	return s.queryWords(ctx, query)
+}
+
+func (s *VocabQuizService) queryWords(ctx context.Context, query squirrel.SelectBuilder) ([]models.Word, error) {
+	sqlQuery, args, err := query.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	words := make([]models.Word, 0)
+	for rows.Next() {
+		var w models.Word
+		if err := rows.Scan(wordFields(&w)...); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
+
+// Loads a quiz of the user, locking it when it is about to be answered
+func (s *VocabQuizService) load(ctx context.Context, q querier, userToken string, id int, lock bool) (*models.VocabQuiz, error) {
+	query := squirrel.Select(vocabQuizColumns()...).
+		From(database.VocabQuizzesTable).
+		Where(squirrel.Eq{
+			database.VocabQuizzesIDField:   id,
+			database.VocabQuizzesUserField: userToken,
+		}).
+		PlaceholderFormat(squirrel.Dollar)
+	if lock {
+		query = query.Suffix("FOR UPDATE")
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	quiz := &models.VocabQuiz{}
+	if err := q.QueryRow(ctx, sqlQuery, args...).Scan(vocabQuizFields(quiz)...); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, echo.ErrNotFound
+		}
+		return nil, err
+	}
+	return quiz, nil
+}
+
+func vocabQuizColumns() []string {
+	return []string{
+		database.VocabQuizzesIDField,
+		database.VocabQuizzesUserField,
+		database.VocabQuizzesItemsField,
+		database.VocabQuizzesCreatedAtField,
+	}
+}
+
+func vocabQuizFields(quiz *models.VocabQuiz) []interface{} {
+	return []interface{}{&quiz.ID, &quiz.UserToken, &quiz.Items, &quiz.CreatedAt}
+}
+
+// Removes the correct answer of the items that were not answered yet
+func hideAnswers(quiz *models.VocabQuiz) *models.VocabQuiz {
+	for i := range quiz.Items {
+		if quiz.Items[i].Response == nil {
+			quiz.Items[i].Answer = nil
+		}
+	}
+	return quiz
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+)
+
+// Returned when a vocabulary word of a question is not in the words table
+var ErrUnknownWord = errors.New("unknown vocabulary word")
+
+/**
+* Lemmatizes a vocabulary and finds the variations of each vocabulary word
+* used in the given texts. Returns the base forms of the vocabulary and the
+* wordmap from each variation to its base form.
+**/
+func vocabularyWordMap(lemmatizer *nlp.Lemmatizer, vocabulary []string, texts ...string) (map[string]string, map[string]string) {
+	// Convert vocab list to base forms
+	vocabBaseForms := make(map[string]string)
+	for _, word := range vocabulary {
+		lemmatized := lemmatizer.Lemma(word)
+		vocabBaseForms[lemmatized] = word
+	}
+	// Find variations in the texts
+	variations := make(map[string]string)
+	for _, text := range texts {
+		for _, word := range nlp.Tokenize(text) {
+			lemmatized := lemmatizer.Lemma(word)
+			if _, ok := vocabBaseForms[lemmatized]; ok {
+				variations[word] = lemmatized
+				continue
+			}
+			// Look up the parts of hyphenated words, such as "known" in "well-known"
+			if parts := nlp.Parts(word); len(parts) > 1 {
+				for _, part := range parts {
+					lemmatized := lemmatizer.Lemma(part)
+					if _, ok := vocabBaseForms[lemmatized]; ok {
+						variations[part] = lemmatized
+					}
+				}
+			}
+		}
+	}
+	return vocabBaseForms, variations
+}
+
+// Texts of a question in which variations of its vocabulary are looked up
+func questionTexts(q *models.VerbalQuestionRequest) []string {
+	texts := []string{q.Paragraph}
+	for _, option := range q.Options {
+		texts = append(texts, option.Value)
+	}
+	return texts
+}
+
+/**
+* Creates a record in a join table for each vocabulary word of a question
+* or passage. Fails with ErrUnknownWord when a word is not in the words
+* table.
+**/
+func linkVocabulary(ctx context.Context, db querier, joinTable string, ownerField string, wordField string,
+	ownerID int, vocabBaseForms map[string]string) error {
+	for _, word := range baseFormList(vocabBaseForms) {
+		// Get the ID of the word.
+		var wordID int
+		err := db.QueryRow(ctx, "SELECT "+database.WordsIDField+" FROM "+database.WordsTable+" WHERE "+database.WordsWordField+" = $1", word).Scan(&wordID)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return fmt.Errorf("%w: %s", ErrUnknownWord, vocabBaseForms[word])
+			}
+			return err
+		}
+		// Create a new record in the join table.
+		_, err = db.Exec(ctx, "INSERT INTO "+joinTable+" ("+ownerField+", "+wordField+") VALUES ($1, $2)", ownerID, wordID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the base forms of a vocabulary in alphabetical order
+func baseFormList(vocabBaseForms map[string]string) []string {
+	words := make([]string, 0, len(vocabBaseForms))
+	for word := range vocabBaseForms {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+/**
+* Retrieves the vocabulary of each question, including the vocabulary of
+* the passage a question belongs to.
+**/
+func questionVocabulary(ctx context.Context, db querier, ids []int) (map[int][]models.Word, error) {
+	wordColumns := "w." + database.WordsIDField + ", w." + database.WordsWordField + ", w." +
+		database.WordsMeaningsField + ", w." + database.WordsExamplesField
+	rows, err := db.Query(ctx, `
+		SELECT vqw.`+database.VerbalQuestionWordJoinVerbalField+`, `+wordColumns+`
+		FROM `+database.WordsTable+` AS w
+		INNER JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON w.`+database.WordsIDField+` = vqw.`+database.VerbalQuestionWordJoinWordField+`
+		WHERE vqw.`+database.VerbalQuestionWordJoinVerbalField+` = ANY($1)
+		UNION
+		SELECT q.`+database.VerbalQuestionsIDField+`, `+wordColumns+`
+		FROM `+database.WordsTable+` AS w
+		INNER JOIN `+database.PassageWordsJoinTable+` AS pw ON w.`+database.WordsIDField+` = pw.`+database.PassageWordJoinWordField+`
+		INNER JOIN `+database.VerbalQuestionsTable+` AS q ON q.`+database.VerbalQuestionsPassageField+` = pw.`+database.PassageWordJoinPassageField+`
+		WHERE q.`+database.VerbalQuestionsIDField+` = ANY($1)
+		ORDER BY 1, 3`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vocabulary := make(map[int][]models.Word)
+	var meaningsJson []byte
+	for rows.Next() {
+		var questionID int
+		var word models.Word
+		err = rows.Scan(&questionID, &word.ID, &word.Word, &meaningsJson, &word.Examples)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(meaningsJson, &word.Meanings)
+		if err != nil {
+			return nil, err
+		}
+		vocabulary[questionID] = append(vocabulary[questionID], word)
+	}
+	return vocabulary, rows.Err()
+}
+
+// Columns of a word in the order of wordFields
+func wordColumns(alias string) []string {
+	return []string{
+		alias + "." + database.WordsIDField,
+		alias + "." + database.WordsWordField,
+		alias + "." + database.WordsMeaningsField,
+		alias + "." + database.WordsExamplesField,
+		alias + "." + database.WordsFeaturedField,
+	}
+}
+
+func wordFields(w *models.Word) []interface{} {
+	return []interface{}{&w.ID, &w.Word, &w.Meanings, &w.Examples, &w.Featured}
+}
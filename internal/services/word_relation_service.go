@@ -0,0 +1,528 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+	"grepandit.com/api/internal/wordgraph"
+)
+
+const (
+	maxNeighborhoodDepth = 3
+	maxDrills            = 20
+	// Random words added to the traps of a drill as distractors
+	drillPoolSize = 30
+	drillPrompt   = "Select the two words that are closest in meaning."
+)
+
+var ErrInvalidWordRelation = errors.New("invalid word relation")
+
+type WordRelationService struct {
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+}
+
+func NewWordRelationService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *WordRelationService {
+	return &WordRelationService{DB: db, Lemmatizer: lemmatizer}
+}
+
+/**
+* Relates two words of the words table. The words are looked up by their
+* base form, as they are stored. Relating words that are already related
+* with the same type returns the existing relation.
+**/
+func (s *WordRelationService) Create(ctx context.Context, req *models.WordRelationRequest) (*models.WordRelation, error) {
+	s.normalize(req)
+	if err := wordgraph.Validate(req); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidWordRelation, err.Error())
+	}
+	ids, err := s.wordIDs(ctx, []string{req.Word, req.RelatedWord})
+	if err != nil {
+		return nil, err
+	}
+	for _, word := range []string{req.Word, req.RelatedWord} {
+		if _, ok := ids[word]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWord, word)
+		}
+	}
+	id, err := s.insert(ctx, s.DB, ids[req.Word], ids[req.RelatedWord], req.Type)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := s.relations(ctx, squirrel.Eq{"r." + database.WordRelationsIDField: id})
+	if err != nil {
+		return nil, err
+	}
+	return orient(&relations[0], ids[req.Word]), nil
+}
+
+func (s *WordRelationService) Delete(ctx context.Context, id int) error {
+	tag, err := s.DB.Exec(ctx, "DELETE FROM "+database.WordRelationsTable+" WHERE "+database.WordRelationsIDField+" = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return echo.ErrNotFound
+	}
+	return nil
+}
+
+// Retrieves the relations of a word with any of the types, or of any type when none is given
+func (s *WordRelationService) GetByWord(ctx context.Context, wordID int, types []models.RelationType) ([]models.WordRelation, error) {
+	relations, err := s.relations(ctx, touching([]int{wordID}, types))
+	if err != nil {
+		return nil, err
+	}
+	for i := range relations {
+		orient(&relations[i], wordID)
+	}
+	return relations, nil
+}
+
+/**
+* Traverses the relations of the given types breadth first from a word, up
+* to depth relations away, loading one level of the graph at a time.
+**/
+func (s *WordRelationService) GetNeighborhood(ctx context.Context, wordID int, depth int, types []models.RelationType) (*models.WordNeighborhood, error) {
+	if depth < 1 || depth > maxNeighborhoodDepth {
+		return nil, fmt.Errorf("%w: depth must be between 1 and %d", ErrInvalidWordRelation, maxNeighborhoodDepth)
+	}
+	words, err := s.words(ctx, []int{wordID})
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	graph := wordgraph.New()
+	loaded := make(map[int]models.WordRelation)
+	seen := map[int]bool{wordID: true}
+	frontier := []int{wordID}
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		relations, err := s.relations(ctx, touching(frontier, types))
+		if err != nil {
+			return nil, err
+		}
+		next := make([]int, 0)
+		for _, relation := range relations {
+			graph.Add(relation.WordID, relation.RelatedWordID, relation.Type)
+			loaded[relation.ID] = relation
+			for _, id := range []int{relation.WordID, relation.RelatedWordID} {
+				if !seen[id] {
+					seen[id] = true
+					next = append(next, id)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	distances := graph.Distances(wordID, depth, types...)
+	neighborhood := &models.WordNeighborhood{
+		Word:      words[wordID],
+		Depth:     depth,
+		Neighbors: make([]models.WordNeighbor, 0),
+		Relations: make([]models.WordRelation, 0),
+	}
+	ids := make([]int, 0, len(distances))
+	for id := range distances {
+		if id != wordID {
+			ids = append(ids, id)
+		}
+	}
+	neighbors, err := s.words(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		neighborhood.Neighbors = append(neighborhood.Neighbors, models.WordNeighbor{Word: neighbors[id], Distance: distances[id]})
+	}
+	sort.Slice(neighborhood.Neighbors, func(i, j int) bool {
+		a, b := neighborhood.Neighbors[i], neighborhood.Neighbors[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		return a.Word.Word < b.Word.Word
+	})
+	for _, relation := range loaded {
+		_, fromReached := distances[relation.WordID]
+		_, toReached := distances[relation.RelatedWordID]
+		if fromReached && toReached {
+			neighborhood.Relations = append(neighborhood.Relations, relation)
+		}
+	}
+	sort.Slice(neighborhood.Relations, func(i, j int) bool {
+		return neighborhood.Relations[i].ID < neighborhood.Relations[j].ID
+	})
+	return neighborhood, nil
+}
+
+/**
+* Generates drills from random pairs of synonyms, optionally involving a
+* given word. The words confused with, related to or opposite of the pair
+* are used as distractors first since they make the most plausible traps,
+* followed by random words. Pairs without enough distractors are skipped.
+**/
+func (s *WordRelationService) GetDrills(ctx context.Context, count int, wordID int) ([]models.SynonymDrill, error) {
+	if count < 1 || count > maxDrills {
+		return nil, fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidWordRelation, maxDrills)
+	}
+	query := squirrel.Select(database.WordRelationsWordField, database.WordRelationsRelatedWordField).
+		From(database.WordRelationsTable).
+		Where(squirrel.Eq{database.WordRelationsTypeField: string(models.RelationSynonym)}).
+		OrderBy("RANDOM()").
+		Limit(uint64(count)).
+		PlaceholderFormat(squirrel.Dollar)
+	if wordID > 0 {
+		query = query.Where(squirrel.Or{
+			squirrel.Eq{database.WordRelationsWordField: wordID},
+			squirrel.Eq{database.WordRelationsRelatedWordField: wordID},
+		})
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pairs := make([][2]int, 0)
+	for rows.Next() {
+		var pair [2]int
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	drills := make([]models.SynonymDrill, 0, len(pairs))
+	for _, pair := range pairs {
+		drill, err := s.drill(ctx, r, pair)
+		if errors.Is(err, wordgraph.ErrNotEnoughDistractors) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		drills = append(drills, *drill)
+	}
+	return drills, nil
+}
+
+func (s *WordRelationService) drill(ctx context.Context, r *rand.Rand, pair [2]int) (*models.SynonymDrill, error) {
+	graph := wordgraph.New()
+	relations, err := s.relations(ctx, touching(pair[:], nil))
+	if err != nil {
+		return nil, err
+	}
+	traps := make([]int, 0)
+	for _, relation := range relations {
+		graph.Add(relation.WordID, relation.RelatedWordID, relation.Type)
+		if relation.Type == models.RelationSynonym {
+			continue
+		}
+		for _, id := range []int{relation.WordID, relation.RelatedWordID} {
+			if id != pair[0] && id != pair[1] {
+				traps = append(traps, id)
+			}
+		}
+	}
+	r.Shuffle(len(traps), func(i, j int) { traps[i], traps[j] = traps[j], traps[i] })
+	candidates := traps
+	rows, err := s.DB.Query(ctx, "SELECT "+database.WordsIDField+" FROM "+database.WordsTable+" ORDER BY RANDOM() LIMIT $1", drillPoolSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Synonyms among the candidates would give the drill other answers
+	ids := append(append(make([]int, 0, len(candidates)+2), candidates...), pair[:]...)
+	synonyms, err := s.relations(ctx, squirrel.And{
+		squirrel.Eq{"r." + database.WordRelationsTypeField: string(models.RelationSynonym)},
+		squirrel.Expr("r."+database.WordRelationsWordField+" = ANY(?)", ids),
+		squirrel.Expr("r."+database.WordRelationsRelatedWordField+" = ANY(?)", ids),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range synonyms {
+		graph.Add(relation.WordID, relation.RelatedWordID, relation.Type)
+	}
+	options, answer, err := graph.Drill(r, pair, candidates)
+	if err != nil {
+		return nil, err
+	}
+	words, err := s.words(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	drill := &models.SynonymDrill{Prompt: drillPrompt, WordIDs: options, Answer: answer}
+	for _, id := range options {
+		drill.Options = append(drill.Options, words[id].Word)
+	}
+	return drill, nil
+}
+
+/**
+* Imports the relations of a thesaurus file in CSV format. Rows that are
+* not valid or relate unknown words are reported, with suggestions for the
+* unknown words, and the other rows are imported in a single transaction.
+* Rows of the report are the line numbers of the file.
+**/
+func (s *WordRelationService) Import(ctx context.Context, r io.Reader, dryRun bool) (*models.WordRelationImportReport, error) {
+	rows, err := wordgraph.ReadCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImport, err.Error())
+	}
+	report := &models.WordRelationImportReport{Rows: len(rows), Errors: make([]models.ImportRowError, 0), DryRun: dryRun}
+	words := make([]string, 0, 2*len(rows))
+	for i := range rows {
+		if rows[i].Err != nil {
+			continue
+		}
+		// Words that only differ by their inflection are the same word
+		s.normalize(&rows[i].Relation)
+		if err := wordgraph.Validate(&rows[i].Relation); err != nil {
+			rows[i].Err = err
+			continue
+		}
+		words = append(words, rows[i].Relation.Word, rows[i].Relation.RelatedWord)
+	}
+	ids, err := s.wordIDs(ctx, words)
+	if err != nil {
+		return nil, err
+	}
+	var dictionary []string
+	valid := make([]wordgraph.Row, 0, len(rows))
+	for _, row := range rows {
+		if row.Err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row.Line, Message: row.Err.Error()})
+			continue
+		}
+		unknown := make([]models.UnknownWord, 0)
+		for _, word := range []string{row.Relation.Word, row.Relation.RelatedWord} {
+			if _, ok := ids[word]; ok {
+				continue
+			}
+			if dictionary == nil {
+				if dictionary, err = NewVerbalQuestionService(s.DB, nil).allWords(ctx); err != nil {
+					return nil, err
+				}
+			}
+			unknown = append(unknown, models.UnknownWord{Word: word, Suggestions: suggestWords(word, dictionary, maxWordSuggestions)})
+		}
+		if len(unknown) > 0 {
+			report.Errors = append(report.Errors, models.ImportRowError{
+				Row:          row.Line,
+				Message:      ErrUnknownWord.Error(),
+				UnknownWords: unknown,
+			})
+			continue
+		}
+		valid = append(valid, row)
+	}
+	report.Failed = len(report.Errors)
+	if dryRun {
+		return report, nil
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rollback in case of error. This is a no-op if the transaction has been committed.
+	defer tx.Rollback(ctx)
+	for _, row := range valid {
+		a, b := canonicalPair(ids[row.Relation.Word], ids[row.Relation.RelatedWord])
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO `+database.WordRelationsTable+` (`+
+			database.WordRelationsWordField+`, `+
+			database.WordRelationsRelatedWordField+`, `+
+			database.WordRelationsTypeField+`)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`, a, b, string(row.Relation.Type))
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			report.Existing++
+		} else {
+			report.Imported++
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Inserts a relation unless it exists and returns its id
+func (s *WordRelationService) insert(ctx context.Context, q querier, wordID int, relatedWordID int, relationType models.RelationType) (int, error) {
+	a, b := canonicalPair(wordID, relatedWordID)
+	var id int
+	// The no-op update makes the existing row available to RETURNING
+	err := q.QueryRow(ctx, `
+		INSERT INTO `+database.WordRelationsTable+` (`+
+		database.WordRelationsWordField+`, `+
+		database.WordRelationsRelatedWordField+`, `+
+		database.WordRelationsTypeField+`)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (`+database.WordRelationsWordField+`, `+database.WordRelationsRelatedWordField+`, `+database.WordRelationsTypeField+`)
+		DO UPDATE SET `+database.WordRelationsTypeField+` = EXCLUDED.`+database.WordRelationsTypeField+`
+		RETURNING `+database.WordRelationsIDField, a, b, string(relationType)).Scan(&id)
+	return id, err
+}
+
+// Retrieves relations along with their words
+func (s *WordRelationService) relations(ctx context.Context, where squirrel.Sqlizer) ([]models.WordRelation, error) {
+	query := squirrel.Select(
+		"r."+database.WordRelationsIDField,
+		"r."+database.WordRelationsWordField,
+		"a."+database.WordsWordField,
+		"r."+database.WordRelationsRelatedWordField,
+		"b."+database.WordsWordField,
+		"r."+database.WordRelationsTypeField).
+		From(database.WordRelationsTable + " AS r").
+		Join(database.WordsTable + " AS a ON a." + database.WordsIDField + " = r." + database.WordRelationsWordField).
+		Join(database.WordsTable + " AS b ON b." + database.WordsIDField + " = r." + database.WordRelationsRelatedWordField).
+		Where(where).
+		OrderBy("r." + database.WordRelationsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	relations := make([]models.WordRelation, 0)
+	for rows.Next() {
+		var relation models.WordRelation
+		err := rows.Scan(
+			&relation.ID,
+			&relation.WordID,
+			&relation.Word,
+			&relation.RelatedWordID,
+			&relation.RelatedWord,
+			&relation.Type)
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, relation)
+	}
+	return relations, rows.Err()
+}
+
+// Retrieves words by id
+func (s *WordRelationService) words(ctx context.Context, ids []int) (map[int]models.Word, error) {
+	query := squirrel.Select(wordColumns("w")...).
+		From(database.WordsTable + " AS w").
+		Where(squirrel.Expr("w."+database.WordsIDField+" = ANY(?)", ids)).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	words := make(map[int]models.Word)
+	for rows.Next() {
+		var w models.Word
+		if err := rows.Scan(wordFields(&w)...); err != nil {
+			return nil, err
+		}
+		words[w.ID] = w
+	}
+	return words, rows.Err()
+}
+
+// Lowercases and lemmatizes the words of a relation, as words are stored by their base form
+func (s *WordRelationService) normalize(req *models.WordRelationRequest) {
+	req.Word = s.Lemmatizer.Lemma(strings.ToLower(strings.TrimSpace(req.Word)))
+	req.RelatedWord = s.Lemmatizer.Lemma(strings.ToLower(strings.TrimSpace(req.RelatedWord)))
+}
+
+// Finds the ids of words of the words table
+func (s *WordRelationService) wordIDs(ctx context.Context, words []string) (map[string]int, error) {
+	rows, err := s.DB.Query(ctx, "SELECT "+database.WordsIDField+", "+database.WordsWordField+
+		" FROM "+database.WordsTable+" WHERE "+database.WordsWordField+" = ANY($1)", words)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var word string
+		if err := rows.Scan(&id, &word); err != nil {
+			return nil, err
+		}
+		ids[word] = id
+	}
+	return ids, rows.Err()
+}
+
+// Condition on the relations of any of the words with any of the types
+func touching(ids []int, types []models.RelationType) squirrel.Sqlizer {
+	where := squirrel.And{squirrel.Or{
+		squirrel.Expr("r."+database.WordRelationsWordField+" = ANY(?)", ids),
+		squirrel.Expr("r."+database.WordRelationsRelatedWordField+" = ANY(?)", ids),
+	}}
+	if len(types) > 0 {
+		names := make([]string, len(types))
+		for i, relationType := range types {
+			names[i] = string(relationType)
+		}
+		where = append(where, squirrel.Eq{"r." + database.WordRelationsTypeField: names})
+	}
+	return where
+}
+
+// Relations are stored once, from the word with the lowest id
+func canonicalPair(a int, b int) (int, int) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
+
+// Swaps the words of a relation so that it starts from the given word
+func orient(relation *models.WordRelation, wordID int) *models.WordRelation {
+	if relation.RelatedWordID == wordID {
+		relation.WordID, relation.RelatedWordID = relation.RelatedWordID, relation.WordID
+		relation.Word, relation.RelatedWord = relation.RelatedWord, relation.Word
+	}
+	return relation
+}
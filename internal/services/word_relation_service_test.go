@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+)
+
+func TestNormalizeWordRelation(t *testing.T) {
+	lemmatizer, err := nlp.NewLemmatizer(0)
+	if err != nil {
+		t.Fatalf("NewLemmatizer() error = %v", err)
+	}
+	s := &WordRelationService{Lemmatizer: lemmatizer}
+	req := &models.WordRelationRequest{Word: " Abated", RelatedWord: "DIMINISHED ", Type: models.RelationSynonym}
+	s.normalize(req)
+	if req.Word != "abate" || req.RelatedWord != "diminish" {
+		t.Errorf("normalize() = %q, %q, want abate, diminish", req.Word, req.RelatedWord)
+	}
+	// Inflections of a word are the same word
+	_, err = s.Create(context.Background(), &models.WordRelationRequest{Word: "Ran", RelatedWord: "run", Type: models.RelationSynonym})
+	if !errors.Is(err, ErrInvalidWordRelation) {
+		t.Errorf("Create() error = %v, want %v", err, ErrInvalidWordRelation)
+	}
+}
@@ -4,32 +4,56 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Masterminds/squirrel"
-	"github.com/aaaton/golem/v4"
-	"github.com/aaaton/golem/v4/dicts/en"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/autocomplete"
 	"grepandit.com/api/internal/database"
 	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
 )
 
+/**
+* Interval after which the autocomplete index is reloaded in the background
+* to pick up the new question counts and variants.
+**/
+const autocompleteRefreshInterval = 15 * time.Minute
+
+/**
+* Interval after which the words created by other processes, such as the
+* admin command, are added to the autocomplete index.
+**/
+const autocompleteCatchUpInterval = time.Minute
+
 type WordService struct {
-	DB *pgxpool.Pool
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+	// In-memory autocomplete index, loaded on first use
+	autocomplete           *autocomplete.Index
+	autocompleteMu         sync.Mutex
+	autocompleteLoadedAt   time.Time
+	autocompleteCaughtUpAt time.Time
+	autocompleteRefreshing bool
+	// Highest id of the indexed words, from which new words are looked up
+	autocompleteMaxID int
+	// Words indexed while the index is reloaded, which the reload may miss
+	autocompletePending []autocomplete.Entry
 }
 
-func NewWordService(db *pgxpool.Pool) *WordService {
-	return &WordService{DB: db}
+func NewWordService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *WordService {
+	return &WordService{DB: db, Lemmatizer: lemmatizer, autocomplete: autocomplete.New()}
 }
 
+// Creates a word. Words are only featured through FeatureWords.
 func (s *WordService) Create(ctx context.Context, w *models.Word) error {
-	// Lemmatize to get base forms of words and find variations
-	lemmatizer, err := golem.New(en.New())
-	if err != nil {
-		println(err)
-		return err
-	}
-	baseForm := lemmatizer.Lemma(w.Word)
+	w.Featured = false
+	baseForm := s.Lemmatizer.Lemma(w.Word)
 	meaningsJson, err := json.Marshal(w.Meanings)
 	if err != nil {
 		return err
@@ -38,158 +62,339 @@ func (s *WordService) Create(ctx context.Context, w *models.Word) error {
 		Columns(
 			database.WordsWordField,
 			database.WordsExamplesField,
-			database.WordsMeaningsField,
-			database.WordsMarkedField).
+			database.WordsMeaningsField).
 		Values(
 			baseForm,
 			w.Examples,
-			meaningsJson,
-			w.Marked).
+			meaningsJson).
 		Suffix("RETURNING " + database.WordsIDField).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
 		return err
 	}
-	return s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&w.ID)
+	err = s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&w.ID)
+	if err != nil {
+		return err
+	}
+	s.IndexWords(autocomplete.Entry{WordID: w.ID, Word: baseForm, Variants: []string{w.Word}})
+	return nil
 }
 
-func (s *WordService) GetByID(ctx context.Context, id int) (*models.Word, error) {
-	w := &models.Word{}
-	query := squirrel.Select("*").
-		From(database.WordsTable).
-		Where(squirrel.Eq{database.WordsIDField: id}).
-		PlaceholderFormat(squirrel.Dollar)
-	sqlQuery, args, err := query.ToSql()
-	if err != nil {
+/**
+* Adds created words to the autocomplete index. Every word created by the
+* server goes through it, so that new words are suggested right away.
+**/
+func (s *WordService) IndexWords(entries ...autocomplete.Entry) {
+	s.autocompleteMu.Lock()
+	defer s.autocompleteMu.Unlock()
+	if s.autocompleteRefreshing {
+		s.autocompletePending = append(s.autocompletePending, entries...)
+	}
+	for _, entry := range entries {
+		s.autocomplete.Add(entry)
+		s.autocompleteMaxID = maxInt(s.autocompleteMaxID, entry.WordID)
+	}
+}
+
+/**
+* Suggests at most limit words starting with prefix, matching base forms
+* and the inflected variants used in questions. The lemma of the prefix
+* is matched too, so that "ran" suggests "run".
+**/
+func (s *WordService) Autocomplete(ctx context.Context, prefix string, limit int) ([]models.WordSuggestion, error) {
+	if err := s.loadAutocomplete(ctx); err != nil {
 		return nil, err
 	}
-	var meaningsJson []byte
-	err = s.DB.QueryRow(
-		ctx,
-		sqlQuery,
-		args...).Scan(
-		&w.ID,
-		&w.Word,
-		&meaningsJson,
-		&w.Examples,
-		&w.Marked)
-	if err != nil {
-		if err.Error() == "no rows in result set" {
-			return nil, echo.ErrNotFound
+	lemma := s.Lemmatizer.Lemma(strings.ToLower(strings.TrimSpace(prefix)))
+	return s.autocomplete.Complete(prefix, limit, lemma), nil
+}
+
+/**
+* Loads the autocomplete index on first use and refreshes it in the
+* background once it is older than autocompleteRefreshInterval. In between,
+* the words created by other processes are added every
+* autocompleteCatchUpInterval.
+**/
+func (s *WordService) loadAutocomplete(ctx context.Context) error {
+	s.autocompleteMu.Lock()
+	loadedAt := s.autocompleteLoadedAt
+	stale := !loadedAt.IsZero() && !s.autocompleteRefreshing && time.Since(loadedAt) > autocompleteRefreshInterval
+	if stale {
+		s.autocompleteRefreshing = true
+	}
+	catchUp := !loadedAt.IsZero() && !stale && time.Since(s.autocompleteCaughtUpAt) > autocompleteCatchUpInterval
+	if catchUp {
+		s.autocompleteCaughtUpAt = time.Now()
+	}
+	maxID := s.autocompleteMaxID
+	s.autocompleteMu.Unlock()
+	if loadedAt.IsZero() {
+		return s.RefreshAutocomplete(ctx)
+	}
+	if stale {
+		go func() {
+			if err := s.RefreshAutocomplete(context.Background()); err != nil {
+				fmt.Println(err.Error())
+			}
+		}()
+	}
+	if catchUp {
+		return s.indexNewWords(ctx, maxID)
+	}
+	return nil
+}
+
+// Adds the words created after the word with the given id to the autocomplete index
+func (s *WordService) indexNewWords(ctx context.Context, afterID int) error {
+	rows, err := s.DB.Query(ctx, `
+		SELECT `+database.WordsIDField+`, `+database.WordsWordField+`
+		FROM `+database.WordsTable+`
+		WHERE `+database.WordsIDField+` > $1`, afterID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	entries := make([]autocomplete.Entry, 0)
+	for rows.Next() {
+		var entry autocomplete.Entry
+		if err := rows.Scan(&entry.WordID, &entry.Word); err != nil {
+			return err
 		}
-		return nil, err
+		entries = append(entries, entry)
 	}
-	err = json.Unmarshal(meaningsJson, &w.Meanings)
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	s.IndexWords(entries...)
+	return nil
+}
+
+/**
+* Reloads the autocomplete index from the words and the wordmaps of the
+* questions. Words indexed while it is reloaded are kept, as the words
+* loaded may not include them.
+**/
+func (s *WordService) RefreshAutocomplete(ctx context.Context) error {
+	s.autocompleteMu.Lock()
+	s.autocompleteRefreshing = true
+	s.autocompleteMu.Unlock()
+	entries, err := s.autocompleteEntries(ctx)
+	return s.replaceAutocomplete(entries, err)
+}
+
+// Replaces the index with the entries of a reload, keeping the words indexed since the reload started
+func (s *WordService) replaceAutocomplete(entries []autocomplete.Entry, err error) error {
+	s.autocompleteMu.Lock()
+	defer s.autocompleteMu.Unlock()
+	pending := s.autocompletePending
+	s.autocompletePending = nil
+	s.autocompleteRefreshing = false
 	if err != nil {
-		return nil, err
+		return err
+	}
+	loaded := make(map[int]bool, len(entries))
+	for _, entry := range entries {
+		loaded[entry.WordID] = true
+		s.autocompleteMaxID = maxInt(s.autocompleteMaxID, entry.WordID)
 	}
-	return w, nil
+	s.autocomplete.Replace(entries)
+	for _, entry := range pending {
+		if !loaded[entry.WordID] {
+			s.autocomplete.Add(entry)
+		}
+	}
+	s.autocompleteLoadedAt = time.Now()
+	s.autocompleteCaughtUpAt = s.autocompleteLoadedAt
+	return nil
 }
 
-func (s *WordService) GetByWord(ctx context.Context, word string) (*models.Word, error) {
-	// Lemmatize to get base forms of words and find variations
-	lemmatizer, err := golem.New(en.New())
+// Loads the entries of the autocomplete index from the words and the wordmaps of the questions
+func (s *WordService) autocompleteEntries(ctx context.Context) ([]autocomplete.Entry, error) {
+	// Count the active questions using each word
+	rows, err := s.DB.Query(ctx, `
+		SELECT w.`+database.WordsIDField+`, w.`+database.WordsWordField+`, COUNT(vq.`+database.VerbalQuestionsIDField+`)
+		FROM `+database.WordsTable+` AS w
+		LEFT JOIN `+database.VerbalQuestionWordsJoinTable+` AS vqw ON vqw.`+database.VerbalQuestionWordJoinWordField+` = w.`+database.WordsIDField+`
+		LEFT JOIN `+database.VerbalQuestionsTable+` AS vq ON vq.`+database.VerbalQuestionsIDField+` = vqw.`+database.VerbalQuestionWordJoinVerbalField+`
+			AND vq.`+database.VerbalQuestionsDeletedAtField+` IS NULL
+		GROUP BY w.`+database.WordsIDField+`, w.`+database.WordsWordField)
 	if err != nil {
-		println(err)
 		return nil, err
 	}
-	baseForm := lemmatizer.Lemma(word)
-	w := &models.Word{}
-	query := squirrel.
-		Select("*").
-		From(database.WordsTable).
-		Where(squirrel.Eq{database.WordsWordField: baseForm}).
-		PlaceholderFormat(squirrel.Dollar)
-	sqlQuery, args, err := query.ToSql()
+	defer rows.Close()
+	entries := make([]autocomplete.Entry, 0)
+	byWord := make(map[string]int)
+	for rows.Next() {
+		var entry autocomplete.Entry
+		if err := rows.Scan(&entry.WordID, &entry.Word, &entry.Frequency); err != nil {
+			return nil, err
+		}
+		byWord[strings.ToLower(entry.Word)] = len(entries)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The wordmaps of questions and passages map each variant to its base form
+	variantRows, err := s.DB.Query(ctx, `
+		SELECT DISTINCT LOWER(m.key), LOWER(m.value)
+		FROM `+database.VerbalQuestionsTable+` AS vq, jsonb_each_text(COALESCE(vq.`+database.VerbalQuestionsWordmapField+`, '{}'::jsonb)) AS m
+		UNION
+		SELECT DISTINCT LOWER(m.key), LOWER(m.value)
+		FROM `+database.PassagesTable+` AS pg, jsonb_each_text(COALESCE(pg.`+database.PassagesWordmapField+`, '{}'::jsonb)) AS m`)
 	if err != nil {
 		return nil, err
 	}
-	var meaningsJson []byte
-	err = s.DB.QueryRow(
-		ctx,
-		sqlQuery,
-		args...).Scan(
-		&w.ID,
-		&w.Word,
-		&meaningsJson,
-		&w.Examples,
-		&w.Marked)
-	if err != nil {
-		if err.Error() == "no rows in result set" {
-			return nil, echo.ErrNotFound
+	defer variantRows.Close()
+	for variantRows.Next() {
+		var variant, baseForm string
+		if err := variantRows.Scan(&variant, &baseForm); err != nil {
+			return nil, err
+		}
+		if n, ok := byWord[baseForm]; ok {
+			entries[n].Variants = append(entries[n].Variants, variant)
 		}
+	}
+	if err := variantRows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *WordService) GetByID(ctx context.Context, id int) (*models.Word, error) {
+	words, err := s.query(ctx, squirrel.Eq{"w." + database.WordsIDField: id})
+	if err != nil {
 		return nil, err
 	}
-	err = json.Unmarshal(meaningsJson, &w.Meanings)
+	if len(words) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &words[0], nil
+}
+
+func (s *WordService) GetByWord(ctx context.Context, word string) (*models.Word, error) {
+	baseForm := s.Lemmatizer.Lemma(word)
+	words, err := s.query(ctx, squirrel.Eq{"w." + database.WordsWordField: baseForm})
 	if err != nil {
 		return nil, err
 	}
-	return w, nil
+	if len(words) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &words[0], nil
 }
 
-func (s *WordService) MarkWords(ctx context.Context, words []string) error {
-	lemmatizer, err := golem.New(en.New())
+/**
+* Marks words for a user. The words are looked up by their base form and
+* the ones that are not in the words table are returned as unknown.
+**/
+func (s *WordService) MarkWords(ctx context.Context, userToken string, words []string) ([]string, error) {
+	ids, unknown, err := s.idsByWord(ctx, words)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	baseFormsSet := make(map[string]struct{})
-	for _, word := range words {
-		baseForm := lemmatizer.Lemma(word)
-		baseFormsSet[baseForm] = struct{}{}
+	if len(ids) > 0 {
+		if err := NewUserService(s.DB).AddMarkedWords(ctx, userToken, ids); err != nil {
+			return nil, err
+		}
 	}
-	baseForms := make([]string, 0, len(baseFormsSet))
-	for baseForm := range baseFormsSet {
-		baseForms = append(baseForms, baseForm)
+	return unknown, nil
+}
+
+// Retrieves the words marked by a user
+func (s *WordService) GetMarkedWords(ctx context.Context, userToken string) ([]models.Word, error) {
+	return s.query(ctx, squirrel.Expr(`EXISTS (SELECT 1 FROM `+database.UserMarkedWordsTable+` AS m
+		WHERE m.`+database.UserMarkedWordsWordField+` = w.`+database.WordsIDField+`
+		AND m.`+database.UserMarkedWordsUserField+` = ?)`, userToken))
+}
+
+// Retrieves the featured words curated by the admins
+func (s *WordService) GetFeaturedWords(ctx context.Context) ([]models.Word, error) {
+	return s.query(ctx, squirrel.Eq{"w." + database.WordsFeaturedField: true})
+}
+
+/**
+* Adds words to the featured list, or removes them from it, for every user.
+* The words that are not in the words table are returned as unknown.
+**/
+func (s *WordService) FeatureWords(ctx context.Context, words []string, featured bool) ([]string, error) {
+	ids, unknown, err := s.idsByWord(ctx, words)
+	if err != nil {
+		return nil, err
 	}
 	query := squirrel.Update(database.WordsTable).
-		Set("marked", true).
-		Where(squirrel.Eq{"word": words}).
+		Set(database.WordsFeaturedField, featured).
+		Where(squirrel.Eq{database.WordsIDField: ids}).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = s.DB.Exec(ctx, sqlQuery, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return unknown, nil
+}
+
+// Finds the ids of the base forms of words and the words that are unknown
+func (s *WordService) idsByWord(ctx context.Context, words []string) ([]int, []string, error) {
+	baseForms := make(map[string]string)
+	for _, word := range words {
+		baseForms[s.Lemmatizer.Lemma(word)] = word
+	}
+	forms := make([]string, 0, len(baseForms))
+	for baseForm := range baseForms {
+		forms = append(forms, baseForm)
+	}
+	found, err := s.query(ctx, squirrel.Eq{"w." + database.WordsWordField: forms})
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(found))
+	for _, w := range found {
+		ids = append(ids, w.ID)
+		delete(baseForms, w.Word)
+	}
+	unknown := make([]string, 0, len(baseForms))
+	for _, word := range baseForms {
+		unknown = append(unknown, word)
+	}
+	sort.Strings(unknown)
+	return ids, unknown, nil
 }
 
-func (s *WordService) GetMarkedWords(ctx context.Context) ([]*models.Word, error) {
-	// Construct the SQL query
-	query := squirrel.
-		Select("*").
-		From(database.WordsTable).
-		Where(squirrel.Eq{database.WordsMarkedField: true}).
+// Retrieves the words matching a condition, ordered by word
+func (s *WordService) query(ctx context.Context, where squirrel.Sqlizer) ([]models.Word, error) {
+	query := squirrel.Select(wordColumns("w")...).
+		From(database.WordsTable + " AS w").
+		Where(where).
+		OrderBy("w." + database.WordsWordField).
 		PlaceholderFormat(squirrel.Dollar)
 	sqlQuery, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
-	// Execute the SQL query
 	rows, err := s.DB.Query(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	// Iterate over rows and unmarshal JSON
-	var words []*models.Word
+	words := make([]models.Word, 0)
 	for rows.Next() {
-		w := &models.Word{}
-		var meaningsJson []byte
-		if err := rows.Scan(&w.ID, &w.Word, &meaningsJson, &w.Examples, &w.Marked); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal(meaningsJson, &w.Meanings); err != nil {
+		var w models.Word
+		if err := rows.Scan(wordFields(&w)...); err != nil {
 			return nil, err
 		}
 		words = append(words, w)
 	}
-	// Check for errors from iterating over rows.
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return words, rows.Err()
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
 	}
-	return words, nil
+	return b
 }
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"grepandit.com/api/internal/autocomplete"
+)
+
+func TestReplaceAutocompleteKeepsIndexedWords(t *testing.T) {
+	s := NewWordService(nil, nil)
+	s.IndexWords(autocomplete.Entry{WordID: 1, Word: "abate"})
+
+	// A word created while the index is reloaded is not in the reloaded words
+	s.autocompleteRefreshing = true
+	s.IndexWords(autocomplete.Entry{WordID: 3, Word: "abjure"})
+	err := s.replaceAutocomplete([]autocomplete.Entry{{WordID: 1, Word: "abate", Frequency: 4}, {WordID: 2, Word: "aberrant"}}, nil)
+	if err != nil {
+		t.Fatalf("replaceAutocomplete() error = %v", err)
+	}
+	if s.autocomplete.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.autocomplete.Len())
+	}
+	if got := s.autocomplete.Complete("abj", 5); len(got) != 1 || got[0].WordID != 3 {
+		t.Errorf("Complete(abj) = %+v, want the word created during the reload", got)
+	}
+	if got := s.autocomplete.Complete("abate", 1); len(got) != 1 || got[0].Frequency != 4 {
+		t.Errorf("Complete(abate) = %+v, want the reloaded frequency", got)
+	}
+	if s.autocompleteMaxID != 3 {
+		t.Errorf("highest indexed id = %d, want 3", s.autocompleteMaxID)
+	}
+
+	// A failed reload keeps the index as it is
+	s.autocompleteRefreshing = true
+	if err := s.replaceAutocomplete(nil, errors.New("connection lost")); err == nil {
+		t.Errorf("replaceAutocomplete() error = nil, want the error of the reload")
+	}
+	if s.autocomplete.Len() != 3 || s.autocompleteRefreshing {
+		t.Errorf("Len() = %d, refreshing = %t, want 3 words and the reload finished", s.autocomplete.Len(), s.autocompleteRefreshing)
+	}
+}
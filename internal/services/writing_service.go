@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/labstack/echo/v4"
+	"grepandit.com/api/internal/database"
+	"grepandit.com/api/internal/essay"
+	"grepandit.com/api/internal/models"
+	"grepandit.com/api/internal/nlp"
+)
+
+// Time allowed for each analytical writing task in the exam
+const defaultWritingTimeLimit = 30 * 60
+
+var (
+	ErrInvalidWritingPrompt = errors.New("invalid writing prompt")
+	ErrInvalidEssay         = errors.New("invalid essay")
+	ErrEssaySubmitted       = errors.New("essay has already been submitted")
+)
+
+type WritingService struct {
+	DB         *pgxpool.Pool
+	Lemmatizer *nlp.Lemmatizer
+}
+
+func NewWritingService(db *pgxpool.Pool, lemmatizer *nlp.Lemmatizer) *WritingService {
+	return &WritingService{DB: db, Lemmatizer: lemmatizer}
+}
+
+// Creates a new issue or argument prompt
+func (s *WritingService) CreatePrompt(ctx context.Context, p *models.WritingPrompt) error {
+	if p.Type != models.WritingTaskIssue && p.Type != models.WritingTaskArgument {
+		return fmt.Errorf("%w: type must be %s or %s", ErrInvalidWritingPrompt, models.WritingTaskIssue, models.WritingTaskArgument)
+	}
+	if strings.TrimSpace(p.Prompt) == "" {
+		return fmt.Errorf("%w: prompt is required", ErrInvalidWritingPrompt)
+	}
+	if p.TimeLimit <= 0 {
+		p.TimeLimit = defaultWritingTimeLimit
+	}
+	query := squirrel.Insert(database.WritingPromptsTable).
+		Columns(
+			database.WritingPromptsTypeField,
+			database.WritingPromptsPromptField,
+			database.WritingPromptsInstructionsField,
+			database.WritingPromptsTimeLimitField).
+		Values(string(p.Type), p.Prompt, p.Instructions, p.TimeLimit).
+		Suffix("RETURNING " + database.WritingPromptsIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+	return s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&p.ID)
+}
+
+func (s *WritingService) GetPrompt(ctx context.Context, id int) (*models.WritingPrompt, error) {
+	prompts, err := s.listPrompts(ctx, squirrel.Eq{database.WritingPromptsIDField: id}, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &prompts[0], nil
+}
+
+// Retrieves the prompts of a task type, or every prompt when it is empty
+func (s *WritingService) GetPrompts(ctx context.Context, taskType models.WritingTaskType) ([]models.WritingPrompt, error) {
+	return s.listPrompts(ctx, taskFilter(taskType), database.WritingPromptsIDField, 0)
+}
+
+// Retrieves a prompt at random, optionally restricted to a task type
+func (s *WritingService) RandomPrompt(ctx context.Context, taskType models.WritingTaskType) (*models.WritingPrompt, error) {
+	prompts, err := s.listPrompts(ctx, taskFilter(taskType), "RANDOM()", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(prompts) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &prompts[0], nil
+}
+
+/**
+* Starts an essay for a prompt. The time taken to write the essay is
+* measured from this moment until it is submitted.
+**/
+func (s *WritingService) StartEssay(ctx context.Context, userToken string, promptID int) (*models.Essay, error) {
+	prompt, err := s.GetPrompt(ctx, promptID)
+	if err != nil {
+		return nil, err
+	}
+	e := &models.Essay{
+		UserToken: userToken,
+		PromptID:  prompt.ID,
+		Prompt:    prompt,
+		Status:    models.EssayInProgress,
+		StartedAt: time.Now(),
+	}
+	query := squirrel.Insert(database.EssaysTable).
+		Columns(
+			database.EssaysUserField,
+			database.EssaysPromptField,
+			database.EssaysStatusField,
+			database.EssaysStartedAtField).
+		Values(userToken, prompt.ID, string(e.Status), e.StartedAt).
+		Suffix("RETURNING " + database.EssaysIDField).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.QueryRow(ctx, sqlQuery, args...).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+/**
+* Submits the text of an essay that is in progress and scores it. Essays
+* submitted after the time limit of the prompt are still scored but are
+* marked as overtime.
+**/
+func (s *WritingService) SubmitEssay(ctx context.Context, userToken string, id int, text string) (*models.Essay, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%w: text is required", ErrInvalidEssay)
+	}
+	e, err := s.GetEssay(ctx, userToken, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != models.EssayInProgress {
+		return nil, ErrEssaySubmitted
+	}
+	score, err := s.Score(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	e.Text = text
+	e.Status = models.EssaySubmitted
+	e.SubmittedAt = &now
+	e.Duration = int(now.Sub(e.StartedAt).Seconds())
+	e.Overtime = e.Prompt != nil && e.Duration > e.Prompt.TimeLimit
+	e.Score = score
+	scoreJson, err := json.Marshal(score)
+	if err != nil {
+		return nil, err
+	}
+	// Only essays that are still in progress can be submitted
+	query := squirrel.Update(database.EssaysTable).
+		Set(database.EssaysTextField, e.Text).
+		Set(database.EssaysStatusField, string(e.Status)).
+		Set(database.EssaysSubmittedAtField, now).
+		Set(database.EssaysDurationField, e.Duration).
+		Set(database.EssaysOvertimeField, e.Overtime).
+		Set(database.EssaysScoreField, scoreJson).
+		Where(squirrel.Eq{
+			database.EssaysIDField:     id,
+			database.EssaysStatusField: string(models.EssayInProgress),
+		}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	tag, err := s.DB.Exec(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrEssaySubmitted
+	}
+	return e, nil
+}
+
+// Retrieves an essay of the user along with its prompt
+func (s *WritingService) GetEssay(ctx context.Context, userToken string, id int) (*models.Essay, error) {
+	essays, err := s.listEssays(ctx, squirrel.Eq{
+		"e." + database.EssaysIDField:   id,
+		"e." + database.EssaysUserField: userToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(essays) == 0 {
+		return nil, echo.ErrNotFound
+	}
+	return &essays[0], nil
+}
+
+// Retrieves the essay history of a user, most recent first
+func (s *WritingService) GetEssaysByUserToken(ctx context.Context, userToken string) ([]models.Essay, error) {
+	return s.listEssays(ctx, squirrel.Eq{"e." + database.EssaysUserField: userToken})
+}
+
+/**
+* Scores a text with the local rubric. Words of the text whose base form is
+* in the words table count as sophisticated vocabulary.
+**/
+func (s *WritingService) Score(ctx context.Context, text string) (*models.EssayScore, error) {
+	lemmas := make(map[string]string)
+	for _, w := range essay.Words(text) {
+		lemmas[w] = s.Lemmatizer.Lemma(w)
+	}
+	candidates := make([]string, 0, len(lemmas))
+	for _, lemma := range lemmas {
+		candidates = append(candidates, lemma)
+	}
+	known, err := s.knownWords(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	result := essay.Evaluate(text, func(word string) bool {
+		_, ok := known[lemmas[word]]
+		return ok
+	})
+	score := models.EssayScore(result)
+	return &score, nil
+}
+
+// Returns the subset of the given words that are in the words table
+func (s *WritingService) knownWords(ctx context.Context, words []string) (map[string]struct{}, error) {
+	known := make(map[string]struct{})
+	if len(words) == 0 {
+		return known, nil
+	}
+	query := squirrel.Select(database.WordsWordField).
+		From(database.WordsTable).
+		Where(squirrel.Eq{database.WordsWordField: words}).
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		known[word] = struct{}{}
+	}
+	return known, rows.Err()
+}
+
+func (s *WritingService) listPrompts(ctx context.Context, where squirrel.Sqlizer, orderBy string, limit uint64) ([]models.WritingPrompt, error) {
+	query := squirrel.Select(
+		database.WritingPromptsIDField,
+		database.WritingPromptsTypeField,
+		database.WritingPromptsPromptField,
+		database.WritingPromptsInstructionsField,
+		database.WritingPromptsTimeLimitField).
+		From(database.WritingPromptsTable).
+		Where(where).
+		PlaceholderFormat(squirrel.Dollar)
+	if orderBy != "" {
+		query = query.OrderBy(orderBy)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	prompts := make([]models.WritingPrompt, 0)
+	for rows.Next() {
+		var p models.WritingPrompt
+		var taskType string
+		err = rows.Scan(&p.ID, &taskType, &p.Prompt, &p.Instructions, &p.TimeLimit)
+		if err != nil {
+			return nil, err
+		}
+		p.Type = models.WritingTaskType(taskType)
+		prompts = append(prompts, p)
+	}
+	return prompts, rows.Err()
+}
+
+func (s *WritingService) listEssays(ctx context.Context, where squirrel.Sqlizer) ([]models.Essay, error) {
+	query := squirrel.Select(
+		"e."+database.EssaysIDField,
+		"e."+database.EssaysUserField,
+		"e."+database.EssaysPromptField,
+		"e."+database.EssaysTextField,
+		"e."+database.EssaysStatusField,
+		"e."+database.EssaysStartedAtField,
+		"e."+database.EssaysSubmittedAtField,
+		"e."+database.EssaysDurationField,
+		"e."+database.EssaysOvertimeField,
+		"e."+database.EssaysScoreField,
+		"p."+database.WritingPromptsTypeField,
+		"p."+database.WritingPromptsPromptField,
+		"p."+database.WritingPromptsInstructionsField,
+		"p."+database.WritingPromptsTimeLimitField).
+		From(database.EssaysTable+" AS e").
+		Join(database.WritingPromptsTable+" AS p ON e."+database.EssaysPromptField+" = p."+database.WritingPromptsIDField).
+		Where(where).
+		OrderBy("e."+database.EssaysStartedAtField+" DESC", "e."+database.EssaysIDField+" DESC").
+		PlaceholderFormat(squirrel.Dollar)
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	essays := make([]models.Essay, 0)
+	for rows.Next() {
+		e, err := scanEssay(rows)
+		if err != nil {
+			return nil, err
+		}
+		essays = append(essays, *e)
+	}
+	return essays, rows.Err()
+}
+
+func scanEssay(row pgx.Row) (*models.Essay, error) {
+	e := &models.Essay{Prompt: &models.WritingPrompt{}}
+	var status, taskType string
+	var scoreJson []byte
+	err := row.Scan(&e.ID, &e.UserToken, &e.PromptID, &e.Text, &status, &e.StartedAt, &e.SubmittedAt,
+		&e.Duration, &e.Overtime, &scoreJson, &taskType, &e.Prompt.Prompt, &e.Prompt.Instructions, &e.Prompt.TimeLimit)
+	if err != nil {
+		return nil, err
+	}
+	e.Status = models.EssayStatus(status)
+	e.Prompt.ID = e.PromptID
+	e.Prompt.Type = models.WritingTaskType(taskType)
+	if scoreJson != nil {
+		e.Score = &models.EssayScore{}
+		err = json.Unmarshal(scoreJson, e.Score)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Filters prompts by task type when one is given
+func taskFilter(taskType models.WritingTaskType) squirrel.Sqlizer {
+	if taskType == "" {
+		return squirrel.And{}
+	}
+	return squirrel.Eq{database.WritingPromptsTypeField: string(taskType)}
+}
@@ -0,0 +1,160 @@
+/**
+* Package srs implements an SM-2 style spaced repetition scheduler. Each
+* review is graded again, hard, good or easy and moves the card to its next
+* interval in days, adjusting the ease factor that scales the intervals of
+* later reviews. Cards that are forgotten are relearned after a short step.
+**/
+package srs
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+type Grade int
+
+const (
+	Again Grade = iota + 1
+	Hard
+	Good
+	Easy
+)
+
+// Scheduling constants
+const (
+	InitialEase    = 2.5
+	MinimumEase    = 1.3
+	RelearnStep    = 10 * time.Minute
+	hardFactor     = 1.2
+	easyBonus      = 1.3
+	firstInterval  = 1
+	easyInterval   = 4
+	secondInterval = 6
+)
+
+var ErrInvalidGrade = errors.New("invalid grade")
+
+func (g Grade) String() string {
+	switch g {
+	case Again:
+		return "again"
+	case Hard:
+		return "hard"
+	case Good:
+		return "good"
+	case Easy:
+		return "easy"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseGrade(s string) (Grade, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "again":
+		return Again, nil
+	case "hard":
+		return Hard, nil
+	case "good":
+		return Good, nil
+	case "easy":
+		return Easy, nil
+	}
+	return 0, ErrInvalidGrade
+}
+
+// Whether the grade counts as remembering the card
+func (g Grade) Recalled() bool {
+	return g >= Hard
+}
+
+/**
+* Scheduling state of a card. Repetitions counts the consecutive reviews
+* the card was recalled in and Interval is the number of days until the
+* card is due, which is zero while the card is being relearned.
+**/
+type State struct {
+	Repetitions int       `json:"repetitions"`
+	Interval    int       `json:"interval"`
+	Ease        float64   `json:"ease"`
+	Lapses      int       `json:"lapses"`
+	Due         time.Time `json:"due"`
+}
+
+// State of a card that was never reviewed, which is due right away
+func New(now time.Time) State {
+	return State{Ease: InitialEase, Due: now}
+}
+
+/**
+* Schedules the next review of a card graded at the given time. Forgotten
+* cards lose ease and restart their repetitions, hard reviews grow the
+* interval slowly, good reviews grow it by the ease and easy reviews add a
+* bonus on top.
+**/
+func Review(s State, g Grade, now time.Time) (State, error) {
+	if g < Again || g > Easy {
+		return s, ErrInvalidGrade
+	}
+	if s.Ease == 0 {
+		s.Ease = InitialEase
+	}
+	switch g {
+	case Again:
+		s.Repetitions = 0
+		s.Lapses++
+		s.Interval = 0
+		s.Ease -= 0.2
+	case Hard:
+		if s.Repetitions == 0 {
+			s.Interval = firstInterval
+		} else {
+			s.Interval = maxInt(s.Interval+1, round(float64(s.Interval)*hardFactor))
+		}
+		s.Repetitions++
+		s.Ease -= 0.15
+	case Good:
+		s.Interval = nextInterval(s)
+		s.Repetitions++
+	case Easy:
+		if s.Repetitions == 0 {
+			s.Interval = easyInterval
+		} else {
+			s.Interval = maxInt(nextInterval(s)+1, round(float64(nextInterval(s))*easyBonus))
+		}
+		s.Repetitions++
+		s.Ease += 0.15
+	}
+	s.Ease = math.Max(MinimumEase, math.Round(s.Ease*100)/100)
+	if s.Interval == 0 {
+		s.Due = now.Add(RelearnStep)
+	} else {
+		s.Due = now.AddDate(0, 0, s.Interval)
+	}
+	return s, nil
+}
+
+// Interval after a good review
+func nextInterval(s State) int {
+	switch s.Repetitions {
+	case 0:
+		return firstInterval
+	case 1:
+		return secondInterval
+	default:
+		return maxInt(s.Interval+1, round(float64(s.Interval)*s.Ease))
+	}
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
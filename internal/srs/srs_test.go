@@ -0,0 +1,111 @@
+package srs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// Reviews a new card with the grades in order, one review whenever it is due
+func reviewAll(t *testing.T, grades ...Grade) State {
+	t.Helper()
+	s := New(start)
+	for _, g := range grades {
+		var err error
+		s, err = Review(s, g, s.Due)
+		if err != nil {
+			t.Fatalf("Review(%s) error = %v", g, err)
+		}
+	}
+	return s
+}
+
+func TestReviewIntervals(t *testing.T) {
+	tests := []struct {
+		name     string
+		grades   []Grade
+		interval int
+		ease     float64
+		lapses   int
+	}{
+		{"first good", []Grade{Good}, 1, 2.5, 0},
+		{"second good", []Grade{Good, Good}, 6, 2.5, 0},
+		{"third good grows by ease", []Grade{Good, Good, Good}, 15, 2.5, 0},
+		{"first easy", []Grade{Easy}, 4, 2.65, 0},
+		{"easy adds a bonus", []Grade{Good, Good, Easy}, 20, 2.65, 0},
+		{"hard grows slowly", []Grade{Good, Good, Hard}, 7, 2.35, 0},
+		{"again relearns", []Grade{Good, Good, Again}, 0, 2.3, 1},
+		{"relearned card restarts", []Grade{Good, Good, Again, Good}, 1, 2.3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := reviewAll(t, tt.grades...)
+			if s.Interval != tt.interval || s.Ease != tt.ease || s.Lapses != tt.lapses {
+				t.Errorf("got interval %d, ease %v, lapses %d, want %d, %v, %d",
+					s.Interval, s.Ease, s.Lapses, tt.interval, tt.ease, tt.lapses)
+			}
+		})
+	}
+}
+
+func TestReviewDueDate(t *testing.T) {
+	s, err := Review(New(start), Good, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.AddDate(0, 0, 1); !s.Due.Equal(want) {
+		t.Errorf("due = %v, want %v", s.Due, want)
+	}
+	s, err = Review(s, Again, s.Due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.AddDate(0, 0, 1).Add(RelearnStep); !s.Due.Equal(want) {
+		t.Errorf("relearning due = %v, want %v", s.Due, want)
+	}
+}
+
+func TestEaseHasAFloor(t *testing.T) {
+	grades := make([]Grade, 20)
+	for i := range grades {
+		grades[i] = Again
+	}
+	if s := reviewAll(t, grades...); s.Ease != MinimumEase {
+		t.Errorf("ease = %v, want %v", s.Ease, MinimumEase)
+	}
+}
+
+func TestIntervalsNeverShrinkWhenRecalled(t *testing.T) {
+	for _, g := range []Grade{Hard, Good, Easy} {
+		s := New(start)
+		previous := 0
+		for i := 0; i < 10; i++ {
+			var err error
+			s, err = Review(s, g, s.Due)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Interval <= previous && i > 0 {
+				t.Fatalf("%s review %d: interval %d after %d", g, i, s.Interval, previous)
+			}
+			previous = s.Interval
+		}
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	for _, g := range []Grade{Again, Hard, Good, Easy} {
+		parsed, err := ParseGrade(g.String())
+		if err != nil || parsed != g {
+			t.Errorf("ParseGrade(%q) = %v, %v", g.String(), parsed, err)
+		}
+	}
+	if _, err := ParseGrade("perfect"); !errors.Is(err, ErrInvalidGrade) {
+		t.Errorf("ParseGrade(perfect) error = %v, want ErrInvalidGrade", err)
+	}
+	if _, err := Review(New(start), Grade(9), start); !errors.Is(err, ErrInvalidGrade) {
+		t.Errorf("Review with grade 9 error = %v, want ErrInvalidGrade", err)
+	}
+}
@@ -0,0 +1,177 @@
+/**
+* Package vocabquiz generates multiple choice vocabulary items from the
+* meanings and examples of words. Distractors are drawn from a pool of
+* other words, preferring words that have a meaning of the same part of
+* speech as the one being tested so that the options stay plausible.
+**/
+package vocabquiz
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"strings"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Number of options of an item unless set otherwise
+const DefaultChoices = 4
+
+// Replaces the word in fill in the blank items
+const Blank = "______"
+
+var (
+	ErrNoContent      = errors.New("word has no content for this kind of item")
+	ErrNotEnoughWords = errors.New("not enough words for distractors")
+	ErrUnknownKind    = errors.New("unknown kind of item")
+	wordPattern       = regexp.MustCompile(`[A-Za-z]+(?:['-][A-Za-z]+)*`)
+	AllKinds          = []models.VocabQuizKind{models.DefinitionToWord, models.WordToDefinition, models.FillInBlank}
+)
+
+// Finds the base form of a word, as the words table stores base forms
+type Lemmatizer interface {
+	Lemma(word string) string
+}
+
+type Generator struct {
+	pool       []models.Word
+	lemmatizer Lemmatizer
+	rand       *rand.Rand
+	// Number of options of each item
+	Choices int
+}
+
+func NewGenerator(pool []models.Word, lemmatizer Lemmatizer, r *rand.Rand) *Generator {
+	return &Generator{pool: pool, lemmatizer: lemmatizer, rand: r, Choices: DefaultChoices}
+}
+
+/**
+* Generates up to length items, going through the words in order and
+* cycling through the kinds. A word that cannot be used for a kind, for
+* instance because none of its examples contain it, is tried with the
+* other kinds before it is skipped.
+**/
+func (g *Generator) Quiz(words []models.Word, kinds []models.VocabQuizKind, length int) []models.VocabQuizItem {
+	if len(kinds) == 0 {
+		kinds = AllKinds
+	}
+	items := make([]models.VocabQuizItem, 0, length)
+	next := 0
+	for _, word := range words {
+		if len(items) >= length {
+			break
+		}
+		for i := range kinds {
+			item, err := g.Item(word, kinds[(next+i)%len(kinds)])
+			if err == nil {
+				items = append(items, item)
+				next = (next + i + 1) % len(kinds)
+				break
+			}
+		}
+	}
+	return items
+}
+
+// Generates an item of the given kind about a word
+func (g *Generator) Item(word models.Word, kind models.VocabQuizKind) (models.VocabQuizItem, error) {
+	item := models.VocabQuizItem{Kind: kind, WordID: word.ID}
+	if len(word.Meanings) == 0 {
+		return item, ErrNoContent
+	}
+	meaning := word.Meanings[g.rand.Intn(len(word.Meanings))]
+	var correct string
+	var distractors []string
+	switch kind {
+	case models.DefinitionToWord:
+		item.Prompt = meaning.Meaning
+		correct = word.Word
+		distractors = g.distractors(word, meaning.Type, func(w models.Word, _ models.Meaning) string { return w.Word })
+	case models.WordToDefinition:
+		item.Prompt = word.Word
+		correct = meaning.Meaning
+		distractors = g.distractors(word, meaning.Type, func(_ models.Word, m models.Meaning) string { return m.Meaning })
+	case models.FillInBlank:
+		examples := make([]string, 0)
+		for _, example := range word.Examples {
+			if blanked, ok := g.blank(example, word.Word); ok {
+				examples = append(examples, blanked)
+			}
+		}
+		if len(examples) == 0 {
+			return item, ErrNoContent
+		}
+		item.Prompt = examples[g.rand.Intn(len(examples))]
+		correct = word.Word
+		distractors = g.distractors(word, meaning.Type, func(w models.Word, _ models.Meaning) string { return w.Word })
+	default:
+		return item, ErrUnknownKind
+	}
+	if len(distractors) < g.Choices-1 {
+		return item, ErrNotEnoughWords
+	}
+	options := append([]string{correct}, distractors[:g.Choices-1]...)
+	g.rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	for i, option := range options {
+		if option == correct {
+			answer := i
+			item.Answer = &answer
+		}
+	}
+	item.Options = options
+	return item, nil
+}
+
+/**
+* Picks distinct distractors from the pool in random order. Words with a
+* meaning of the given part of speech come first, followed by the others
+* in case there are not enough of them. The option function returns the
+* text of the option for a word and its meaning.
+**/
+func (g *Generator) distractors(target models.Word, partOfSpeech string, option func(models.Word, models.Meaning) string) []string {
+	sameType := make([]string, 0)
+	otherType := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range target.Meanings {
+		seen[strings.ToLower(option(target, m))] = true
+	}
+	for _, i := range g.rand.Perm(len(g.pool)) {
+		w := g.pool[i]
+		if w.ID == target.ID || strings.EqualFold(w.Word, target.Word) || len(w.Meanings) == 0 {
+			continue
+		}
+		meaning, matches := w.Meanings[0], false
+		for _, m := range w.Meanings {
+			if strings.EqualFold(m.Type, partOfSpeech) {
+				meaning, matches = m, true
+				break
+			}
+		}
+		text := option(w, meaning)
+		if text == "" || seen[strings.ToLower(text)] {
+			continue
+		}
+		seen[strings.ToLower(text)] = true
+		if matches {
+			sameType = append(sameType, text)
+		} else {
+			otherType = append(otherType, text)
+		}
+	}
+	return append(sameType, otherType...)
+}
+
+// Blanks every form of the word in an example, if the example uses it
+func (g *Generator) blank(example string, word string) (string, bool) {
+	found := false
+	blanked := wordPattern.ReplaceAllStringFunc(example, func(token string) string {
+		lower := strings.ToLower(token)
+		if lower == strings.ToLower(word) || g.lemmatizer.Lemma(lower) == strings.ToLower(word) {
+			found = true
+			return Blank
+		}
+		return token
+	})
+	return blanked, found
+}
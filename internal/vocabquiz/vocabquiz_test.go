@@ -0,0 +1,149 @@
+package vocabquiz
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Lemmatizer that knows the inflections used by the test words
+type stubLemmatizer map[string]string
+
+func (l stubLemmatizer) Lemma(word string) string {
+	if lemma, ok := l[word]; ok {
+		return lemma
+	}
+	return word
+}
+
+var lemmatizer = stubLemmatizer{"obfuscated": "obfuscate", "obfuscates": "obfuscate"}
+
+func word(id int, w string, partOfSpeech string, meaning string, examples ...string) models.Word {
+	return models.Word{
+		ID:       id,
+		Word:     w,
+		Meanings: []models.Meaning{{Meaning: meaning, Type: partOfSpeech}},
+		Examples: examples,
+	}
+}
+
+var (
+	obfuscate = word(1, "obfuscate", "verb", "to make unclear",
+		"The report obfuscated the real costs.", "No example uses it here.")
+	pool = []models.Word{
+		obfuscate,
+		word(2, "elucidate", "verb", "to make clear"),
+		word(3, "placate", "verb", "to make less angry"),
+		word(4, "vacillate", "verb", "to waver between opinions"),
+		word(5, "laconic", "adjective", "using few words"),
+		word(6, "prodigal", "adjective", "wastefully extravagant"),
+		word(7, "enervate", "verb", "to weaken"),
+	}
+	verbs = map[string]bool{"obfuscate": true, "elucidate": true, "placate": true, "vacillate": true, "enervate": true}
+)
+
+func newGenerator(seed int64) *Generator {
+	return NewGenerator(pool, lemmatizer, rand.New(rand.NewSource(seed)))
+}
+
+func TestItemKinds(t *testing.T) {
+	tests := []struct {
+		kind    models.VocabQuizKind
+		prompt  string
+		correct string
+	}{
+		{models.DefinitionToWord, "to make unclear", "obfuscate"},
+		{models.WordToDefinition, "obfuscate", "to make unclear"},
+		{models.FillInBlank, "The report " + Blank + " the real costs.", "obfuscate"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			item, err := newGenerator(1).Item(obfuscate, tt.kind)
+			if err != nil {
+				t.Fatalf("Item() error = %v", err)
+			}
+			if item.Prompt != tt.prompt {
+				t.Errorf("prompt = %q, want %q", item.Prompt, tt.prompt)
+			}
+			if len(item.Options) != DefaultChoices {
+				t.Fatalf("got %d options, want %d", len(item.Options), DefaultChoices)
+			}
+			if item.Answer == nil || item.Options[*item.Answer] != tt.correct {
+				t.Errorf("answer %v does not point at %q in %v", item.Answer, tt.correct, item.Options)
+			}
+			seen := make(map[string]bool)
+			for _, option := range item.Options {
+				if seen[option] {
+					t.Errorf("option %q is repeated", option)
+				}
+				seen[option] = true
+			}
+		})
+	}
+}
+
+func TestDistractorsShareThePartOfSpeech(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		item, err := newGenerator(seed).Item(obfuscate, models.DefinitionToWord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// There are enough verbs in the pool for every distractor
+		for _, option := range item.Options {
+			if !verbs[option] {
+				t.Fatalf("seed %d: distractor %q is not a verb", seed, option)
+			}
+		}
+	}
+}
+
+func TestDistractorsFallBackToOtherWords(t *testing.T) {
+	g := newGenerator(1)
+	g.Choices = 7
+	item, err := g.Item(obfuscate, models.DefinitionToWord)
+	if err != nil {
+		t.Fatalf("Item() error = %v", err)
+	}
+	if len(item.Options) != 7 {
+		t.Errorf("got %d options, want the whole pool", len(item.Options))
+	}
+	g.Choices = 8
+	if _, err := g.Item(obfuscate, models.DefinitionToWord); !errors.Is(err, ErrNotEnoughWords) {
+		t.Errorf("Item() error = %v, want ErrNotEnoughWords", err)
+	}
+}
+
+func TestItemWithoutContent(t *testing.T) {
+	g := newGenerator(1)
+	if _, err := g.Item(pool[1], models.FillInBlank); !errors.Is(err, ErrNoContent) {
+		t.Errorf("word without examples: error = %v, want ErrNoContent", err)
+	}
+	if _, err := g.Item(models.Word{ID: 9, Word: "empty"}, models.WordToDefinition); !errors.Is(err, ErrNoContent) {
+		t.Errorf("word without meanings: error = %v, want ErrNoContent", err)
+	}
+	if _, err := g.Item(obfuscate, "anagram"); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("unknown kind: error = %v, want ErrUnknownKind", err)
+	}
+}
+
+func TestQuizCyclesKinds(t *testing.T) {
+	words := []models.Word{obfuscate, pool[1], pool[2], pool[3]}
+	items := newGenerator(1).Quiz(words, nil, 3)
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	// Words without examples skip the fill in the blank kind
+	want := []models.VocabQuizKind{models.DefinitionToWord, models.WordToDefinition, models.DefinitionToWord}
+	for i, item := range items {
+		if item.Kind != want[i] || item.WordID != words[i].ID {
+			t.Errorf("item %d is %s about %d, want %s about %d", i, item.Kind, item.WordID, want[i], words[i].ID)
+		}
+	}
+	items = newGenerator(1).Quiz(words, []models.VocabQuizKind{models.FillInBlank}, 4)
+	if len(items) != 1 || !strings.Contains(items[0].Prompt, Blank) {
+		t.Errorf("got %+v, want the single fill in the blank item", items)
+	}
+}
@@ -0,0 +1,82 @@
+package wordgraph
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"grepandit.com/api/internal/models"
+)
+
+var ErrInvalidRow = errors.New("invalid relation row")
+
+// Columns of a thesaurus file
+var CSVColumns = []string{"word", "related_word", "type"}
+
+// Relation read from a line of a thesaurus file
+type Row struct {
+	Line     int
+	Relation models.WordRelationRequest
+	Err      error
+}
+
+/**
+* Reads a thesaurus file with a word, a related word and a relation type on
+* each line, such as "terse,laconic,synonym". The header line is optional,
+* lines starting with # are comments and words are lowercased. Lines that
+* are not valid are returned with an error so the rest can be imported.
+**/
+func ReadCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows := make([]Row, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == 0 && isHeader(record) {
+			continue
+		}
+		row := Row{Line: line}
+		if len(record) != len(CSVColumns) {
+			row.Err = fmt.Errorf("%w: expected %d columns, got %d", ErrInvalidRow, len(CSVColumns), len(record))
+			rows = append(rows, row)
+			continue
+		}
+		row.Relation = models.WordRelationRequest{
+			Word:        strings.ToLower(strings.TrimSpace(record[0])),
+			RelatedWord: strings.ToLower(strings.TrimSpace(record[1])),
+			Type:        models.RelationType(strings.ToLower(strings.TrimSpace(record[2]))),
+		}
+		row.Err = Validate(&row.Relation)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Checks that a relation links two different words with a known type
+func Validate(relation *models.WordRelationRequest) error {
+	if relation.Word == "" || relation.RelatedWord == "" {
+		return fmt.Errorf("%w: word and related_word are required", ErrInvalidRow)
+	}
+	if strings.EqualFold(relation.Word, relation.RelatedWord) {
+		return fmt.Errorf("%w: a word cannot be related to itself", ErrInvalidRow)
+	}
+	if !relation.Type.Valid() {
+		return fmt.Errorf("%w: unknown relation type %q", ErrInvalidRow, relation.Type)
+	}
+	return nil
+}
+
+func isHeader(record []string) bool {
+	return len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), CSVColumns[0])
+}
@@ -0,0 +1,132 @@
+/**
+* Package wordgraph holds the relations between words as an undirected
+* graph, traverses the neighborhood of a word and builds "pick the two
+* synonyms" drills in the style of sentence equivalence questions.
+**/
+package wordgraph
+
+import (
+	"errors"
+	"math/rand"
+
+	"grepandit.com/api/internal/models"
+)
+
+// Number of options of a drill, as in sentence equivalence questions
+const DrillOptions = 6
+
+var ErrNotEnoughDistractors = errors.New("not enough distractors for a drill")
+
+type Graph struct {
+	adjacent map[int]map[int][]models.RelationType
+}
+
+func New() *Graph {
+	return &Graph{adjacent: make(map[int]map[int][]models.RelationType)}
+}
+
+// Adds a relation between two words in both directions
+func (g *Graph) Add(a int, b int, relationType models.RelationType) {
+	if a == b || g.Related(a, b, relationType) {
+		return
+	}
+	g.link(a, b, relationType)
+	g.link(b, a, relationType)
+}
+
+func (g *Graph) link(from int, to int, relationType models.RelationType) {
+	if g.adjacent[from] == nil {
+		g.adjacent[from] = make(map[int][]models.RelationType)
+	}
+	g.adjacent[from][to] = append(g.adjacent[from][to], relationType)
+}
+
+// Whether the words are related with any of the types, or with any type when none is given
+func (g *Graph) Related(a int, b int, types ...models.RelationType) bool {
+	relations, ok := g.adjacent[a][b]
+	if !ok {
+		return false
+	}
+	if len(types) == 0 {
+		return true
+	}
+	for _, relation := range relations {
+		for _, relationType := range types {
+			if relation == relationType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Words related to a word with any of the types, or with any type when none is given
+func (g *Graph) Neighbors(id int, types ...models.RelationType) []int {
+	neighbors := make([]int, 0)
+	for neighbor := range g.adjacent[id] {
+		if g.Related(id, neighbor, types...) {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
+
+/**
+* Breadth first traversal from a word following relations of the given
+* types, or of any type when none is given. Returns the distance of every
+* word reached within depth relations, including the start at distance 0.
+**/
+func (g *Graph) Distances(start int, depth int, types ...models.RelationType) map[int]int {
+	distances := map[int]int{start: 0}
+	frontier := []int{start}
+	for d := 1; d <= depth && len(frontier) > 0; d++ {
+		next := make([]int, 0)
+		for _, id := range frontier {
+			for _, neighbor := range g.Neighbors(id, types...) {
+				if _, seen := distances[neighbor]; !seen {
+					distances[neighbor] = d
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+	return distances
+}
+
+/**
+* Picks the options of a drill for a pair of synonyms. Candidates are taken
+* in order, so callers put the most plausible traps first, and a candidate
+* is skipped when it is a synonym of the pair or of a distractor already
+* picked, which would give the drill another correct answer. Returns the
+* options in random order and the indexes of the pair among them.
+**/
+func (g *Graph) Drill(r *rand.Rand, pair [2]int, candidates []int) ([]int, []int, error) {
+	options := []int{pair[0], pair[1]}
+	for _, candidate := range candidates {
+		if len(options) == DrillOptions {
+			break
+		}
+		usable := true
+		for _, option := range options {
+			if candidate == option || g.Related(candidate, option, models.RelationSynonym) {
+				usable = false
+				break
+			}
+		}
+		if usable {
+			options = append(options, candidate)
+		}
+	}
+	if len(options) < DrillOptions {
+		return nil, nil, ErrNotEnoughDistractors
+	}
+	r.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	answer := make([]int, 0, 2)
+	for i, option := range options {
+		if option == pair[0] || option == pair[1] {
+			answer = append(answer, i)
+		}
+	}
+	return options, answer, nil
+}
@@ -0,0 +1,118 @@
+package wordgraph
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"grepandit.com/api/internal/models"
+)
+
+// terse(1) - laconic(2) - succinct(3) are synonyms, verbose(4) is their
+// antonym, loquacious(5) a synonym of verbose and lucid(6) is confused with
+// laconic. The remaining words are unrelated.
+func sampleGraph() *Graph {
+	g := New()
+	g.Add(1, 2, models.RelationSynonym)
+	g.Add(2, 3, models.RelationSynonym)
+	g.Add(1, 4, models.RelationAntonym)
+	g.Add(4, 5, models.RelationSynonym)
+	g.Add(2, 6, models.RelationConfusable)
+	return g
+}
+
+func TestRelationsGoBothWays(t *testing.T) {
+	g := sampleGraph()
+	if !g.Related(2, 1, models.RelationSynonym) || !g.Related(4, 1) {
+		t.Error("relations should be symmetric")
+	}
+	if g.Related(1, 2, models.RelationAntonym) {
+		t.Error("terse and laconic are not antonyms")
+	}
+	g.Add(1, 2, models.RelationSynonym)
+	if got := g.adjacent[1][2]; len(got) != 1 {
+		t.Errorf("duplicate relation was added: %v", got)
+	}
+}
+
+func TestDistances(t *testing.T) {
+	g := sampleGraph()
+	tests := []struct {
+		name  string
+		depth int
+		types []models.RelationType
+		want  map[int]int
+	}{
+		{"depth 1", 1, nil, map[int]int{1: 0, 2: 1, 4: 1}},
+		{"depth 2", 2, nil, map[int]int{1: 0, 2: 1, 4: 1, 3: 2, 5: 2, 6: 2}},
+		{"synonyms only", 3, []models.RelationType{models.RelationSynonym}, map[int]int{1: 0, 2: 1, 3: 2}},
+		{"depth 0", 0, nil, map[int]int{1: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.Distances(1, tt.depth, tt.types...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Distances() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrillSkipsOtherSynonyms(t *testing.T) {
+	g := sampleGraph()
+	// succinct would be a third synonym and loquacious is a synonym of verbose
+	candidates := []int{3, 4, 5, 6, 7, 8, 9}
+	options, answer, err := g.Drill(rand.New(rand.NewSource(1)), [2]int{1, 2}, candidates)
+	if err != nil {
+		t.Fatalf("Drill() error = %v", err)
+	}
+	got := append([]int(nil), options...)
+	sort.Ints(got)
+	if want := []int{1, 2, 4, 6, 7, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("options = %v, want %v", got, want)
+	}
+	if len(answer) != 2 {
+		t.Fatalf("answer = %v, want two indexes", answer)
+	}
+	pair := []int{options[answer[0]], options[answer[1]]}
+	sort.Ints(pair)
+	if !reflect.DeepEqual(pair, []int{1, 2}) {
+		t.Errorf("answer points at %v, want the pair", pair)
+	}
+	if _, _, err := g.Drill(rand.New(rand.NewSource(1)), [2]int{1, 2}, []int{3, 4, 5}); !errors.Is(err, ErrNotEnoughDistractors) {
+		t.Errorf("Drill() error = %v, want ErrNotEnoughDistractors", err)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	file := strings.Join([]string{
+		"word,related_word,type",
+		"# comments are skipped",
+		"Terse, laconic, synonym",
+		"terse,verbose,antonym",
+		"terse,terse,synonym",
+		"terse,lucid",
+		"laconic,lucid,similar",
+	}, "\n")
+	rows, err := ReadCSV(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ReadCSV() error = %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5", len(rows))
+	}
+	want := models.WordRelationRequest{Word: "terse", RelatedWord: "laconic", Type: models.RelationSynonym}
+	if rows[0].Err != nil || rows[0].Relation != want || rows[0].Line != 3 {
+		t.Errorf("first row = %+v, want %+v on line 3", rows[0], want)
+	}
+	if rows[1].Err != nil {
+		t.Errorf("second row error = %v", rows[1].Err)
+	}
+	for _, row := range rows[2:] {
+		if !errors.Is(row.Err, ErrInvalidRow) {
+			t.Errorf("line %d error = %v, want ErrInvalidRow", row.Line, row.Err)
+		}
+	}
+}